@@ -0,0 +1,206 @@
+package otp
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/adam-good/OTP/internal/scrypt"
+)
+
+/*
+    Aegis Authenticator backups
+
+    An Aegis export is JSON with a header and a database. In a plain export the
+    database is a JSON object; in an encrypted one it's base64 AES-256-GCM ciphertext
+    under a random master key, and the header holds "slots" each carrying the master
+    key encrypted under some other key. Password slots (type 1) derive that key from
+    the password with scrypt.
+*/
+
+type aegisFile struct {
+    Version int             `json:"version"`
+    Header  aegisHeader     `json:"header"`
+    DB      json.RawMessage `json:"db"`
+}
+
+type aegisHeader struct {
+    Slots   []aegisSlot     `json:"slots"`
+    Params  *aegisParams    `json:"params"`
+}
+
+type aegisSlot struct {
+    Type        int         `json:"type"`
+    Key         string      `json:"key"`
+    KeyParams   aegisParams `json:"key_params"`
+    N           int         `json:"n"`
+    R           int         `json:"r"`
+    P           int         `json:"p"`
+    Salt        string      `json:"salt"`
+}
+
+// aegisParams are the AES-GCM nonce and tag, both hex
+type aegisParams struct {
+    Nonce   string  `json:"nonce"`
+    Tag     string  `json:"tag"`
+}
+
+type aegisDB struct {
+    Version int             `json:"version"`
+    Entries []aegisEntry    `json:"entries"`
+}
+
+type aegisEntry struct {
+    Type    string  `json:"type"`
+    Name    string  `json:"name"`
+    Issuer  string  `json:"issuer"`
+    Info    struct {
+        Secret  string  `json:"secret"`
+        Algo    string  `json:"algo"`
+        Digits  int     `json:"digits"`
+        Period  int     `json:"period"`
+        Counter uint64  `json:"counter"`
+    } `json:"info"`
+}
+
+// aegisPasswordSlot is the Aegis slot type for password derived keys
+const aegisPasswordSlot int = 1
+
+// open decrypts ciphertext with AES-256-GCM under key using the hex nonce and tag in params
+func (params aegisParams) open(key []byte, ciphertext []byte) ([]byte, error) {
+    var nonce, err = hex.DecodeString(params.Nonce)
+    if (err != nil) {
+        return nil, err
+    }
+    var tag []byte
+    if tag, err = hex.DecodeString(params.Tag); err != nil {
+        return nil, err
+    }
+    var block cipher.Block
+    if block, err = aes.NewCipher(key); err != nil {
+        return nil, err
+    }
+    var gcm cipher.AEAD
+    if gcm, err = cipher.NewGCMWithNonceSize(block, len(nonce)); err != nil {
+        return nil, err
+    }
+    // Go wants the tag on the end of the ciphertext
+    var sealed []byte = append(append([]byte(nil), ciphertext...), tag...)
+    return gcm.Open(nil, nonce, sealed, nil)
+}
+
+// masterKey tries password against every password slot and returns the master key from the first that opens
+func (h aegisHeader) masterKey(password string) ([]byte, error) {
+    for _, slot := range(h.Slots) {
+        if (slot.Type != aegisPasswordSlot) {
+            continue
+        }
+        var salt, err = hex.DecodeString(slot.Salt)
+        if (err != nil) {
+            return nil, fmt.Errorf("%w: slot salt: %v", ErrInvalidBackup, err)
+        }
+        var encrypted []byte
+        if encrypted, err = hex.DecodeString(slot.Key); err != nil {
+            return nil, fmt.Errorf("%w: slot key: %v", ErrInvalidBackup, err)
+        }
+        var derived []byte
+        if derived, err = scrypt.Key([]byte(password), salt, slot.N, slot.R, slot.P, 32); err != nil {
+            return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+        }
+        var master []byte
+        if master, err = slot.KeyParams.open(derived, encrypted); err == nil {
+            return master, nil
+        }
+    }
+    return nil, ErrWrongPassword
+}
+
+// key converts an entry to a Key
+func (e aegisEntry) key() (*Key, error) {
+    var secret, err = DecodeBase32(e.Info.Secret)
+    if (err != nil) {
+        return nil, err
+    }
+    var algo, ok = ParseAlgorithm(e.Info.Algo)
+    if (!ok) {
+        return nil, fmt.Errorf("%w: %s", ErrInvalidAlgorithm, e.Info.Algo)
+    }
+
+    var opts []Option = []Option{
+        WithAlgorithm(algo),
+        WithDigits(e.Info.Digits),
+        WithIssuer(e.Issuer),
+        WithAccount(e.Name),
+    }
+    switch strings.ToLower(e.Type) {
+    case "totp":
+        opts = append(opts, WithPeriod(time.Duration(e.Info.Period) * time.Second))
+    case "hotp":
+        opts = append(opts, WithHOTP(e.Info.Counter))
+    default:
+        return nil, fmt.Errorf("unsupported entry type %q", e.Type)
+    }
+    return NewKey(secret, opts...)
+}
+
+/*
+    ImportAegis reads an Aegis Authenticator JSON backup and returns a Key for each entry
+
+    Encrypted vaults are opened with password, which is ignored for plain exports; a
+    password that opens none of the vault's slots fails with ErrWrongPassword. Only
+    TOTP and HOTP entries can be imported, any other type (Steam, mOTP, Yandex) fails
+    the whole import rather than quietly dropping an account. Other problems with the
+    file wrap ErrInvalidBackup.
+*/
+func ImportAegis(data []byte, password string) ([]*Key, error) {
+    var file aegisFile
+    if err := json.Unmarshal(data, &file); err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+    }
+    if (len(file.DB) == 0) {
+        return nil, fmt.Errorf("%w: no database", ErrInvalidBackup)
+    }
+
+    var plain []byte = file.DB
+    if (file.DB[0] == '"') {
+        // encrypted, the database is a base64 string
+        if (file.Header.Params == nil) {
+            return nil, fmt.Errorf("%w: encrypted database without parameters", ErrInvalidBackup)
+        }
+        var encoded string
+        if err := json.Unmarshal(file.DB, &encoded); err != nil {
+            return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+        }
+        var ciphertext, err = base64.StdEncoding.DecodeString(encoded)
+        if (err != nil) {
+            return nil, fmt.Errorf("%w: database: %v", ErrInvalidBackup, err)
+        }
+        var master []byte
+        if master, err = file.Header.masterKey(password); err != nil {
+            return nil, err
+        }
+        if plain, err = file.Header.Params.open(master, ciphertext); err != nil {
+            return nil, fmt.Errorf("%w: database: %v", ErrInvalidBackup, err)
+        }
+    }
+
+    var db aegisDB
+    if err := json.Unmarshal(plain, &db); err != nil {
+        return nil, fmt.Errorf("%w: database: %v", ErrInvalidBackup, err)
+    }
+
+    var keys []*Key
+    for i, e := range(db.Entries) {
+        var k, err = e.key()
+        if (err != nil) {
+            return nil, fmt.Errorf("%w: entry %d (%s): %w", ErrInvalidBackup, i, e.Name, err)
+        }
+        keys = append(keys, k)
+    }
+    return keys, nil
+}
@@ -0,0 +1,114 @@
+package otp
+
+import (
+    "crypto/sha1"
+    "crypto/sha256"
+    "crypto/sha512"
+    "hash"
+    "strings"
+    "sync"
+)
+
+/*
+    Algorithm is the hash function underneath the HMAC
+
+    RFC 6238 allows HMAC-SHA-1 (the default, and the only one RFC 4226 defines), HMAC-SHA-256
+    and HMAC-SHA-512. The names match the "algorithm" parameter of otpauth:// URIs.
+*/
+type Algorithm int
+
+const (
+    SHA1 Algorithm = iota
+    SHA256
+    SHA512
+)
+
+// algorithmInfo is what we know about an Algorithm
+type algorithmInfo struct {
+    name    string
+    newHash func() hash.Hash
+    hashes  *sync.Pool  // of hashes from newHash, so generating a code doesn't allocate one
+}
+
+// hashPool makes the pool for algorithmInfo.hashes
+func hashPool(newHash func() hash.Hash) *sync.Pool {
+    return &sync.Pool{New: func() any { return newHash() }}
+}
+
+/*
+    algorithms is indexed by Algorithm
+
+    It starts with the RFC 6238 algorithms and grows through RegisterAlgorithm.
+*/
+var (
+    algorithmsMu    sync.RWMutex
+    algorithms      []algorithmInfo = []algorithmInfo{
+        SHA1:   {"SHA1", sha1.New, hashPool(sha1.New)},
+        SHA256: {"SHA256", sha256.New, hashPool(sha256.New)},
+        SHA512: {"SHA512", sha512.New, hashPool(sha512.New)},
+    }
+)
+
+/*
+    RegisterAlgorithm makes any hash usable for HMAC/HOTP/TOTP
+
+    newHash is a hash constructor just like the one crypto/hmac takes, e.g. sha3.New256,
+    and name is what String returns and ParseAlgorithm accepts. Registering a name that's
+    already known returns the existing Algorithm with its hash replaced.
+*/
+func RegisterAlgorithm(name string, newHash func() hash.Hash) Algorithm {
+    algorithmsMu.Lock()
+    defer algorithmsMu.Unlock()
+
+    for i, info := range(algorithms) {
+        if (strings.EqualFold(info.name, name)) {
+            // a new pool too, so no hash from the old constructor is handed out again
+            algorithms[i].newHash = newHash
+            algorithms[i].hashes = hashPool(newHash)
+            return Algorithm(i)
+        }
+    }
+    algorithms = append(algorithms, algorithmInfo{strings.ToUpper(name), newHash, hashPool(newHash)})
+    return Algorithm(len(algorithms) - 1)
+}
+
+// info looks the algorithm up, unknown algorithms are reported as not ok
+func (a Algorithm) info() (algorithmInfo, bool) {
+    algorithmsMu.RLock()
+    defer algorithmsMu.RUnlock()
+
+    if (a < 0 || int(a) >= len(algorithms)) {
+        return algorithmInfo{}, false
+    }
+    return algorithms[a], true
+}
+
+// Hash returns the hash constructor for the algorithm, SHA-1 for an unknown algorithm
+func (a Algorithm) Hash() func() hash.Hash {
+    var info, ok = a.info()
+    if (!ok) {
+        return sha1.New
+    }
+    return info.newHash
+}
+
+func (a Algorithm) String() string {
+    var info, ok = a.info()
+    if (!ok) {
+        return "UNKNOWN"
+    }
+    return info.name
+}
+
+// ParseAlgorithm reads an algorithm name like the one in an otpauth:// URI, ignoring case
+func ParseAlgorithm(name string) (Algorithm, bool) {
+    algorithmsMu.RLock()
+    defer algorithmsMu.RUnlock()
+
+    for i, info := range(algorithms) {
+        if (strings.EqualFold(info.name, name)) {
+            return Algorithm(i), true
+        }
+    }
+    return SHA1, false
+}
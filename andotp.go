@@ -0,0 +1,140 @@
+package otp
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/pbkdf2"
+    "crypto/sha1"
+    "crypto/sha256"
+    "encoding/binary"
+    "encoding/json"
+    "fmt"
+    "strings"
+    "time"
+)
+
+/*
+    andOTP backups
+
+    A plain andOTP backup is a JSON array of entries. An encrypted one (.json.aes) is
+    AES-256-GCM; since andOTP 0.6.3 the file starts with the PBKDF2 iteration count
+    (4 bytes big-endian), a 12 byte salt and a 12 byte nonce, and the key is
+    PBKDF2-HMAC-SHA1 of the password. Older versions used SHA-256 of the password as
+    the key and start with just the nonce.
+*/
+
+type andOTPEntry struct {
+    Secret      string  `json:"secret"`
+    Issuer      string  `json:"issuer"`
+    Label       string  `json:"label"`
+    Digits      int     `json:"digits"`
+    Type        string  `json:"type"`
+    Algorithm   string  `json:"algorithm"`
+    Period      int     `json:"period"`
+    Counter     uint64  `json:"counter"`
+}
+
+const (
+    andOTPSaltSize  int = 12
+    andOTPNonceSize int = 12
+)
+
+// andOTPOpen decrypts a nonce-prefixed AES-256-GCM andOTP blob
+func andOTPOpen(key []byte, data []byte) ([]byte, error) {
+    if (len(data) < andOTPNonceSize) {
+        return nil, fmt.Errorf("%w: file too short", ErrInvalidBackup)
+    }
+    var block, err = aes.NewCipher(key)
+    if (err != nil) {
+        return nil, err
+    }
+    var gcm cipher.AEAD
+    if gcm, err = cipher.NewGCMWithNonceSize(block, andOTPNonceSize); err != nil {
+        return nil, err
+    }
+    return gcm.Open(nil, data[:andOTPNonceSize], data[andOTPNonceSize:], nil)
+}
+
+// andOTPDecrypt tries the current PBKDF2 format first and then the old SHA-256 one
+func andOTPDecrypt(data []byte, password string) ([]byte, error) {
+    if (len(data) > 4 + andOTPSaltSize) {
+        var iterations int = int(binary.BigEndian.Uint32(data))
+        var salt []byte = data[4:4 + andOTPSaltSize]
+        // a garbage iteration count means this is the old format
+        if (iterations > 0 && iterations <= 10000000) {
+            var key, err = pbkdf2.Key(sha1.New, password, salt, iterations, 32)
+            if (err != nil) {
+                return nil, err
+            }
+            var plain []byte
+            if plain, err = andOTPOpen(key, data[4 + andOTPSaltSize:]); err == nil {
+                return plain, nil
+            }
+        }
+    }
+    var key [32]byte = sha256.Sum256([]byte(password))
+    var plain, err = andOTPOpen(key[:], data)
+    if (err != nil) {
+        return nil, ErrWrongPassword
+    }
+    return plain, nil
+}
+
+// key converts an entry to a Key
+func (e andOTPEntry) key() (*Key, error) {
+    var secret, err = DecodeBase32(e.Secret)
+    if (err != nil) {
+        return nil, err
+    }
+    var algo, ok = ParseAlgorithm(e.Algorithm)
+    if (!ok) {
+        return nil, fmt.Errorf("%w: %s", ErrInvalidAlgorithm, e.Algorithm)
+    }
+
+    var opts []Option = []Option{
+        WithAlgorithm(algo),
+        WithDigits(e.Digits),
+        WithIssuer(e.Issuer),
+        WithAccount(trimIssuer(e.Label, e.Issuer)),
+    }
+    switch strings.ToUpper(e.Type) {
+    case "TOTP":
+        opts = append(opts, WithPeriod(time.Duration(e.Period) * time.Second))
+    case "HOTP":
+        opts = append(opts, WithHOTP(e.Counter))
+    default:
+        return nil, fmt.Errorf("unsupported entry type %q", e.Type)
+    }
+    return NewKey(secret, opts...)
+}
+
+/*
+    ImportAndOTP reads an andOTP backup and returns a Key for each entry
+
+    An empty password means data is a plain JSON backup, otherwise it is decrypted
+    first; a password that doesn't decrypt it fails with ErrWrongPassword. As with
+    ImportAegis, anything but TOTP and HOTP entries (e.g. Steam) fails the import.
+*/
+func ImportAndOTP(data []byte, password string) ([]*Key, error) {
+    if (password != "") {
+        var err error
+        if data, err = andOTPDecrypt(data, password); err != nil {
+            return nil, err
+        }
+    }
+
+    var entries []andOTPEntry
+    if err := json.Unmarshal(data, &entries); err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+    }
+
+    var keys []*Key
+    for i, e := range(entries) {
+        var k, err = e.key()
+        if (err != nil) {
+            return nil, fmt.Errorf("%w: entry %d (%s): %w", ErrInvalidBackup, i, e.Label, err)
+        }
+        keys = append(keys, k)
+    }
+    return keys, nil
+}
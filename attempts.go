@@ -0,0 +1,19 @@
+package otp
+
+import (
+    "time"
+)
+
+/*
+    AttemptStore counts failed verifications per user
+
+    RFC 4226 section 7.3 asks servers to throttle guessing, and the first thing that
+    needs is a count of recent failures that every instance of a service sees.
+    RecordFailure adds one and returns the count within the current window, which
+    starts at the first failure and lasts window; once it's over the count starts
+    again from one. ResetFailures clears it, typically after a success.
+*/
+type AttemptStore interface {
+    RecordFailure(user string, window time.Duration) (int, error)
+    ResetFailures(user string) error
+}
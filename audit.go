@@ -0,0 +1,144 @@
+package otp
+
+import (
+    "encoding/json"
+    "errors"
+    "io"
+    "sync"
+    "time"
+)
+
+// VerifyResult is how a verification ended, in a form that's easy to log and count
+type VerifyResult string
+
+const (
+    ResultValid     VerifyResult = "valid"
+    ResultInvalid   VerifyResult = "invalid"        // the code matched nothing
+    ResultReplayed  VerifyResult = "replayed"       // the code matched but was used before
+    ResultLockedOut VerifyResult = "locked_out"     // the user was or got locked out
+    ResultError     VerifyResult = "error"          // the key or a store failed
+)
+
+// VerifyResultOf classifies the error a validation returned
+func VerifyResultOf(err error) VerifyResult {
+    switch {
+    case err == nil:
+        return ResultValid
+    case errors.Is(err, ErrLockedOut):
+        return ResultLockedOut
+    case errors.Is(err, ErrCodeReused):
+        return ResultReplayed
+    case errors.Is(err, ErrCodeMismatch):
+        return ResultInvalid
+    default:
+        return ResultError
+    }
+}
+
+/*
+    AuditEvent records one verification
+
+    Offset is where the code matched relative to what was expected: TOTP steps from
+    the current one (negative for a code from the past) or, for HOTP, how far ahead of
+    the stored counter the token was. It's 0 when nothing matched. Fingerprint is the
+    key's, see Key.Fingerprint. Scheme is which of two kinds of code matched when a
+    validation accepts both, "standard" or "legacy" for ValidateDuringMigration, and
+    empty otherwise. RemoteAddr is left empty by the package; servers fill it in, see
+    AuditFunc.
+*/
+type AuditEvent struct {
+    Time        time.Time
+    User        string
+    Type        Type
+    Algorithm   Algorithm
+    Fingerprint string
+    Result      VerifyResult
+    Offset      int64
+    Scheme      string
+    RemoteAddr  string
+    Err         error       // why it failed, nil if it didn't
+}
+
+/*
+    AuditSink receives an event for every verification
+
+    ValidateTOTP, ValidateTOTPStep and the Key validation methods send one to the sink
+    in their options (WithAudit for a Key), whatever the result. Audit is called
+    synchronously and must be safe for concurrent use; a sink that can fail has to
+    deal with that itself, the verification result doesn't change.
+*/
+type AuditSink interface {
+    Audit(e AuditEvent)
+}
+
+// AuditFunc lets a plain function be an AuditSink, e.g. one that adds the remote address and passes the event on
+type AuditFunc func(e AuditEvent)
+
+func (f AuditFunc) Audit(e AuditEvent) {
+    f(e)
+}
+
+// audit sends sink the event for a validation that just ended
+func audit(sink AuditSink, now time.Time, user string, t Type, algo Algorithm, fp string, offset int64, err error) {
+    sink.Audit(AuditEvent{
+        Time:       now,
+        User:       user,
+        Type:       t,
+        Algorithm:  algo,
+        Fingerprint: fp,
+        Result:     VerifyResultOf(err),
+        Offset:     offset,
+        Err:        err,
+    })
+}
+
+// auditRecord is the JSON form of an AuditEvent
+type auditRecord struct {
+    Time        time.Time       `json:"time"`
+    User        string          `json:"user"`
+    Type        string          `json:"type"`
+    Algorithm   string          `json:"algorithm"`
+    Fingerprint string          `json:"fingerprint,omitempty"`
+    Result      VerifyResult    `json:"result"`
+    Offset      int64           `json:"offset"`
+    Scheme      string          `json:"scheme,omitempty"`
+    RemoteAddr  string          `json:"remote_addr,omitempty"`
+    Error       string          `json:"error,omitempty"`
+}
+
+// jsonAudit writes events as JSON lines
+type jsonAudit struct {
+    mu  sync.Mutex
+    enc *json.Encoder
+}
+
+/*
+    NewJSONAudit returns an AuditSink writing each event to w as one line of JSON
+
+    Point it at a file opened with O_APPEND, or a pipe to wherever the trail is kept.
+    Write errors are dropped, see AuditSink.
+*/
+func NewJSONAudit(w io.Writer) AuditSink {
+    return &jsonAudit{enc: json.NewEncoder(w)}
+}
+
+func (a *jsonAudit) Audit(e AuditEvent) {
+    var r auditRecord = auditRecord{
+        Time:       e.Time.UTC(),
+        User:       e.User,
+        Type:       e.Type.String(),
+        Algorithm:  e.Algorithm.String(),
+        Fingerprint: e.Fingerprint,
+        Result:     e.Result,
+        Offset:     e.Offset,
+        Scheme:     e.Scheme,
+        RemoteAddr: e.RemoteAddr,
+    }
+    if (e.Err != nil) {
+        r.Error = e.Err.Error()
+    }
+
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.enc.Encode(r)
+}
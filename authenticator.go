@@ -0,0 +1,103 @@
+package otp
+
+/*
+    Authenticators
+
+    HOTP, TOTP, Steam Guard and Mobile-OTP all come down to showing a code and checking
+    one, but each has its own functions and options. CodeGenerator and CodeValidator are
+    those two operations with the differences left inside the implementation, so
+    application code, a CLI or a server can take any of them:
+        *Key            HOTP or TOTP, whichever the key is
+        *Generator      TOTP from a Generator, without the stores; an HOTP one
+                        keeps no counter and fails with ErrNotTOTP
+        SteamKey        Steam Guard
+        MOTPKey         Mobile-OTP
+    Codes are strings because Steam's and mOTP's aren't decimal. Each implementation
+    reads the time from its own Clock and keeps its own replay, lockout and audit
+    settings, so a caller only ever passes the code.
+*/
+
+// CodeGenerator is anything that can show the current code
+type CodeGenerator interface {
+    GenerateCode() (string, error)
+}
+
+// CodeValidator is anything that can check a submitted code, failing with ErrCodeMismatch when it's wrong
+type CodeValidator interface {
+    ValidateCode(code string) error
+}
+
+// Authenticator both shows and checks codes
+type Authenticator interface {
+    CodeGenerator
+    CodeValidator
+}
+
+// GenerateCode is Generate as a string, see CodeGenerator
+func (k *Key) GenerateCode() (string, error) {
+    var code, err = k.Generate()
+    return code.String(), err
+}
+
+// ValidateCode is Validate, see CodeValidator
+func (k *Key) ValidateCode(code string) error {
+    return k.Validate(code)
+}
+
+// GenerateCode is the TOTP code for now by the key's Clock, see CodeGenerator; HOTP generators fail with ErrNotTOTP
+func (g *Generator) GenerateCode() (string, error) {
+    var code, err = g.GenerateAt(clockNow(g.clock))
+    return code.String(), err
+}
+
+// ValidateCode checks a TOTP code for now by the key's Clock, see CodeValidator; HOTP generators fail with ErrNotTOTP
+func (g *Generator) ValidateCode(code string) error {
+    var _, err = g.ValidateAt(code, clockNow(g.clock))
+    return err
+}
+
+/*
+    SteamKey is a Steam Guard secret as an Authenticator
+
+    Opts is what ValidateSteam takes; GenerateCode uses its Time, or its Clock's time
+    when Time is zero.
+*/
+type SteamKey struct {
+    Secret  []byte
+    Opts    ValidateOpts
+}
+
+func (s SteamKey) GenerateCode() (string, error) {
+    return GenerateSteamAt(s.Secret, s.Opts.withDefaults().Time)
+}
+
+func (s SteamKey) ValidateCode(code string) error {
+    return ValidateSteam(s.Secret, code, s.Opts)
+}
+
+/*
+    MOTPKey is a Mobile-OTP secret and PIN as an Authenticator
+
+    Opts is what ValidateMOTP takes, so set its Skew (usually MOTPSkew); GenerateCode
+    uses its Time, or its Clock's time when Time is zero.
+*/
+type MOTPKey struct {
+    Secret  string
+    PIN     string
+    Opts    ValidateOpts
+}
+
+func (m MOTPKey) GenerateCode() (string, error) {
+    return GenerateMOTPAt(m.Secret, m.PIN, m.Opts.withDefaults().Time)
+}
+
+func (m MOTPKey) ValidateCode(code string) error {
+    return ValidateMOTP(m.Secret, m.PIN, code, m.Opts)
+}
+
+var (
+    _ Authenticator = (*Key)(nil)
+    _ Authenticator = (*Generator)(nil)
+    _ Authenticator = SteamKey{}
+    _ Authenticator = MOTPKey{}
+)
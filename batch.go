@@ -0,0 +1,133 @@
+package otp
+
+import (
+    "fmt"
+    "time"
+)
+
+/*
+    Batch generation
+
+    Printing a sheet of emergency codes or loading an offline verification appliance
+    means generating every code for a stretch of time (or run of counters) in one go.
+    These functions do that and say when each TOTP code is current, check the size of
+    the batch first so a mistyped date doesn't try to fill memory, and with a Key
+    fetch a provided secret once for the whole batch rather than once a code.
+*/
+
+// MaxBatchCodes is the most codes one batch can hold, a little over a year of 30 second steps
+const MaxBatchCodes int = 1 << 20
+
+/*
+    BatchCode is one code in a batch
+
+    Counter is the TOTP time step or HOTP counter the code is for. Start and Expiry are
+    when a TOTP code becomes and stops being current; both are zero for HOTP.
+*/
+type BatchCode struct {
+    Code    Code
+    Counter uint64
+    Start   time.Time
+    Expiry  time.Time
+}
+
+// checkBatch makes sure first to last is a batch that can be generated
+func checkBatch(first uint64, last uint64) error {
+    if (last >= first && last - first >= uint64(MaxBatchCodes)) {
+        return fmt.Errorf("%w: %d to %d is more than %d", ErrBatchTooLarge, first, last, MaxBatchCodes)
+    }
+    return nil
+}
+
+// generateBatch runs generate for every counter from first to last inclusive
+func generateBatch(first uint64, last uint64, generate func(counter uint64) (Code, error)) ([]BatchCode, error) {
+    if err := checkBatch(first, last); err != nil {
+        return nil, err
+    }
+    if (last < first) {
+        return []BatchCode{}, nil
+    }
+    defer observeGenerate(time.Now())
+    var codes []BatchCode = make([]BatchCode, 0, last - first + 1)
+    for c := first; ; c++ {
+        var code, err = generate(c)
+        if (err != nil) {
+            return nil, err
+        }
+        codes = append(codes, BatchCode{Code: code, Counter: c})
+        if (c == last) {
+            return codes, nil
+        }
+    }
+}
+
+// totpBatch generates the TOTP batch from the step containing from to the one containing to, leaving out steps before T0
+func totpBatch(from time.Time, to time.Time, period time.Duration, t0 time.Time, generate func(first uint64, last uint64) ([]BatchCode, error)) ([]BatchCode, error) {
+    if (period < time.Second) {
+        return nil, ErrInvalidPeriod
+    }
+    var first, last int64 = max(timeStepFrom(from, t0, period), 0), timeStepFrom(to, t0, period)
+    if (last < first) {
+        return []BatchCode{}, nil
+    }
+    var codes, err = generate(uint64(first), uint64(last))
+    if (err != nil) {
+        return nil, err
+    }
+    for i := range(codes) {
+        codes[i].Start = stepStart(int64(codes[i].Counter), t0, period)
+        codes[i].Expiry = stepStart(int64(codes[i].Counter) + 1, t0, period)
+    }
+    return codes, nil
+}
+
+/*
+    GenerateHOTPRange returns the HOTP codes for every counter from first to last inclusive
+
+    last before first is an empty batch, and more than MaxBatchCodes counters fails with
+    ErrBatchTooLarge. See GenerateHOTP for digits and algo.
+*/
+func GenerateHOTPRange(key []byte, first uint64, last uint64, digits int, algo Algorithm) ([]BatchCode, error) {
+    return generateBatch(first, last, func(counter uint64) (Code, error) {
+        return generateHOTP(key, counter, digits, algo, Truncation{})
+    })
+}
+
+/*
+    GenerateTOTPRange returns the TOTP codes for every time step from the one containing from to the one containing to
+
+    Both ends are included, so a sheet for a day is from midnight to a second before the
+    next. Steps before t0 are left out and to before from is an empty batch; more than
+    MaxBatchCodes steps fails with ErrBatchTooLarge. See GenerateTOTP for the rest.
+*/
+func GenerateTOTPRange(key []byte, from time.Time, to time.Time, digits int, algo Algorithm, period time.Duration, t0 time.Time) ([]BatchCode, error) {
+    return totpBatch(from, to, period, t0, func(first uint64, last uint64) ([]BatchCode, error) {
+        return GenerateHOTPRange(key, first, last, digits, algo)
+    })
+}
+
+// GenerateRange is GenerateTOTPRange with the key's settings, including any checksum digit or fixed truncation
+func (k *Key) GenerateRange(from time.Time, to time.Time) ([]BatchCode, error) {
+    return totpBatch(from, to, k.Period, k.T0, k.generateBatch)
+}
+
+// GenerateCounterRange is GenerateHOTPRange with the key's settings; it doesn't move Counter
+func (k *Key) GenerateCounterRange(first uint64, last uint64) ([]BatchCode, error) {
+    return k.generateBatch(first, last)
+}
+
+// generateBatch is generateBatch for the key, fetching its secret once
+func (k *Key) generateBatch(first uint64, last uint64) ([]BatchCode, error) {
+    if err := checkBatch(first, last); err != nil {
+        return nil, err
+    }
+    var secret, done, err = k.secret()
+    if (err != nil) {
+        return nil, err
+    }
+    defer done()
+    return generateBatch(first, last, func(counter uint64) (Code, error) {
+        var code, _, err = k.generateWith(secret, counter)
+        return code, err
+    })
+}
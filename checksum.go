@@ -0,0 +1,94 @@
+package otp
+
+import (
+    "encoding/hex"
+    "strconv"
+    "strings"
+)
+
+// checksumKey keys the HMAC in CodesChecksum so its output can't be confused with any code
+var checksumKey []byte = []byte("otp codes checksum")
+
+/*
+    CodesChecksum returns a short checksum over an ordered list of codes
+
+    It is meant for the footer of a printed sheet of backup codes so a user can check they
+    copied the page correctly; it is not a secret. Each code is length-prefixed before
+    going into the HMAC so ["12", "3"] and ["1", "23"] don't collide. The result is the
+    first 4 bytes of the HMAC in upper case hex.
+*/
+func CodesChecksum(codes []string) string {
+    var message []byte
+    for _, code := range(codes) {
+        message = append(message, strconv.Itoa(len(code))...)
+        message = append(message, ':')
+        message = append(message, code...)
+    }
+
+    var sum []byte = HMAC(checksumKey, message)
+    return strings.ToUpper(hex.EncodeToString(sum[:4]))
+}
+
+/*
+    RFC 4226 checksum digit
+
+    Some hardware tokens show a code with one more digit on the end, a Luhn checksum
+    over the code (RFC 4226 appendix C, the addChecksum option of its reference code),
+    so a mistyped code is caught before it counts as a wrong guess. The checksum adds no
+    security; the code underneath is the ordinary HOTP or TOTP code. WithChecksum and
+    ValidateOpts.Checksum make a Key and the validation functions generate and expect
+    it. A code and its checksum have to fit in a Code, so the code can be at most
+    MaxChecksumDigits long.
+*/
+
+// MaxChecksumDigits is the longest code a checksum digit can be added to
+const MaxChecksumDigits int = 8
+
+// doubleDigits[d] is the digit sum of 2*d
+var doubleDigits [10]uint32 = [10]uint32{0, 2, 4, 6, 8, 1, 3, 5, 7, 9}
+
+// ChecksumDigit is the checksum digit of the digits digit code value, as RFC 4226's calcChecksum computes it
+func ChecksumDigit(value uint32, digits int) uint32 {
+    var total uint32
+    // the rightmost digit is doubled, then every other one going left
+    var double bool = true
+    for i := 0; i < digits; i++ {
+        var d uint32 = value % 10
+        value /= 10
+        if (double) {
+            d = doubleDigits[d]
+        }
+        total += d
+        double = !double
+    }
+    return (10 - total % 10) % 10
+}
+
+// AddChecksum appends the checksum digit to c, failing with ErrInvalidDigits if c is longer than MaxChecksumDigits
+func AddChecksum(c Code) (Code, error) {
+    if (c.IsZero() || c.Digits > MaxChecksumDigits) {
+        return Code{}, ErrInvalidDigits
+    }
+    return Code{Value: c.Value * 10 + ChecksumDigit(c.Value, c.Digits), Digits: c.Digits + 1}, nil
+}
+
+/*
+    stripChecksum checks the checksum digit at the end of a submitted code, normalized under policy
+
+    It returns the code without it, or false if the code is malformed, too long or its
+    checksum is wrong. The checksum isn't secret, so there's no need to compare it in
+    constant time.
+*/
+func stripChecksum(code string, policy NormalizationPolicy) (string, bool) {
+    var normalized, ok = Normalize(code, policy)
+    if (!ok || len(normalized) < 2 || len(normalized) > MaxChecksumDigits + 1) {
+        return "", false
+    }
+    var digits string = normalized[:len(normalized) - 1]
+    var value, err = strconv.ParseUint(digits, 10, 32)
+    if (err != nil) {
+        return "", false
+    }
+    var check uint32 = uint32(normalized[len(normalized) - 1] - '0')
+    return digits, ChecksumDigit(uint32(value), len(digits)) == check
+}
@@ -0,0 +1,41 @@
+package otp
+
+import (
+    "crypto/hmac"
+    "crypto/sha1"
+    "encoding/hex"
+    "strings"
+    "testing"
+)
+
+func TestCodesChecksum(t *testing.T) {
+    var tests = []struct {
+        codes   []string
+        message string
+    }{
+        {nil, ""},
+        {[]string{"755224"}, "6:755224"},
+        {[]string{"755224", "287082"}, "6:7552246:287082"},
+        {[]string{"12", "3"}, "2:121:3"},
+        {[]string{"1", "23"}, "1:12:23"},
+    }
+    var seen map[string][]string = map[string][]string{}
+    for _, tt := range(tests) {
+        var mac = hmac.New(sha1.New, checksumKey)
+        mac.Write([]byte(tt.message))
+        var want string = strings.ToUpper(hex.EncodeToString(mac.Sum(nil)[:4]))
+
+        var got string = CodesChecksum(tt.codes)
+        if (got != want) {
+            t.Errorf("%q: %s, want %s", tt.codes, got, want)
+        }
+        if other, ok := seen[got]; ok {
+            t.Errorf("%q and %q have the same checksum %s", tt.codes, other, got)
+        }
+        seen[got] = tt.codes
+    }
+
+    if (CodesChecksum([]string{"287082", "755224"}) == CodesChecksum([]string{"755224", "287082"})) {
+        t.Error("the order of the codes doesn't change the checksum")
+    }
+}
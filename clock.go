@@ -0,0 +1,46 @@
+package otp
+
+import (
+    "time"
+)
+
+/*
+    Clock tells the time
+
+    TOTP depends on the time, so everything that reads it takes a Clock: a Key
+    (WithClock), ValidateOpts, LockoutPolicy, SecretRing, CheckSecretHealth and the
+    stores. Use one to drive tests with a fixed or stepped time, or to validate against
+    a trusted time source instead of the host's clock. nil means the system clock.
+*/
+type Clock interface {
+    Now() time.Time
+}
+
+// SystemClock is the host's clock
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+    return time.Now()
+}
+
+// ClockFunc lets a plain function be a Clock
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+    return f()
+}
+
+// FixedClock is a Clock that's always t
+func FixedClock(t time.Time) Clock {
+    return ClockFunc(func() time.Time {
+        return t
+    })
+}
+
+// clockNow reads c, or the system clock if c is nil
+func clockNow(c Clock) time.Time {
+    if (c == nil) {
+        return time.Now()
+    }
+    return c.Now()
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "strings"
+    "text/tabwriter"
+
+    otp "github.com/adam-good/OTP"
+    "github.com/adam-good/OTP/keyring"
+    "github.com/adam-good/OTP/vault"
+)
+
+// accountArgs splits the account name off the front of args (or the back, after the flags) and parses the flags
+func accountArgs(fs *flag.FlagSet, args []string) (string, error) {
+    var name string
+    if (len(args) > 0 && !strings.HasPrefix(args[0], "-")) {
+        name, args = args[0], args[1:]
+    }
+    if err := fs.Parse(args); err != nil {
+        return "", err
+    }
+    if (name == "" && fs.NArg() > 0) {
+        name = fs.Arg(0)
+    }
+    if (name == "") {
+        return "", errors.New("an account name is required")
+    }
+    return name, nil
+}
+
+/*
+    openDefaultStore opens the account store at path
+
+    A path of "keyring" means the system keychain. Anything else is a vault file, which
+    is created if it isn't there yet, and that needs the passphrase.
+*/
+func openDefaultStore(path string, stdin io.Reader, stderr io.Writer) (otp.KeyStore, error) {
+    if (path == keyringStore) {
+        return keyring.New("otp"), nil
+    }
+    var passphrase, err = readPassphrase(stdin, stderr)
+    if (err != nil) {
+        return nil, err
+    }
+    return vault.OpenOrCreate(path, passphrase)
+}
+
+func runAdd(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+    var fs *flag.FlagSet = flag.NewFlagSet("add", flag.ContinueOnError)
+    var kf keyFlags
+    kf.register(fs)
+    var uri, path string
+    var hotp, force bool
+    fs.StringVar(&uri, "uri", "", "an otpauth:// URI for the account, instead of --secret and friends")
+    fs.BoolVar(&hotp, "hotp", false, "with --secret, make it a counter based account starting at --counter")
+    fs.BoolVar(&force, "force", false, "replace an existing account with the same name")
+    fs.StringVar(&path, "store", defaultStorePath(), "account store file")
+    var name, err = accountArgs(fs, args)
+    if (err != nil) {
+        return err
+    }
+
+    var k *otp.Key
+    if (uri != "") {
+        k, err = otp.ParseURI(uri)
+    } else {
+        k, err = kf.key(stdin, hotp)
+    }
+    if (err != nil) {
+        return err
+    }
+
+    var s otp.KeyStore
+    if s, err = openDefaultStore(path, stdin, stderr); err != nil {
+        return err
+    }
+    if _, err = s.Get(name); err == nil && !force {
+        return fmt.Errorf("account %q already exists, use --force to replace it", name)
+    }
+    return s.Put(name, k)
+}
+
+func runCode(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+    var fs *flag.FlagSet = flag.NewFlagSet("code", flag.ContinueOnError)
+    var path, server string
+    fs.StringVar(&path, "store", defaultStorePath(), "account store file")
+    fs.StringVar(&server, "ntp", "", "NTP server to take the time from for TOTP, instead of the local clock")
+    var name, err = accountArgs(fs, args)
+    if (err != nil) {
+        return err
+    }
+
+    var s otp.KeyStore
+    if s, err = openDefaultStore(path, stdin, stderr); err != nil {
+        return err
+    }
+    var k *otp.Key
+    if k, err = s.Get(name); errors.Is(err, otp.ErrKeyNotFound) {
+        return fmt.Errorf("no account %q", name)
+    } else if (err != nil) {
+        return fmt.Errorf("account %q: %w", name, err)
+    }
+
+    var code otp.Code
+    if (k.Type == otp.TypeHOTP) {
+        // each HOTP code is used up, so move the stored counter on before showing it
+        if code, err = k.GenerateCounter(k.Counter); err != nil {
+            return err
+        }
+        k.Counter++
+        if err = s.Put(name, k); err != nil {
+            return err
+        }
+    } else {
+        if (server != "") {
+            if k.Clock, err = ntpClock(server); err != nil {
+                return err
+            }
+        }
+        if code, err = k.Generate(); err != nil {
+            return err
+        }
+    }
+    fmt.Fprintln(stdout, code)
+    return nil
+}
+
+func runList(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+    var fs *flag.FlagSet = flag.NewFlagSet("list", flag.ContinueOnError)
+    var path string
+    fs.StringVar(&path, "store", defaultStorePath(), "account store file")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    var s, err = openDefaultStore(path, stdin, stderr)
+    if (err != nil) {
+        return err
+    }
+    var names []string
+    if names, err = s.Names(); err != nil {
+        return err
+    }
+
+    var tw *tabwriter.Writer = tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+    for _, name := range(names) {
+        var k, err = s.Get(name)
+        if (err != nil) {
+            fmt.Fprintf(tw, "%s\t(unreadable: %v)\n", name, err)
+            continue
+        }
+        var label string = k.Account
+        if (k.Issuer != "") {
+            label = k.Issuer + ":" + k.Account
+        }
+        fmt.Fprintf(tw, "%s\t%s\t%s\n", name, k.Type, label)
+    }
+    return tw.Flush()
+}
+
+func runRemove(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+    var fs *flag.FlagSet = flag.NewFlagSet("rm", flag.ContinueOnError)
+    var path string
+    fs.StringVar(&path, "store", defaultStorePath(), "account store file")
+    var name, err = accountArgs(fs, args)
+    if (err != nil) {
+        return err
+    }
+
+    var s otp.KeyStore
+    if s, err = openDefaultStore(path, stdin, stderr); err != nil {
+        return err
+    }
+    if err = s.Remove(name); errors.Is(err, otp.ErrKeyNotFound) {
+        return fmt.Errorf("no account %q", name)
+    }
+    return err
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "time"
+
+    otp "github.com/adam-good/OTP"
+    "github.com/adam-good/OTP/ntp"
+)
+
+// ntpClock is the local clock corrected by what server says the time is
+func ntpClock(server string) (otp.Clock, error) {
+    var offset, err = ntp.Offset(server)
+    if (err != nil) {
+        return nil, fmt.Errorf("asking %s for the time: %w", server, err)
+    }
+    return ntp.Clock{Offset: offset}, nil
+}
+
+/*
+    runClock reports how far the local clock is from an NTP server's
+
+    An offset of more than a few seconds is worth fixing; past half a TOTP period,
+    codes start landing in the wrong time step.
+*/
+func runClock(args []string, stdout io.Writer) error {
+    var fs *flag.FlagSet = flag.NewFlagSet("clock", flag.ContinueOnError)
+    var server string
+    fs.StringVar(&server, "server", ntp.DefaultServer, "NTP server to ask")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    var r, err = ntp.Query(server, ntp.DefaultTimeout)
+    if (err != nil) {
+        return err
+    }
+    var ahead string = "behind"
+    var offset time.Duration = r.Offset
+    if (offset < 0) {
+        ahead, offset = "ahead of", -offset
+    }
+    fmt.Fprintf(stdout, "local clock is %s %s %s (round trip %s, stratum %d)\n",
+        offset.Round(time.Millisecond), ahead, server, r.RTT.Round(time.Millisecond), r.Stratum)
+    if (offset > otp.DefaultPeriod / 2) {
+        fmt.Fprintln(stdout, "that's enough to break TOTP codes, fix the clock or use --ntp")
+    }
+    return nil
+}
@@ -0,0 +1,310 @@
+/*
+    Command otp generates and checks one-time passwords from the command line
+
+    Usage:
+
+        otp totp   --secret BASE32 [--digits 6] [--algorithm SHA1] [--period 30s] [--watch] [--ntp SERVER]
+        otp hotp   --secret BASE32 --counter N [--digits 6] [--algorithm SHA1]
+        otp verify --secret BASE32 --code CODE [--counter N] [--window 1] [...]
+        otp steam  --secret BASE64 [--ntp SERVER]
+        otp clock  [--server pool.ntp.org]
+
+        otp add  NAME --uri otpauth://... | --secret BASE32 [--hotp] [...]
+        otp code NAME
+        otp list
+        otp rm   NAME
+
+    totp and hotp print the code; totp --watch keeps printing it, with the seconds left
+    before it changes, until interrupted. verify exits 0 if the code is valid and 1 if it isn't;
+    with --counter it checks an HOTP code and prints the counter that matched. A secret
+    of "-" is read from standard input so it doesn't end up in the process list.
+    steam prints a Steam Guard code from the base64 shared_secret of a Steam
+    authenticator.
+
+    clock asks an NTP server how far off the local clock is. On a machine whose clock
+    can't be fixed, --ntp SERVER on totp, verify, steam and code makes TOTP codes with the
+    server's time instead.
+
+    add, code, list and rm manage named accounts kept in an encrypted store, so the
+    secret only has to be given once; see store.go for where it lives and how the
+    passphrase is supplied. code on an HOTP account moves its stored counter on.
+*/
+package main
+
+import (
+    "bufio"
+    "context"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "os/signal"
+    "strings"
+    "time"
+
+    otp "github.com/adam-good/OTP"
+)
+
+// keyFlags are the flags every subcommand uses to describe the key
+type keyFlags struct {
+    secret      string
+    digits      int
+    algorithm   string
+    period      time.Duration
+    counter     uint64
+    ntp         string
+}
+
+func (kf *keyFlags) register(fs *flag.FlagSet) {
+    fs.StringVar(&kf.secret, "secret", "", "the shared secret in Base32, or - to read it from stdin")
+    fs.IntVar(&kf.digits, "digits", otp.DefaultDigits, "code length")
+    fs.StringVar(&kf.algorithm, "algorithm", "SHA1", "hash algorithm: SHA1, SHA256 or SHA512")
+    fs.DurationVar(&kf.period, "period", otp.DefaultPeriod, "TOTP time step")
+    fs.Uint64Var(&kf.counter, "counter", 0, "HOTP counter")
+    fs.StringVar(&kf.ntp, "ntp", "", "NTP server to take the time from for TOTP, instead of the local clock")
+}
+
+// key builds the key the flags describe, counter based if hotp is set
+func (kf *keyFlags) key(stdin io.Reader, hotp bool) (*otp.Key, error) {
+    var encoded string = kf.secret
+    if (encoded == "-") {
+        var line, err = bufio.NewReader(stdin).ReadString('\n')
+        if (err != nil && err != io.EOF) {
+            return nil, err
+        }
+        encoded = strings.TrimSpace(line)
+    }
+    if (encoded == "") {
+        return nil, errors.New("--secret is required")
+    }
+    var secret, err = otp.DecodeBase32(encoded)
+    if (err != nil) {
+        return nil, err
+    }
+    var algo, ok = otp.ParseAlgorithm(kf.algorithm)
+    if (!ok) {
+        return nil, fmt.Errorf("unknown algorithm %q", kf.algorithm)
+    }
+
+    var opts []otp.Option = []otp.Option{otp.WithDigits(kf.digits), otp.WithAlgorithm(algo), otp.WithPeriod(kf.period)}
+    if (hotp) {
+        opts = append(opts, otp.WithHOTP(kf.counter))
+    } else if (kf.ntp != "") {
+        var clock otp.Clock
+        if clock, err = ntpClock(kf.ntp); err != nil {
+            return nil, err
+        }
+        opts = append(opts, otp.WithClock(clock))
+    }
+    return otp.NewKey(secret, opts...)
+}
+
+// isSet reports whether the named flag was given on the command line
+func isSet(fs *flag.FlagSet, name string) bool {
+    var set bool = false
+    fs.Visit(func(f *flag.Flag) {
+        if (f.Name == name) {
+            set = true
+        }
+    })
+    return set
+}
+
+func runTOTP(args []string, stdin io.Reader, stdout io.Writer) error {
+    var fs *flag.FlagSet = flag.NewFlagSet("totp", flag.ContinueOnError)
+    var kf keyFlags
+    kf.register(fs)
+    var watching bool
+    fs.BoolVar(&watching, "watch", false, "keep showing the current code and how long it has left")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    var k, err = kf.key(stdin, false)
+    if (err != nil) {
+        return err
+    }
+    if (watching) {
+        var ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt)
+        defer stop()
+        return watch(ctx, k, stdout, isTerminal(stdout))
+    }
+    return printCode(stdout, k)
+}
+
+// printCode prints the current code from any kind of key
+func printCode(stdout io.Writer, g otp.CodeGenerator) error {
+    var code, err = g.GenerateCode()
+    if (err != nil) {
+        return err
+    }
+    fmt.Fprintln(stdout, code)
+    return nil
+}
+
+func runHOTP(args []string, stdin io.Reader, stdout io.Writer) error {
+    var fs *flag.FlagSet = flag.NewFlagSet("hotp", flag.ContinueOnError)
+    var kf keyFlags
+    kf.register(fs)
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    if (!isSet(fs, "counter")) {
+        return errors.New("--counter is required")
+    }
+
+    var k, err = kf.key(stdin, true)
+    if (err != nil) {
+        return err
+    }
+    // the key's Counter is kf.counter, so its current code is that counter's
+    return printCode(stdout, k)
+}
+
+// isTerminal reports whether w is a terminal, where watch can redraw a single line
+func isTerminal(w io.Writer) bool {
+    var f, ok = w.(*os.File)
+    if (!ok) {
+        return false
+    }
+    var info, err = f.Stat()
+    return err == nil && info.Mode() & os.ModeCharDevice != 0
+}
+
+/*
+    watch shows k's current code until ctx is done
+
+    On a terminal one line is redrawn every second with the code and the seconds left
+    in its time step. Anywhere else (a pipe, a file) each new code is printed on its own
+    line as it comes up, so scripts can read them.
+*/
+func watch(ctx context.Context, k *otp.Key, w io.Writer, terminal bool) error {
+    var infos, err = k.WatchInfo(ctx, time.Second)
+    if (err != nil) {
+        return err
+    }
+
+    var last string
+    for info := range(infos) {
+        // time left in this step, rounded up so it counts down to 1 rather than 0
+        var left int = int((info.Remaining + time.Second - 1) / time.Second)
+
+        if (terminal) {
+            fmt.Fprintf(w, "\r%s  %2ds left ", info.Code, left)
+        } else if (info.Code != last) {
+            fmt.Fprintln(w, info.Code)
+        }
+        last = info.Code
+    }
+    if (terminal) {
+        fmt.Fprintln(w)
+    }
+    if (ctx.Err() != nil) {
+        return nil
+    }
+    // the watch stopped because a code couldn't be generated, this says why
+    _, err = k.Generate()
+    return err
+}
+
+// errInvalid is returned by verify for a code that doesn't match, so main exits 1 quietly
+var errInvalid error = errors.New("invalid code")
+
+func runVerify(args []string, stdin io.Reader, stdout io.Writer) error {
+    var fs *flag.FlagSet = flag.NewFlagSet("verify", flag.ContinueOnError)
+    var kf keyFlags
+    kf.register(fs)
+    var code string
+    var window int
+    fs.StringVar(&code, "code", "", "the code to check")
+    fs.IntVar(&window, "window", 1, "TOTP steps either side, or HOTP counters ahead, to accept")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    if (code == "") {
+        return errors.New("--code is required")
+    }
+
+    var hotp bool = isSet(fs, "counter")
+    var k, err = kf.key(stdin, hotp)
+    if (err != nil) {
+        return err
+    }
+    k.Skew = max(window, 0)
+
+    if err = k.Validate(code); errors.Is(err, otp.ErrCodeMismatch) {
+        return errInvalid
+    } else if (err != nil) {
+        return err
+    }
+    if (hotp) {
+        // Validate has moved the counter past the one that matched
+        fmt.Fprintln(stdout, k.Counter - 1)
+    }
+    return nil
+}
+
+const usage string = `usage:
+    otp totp   --secret BASE32 [--digits N] [--algorithm SHA1] [--period 30s] [--watch] [--ntp SERVER]
+    otp hotp   --secret BASE32 --counter N [--digits N] [--algorithm SHA1]
+    otp verify --secret BASE32 --code CODE [--counter N] [--window N] [--ntp SERVER]
+    otp steam  --secret BASE64 [--ntp SERVER]
+    otp clock  [--server HOST]
+    otp add    NAME --uri otpauth://... | --secret BASE32 [--hotp] [--force]
+    otp code   NAME [--ntp SERVER]
+    otp list
+    otp rm     NAME
+`
+
+// run dispatches to a subcommand and returns the exit status
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+    if (len(args) < 1) {
+        fmt.Fprint(stderr, usage)
+        return 2
+    }
+
+    var err error
+    switch args[0] {
+    case "totp":
+        err = runTOTP(args[1:], stdin, stdout)
+    case "hotp":
+        err = runHOTP(args[1:], stdin, stdout)
+    case "verify":
+        err = runVerify(args[1:], stdin, stdout)
+    case "steam":
+        err = runSteam(args[1:], stdin, stdout)
+    case "clock":
+        err = runClock(args[1:], stdout)
+    case "add":
+        err = runAdd(args[1:], stdin, stdout, stderr)
+    case "code":
+        err = runCode(args[1:], stdin, stdout, stderr)
+    case "list", "ls":
+        err = runList(args[1:], stdin, stdout, stderr)
+    case "rm", "remove":
+        err = runRemove(args[1:], stdin, stdout, stderr)
+    case "help", "-h", "--help":
+        fmt.Fprint(stdout, usage)
+        return 0
+    default:
+        fmt.Fprintf(stderr, "otp: unknown command %q\n%s", args[0], usage)
+        return 2
+    }
+
+    switch {
+    case err == nil:
+        return 0
+    case errors.Is(err, errInvalid):
+        return 1
+    case errors.Is(err, flag.ErrHelp):
+        return 0
+    default:
+        fmt.Fprintln(stderr, "otp:", err)
+        return 2
+    }
+}
+
+func main() {
+    os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+    "bufio"
+    "errors"
+    "flag"
+    "io"
+    "strings"
+
+    otp "github.com/adam-good/OTP"
+)
+
+/*
+    runSteam prints a Steam Guard code
+
+    The secret is the base64 shared_secret from a Steam authenticator's .maFile, not
+    Base32 like the other commands take.
+*/
+func runSteam(args []string, stdin io.Reader, stdout io.Writer) error {
+    var fs *flag.FlagSet = flag.NewFlagSet("steam", flag.ContinueOnError)
+    var encoded, server string
+    fs.StringVar(&encoded, "secret", "", "the shared_secret in base64, or - to read it from stdin")
+    fs.StringVar(&server, "ntp", "", "NTP server to take the time from, instead of the local clock")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+
+    if (encoded == "-") {
+        var line, err = bufio.NewReader(stdin).ReadString('\n')
+        if (err != nil && err != io.EOF) {
+            return err
+        }
+        encoded = strings.TrimSpace(line)
+    }
+    if (encoded == "") {
+        return errors.New("--secret is required")
+    }
+    var secret, err = otp.DecodeBase64(encoded)
+    if (err != nil) {
+        return err
+    }
+
+    var key otp.SteamKey = otp.SteamKey{Secret: secret}
+    if (server != "") {
+        if key.Opts.Clock, err = ntpClock(server); err != nil {
+            return err
+        }
+    }
+    return printCode(stdout, key)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+)
+
+/*
+    The account store
+
+    Named accounts live in a vault (see the vault package), by default accounts.json
+    under the user's config directory or wherever OTP_STORE or --store says. The
+    passphrase comes from OTP_PASSPHRASE or is asked for on the terminal.
+
+    A store of "keyring" uses the system keychain instead, with no passphrase.
+*/
+
+// keyringStore is the store name that means the system keychain
+const keyringStore string = "keyring"
+
+// defaultStorePath is OTP_STORE or accounts.json in the user's config directory
+func defaultStorePath() string {
+    if p := os.Getenv("OTP_STORE"); p != "" {
+        return p
+    }
+    var dir, err = os.UserConfigDir()
+    if (err != nil) {
+        return "otp-accounts.json"
+    }
+    return filepath.Join(dir, "otp", "accounts.json")
+}
+
+// readPassphrase returns OTP_PASSPHRASE or asks for the passphrase, with echo off if stdin is a terminal
+func readPassphrase(stdin io.Reader, stderr io.Writer) (string, error) {
+    if p, ok := os.LookupEnv("OTP_PASSPHRASE"); ok {
+        return p, nil
+    }
+    fmt.Fprint(stderr, "passphrase: ")
+    if (stdin == os.Stdin && isTerminal(os.Stdin)) {
+        // there's no portable way to turn echo off in the standard library
+        var off *exec.Cmd = exec.Command("stty", "-echo")
+        off.Stdin = os.Stdin
+        if off.Run() == nil {
+            defer func() {
+                var on *exec.Cmd = exec.Command("stty", "echo")
+                on.Stdin = os.Stdin
+                on.Run()
+                fmt.Fprintln(stderr)
+            }()
+        }
+    }
+    var line, err = bufio.NewReader(stdin).ReadString('\n')
+    if (err != nil && err != io.EOF) {
+        return "", err
+    }
+    return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,166 @@
+/*
+    Command otpd is an HTTP JSON service for enrolling and verifying one-time passwords
+
+    It lets services that aren't written in Go use this package over HTTP:
+
+        POST /v1/enroll         {"user": "alice", "issuer": "Example", "account": "alice@example.com"}
+                                -> {"user": "alice", "uri": "otpauth://...", "secret": "BASE32"}
+        POST /v1/provision-uri  {"user": "alice", "qr": true}
+                                -> {"uri": "otpauth://...", "qr_png": "base64 PNG"}
+        POST /v1/verify         {"user": "alice", "code": "123456"}
+                                -> {"valid": true}
+        POST /v1/resync         {"user": "alice", "codes": ["123456", "654321"]}
+                                -> {"valid": true}
+
+    enroll also takes "type" ("totp" or "hotp"), "digits", "algorithm", "period" (in
+    seconds) and "replace", to overwrite an existing key. Errors come back as
+    {"error": "..."} with a 4xx or 5xx status; a wrong code is not an error, just
+    "valid": false. After --max-attempts wrong codes a user is locked out and verify
+    answers 429 with a Retry-After header until the lockout ends. Each user's TOTP
+    clock drift is remembered, so a phone that's always a step or two behind keeps
+    working without a wider --skew. resync is for HOTP tokens that got too far ahead:
+    it looks for the codes, consecutive, up to 1000 counters on and moves the counter
+    past them.
+
+    Keys are kept in a vault file (--store, passphrase from OTP_PASSPHRASE), the
+    system keychain (--store keyring), Redis (--store redis://host:6379/0), which
+    is what to use when running more than one instance, or only in memory (--store
+    memory) for trying things out. Set OTPD_TOKEN to require an
+    "Authorization: Bearer TOKEN" header on every request.
+
+    --audit appends a JSON line for every verification (user, result, algorithm, how
+    far off the code was and the client's address) to a file.
+
+    GET /metrics serves Prometheus metrics: verifications by result, enrolments,
+    lockouts and latency histograms. It's behind the bearer token too, when there is
+    one.
+*/
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "log"
+    "net/http"
+    "net/url"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+
+    otp "github.com/adam-good/OTP"
+    "github.com/adam-good/OTP/keyring"
+    "github.com/adam-good/OTP/memstore"
+    "github.com/adam-good/OTP/redisstore"
+    "github.com/adam-good/OTP/vault"
+)
+
+// stateStore keeps the used codes, failed attempts and clock drift
+type stateStore interface {
+    otp.ReplayStore
+    otp.LockoutStore
+    otp.DriftStore
+}
+
+/*
+    openStore opens the key store named by path
+
+    A redis:// URL is also used for replay protection, lockouts and drift, so every
+    instance behind a load balancer sees the same used codes and failures; otherwise they're
+    tracked in memory.
+*/
+func openStore(path string) (otp.KeyStore, stateStore, error) {
+    switch path {
+    case "memory":
+        var store *memstore.Store = memstore.New(memstore.Options{})
+        return store, store, nil
+    case "keyring":
+        return keyring.New("otpd"), memstore.New(memstore.Options{}), nil
+    }
+    if (strings.HasPrefix(path, "redis://")) {
+        var u, err = url.Parse(path)
+        if (err != nil) {
+            return nil, nil, err
+        }
+        var opts redisstore.Options
+        opts.Password, _ = u.User.Password()
+        if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+            if opts.DB, err = strconv.Atoi(db); err != nil {
+                return nil, nil, fmt.Errorf("bad redis database %q", db)
+            }
+        }
+        var store *redisstore.Store = redisstore.New(u.Host, opts)
+        return store, store, store.Ping()
+    }
+    var passphrase, ok = os.LookupEnv("OTP_PASSPHRASE")
+    if (!ok) {
+        return nil, nil, errors.New("OTP_PASSPHRASE must be set to open a vault")
+    }
+    var v, err = vault.OpenOrCreate(path, passphrase)
+    return v, memstore.New(memstore.Options{}), err
+}
+
+func main() {
+    var listen, path, issuer, auditPath string
+    var skew, maxAttempts int
+    var lockout time.Duration
+    flag.StringVar(&listen, "listen", ":8080", "address to listen on")
+    flag.StringVar(&path, "store", "otpd.vault", "vault file for the keys, \"keyring\" for the system keychain, redis://[:password@]host:port[/db] or \"memory\"")
+    flag.StringVar(&issuer, "issuer", "", "issuer for keys enrolled without one")
+    flag.IntVar(&skew, "skew", 1, "TOTP steps either side of now, or HOTP counters ahead, to accept")
+    flag.IntVar(&maxAttempts, "max-attempts", 5, "wrong codes within 15 minutes before a user is locked out, 0 for no lockout")
+    flag.DurationVar(&lockout, "lockout", time.Minute, "how long the first lockout lasts, doubling each time up to an hour")
+    flag.StringVar(&auditPath, "audit", "", "file to append a JSON line to for every verification, \"-\" for stdout")
+    flag.Parse()
+
+    // refuse to hand out or check codes if this build gets the RFC vectors wrong
+    if err := otp.SelfTest(); err != nil {
+        fmt.Fprintln(os.Stderr, "otpd:", err)
+        os.Exit(1)
+    }
+
+    var store, state, err = openStore(path)
+    if (err != nil) {
+        fmt.Fprintln(os.Stderr, "otpd:", err)
+        os.Exit(1)
+    }
+
+    var s *server = newServer(store, state, issuer, skew, os.Getenv("OTPD_TOKEN"))
+    s.metrics = newMetrics()
+    otp.SetObserver(s.metrics)
+    switch auditPath {
+    case "":
+    case "-":
+        s.audit = otp.NewJSONAudit(os.Stdout)
+    default:
+        var f *os.File
+        if f, err = os.OpenFile(auditPath, os.O_WRONLY | os.O_APPEND | os.O_CREATE, 0600); err != nil {
+            fmt.Fprintln(os.Stderr, "otpd:", err)
+            os.Exit(1)
+        }
+        s.audit = otp.NewJSONAudit(f)
+    }
+    if (maxAttempts > 0) {
+        s.lockout = &otp.LockoutPolicy{
+            Store:          state,
+            MaxAttempts:    maxAttempts,
+            Duration:       lockout,
+            OnLockout:      func(user string, until time.Time, lockouts int) {
+                log.Printf("locked out %q until %s (lockout %d)", user, until.Format(time.RFC3339), lockouts)
+            },
+            OnReplay:       func(user string) {
+                log.Printf("replayed code for %q", user)
+            },
+        }
+    }
+    var httpServer *http.Server = &http.Server{
+        Addr:               listen,
+        Handler:            s.routes(),
+        ReadHeaderTimeout:  10 * time.Second,
+        ReadTimeout:        30 * time.Second,
+        WriteTimeout:       30 * time.Second,
+    }
+    log.Printf("otpd listening on %s", listen)
+    log.Fatal(httpServer.ListenAndServe())
+}
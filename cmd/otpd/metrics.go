@@ -0,0 +1,148 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "net/http"
+    "sort"
+    "strconv"
+    "sync"
+    "time"
+
+    otp "github.com/adam-good/OTP"
+)
+
+// latencyBuckets are the histogram upper bounds in seconds, from an in-memory check to a slow store
+var latencyBuckets []float64 = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// histogram counts observations into latencyBuckets
+type histogram struct {
+    counts  []uint64    // per bucket, not cumulative
+    sum     float64
+    count   uint64
+}
+
+func (h *histogram) observe(d time.Duration) {
+    if (h.counts == nil) {
+        h.counts = make([]uint64, len(latencyBuckets))
+    }
+    var seconds float64 = d.Seconds()
+    for i, le := range(latencyBuckets) {
+        if (seconds <= le) {
+            h.counts[i]++
+            break
+        }
+    }
+    h.sum += seconds
+    h.count++
+}
+
+// write prints h in the Prometheus text format under name with the given labels ("" for none)
+func (h *histogram) write(w io.Writer, name string, labels string) {
+    var sep string = ""
+    if (labels != "") {
+        sep = ","
+    }
+    var cumulative uint64
+    for i, le := range(latencyBuckets) {
+        if (h.counts != nil) {
+            cumulative += h.counts[i]
+        }
+        fmt.Fprintf(w, "%s_bucket{%s%sle=\"%s\"} %d\n", name, labels, sep, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+    }
+    fmt.Fprintf(w, "%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, h.count)
+    if (labels != "") {
+        labels = "{" + labels + "}"
+    }
+    fmt.Fprintf(w, "%s_sum%s %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+    fmt.Fprintf(w, "%s_count%s %d\n", name, labels, h.count)
+}
+
+/*
+    metrics is an otp.Observer that keeps Prometheus metrics
+
+    Verifications are counted by result: "valid", "invalid" for a wrong code,
+    "replayed", "locked_out" and "error" when the store or key failed. It's written
+    out by hand in the text exposition format so otpd needs no client library.
+*/
+type metrics struct {
+    mu              sync.Mutex
+    verifications   map[string]uint64
+    verifyLatency   map[string]*histogram   // by result
+    enrollments     uint64
+    lockouts        uint64
+}
+
+func newMetrics() *metrics {
+    return &metrics{verifications: map[string]uint64{}, verifyLatency: map[string]*histogram{}}
+}
+
+func (m *metrics) verified(err error, took time.Duration) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    var result string = string(otp.VerifyResultOf(err))
+    m.verifications[result]++
+    if (m.verifyLatency[result] == nil) {
+        m.verifyLatency[result] = &histogram{}
+    }
+    m.verifyLatency[result].observe(took)
+}
+
+func (m *metrics) OnVerifySuccess(user string, took time.Duration) {
+    m.verified(nil, took)
+}
+
+func (m *metrics) OnVerifyFailure(user string, err error, took time.Duration) {
+    m.verified(err, took)
+}
+
+// OnGenerate is ignored, otpd never hands out codes
+func (m *metrics) OnGenerate(took time.Duration) {}
+
+func (m *metrics) OnLockout(user string, until time.Time) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.lockouts++
+}
+
+// enrolled counts a successful enrolment, which the package itself doesn't see
+func (m *metrics) enrolled() {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.enrollments++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+    var results []string
+    for result := range(m.verifications) {
+        results = append(results, result)
+    }
+    sort.Strings(results)
+
+    fmt.Fprintln(w, "# HELP otpd_verifications_total Code verifications by result.")
+    fmt.Fprintln(w, "# TYPE otpd_verifications_total counter")
+    for _, result := range(results) {
+        fmt.Fprintf(w, "otpd_verifications_total{result=%q} %d\n", result, m.verifications[result])
+    }
+    fmt.Fprintln(w, "# HELP otpd_verification_duration_seconds How long verifications took, store included.")
+    fmt.Fprintln(w, "# TYPE otpd_verification_duration_seconds histogram")
+    for _, result := range(results) {
+        m.verifyLatency[result].write(w, "otpd_verification_duration_seconds", fmt.Sprintf("result=%q", result))
+    }
+
+    fmt.Fprintln(w, "# HELP otpd_enrollments_total Users enrolled or re-enrolled.")
+    fmt.Fprintln(w, "# TYPE otpd_enrollments_total counter")
+    fmt.Fprintf(w, "otpd_enrollments_total %d\n", m.enrollments)
+    fmt.Fprintln(w, "# HELP otpd_lockouts_total Users locked out after too many failed attempts.")
+    fmt.Fprintln(w, "# TYPE otpd_lockouts_total counter")
+    fmt.Fprintf(w, "otpd_lockouts_total %d\n", m.lockouts)
+}
+
+var _ otp.Observer = (*metrics)(nil)
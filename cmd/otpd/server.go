@@ -0,0 +1,403 @@
+package main
+
+import (
+    "crypto/subtle"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    otp "github.com/adam-good/OTP"
+)
+
+// maxBody caps request bodies, nothing here needs more than a few hundred bytes
+const maxBody int64 = 64 << 10
+
+// server holds what the handlers share
+type server struct {
+    store   otp.KeyStore
+    replay  otp.ReplayStore     // where used TOTP codes are recorded
+    drift   otp.DriftStore      // where users' clock drift is recorded, nil for not at all
+    lockout *otp.LockoutPolicy  // nil means users are never locked out
+    metrics *metrics            // served on /metrics if set
+    audit   otp.AuditSink       // where verifications are recorded, nil for nowhere
+    issuer  string
+    skew    int
+    token   string
+
+    // mu serializes changes to a key (HOTP counters, enrolment)
+    mu      sync.Mutex
+}
+
+func newServer(store otp.KeyStore, state stateStore, issuer string, skew int, token string) *server {
+    return &server{store: store, replay: state, drift: state, issuer: issuer, skew: skew, token: token}
+}
+
+// routes returns the service's handler
+func (s *server) routes() http.Handler {
+    var mux *http.ServeMux = http.NewServeMux()
+    mux.HandleFunc("/v1/enroll", post(s.handleEnroll))
+    mux.HandleFunc("/v1/provision-uri", post(s.handleProvisionURI))
+    mux.HandleFunc("/v1/verify", post(s.handleVerify))
+    mux.HandleFunc("/v1/resync", post(s.handleResync))
+    if (s.metrics != nil) {
+        mux.Handle("/metrics", s.metrics)
+    }
+    return s.authorize(mux)
+}
+
+// post only lets POST requests through to h
+func post(h http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if (r.Method != http.MethodPost) {
+            w.Header().Set("Allow", http.MethodPost)
+            writeError(w, http.StatusMethodNotAllowed, "use POST")
+            return
+        }
+        h(w, r)
+    }
+}
+
+// authorize checks the bearer token if one is configured
+func (s *server) authorize(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if (s.token != "") {
+            var given, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+            if (subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) != 1) {
+                writeError(w, http.StatusUnauthorized, "missing or wrong bearer token")
+                return
+            }
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// readJSON decodes the request body into v, writing a 400 and returning false if it can't
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+    var dec *json.Decoder = json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody))
+    dec.DisallowUnknownFields()
+    if err := dec.Decode(v); err != nil {
+        writeError(w, http.StatusBadRequest, "bad request body: " + err.Error())
+        return false
+    }
+    return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    var enc *json.Encoder = json.NewEncoder(w)
+    // URIs are full of &, leave them readable
+    enc.SetEscapeHTML(false)
+    enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+    writeJSON(w, status, map[string]string{"error": message})
+}
+
+// storeError reports a failure from the store, 404 for an unknown user
+func storeError(w http.ResponseWriter, user string, err error) {
+    if (errors.Is(err, otp.ErrKeyNotFound)) {
+        writeError(w, http.StatusNotFound, fmt.Sprintf("no key enrolled for %q", user))
+        return
+    }
+    log.Printf("store: %v", err)
+    writeError(w, http.StatusInternalServerError, "store error")
+}
+
+type enrollRequest struct {
+    User        string  `json:"user"`
+    Issuer      string  `json:"issuer"`
+    Account     string  `json:"account"`
+    Type        string  `json:"type"`
+    Digits      int     `json:"digits"`
+    Algorithm   string  `json:"algorithm"`
+    Period      int     `json:"period"`
+    Replace     bool    `json:"replace"`
+}
+
+type enrollResponse struct {
+    User    string  `json:"user"`
+    URI     string  `json:"uri"`
+    Secret  string  `json:"secret"`
+}
+
+// options turns the request into key options and its algorithm, or an error to show the client
+func (req enrollRequest) options(defaultIssuer string) ([]otp.Option, otp.Algorithm, error) {
+    var opts []otp.Option
+    var algo otp.Algorithm = otp.SHA1
+    var issuer string = req.Issuer
+    if (issuer == "") {
+        issuer = defaultIssuer
+    }
+    var account string = req.Account
+    if (account == "") {
+        account = req.User
+    }
+    opts = append(opts, otp.WithIssuer(issuer), otp.WithAccount(account))
+
+    if (req.Digits != 0) {
+        opts = append(opts, otp.WithDigits(req.Digits))
+    }
+    if (req.Algorithm != "") {
+        var ok bool
+        if algo, ok = otp.ParseAlgorithm(req.Algorithm); !ok {
+            return nil, algo, fmt.Errorf("unknown algorithm %q", req.Algorithm)
+        }
+        opts = append(opts, otp.WithAlgorithm(algo))
+    }
+    switch strings.ToLower(req.Type) {
+    case "", "totp":
+        if (req.Period != 0) {
+            opts = append(opts, otp.WithPeriod(time.Duration(req.Period) * time.Second))
+        }
+    case "hotp":
+        opts = append(opts, otp.WithHOTP(0))
+    default:
+        return nil, algo, fmt.Errorf("unknown type %q", req.Type)
+    }
+    return opts, algo, nil
+}
+
+func (s *server) handleEnroll(w http.ResponseWriter, r *http.Request) {
+    var req enrollRequest
+    if (!readJSON(w, r, &req)) {
+        return
+    }
+    if (req.User == "") {
+        writeError(w, http.StatusBadRequest, "user is required")
+        return
+    }
+    var opts, algo, err = req.options(s.issuer)
+    if (err != nil) {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    // a secret as long as the hash output, as RFC 4226 recommends
+    var secret []byte
+    if secret, err = otp.GenerateSecret(otp.SecretLength(algo)); err != nil {
+        writeError(w, http.StatusInternalServerError, "couldn't generate a secret")
+        return
+    }
+    var k *otp.Key
+    if k, err = otp.NewKey(secret, opts...); err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    var uri string
+    if uri, err = k.URI(); err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if _, err = s.store.Get(req.User); err == nil && !req.Replace {
+        writeError(w, http.StatusConflict, fmt.Sprintf("%q is already enrolled, set replace to overwrite", req.User))
+        return
+    }
+    if err = s.store.Put(req.User, k); err != nil {
+        storeError(w, req.User, err)
+        return
+    }
+    if (s.metrics != nil) {
+        s.metrics.enrolled()
+    }
+    writeJSON(w, http.StatusCreated, enrollResponse{User: req.User, URI: uri, Secret: otp.EncodeBase32(k.Secret)})
+}
+
+type provisionRequest struct {
+    User    string  `json:"user"`
+    QR      bool    `json:"qr"`
+}
+
+type provisionResponse struct {
+    URI     string  `json:"uri"`
+    QRPNG   string  `json:"qr_png,omitempty"`
+}
+
+func (s *server) handleProvisionURI(w http.ResponseWriter, r *http.Request) {
+    var req provisionRequest
+    if (!readJSON(w, r, &req)) {
+        return
+    }
+    var k, err = s.store.Get(req.User)
+    if (err != nil) {
+        storeError(w, req.User, err)
+        return
+    }
+
+    var resp provisionResponse
+    if resp.URI, err = k.URI(); err != nil {
+        writeError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    if (req.QR) {
+        var png []byte
+        if png, err = k.QRCodePNG(); err != nil {
+            writeError(w, http.StatusInternalServerError, err.Error())
+            return
+        }
+        resp.QRPNG = base64.StdEncoding.EncodeToString(png)
+    }
+    writeJSON(w, http.StatusOK, resp)
+}
+
+type verifyRequest struct {
+    User    string  `json:"user"`
+    Code    string  `json:"code"`
+}
+
+type verifyResponse struct {
+    Valid   bool    `json:"valid"`
+}
+
+// auditFor returns the audit sink for a request from remoteAddr
+func (s *server) auditFor(remoteAddr string) otp.AuditSink {
+    if (s.audit == nil) {
+        return nil
+    }
+    return otp.AuditFunc(func(e otp.AuditEvent) {
+        e.RemoteAddr = remoteAddr
+        s.audit.Audit(e)
+    })
+}
+
+// validate checks code with v, so any kind of key otpd can store is checked the same way
+func validate(v otp.CodeValidator, code string) error {
+    return v.ValidateCode(code)
+}
+
+/*
+    verify checks code for user
+
+    TOTP codes are marked used in the replay store and HOTP keys are saved with their
+    counter moved on, so no code works twice. Wrong and replayed codes count towards
+    the lockout policy.
+*/
+func (s *server) verify(user string, code string, remoteAddr string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    var k, err = s.store.Get(user)
+    if (err != nil) {
+        return err
+    }
+    k.Skew = s.skew
+    k.Lockout, k.User = s.lockout, user
+    k.Audit = s.auditFor(remoteAddr)
+
+    if (k.Type == otp.TypeHOTP) {
+        // with a shared store the lock above isn't enough, other instances move counters too
+        if updater, ok := s.store.(otp.KeyUpdater); ok {
+            return updater.Update(user, func(k *otp.Key) error {
+                k.Skew = s.skew
+                k.Lockout, k.User = s.lockout, user
+                k.Audit = s.auditFor(remoteAddr)
+                return validate(k, code)
+            })
+        }
+        if err = validate(k, code); err != nil {
+            return err
+        }
+        return s.store.Put(user, k)
+    }
+
+    k.Replay, k.Drift = s.replay, s.drift
+    return validate(k, code)
+}
+
+func (s *server) handleVerify(w http.ResponseWriter, r *http.Request) {
+    var req verifyRequest
+    if (!readJSON(w, r, &req)) {
+        return
+    }
+    if (req.User == "" || req.Code == "") {
+        writeError(w, http.StatusBadRequest, "user and code are required")
+        return
+    }
+
+    var err error = s.verify(req.User, req.Code, r.RemoteAddr)
+    switch {
+    case errors.Is(err, otp.ErrLockedOut):
+        if until, _ := s.lockout.LockedUntil(req.User); !until.IsZero() {
+            w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(until).Seconds()) + 1))
+        }
+        writeError(w, http.StatusTooManyRequests, "too many failed attempts, try again later")
+    case err == nil:
+        writeJSON(w, http.StatusOK, verifyResponse{Valid: true})
+    case errors.Is(err, otp.ErrCodeMismatch), errors.Is(err, otp.ErrCodeReused), errors.Is(err, otp.ErrOutOfOrder):
+        writeJSON(w, http.StatusOK, verifyResponse{Valid: false})
+    default:
+        storeError(w, req.User, err)
+    }
+}
+
+type resyncRequest struct {
+    User    string      `json:"user"`
+    Codes   []string    `json:"codes"`
+}
+
+// resync fast-forwards user's HOTP counter to codes, see otp.Key.Resync
+func (s *server) resync(user string, codes []string, remoteAddr string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    var k, err = s.store.Get(user)
+    if (err != nil) {
+        return err
+    }
+    k.Lockout, k.User = s.lockout, user
+    k.Audit = s.auditFor(remoteAddr)
+    if (k.Type != otp.TypeHOTP) {
+        return otp.ErrNotHOTP
+    }
+
+    if updater, ok := s.store.(otp.KeyUpdater); ok {
+        return updater.Update(user, func(k *otp.Key) error {
+            k.Lockout, k.User = s.lockout, user
+            k.Audit = s.auditFor(remoteAddr)
+            return k.Resync(codes...)
+        })
+    }
+    if err = k.Resync(codes...); err != nil {
+        return err
+    }
+    return s.store.Put(user, k)
+}
+
+func (s *server) handleResync(w http.ResponseWriter, r *http.Request) {
+    var req resyncRequest
+    if (!readJSON(w, r, &req)) {
+        return
+    }
+    if (req.User == "") {
+        writeError(w, http.StatusBadRequest, "user is required")
+        return
+    }
+
+    var err error = s.resync(req.User, req.Codes, r.RemoteAddr)
+    switch {
+    case errors.Is(err, otp.ErrResyncCodes), errors.Is(err, otp.ErrNotHOTP):
+        writeError(w, http.StatusBadRequest, err.Error())
+    case errors.Is(err, otp.ErrLockedOut):
+        if until, _ := s.lockout.LockedUntil(req.User); !until.IsZero() {
+            w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(until).Seconds()) + 1))
+        }
+        writeError(w, http.StatusTooManyRequests, "too many failed attempts, try again later")
+    case err == nil:
+        writeJSON(w, http.StatusOK, verifyResponse{Valid: true})
+    case errors.Is(err, otp.ErrCodeMismatch):
+        writeJSON(w, http.StatusOK, verifyResponse{Valid: false})
+    default:
+        storeError(w, req.User, err)
+    }
+}
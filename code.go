@@ -0,0 +1,73 @@
+package otp
+
+import (
+    "strconv"
+    "strings"
+)
+
+/*
+    Code is a generated one-time password
+
+    Value is the number the user types, i.e. the truncated HMAC mod 10^Digits, and
+    Digits is how many digits it is shown with. Keep it as a Code (or its String) rather
+    than an int: as a number 012345 would lose its leading zero.
+
+    The zero Code has no digits and renders as ""; it's what the generators return when
+    they're given parameters they can't use, and it never matches anything.
+*/
+type Code struct {
+    Value   uint32
+    Digits  int
+}
+
+// String renders the code zero padded to Digits
+func (c Code) String() string {
+    var buf [16]byte
+    return string(c.appendTo(buf[:0]))
+}
+
+// appendTo is String appended to b, which doesn't allocate when b has room
+func (c Code) appendTo(b []byte) []byte {
+    if (c.Digits <= 0) {
+        return b
+    }
+    var digits [10]byte
+    var s []byte = strconv.AppendUint(digits[:0], uint64(c.Value), 10)
+    for i := len(s); i < c.Digits; i++ {
+        b = append(b, '0')
+    }
+    return append(b, s...)
+}
+
+/*
+    Group renders the code in groups of size digits joined by sep, for showing it to people
+
+    Group(3, " ") gives "123 456" and Group(4, "-") gives "1234-5678". Groups are counted
+    from the left, so a 7 digit code in threes is "123 456 7". Validation under the
+    default Lenient normalization strips spaces and dashes again, so a grouped code
+    that's typed or pasted back as shown is accepted. A size of 0 or less is String.
+*/
+func (c Code) Group(size int, sep string) string {
+    var s string = c.String()
+    if (size <= 0 || len(s) <= size) {
+        return s
+    }
+    var b strings.Builder
+    for i := 0; i < len(s); i += size {
+        if (i > 0) {
+            b.WriteString(sep)
+        }
+        b.WriteString(s[i:min(i + size, len(s))])
+    }
+    return b.String()
+}
+
+// Display renders the code in two halves split by a space the way authenticator apps do, "123 456" or "1234 5678"
+func (c Code) Display() string {
+    return c.Group((c.Digits + 1) / 2, " ")
+}
+
+// IsZero reports whether c is the zero Code
+func (c Code) IsZero() bool {
+    return c.Digits == 0
+}
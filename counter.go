@@ -0,0 +1,14 @@
+package otp
+
+/*
+    CounterStore keeps counters that may only go up
+
+    Tokens that put a counter in every code, like a YubiKey, are protected from replay
+    by refusing any code whose counter isn't past the last one seen. AdvanceCounter
+    records counter for name if it's greater than what's recorded (anything is greater
+    than a name that has never been seen) and reports whether it was. It must be atomic,
+    so two requests with the same code can't both advance it.
+*/
+type CounterStore interface {
+    AdvanceCounter(name string, counter uint64) (bool, error)
+}
@@ -0,0 +1,65 @@
+package otp
+
+import (
+    "crypto/hmac"
+    "crypto/sha1"
+    "encoding/binary"
+    "fmt"
+    "time"
+)
+
+/*
+    referenceTOTP is an independent RFC 6238 implementation on top of crypto/hmac
+
+    It only exists to check this package against: HMAC-SHA1, 30 second steps, the
+    counter as 8 big-endian bytes and RFC 4226 dynamic truncation to 6 digits.
+*/
+func referenceTOTP(secret []byte, t time.Time) string {
+    var msg []byte = make([]byte, 8)
+    binary.BigEndian.PutUint64(msg, uint64(t.Unix() / 30))
+
+    var mac = hmac.New(sha1.New, secret)
+    mac.Write(msg)
+    var sum []byte = mac.Sum(nil)
+
+    var offset int = int(sum[len(sum)-1] & 0x0F)
+    var value uint32 = binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7FFFFFFF
+
+    return fmt.Sprintf("%06d", value % 1000000)
+}
+
+// crossCheck returns our code and the reference code for the same secret and time
+func crossCheck(secret []byte, t time.Time) (ours, reference string, err error) {
+    var code Code
+    if code, err = TOTPAt(secret, t); err != nil {
+        return "", "", err
+    }
+    return code.String(), referenceTOTP(secret, t), nil
+}
+
+/*
+    CrossCheckSelfTest compares TOTP against the reference implementation
+
+    It uses the RFC 6238 test secret over a spread of timestamps (including the RFC's own
+    test times) and returns an error describing the first one where the two disagree.
+*/
+func CrossCheckSelfTest() error {
+    var secret []byte = []byte("12345678901234567890")
+    var times []int64 = []int64{59, 1111111109, 1111111111, 1234567890, 2000000000, 20000000000}
+    // plus one time per step for a day's worth of steps
+    for i := int64(0); i < 2880; i++ {
+        times = append(times, 1700000000 + i * 30)
+    }
+
+    for _, unix := range(times) {
+        var t time.Time = time.Unix(unix, 0)
+        var ours, reference, err = crossCheck(secret, t)
+        if (err != nil) {
+            return fmt.Errorf("otp: cross check failed at %d: %w", unix, err)
+        }
+        if (ours != reference) {
+            return fmt.Errorf("otp: cross check failed at %d: got %s, reference %s", unix, ours, reference)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,52 @@
+package otp
+
+import (
+    "time"
+)
+
+/*
+    Detecting a key's parameters
+
+    Moving keys between systems sometimes loses everything but the secret: an export
+    that only kept Base32, or a vendor that never said which hash its tokens use. With
+    one code that's known to be right and when it was shown, the few settings that
+    are ever used can simply be tried.
+*/
+
+// detectAlgorithms and detectPeriods are what DetectParameters tries, most common first
+var (
+    detectAlgorithms    []Algorithm     = []Algorithm{SHA1, SHA256, SHA512}
+    detectPeriods       []time.Duration = []time.Duration{30 * time.Second, 60 * time.Second, 15 * time.Second, 90 * time.Second, 120 * time.Second}
+)
+
+/*
+    DetectParameters finds the TOTP settings that give code for secret at time t
+
+    A diagnostic for operators: digits is the length of the code and every RFC 6238
+    algorithm is tried with the periods apps use (30, 60, 15, 90 and 120 seconds),
+    Unix epoch T0 and no skew, so t has to be the time the code was current. The
+    first combination that matches is returned, the most common settings first, and
+    ok is false when none does. A wrong combination matches a 6 digit code by chance
+    about once in 70 000 tries, so check what it finds against a second code before
+    relying on it.
+*/
+func DetectParameters(secret []byte, code string, t time.Time) (algo Algorithm, digits int, period time.Duration, ok bool) {
+    var normalized string
+    if normalized, ok = Normalize(code, Lenient); !ok {
+        return SHA1, 0, 0, false
+    }
+    digits = len(normalized)
+    if (len(secret) == 0 || digits < MinDigits || digits > MaxDigits) {
+        return SHA1, 0, 0, false
+    }
+    for _, algo = range(detectAlgorithms) {
+        for _, period = range(detectPeriods) {
+            // settings strict mode refuses just don't match
+            var expected, err = generateHOTP(secret, uint64(timeStepFrom(t, time.Time{}, period)), digits, algo, Truncation{})
+            if (err == nil && codeMatches(expected, normalized, Strict)) {
+                return algo, digits, period, true
+            }
+        }
+    }
+    return SHA1, 0, 0, false
+}
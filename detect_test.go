@@ -0,0 +1,44 @@
+package otp
+
+import (
+    "testing"
+    "time"
+)
+
+func TestDetectParameters(t *testing.T) {
+    var sha256Secret []byte = []byte("12345678901234567890123456789012")
+    var sha512Secret []byte = []byte("1234567890123456789012345678901234567890123456789012345678901234")
+    var at time.Time = time.Unix(1111111109, 0)
+
+    var tests = []struct {
+        name    string
+        secret  []byte
+        digits  int
+        algo    Algorithm
+        period  time.Duration
+    }{
+        {"SHA-256, 8 digits, 60s", sha256Secret, 8, SHA256, 60 * time.Second},
+        {"SHA-1, 6 digits, 30s", RFC4226Secret, 6, SHA1, 30 * time.Second},
+        {"SHA-512, 8 digits, 30s", sha512Secret, 8, SHA512, 30 * time.Second},
+        {"SHA-1, 7 digits, 15s", RFC4226Secret, 7, SHA1, 15 * time.Second},
+    }
+    for _, tt := range(tests) {
+        t.Run(tt.name, func(t *testing.T) {
+            var code, err = GenerateTOTPAt(tt.secret, at, tt.digits, tt.algo, tt.period, time.Time{})
+            if (err != nil) {
+                t.Fatal(err)
+            }
+            var algo, digits, period, ok = DetectParameters(tt.secret, code.String(), at)
+            if (!ok || algo != tt.algo || digits != tt.digits || period != tt.period) {
+                t.Errorf("detected %v, %d digits, %v, %v for %s", algo, digits, period, ok, code)
+            }
+        })
+    }
+
+    if _, _, _, ok := DetectParameters(RFC4226Secret, "12345", at); ok {
+        t.Error("detected parameters for a 5 digit code")
+    }
+    if _, _, _, ok := DetectParameters(RFC4226Secret, "000000", at); ok {
+        t.Error("detected parameters for a code nothing generates")
+    }
+}
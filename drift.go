@@ -0,0 +1,28 @@
+package otp
+
+/*
+    DriftStore remembers how far each user's authenticator clock is off
+
+    Phone clocks drift, and one that's consistently a minute slow only works with a
+    skew that lets everyone's codes be a minute old. With a DriftStore in ValidateOpts
+    (or on a Key, see WithDrift) every successful validation records the offset, in
+    time steps, that the code matched at, and the next validation for that user checks
+    the Skew window around the recorded offset as well as the one around now. The
+    window stays the same size for everyone while a slow clock keeps working, and a
+    clock that gets fixed still matches around now.
+
+    Drift returns 0 for a user it knows nothing about. Offsets are capped at MaxDrift
+    steps either way.
+*/
+type DriftStore interface {
+    Drift(user string) (int64, error)
+    SetDrift(user string, steps int64) error
+}
+
+// MaxDrift is the furthest, in time steps, a user's recorded drift can move the window
+const MaxDrift int64 = 10
+
+// clampDrift keeps a drift within MaxDrift
+func clampDrift(steps int64) int64 {
+    return min(max(steps, -MaxDrift), MaxDrift)
+}
@@ -0,0 +1,118 @@
+package otp
+
+import (
+    "crypto/subtle"
+)
+
+/*
+    Encoder turns the truncated HOTP/TOTP value into the characters shown to the user
+
+    RFC 4226 takes the 31 bit truncated value mod 10^Digits, but other schemes render the
+    same value differently, e.g. Steam Guard uses a 26 character alphabet and YubiKey
+    style tokens use modhex. A Key or Generator writes its codes with the Encoder given
+    to WithEncoder, DecimalEncoder if there isn't one, and reads submitted codes back
+    the same way.
+*/
+type Encoder interface {
+    Encode(value uint32, length int) string
+}
+
+// isDecimal reports whether enc writes codes as RFC 4226 decimal digits, as nil does
+func isDecimal(enc Encoder) bool {
+    if (enc == nil) {
+        return true
+    }
+    var _, ok = enc.(DecimalEncoder)
+    return ok
+}
+
+/*
+    encodedMatches reports whether code is raw written with enc
+
+    For decimal it's codeMatches of expected, the code raw gives. Other encodings are
+    compared ignoring case, and spaces and dashes unless policy is Strict, in constant
+    time like codeMatches.
+*/
+func encodedMatches(enc Encoder, expected Code, raw uint32, code string, policy NormalizationPolicy) bool {
+    if (isDecimal(enc)) {
+        return codeMatches(expected, code, policy)
+    }
+    if (expected.IsZero()) {
+        return false
+    }
+    var want []byte = []byte(enc.Encode(raw, expected.Digits))
+    var got []byte = make([]byte, 0, len(want))
+    for i := 0; i < len(code); i++ {
+        var c byte = code[i]
+        if ((c == ' ' || c == '-') && policy != Strict) {
+            continue
+        }
+        got = append(got, asciiLower(c))
+    }
+    for i := range(want) {
+        want[i] = asciiLower(want[i])
+    }
+    return subtle.ConstantTimeCompare(want, got) == 1
+}
+
+// asciiLower is c in lower case if it's an ASCII letter
+func asciiLower(c byte) byte {
+    if (c >= 'A' && c <= 'Z') {
+        return c + 'a' - 'A'
+    }
+    return c
+}
+
+// DecimalEncoder is the RFC 4226 encoding: value mod 10^length, zero padded
+type DecimalEncoder struct{}
+
+// SteamEncoder is the Steam Guard encoding using its 26 character alphabet
+type SteamEncoder struct{}
+
+// ModHexEncoder is hex using the modhex alphabet from YubiKey OTPs
+type ModHexEncoder struct{}
+
+// HexEncoder is the low length hex digits of value, zero padded
+type HexEncoder struct{}
+
+const (
+    steamAlphabet   string = "23456789BCDFGHJKMNPQRTVWXY"
+    modhexAlphabet  string = "cbdefghijklnrtuv"
+    hexAlphabet     string = "0123456789abcdef"
+)
+
+// encodeBase writes the low length digits of value in base len(alphabet), most significant first
+func encodeBase(value uint32, length int, alphabet string) string {
+    var base uint32 = uint32(len(alphabet))
+    var out []byte = make([]byte, length)
+    for i := length - 1; i >= 0; i-- {
+        out[i] = alphabet[value % base]
+        value /= base
+    }
+    return string(out)
+}
+
+func (DecimalEncoder) Encode(value uint32, length int) string {
+    return encodeBase(value, length, "0123456789")
+}
+
+/*
+    Steam Guard codes are built least significant character first: the first character
+    is value mod 26, then value is divided by 26 and so on.
+*/
+func (SteamEncoder) Encode(value uint32, length int) string {
+    var out []byte = make([]byte, length)
+    for i := 0; i < length; i++ {
+        out[i] = steamAlphabet[value % uint32(len(steamAlphabet))]
+        value /= uint32(len(steamAlphabet))
+    }
+    return string(out)
+}
+
+func (ModHexEncoder) Encode(value uint32, length int) string {
+    return encodeBase(value, length, modhexAlphabet)
+}
+
+func (HexEncoder) Encode(value uint32, length int) string {
+    return encodeBase(value, length, hexAlphabet)
+}
@@ -0,0 +1,92 @@
+package otp
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestEncoders(t *testing.T) {
+    // at 59 the RFC 4226 secret's truncated value is 1094287082, 0x41397eea
+    var at time.Time = time.Unix(59, 0)
+    var tests = []struct {
+        name    string
+        enc     Encoder
+        digits  int
+        want    string
+    }{
+        {"default", nil, 6, "287082"},
+        {"decimal", DecimalEncoder{}, 6, "287082"},
+        {"decimal 8", DecimalEncoder{}, 8, "94287082"},
+        {"steam", SteamEncoder{}, 6, "PV9M4J"},
+        {"modhex", ModHexEncoder{}, 6, "ekiuul"},
+        {"hex", HexEncoder{}, 6, "397eea"},
+        {"hex 8", HexEncoder{}, 8, "41397eea"},
+    }
+    for _, tt := range(tests) {
+        t.Run(tt.name, func(t *testing.T) {
+            var k, err = NewKey(RFC4226Secret, WithDigits(tt.digits), WithEncoder(tt.enc))
+            if (err != nil) {
+                t.Fatal(err)
+            }
+            var got string
+            if got, err = k.GenerateStringAt(at); err != nil || got != tt.want {
+                t.Errorf("Key.GenerateStringAt: %q, %v, want %q", got, err, tt.want)
+            }
+            var g *Generator
+            if g, err = NewGenerator(k); err != nil {
+                t.Fatal(err)
+            }
+            if got, err = g.GenerateStringAt(at); err != nil || got != tt.want {
+                t.Errorf("Generator.GenerateStringAt: %q, %v, want %q", got, err, tt.want)
+            }
+
+            // the code reads back, whatever its case, and the decimal code doesn't unless that's the encoding
+            if err = k.ValidateAt(tt.want, at); err != nil {
+                t.Errorf("Key.ValidateAt(%s): %v", tt.want, err)
+            }
+            if _, err = g.ValidateAt(tt.want, at); err != nil {
+                t.Errorf("Generator.ValidateAt(%s): %v", tt.want, err)
+            }
+            var decimal Code
+            if decimal, err = k.GenerateAt(at); err != nil {
+                t.Fatal(err)
+            }
+            if err = k.ValidateAt(decimal.String(), at); (err == nil) != isDecimal(tt.enc) {
+                t.Errorf("Key.ValidateAt of the decimal code %s: %v", decimal, err)
+            }
+        })
+    }
+}
+
+func TestEncoderMatchesSteam(t *testing.T) {
+    // Steam writes the least significant character first, so its 5 character code
+    // starts the 6 character one
+    var k, err = NewKey(RFC4226Secret, WithEncoder(SteamEncoder{}))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    for _, unix := range([]int64{59, 1111111109, 1234567890, 2000000000}) {
+        var want, _ = GenerateSteamAt(RFC4226Secret, time.Unix(unix, 0))
+        if got, err := k.GenerateStringAt(time.Unix(unix, 0)); err != nil || got[:SteamDigits] != want {
+            t.Errorf("%d: %q, %v, want it to start with GenerateSteamAt's %q", unix, got, err, want)
+        }
+    }
+}
+
+func TestEncodedHOTP(t *testing.T) {
+    var k, err = NewKey(RFC4226Secret, WithHOTP(0), WithSkew(2), WithEncoder(HexEncoder{}))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    // RFC 4226 counter 1 truncates to 0x41397eea
+    if err = k.Validate("39 7E EA"); err != nil {
+        t.Fatalf("hex code for counter 1: %v", err)
+    }
+    if (k.Counter != 2) {
+        t.Errorf("counter %d, want 2", k.Counter)
+    }
+    if _, err = NewKey(RFC4226Secret, WithEncoder(HexEncoder{}), WithChecksum()); !errors.Is(err, ErrInvalidDigits) {
+        t.Errorf("checksum with hex codes: %v, want ErrInvalidDigits", err)
+    }
+}
@@ -0,0 +1,87 @@
+package otp
+
+import (
+    "errors"
+)
+
+/*
+    Errors returned by the package
+
+    They are sentinels so callers can branch on them with errors.Is.
+*/
+var (
+    // the secret is empty, nothing can be generated from it
+    ErrEmptySecret      = errors.New("otp: secret is empty")
+    // the secret is all one byte or produces repeating codes, see CheckSecretHealth
+    ErrDegenerateSecret = errors.New("otp: secret is degenerate")
+    // the secret is shorter than its algorithm needs, see CheckSecret
+    ErrWeakSecret       = errors.New("otp: secret is too short")
+    // the code length is outside MinDigits to MaxDigits
+    ErrInvalidDigits    = errors.New("otp: invalid number of digits")
+    // the TOTP period is less than a second
+    ErrInvalidPeriod    = errors.New("otp: invalid period")
+    // a secret couldn't be decoded from Base32, hex or base64
+    ErrInvalidEncoding  = errors.New("otp: invalid secret encoding")
+    // the algorithm isn't registered or its hash is too short for dynamic truncation
+    ErrInvalidAlgorithm = errors.New("otp: invalid algorithm")
+    // an otpauth:// URI couldn't be parsed, or a key can't be written as one
+    ErrInvalidURI       = errors.New("otp: invalid otpauth URI")
+    // the data doesn't fit in even the largest QR code
+    ErrQRTooLong        = errors.New("otp: data too long for a QR code")
+    // the key uses a setting Google Authenticator's migration format can't express
+    ErrNotMigratable    = errors.New("otp: key can't be exported to a migration payload")
+    // an authenticator app's backup file couldn't be read
+    ErrInvalidBackup    = errors.New("otp: invalid backup")
+    // an encrypted backup couldn't be decrypted with the password given
+    ErrWrongPassword    = errors.New("otp: wrong password")
+    // a KeyStore has no key under the name asked for
+    ErrKeyNotFound      = errors.New("otp: key not found")
+    // a binary key is malformed or in a format version this package doesn't know, see Key.UnmarshalBinary
+    ErrInvalidKeyData   = errors.New("otp: invalid binary key")
+    // the key's secret is only in its KeyProvider, so it can't be exported or stored, see NewProvidedKey
+    ErrProvidedKey      = errors.New("otp: key's secret is held by its provider")
+    // the submitted code doesn't match any acceptable code
+    ErrCodeMismatch     = errors.New("otp: code does not match")
+    // the submitted code matched but has already been used
+    ErrCodeReused       = errors.New("otp: code already used")
+    // the submitted HOTP code is for a counter that was already passed, see ValidateHOTPOrdered
+    ErrOutOfOrder       = errors.New("otp: code arrived out of order")
+    // the user failed too many times recently and has to wait, see LockoutPolicy
+    ErrLockedOut        = errors.New("otp: locked out after too many failed attempts")
+    // a LockoutPolicy was used without a Store to count failures in
+    ErrNoLockoutStore   = errors.New("otp: lockout policy has no store")
+    // an HOTP resync was given fewer than two codes, see ResyncHOTP
+    ErrResyncCodes      = errors.New("otp: resync needs at least two consecutive codes")
+    // the operation only makes sense for an HOTP key
+    ErrNotHOTP          = errors.New("otp: key is not HOTP")
+    // the operation only makes sense for a TOTP key
+    ErrNotTOTP          = errors.New("otp: key is not TOTP")
+    // an OCRA suite string couldn't be parsed, see ParseOCRASuite
+    ErrInvalidOCRASuite = errors.New("otp: invalid OCRA suite")
+    // an OCRA input doesn't fit its suite, e.g. a question that's too long
+    ErrInvalidOCRAInput = errors.New("otp: invalid OCRA input")
+    // an S/KEY password, seed or passphrase couldn't be used, see GenerateSKey
+    ErrInvalidSKey      = errors.New("otp: invalid S/KEY password")
+    // every password in a hash chain has been used, the user needs a new chain
+    ErrChainExhausted   = errors.New("otp: hash chain is used up")
+    // a Yubico OTP is malformed, won't decrypt or is from another key, see ValidateYubicoOTP
+    ErrInvalidYubicoOTP = errors.New("otp: invalid Yubico OTP")
+    // a mnemonic has a word that's not in the list, the wrong number of words or a bad checksum
+    ErrInvalidMnemonic  = errors.New("otp: invalid mnemonic")
+    // secret shares can't be made or combined as asked, see SplitSecret
+    ErrInvalidShares    = errors.New("otp: invalid secret shares")
+    // a passphrase is empty or its salt too short to derive a secret from, see DeriveSecret
+    ErrInvalidPassphrase = errors.New("otp: invalid passphrase or salt")
+    // the package got an RFC test vector wrong, see SelfTest
+    ErrSelfTest         = errors.New("otp: self test failed")
+    // strict mode is on and what was asked for isn't allowed in it, see SetStrictMode
+    ErrNotCompliant     = errors.New("otp: not allowed in strict mode")
+    // a fixed truncation offset doesn't fit in the HMAC, see FixedOffset
+    ErrInvalidTruncation = errors.New("otp: invalid truncation offset")
+    // a batch of codes would be more than MaxBatchCodes long, see GenerateTOTPRange
+    ErrBatchTooLarge    = errors.New("otp: too many codes in one batch")
+    // a ForwardSecureTOTP was asked for a step it no longer has or never had the secret for
+    ErrOutsideChain     = errors.New("otp: time step is outside the hash chain")
+    // a ForwardSecureTOTP was asked for a chain of no steps, see NewForwardSecureTOTP
+    ErrInvalidChain     = errors.New("otp: invalid hash chain length")
+)
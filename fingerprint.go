@@ -0,0 +1,68 @@
+package otp
+
+import (
+    "crypto/sha256"
+    "encoding/binary"
+    "encoding/hex"
+    "time"
+)
+
+/*
+    Key fingerprints
+
+    A fingerprint names a secret without giving it away, for logs, audit events and
+    finding the same key enrolled twice. It's the first 8 bytes, in hex, of the
+    SHA-256 of a fixed label, the parameters that decide the key's codes (type,
+    algorithm, digits, a fixed truncation offset if there is one and, for TOTP, period
+    and T0) and the secret, so the same secret used with two different settings gets
+    two fingerprints. Issuer, account, counter and the rest can change without
+    changing it, and it stays the same across versions of this package.
+
+    The fingerprint of a random secret says nothing useful about it, but one of a
+    guessable secret lets the guess be checked, as any hash would.
+*/
+
+// fingerprintLabel keeps these hashes apart from any other SHA-256 of a secret
+const fingerprintLabel string = "otp key fingerprint v1"
+
+// fingerprint computes the fingerprint of secret used with the given parameters
+func fingerprint(t Type, algo Algorithm, digits int, period time.Duration, t0 time.Time, trunc Truncation, secret []byte) string {
+    var params []byte = make([]byte, 0, 64)
+    params = append(params, fingerprintLabel...)
+    params = append(params, 0, byte(t))
+    params = append(params, algo.String()...)
+    params = append(params, 0)
+    params = binary.BigEndian.AppendUint32(params, uint32(digits))
+    if offset, fixed := trunc.Offset(); fixed {
+        // only added when fixed so dynamic truncation keys kept the fingerprints they had
+        params = append(params, 'f', byte(offset))
+    }
+    if (t == TypeTOTP) {
+        var epoch int64
+        if (!t0.IsZero()) {
+            epoch = t0.Unix()
+        }
+        params = binary.BigEndian.AppendUint64(params, uint64(period))
+        params = binary.BigEndian.AppendUint64(params, uint64(epoch))
+    }
+
+    var h = sha256.New()
+    h.Write(params)
+    h.Write(secret)
+    return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+/*
+    Fingerprint returns the key's fingerprint, see the notes above
+
+    For a key whose secret comes from a Provider the secret is fetched to compute it,
+    and the fingerprint is empty if that fails.
+*/
+func (k *Key) Fingerprint() string {
+    var secret, done, err = k.secret()
+    if (err != nil || len(secret) == 0) {
+        return ""
+    }
+    defer done()
+    return fingerprint(k.Type, k.Algorithm, k.Digits, k.Period, k.T0, k.Truncation, secret)
+}
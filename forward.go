@@ -0,0 +1,107 @@
+package otp
+
+import (
+    "crypto/sha1"
+    "fmt"
+    "sync"
+    "time"
+)
+
+/*
+    Forward Secure TOTP
+
+    Instead of using the same secret for every time step, the secret for step N is the
+    seed hashed N times:
+        K_0 = seed
+        K_N = H(K_N-1) = H^N(seed)
+    Once the token moves on to step N it throws away K_N-1, so anybody who steals the
+    current secret can only compute codes going forward and never the ones already used.
+
+    Step 0 is the time step start is in and the chain is good for `steps` time steps
+    after that. It's safe to use from several goroutines.
+*/
+type ForwardSecureTOTP struct {
+    mu      sync.Mutex
+    secret  []byte  // K_index, the only secret we still hold
+    index   int     // how far along the chain secret is
+    steps   int     // total length of the chain
+    start   int64   // the time step that K_0 belongs to
+}
+
+/*
+    NewForwardSecureTOTP starts a hash chain from seed at time start that lasts for steps time steps
+
+    Pass the token's creation time, clock.Now() for one made now; server and token
+    have to agree on it. It fails with ErrEmptySecret for an empty seed and
+    ErrInvalidChain if steps isn't positive.
+*/
+func NewForwardSecureTOTP(seed []byte, steps int, start time.Time) (*ForwardSecureTOTP, error) {
+    if (len(seed) == 0) {
+        return nil, ErrEmptySecret
+    }
+    if (steps <= 0) {
+        return nil, fmt.Errorf("%w: %d steps", ErrInvalidChain, steps)
+    }
+    var secret []byte = make([]byte, len(seed))
+    copy(secret, seed)
+
+    return &ForwardSecureTOTP{
+        secret: secret,
+        steps:  steps,
+        start:  timeStep(start, DefaultPeriod),
+    }, nil
+}
+
+/*
+    secretAt walks the chain forward to step n and returns K_n
+
+    It fails if n is before where we are (those secrets are gone) or past the end of the
+    chain. f.mu must be held, and K_n is only good until it's released.
+*/
+func (f *ForwardSecureTOTP) secretAt(n int) ([]byte, bool) {
+    if (n < f.index || n >= f.steps) {
+        return nil, false
+    }
+    for f.index < n {
+        var sum [sha1.Size]byte = sha1.Sum(f.secret)
+        // wipe the old link before dropping it
+        Wipe(f.secret)
+        f.secret = sum[:]
+        f.index++
+    }
+    return f.secret, true
+}
+
+/*
+    GenerateAt returns the code for time t, advancing the chain if needed
+
+    It fails with ErrOutsideChain if t is in a step whose secret is already erased or
+    past the end of the chain.
+*/
+func (f *ForwardSecureTOTP) GenerateAt(t time.Time) (Code, error) {
+    var step int64 = timeStep(t, DefaultPeriod)
+    f.mu.Lock()
+    defer f.mu.Unlock()
+
+    var secret []byte
+    var ok bool
+    if (step < f.start || step - f.start >= int64(f.steps)) {
+        return Code{}, ErrOutsideChain
+    }
+    if secret, ok = f.secretAt(int(step - f.start)); !ok {
+        return Code{}, ErrOutsideChain
+    }
+    return HOTP(secret, uint64(step))
+}
+
+// Validate checks code against the code for time t
+func (f *ForwardSecureTOTP) Validate(code string, t time.Time) error {
+    var expected, err = f.GenerateAt(t)
+    if (err != nil) {
+        return err
+    }
+    if (!codeMatches(expected, code, Lenient)) {
+        return ErrCodeMismatch
+    }
+    return nil
+}
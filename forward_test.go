@@ -0,0 +1,97 @@
+package otp
+
+import (
+    "crypto/sha1"
+    "errors"
+    "sync"
+    "testing"
+    "time"
+)
+
+// forwardCode is the code for step start+n of a chain from seed, worked out from scratch
+func forwardCode(t *testing.T, seed []byte, start int64, n int) string {
+    var secret []byte = seed
+    for range(n) {
+        var sum [sha1.Size]byte = sha1.Sum(secret)
+        secret = sum[:]
+    }
+    var code, err = HOTP(secret, uint64(start + int64(n)))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    return code.String()
+}
+
+func TestForwardSecureTOTP(t *testing.T) {
+    var start time.Time = time.Unix(1_700_000_010, 0)
+    var step int64 = timeStep(start, DefaultPeriod)
+    var f, err = NewForwardSecureTOTP(RFC4226Secret, 10, start)
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    var at = func(n int) time.Time {
+        return start.Add(time.Duration(n) * DefaultPeriod)
+    }
+
+    var tests = []struct {
+        name    string
+        code    string
+        t       time.Time
+        err     error
+    }{
+        {"step 0", forwardCode(t, RFC4226Secret, step, 0), at(0), nil},
+        {"step 3", forwardCode(t, RFC4226Secret, step, 3), at(3), nil},
+        {"wrong code", "000000", at(3), ErrCodeMismatch},
+        {"step 2 after step 3", forwardCode(t, RFC4226Secret, step, 2), at(2), ErrOutsideChain},
+        {"before the chain", forwardCode(t, RFC4226Secret, step, 0), at(-1), ErrOutsideChain},
+        {"last step", forwardCode(t, RFC4226Secret, step, 9), at(9), nil},
+        {"past the end", forwardCode(t, RFC4226Secret, step, 10), at(10), ErrOutsideChain},
+    }
+    for _, tt := range(tests) {
+        if err := f.Validate(tt.code, tt.t); !errors.Is(err, tt.err) {
+            t.Errorf("%s: %v, want %v", tt.name, err, tt.err)
+        }
+    }
+}
+
+func TestNewForwardSecureTOTPRejects(t *testing.T) {
+    var tests = []struct {
+        name    string
+        seed    []byte
+        steps   int
+        err     error
+    }{
+        {"empty seed", nil, 10, ErrEmptySecret},
+        {"no steps", RFC4226Secret, 0, ErrInvalidChain},
+        {"negative steps", RFC4226Secret, -1, ErrInvalidChain},
+    }
+    for _, tt := range(tests) {
+        if _, err := NewForwardSecureTOTP(tt.seed, tt.steps, time.Unix(0, 0)); !errors.Is(err, tt.err) {
+            t.Errorf("%s: %v, want %v", tt.name, err, tt.err)
+        }
+    }
+}
+
+func TestForwardSecureTOTPConcurrent(t *testing.T) {
+    var start time.Time = time.Unix(1_700_000_010, 0)
+    var f, err = NewForwardSecureTOTP(RFC4226Secret, 100, start)
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    var wg sync.WaitGroup
+    for i := range(8) {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for n := range(10) {
+                // steps the chain has already passed fail, that's fine; racing on it isn't
+                f.GenerateAt(start.Add(time.Duration(i * 10 + n) * DefaultPeriod))
+            }
+        }()
+    }
+    wg.Wait()
+    var step int64 = timeStep(start, DefaultPeriod)
+    if err := f.Validate(forwardCode(t, RFC4226Secret, step, 99), start.Add(99 * DefaultPeriod)); err != nil {
+        t.Errorf("last step after concurrent use: %v", err)
+    }
+}
@@ -0,0 +1,106 @@
+package otp
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "strings"
+    "time"
+)
+
+/*
+    FreeOTP+ backups
+
+    FreeOTP+ exports either a JSON file with a "tokens" array, where the secret is a
+    list of signed bytes (it's a serialized Java byte[]), or a plain text file with one
+    otpauth:// URI per line. ImportFreeOTPPlus takes either.
+*/
+
+type freeOTPToken struct {
+    Algo        string  `json:"algo"`
+    Counter     uint64  `json:"counter"`
+    Digits      int     `json:"digits"`
+    IssuerExt   string  `json:"issuerExt"`
+    Label       string  `json:"label"`
+    Period      int     `json:"period"`
+    Secret      []int   `json:"secret"`
+    Type        string  `json:"type"`
+}
+
+type freeOTPBackup struct {
+    Tokens  []freeOTPToken  `json:"tokens"`
+}
+
+// key converts a token to a Key
+func (t freeOTPToken) key() (*Key, error) {
+    var secret []byte = make([]byte, len(t.Secret))
+    for i, b := range(t.Secret) {
+        if (b < -128 || b > 255) {
+            return nil, fmt.Errorf("%w: secret byte %d out of range", ErrInvalidEncoding, b)
+        }
+        secret[i] = byte(b)
+    }
+    var algo, ok = ParseAlgorithm(t.Algo)
+    if (!ok) {
+        return nil, fmt.Errorf("%w: %s", ErrInvalidAlgorithm, t.Algo)
+    }
+
+    var opts []Option = []Option{
+        WithAlgorithm(algo),
+        WithDigits(t.Digits),
+        WithIssuer(t.IssuerExt),
+        WithAccount(trimIssuer(t.Label, t.IssuerExt)),
+    }
+    switch strings.ToUpper(t.Type) {
+    case "TOTP":
+        opts = append(opts, WithPeriod(time.Duration(t.Period) * time.Second))
+    case "HOTP":
+        opts = append(opts, WithHOTP(t.Counter))
+    default:
+        return nil, fmt.Errorf("unsupported token type %q", t.Type)
+    }
+    return NewKey(secret, opts...)
+}
+
+/*
+    ImportFreeOTPPlus reads a FreeOTP+ backup, JSON or URI list, and returns a Key for each token
+
+    Blank lines in a URI list are skipped. Problems with the file wrap ErrInvalidBackup.
+*/
+func ImportFreeOTPPlus(data []byte) ([]*Key, error) {
+    var keys []*Key
+    var trimmed []byte = bytes.TrimSpace(data)
+
+    if (len(trimmed) > 0 && trimmed[0] == '{') {
+        var backup freeOTPBackup
+        if err := json.Unmarshal(trimmed, &backup); err != nil {
+            return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+        }
+        for i, t := range(backup.Tokens) {
+            var k, err = t.key()
+            if (err != nil) {
+                return nil, fmt.Errorf("%w: token %d (%s): %w", ErrInvalidBackup, i, t.Label, err)
+            }
+            keys = append(keys, k)
+        }
+        return keys, nil
+    }
+
+    var scanner *bufio.Scanner = bufio.NewScanner(bytes.NewReader(trimmed))
+    for line := 1; scanner.Scan(); line++ {
+        var uri string = strings.TrimSpace(scanner.Text())
+        if (uri == "") {
+            continue
+        }
+        var k, err = ParseURI(uri)
+        if (err != nil) {
+            return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidBackup, line, err)
+        }
+        keys = append(keys, k)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+    }
+    return keys, nil
+}
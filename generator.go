@@ -0,0 +1,355 @@
+package otp
+
+import (
+    "encoding"
+    "encoding/binary"
+    "fmt"
+    "hash"
+    "sync"
+    "time"
+)
+
+/*
+    Generator is a Key made ready for generating and checking many codes
+
+    Every GenerateHOTP call builds the HMAC from scratch: it pads the key to the
+    hash's block size, XORs it with ipad and opad and hashes both pads again before
+    it gets to the counter. A Generator does that once, when it's made, and keeps the
+    hash states it reaches after the pads; each code then starts from a copy of them.
+    The hash objects themselves are kept in a sync.Pool, so a verification server
+    checking codes for the same key from many goroutines at once doesn't allocate new
+    ones each time. A Generator is safe for concurrent use.
+
+    It holds its own copy of the key's padded secret for as long as it lives, even for
+    a key whose secret comes from a Provider; call Destroy once it's no longer needed.
+    It doesn't do replay, lockout, drift or audit; use Key.Validate where those matter.
+    It keeps no counter either, so an HOTP one only has GenerateCounter and
+    ValidateCounter, and the TOTP methods fail with ErrNotTOTP on it.
+*/
+type Generator struct {
+    typ         Type
+    algo        Algorithm
+    digits      int
+    trunc       Truncation
+    checksum    bool
+    enc         Encoder
+    policy      NormalizationPolicy
+    period      time.Duration
+    t0          time.Time
+    skew        int
+    clock       Clock
+    keyLen      int
+
+    newHash     func() hash.Hash
+    ipad        []byte  // K' ^ ipad
+    opad        []byte  // K' ^ opad
+    inner       []byte  // the hash's state after ipad, nil if it can't be saved
+    outer       []byte  // the same after opad
+    pool        sync.Pool
+}
+
+// hmacState is one set of hash objects and buffers from a Generator's pool
+type hmacState struct {
+    inner   hash.Hash
+    outer   hash.Hash
+    message [8]byte
+    sum     []byte
+}
+
+/*
+    NewGenerator makes a Generator with k's settings
+
+    It fails as NewKey would for settings that can't generate codes, and with the
+    provider's error if k's secret can't be fetched.
+*/
+func NewGenerator(k *Key) (*Generator, error) {
+    var secret, done, err = k.secret()
+    if (err != nil) {
+        return nil, err
+    }
+    defer done()
+    if (len(secret) == 0) {
+        return nil, ErrEmptySecret
+    }
+    if (k.Digits < MinDigits || k.Digits > MaxDigits || (k.Checksum && k.Digits > MaxChecksumDigits)) {
+        return nil, ErrInvalidDigits
+    }
+    if (k.Checksum && !isDecimal(k.Encoder)) {
+        return nil, fmt.Errorf("%w: a checksum digit needs decimal codes", ErrInvalidDigits)
+    }
+    if _, ok := k.Algorithm.info(); !ok {
+        return nil, ErrInvalidAlgorithm
+    }
+    if err := checkStrict(k.Algorithm, len(secret)); err != nil {
+        return nil, err
+    }
+
+    var g *Generator = &Generator{
+        typ:        k.Type,
+        algo:       k.Algorithm,
+        digits:     k.Digits,
+        trunc:      k.Truncation,
+        checksum:   k.Checksum,
+        enc:        k.Encoder,
+        policy:     k.Normalization,
+        period:     k.Period,
+        t0:         k.T0,
+        skew:       k.Skew,
+        clock:      k.Clock,
+        keyLen:     len(secret),
+        newHash:    k.Algorithm.Hash(),
+    }
+
+    // K', then the two pads, as in HMACHash
+    var h hash.Hash = g.newHash()
+    var key_ []byte = make([]byte, h.BlockSize())
+    defer Wipe(key_)
+    hmacKey(h, secret, key_, nil)
+    g.ipad = make([]byte, len(key_))
+    g.opad = make([]byte, len(key_))
+    for i := range(key_) {
+        g.ipad[i] = key_[i] ^ 0x36
+        g.opad[i] = key_[i] ^ 0x5C
+    }
+
+    // the states after each pad, for hashes that can save them (the standard library's all can)
+    g.inner = g.saveState(h, g.ipad)
+    g.outer = g.saveState(h, g.opad)
+
+    g.pool.New = func() any {
+        return &hmacState{
+            inner:  g.newHash(),
+            outer:  g.newHash(),
+            sum:    make([]byte, 0, h.Size()),
+        }
+    }
+    return g, nil
+}
+
+// saveState returns h's marshaled state after writing pad to it, or nil if h can't be marshaled
+func (g *Generator) saveState(h hash.Hash, pad []byte) []byte {
+    var m, ok = h.(encoding.BinaryMarshaler)
+    if (!ok) {
+        return nil
+    }
+    if _, ok := h.(encoding.BinaryUnmarshaler); !ok {
+        return nil
+    }
+    h.Reset()
+    h.Write(pad)
+    var state, err = m.MarshalBinary()
+    h.Reset()
+    if (err != nil) {
+        return nil
+    }
+    return state
+}
+
+// restore puts h back to how it was just after pad, from state when there is one
+func restore(h hash.Hash, state []byte, pad []byte) {
+    if (state != nil) {
+        if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err == nil {
+            return
+        }
+    }
+    h.Reset()
+    h.Write(pad)
+}
+
+// truncated is truncate for counter with the generator's key
+func (g *Generator) truncated(counter uint64) (uint32, error) {
+    if (g.ipad == nil) {
+        return 0, ErrEmptySecret
+    }
+    if err := checkStrict(g.algo, g.keyLen); err != nil {
+        return 0, err
+    }
+    var s *hmacState = g.pool.Get().(*hmacState)
+    defer g.pool.Put(s)
+
+    binary.BigEndian.PutUint64(s.message[:], counter)
+    restore(s.inner, g.inner, g.ipad)
+    s.inner.Write(s.message[:])
+    s.sum = s.inner.Sum(s.sum[:0])
+    restore(s.outer, g.outer, g.opad)
+    s.outer.Write(s.sum)
+    s.sum = s.outer.Sum(s.sum[:0])
+    var truncated, err = truncateHMAC(s.sum, g.trunc)
+
+    // nothing derived from the key is left in the pool
+    clear(s.sum)
+    s.inner.Reset()
+    s.outer.Reset()
+    return truncated, err
+}
+
+// code is the code for counter without any checksum digit, which validation strips before comparing, and its raw value
+func (g *Generator) code(counter uint64) (Code, uint32, error) {
+    if err := checkStrictDigits(g.digits); err != nil {
+        return Code{}, 0, err
+    }
+    var truncated, err = g.truncated(counter)
+    if (err != nil) {
+        return Code{}, 0, err
+    }
+    var mod uint64 = 1
+    for i := 0; i < g.digits; i++ {
+        mod *= 10
+    }
+    return Code{Value: uint32(uint64(truncated) % mod), Digits: g.digits}, truncated, nil
+}
+
+// GenerateCounter returns the HOTP code for counter, like Key.GenerateCounter
+func (g *Generator) GenerateCounter(counter uint64) (Code, error) {
+    defer observeGenerate(time.Now())
+    var code, _, err = g.code(counter)
+    if (err != nil || !g.checksum) {
+        return code, err
+    }
+    return AddChecksum(code)
+}
+
+// GenerateStringCounter is GenerateCounter written with the key's Encoder, see WithEncoder
+func (g *Generator) GenerateStringCounter(counter uint64) (string, error) {
+    if (isDecimal(g.enc)) {
+        var code, err = g.GenerateCounter(counter)
+        if (err != nil) {
+            return "", err
+        }
+        return code.String(), nil
+    }
+    defer observeGenerate(time.Now())
+    var code, raw, err = g.code(counter)
+    if (err != nil) {
+        return "", err
+    }
+    return encodeCode(g.enc, code, raw), nil
+}
+
+// GenerateStringAt is GenerateAt written with the key's Encoder, see WithEncoder
+func (g *Generator) GenerateStringAt(t time.Time) (string, error) {
+    if (g.typ != TypeTOTP) {
+        return "", ErrNotTOTP
+    }
+    if (g.period < time.Second) {
+        return "", ErrInvalidPeriod
+    }
+    return g.GenerateStringCounter(uint64(timeStepFrom(t, g.t0, g.period)))
+}
+
+// GenerateAt returns the TOTP code for the time step containing t, like Key.GenerateAt
+func (g *Generator) GenerateAt(t time.Time) (Code, error) {
+    if (g.typ != TypeTOTP) {
+        return Code{}, ErrNotTOTP
+    }
+    if (g.period < time.Second) {
+        return Code{}, ErrInvalidPeriod
+    }
+    return g.GenerateCounter(uint64(timeStepFrom(t, g.t0, g.period)))
+}
+
+/*
+    ValidateAt checks a TOTP code at time t with the key's Skew and returns the step it matched
+
+    Like ValidateTOTPStep the whole window is checked and codes are compared in
+    constant time, but nothing is recorded: use a ReplayStore yourself to stop a code
+    being used twice.
+*/
+func (g *Generator) ValidateAt(code string, t time.Time) (int64, error) {
+    var start time.Time = time.Now()
+    var matched, err = g.validateAt(code, t)
+    observeVerify("", start, err)
+    return matched, err
+}
+
+// validateAt is ValidateAt without the observer
+func (g *Generator) validateAt(code string, t time.Time) (int64, error) {
+    if (g.typ != TypeTOTP) {
+        return 0, ErrNotTOTP
+    }
+    if (g.period < time.Second) {
+        return 0, ErrInvalidPeriod
+    }
+    if (g.checksum) {
+        var ok bool
+        if code, ok = stripChecksum(code, g.policy); !ok {
+            return 0, ErrCodeMismatch
+        }
+    }
+    var step int64 = timeStepFrom(t, g.t0, g.period)
+    var matched int64
+    var match bool
+    for offset := int64(-g.skew); offset <= int64(g.skew); offset++ {
+        var expected, raw, err = g.code(uint64(step + offset))
+        if (err != nil) {
+            return 0, err
+        }
+        if (encodedMatches(g.enc, expected, raw, code, g.policy) && !match) {
+            matched, match = step + offset, true
+        }
+    }
+    if (!match) {
+        return 0, ErrCodeMismatch
+    }
+    return matched, nil
+}
+
+/*
+    ValidateCounter checks an HOTP code from counter through counter+window and returns the counter it matched
+
+    Like ValidateHOTP, store matched+1 as the next counter. It fails with ErrNotHOTP on a
+    TOTP generator.
+*/
+func (g *Generator) ValidateCounter(code string, counter uint64, window int) (uint64, error) {
+    var start time.Time = time.Now()
+    var matched, err = g.validateCounter(code, counter, window)
+    observeVerify("", start, err)
+    return matched, err
+}
+
+// validateCounter is ValidateCounter without the observer
+func (g *Generator) validateCounter(code string, counter uint64, window int) (uint64, error) {
+    if (g.typ != TypeHOTP) {
+        return 0, ErrNotHOTP
+    }
+    if (g.checksum) {
+        var ok bool
+        if code, ok = stripChecksum(code, g.policy); !ok {
+            return 0, ErrCodeMismatch
+        }
+    }
+    var matched uint64
+    var match bool
+    for i := 0; i <= window; i++ {
+        var c uint64 = counter + uint64(i)
+        if (c < counter) {
+            break
+        }
+        var expected, raw, err = g.code(c)
+        if (err != nil) {
+            return 0, err
+        }
+        if (encodedMatches(g.enc, expected, raw, code, g.policy) && !match) {
+            matched, match = c, true
+        }
+    }
+    if (!match) {
+        return 0, ErrCodeMismatch
+    }
+    return matched, nil
+}
+
+/*
+    Destroy wipes the generator's copy of the secret
+
+    Codes generated afterwards fail with ErrEmptySecret. The pooled hash objects are
+    reset after every code so there's nothing of the key in them. Don't call it while
+    other goroutines are still using the generator.
+*/
+func (g *Generator) Destroy() {
+    Wipe(g.ipad)
+    Wipe(g.opad)
+    Wipe(g.inner)
+    Wipe(g.outer)
+    g.ipad, g.opad, g.inner, g.outer = nil, nil, nil, nil
+}
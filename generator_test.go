@@ -0,0 +1,58 @@
+package otp
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestGeneratorTOTP(t *testing.T) {
+    var k, err = NewKey(RFC4226Secret, WithClock(ClockFunc(func() time.Time { return time.Unix(59, 0) })))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    var g *Generator
+    if g, err = NewGenerator(k); err != nil {
+        t.Fatal(err)
+    }
+    defer g.Destroy()
+
+    var code string
+    if code, err = g.GenerateCode(); err != nil || code != rfc4226Codes[1] {
+        t.Errorf("GenerateCode: %s, %v, want %s", code, err, rfc4226Codes[1])
+    }
+    if err = g.ValidateCode(rfc4226Codes[1]); err != nil {
+        t.Errorf("ValidateCode: %v", err)
+    }
+    if _, err = g.ValidateCounter(rfc4226Codes[1], 0, 3); !errors.Is(err, ErrNotHOTP) {
+        t.Errorf("ValidateCounter on a TOTP generator: %v, want ErrNotHOTP", err)
+    }
+}
+
+func TestGeneratorHOTP(t *testing.T) {
+    var k, err = NewKey(RFC4226Secret, WithHOTP(0))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    var g *Generator
+    if g, err = NewGenerator(k); err != nil {
+        t.Fatal(err)
+    }
+    defer g.Destroy()
+
+    if _, err = g.GenerateCode(); !errors.Is(err, ErrNotTOTP) {
+        t.Errorf("GenerateCode on an HOTP generator: %v, want ErrNotTOTP", err)
+    }
+    if err = g.ValidateCode(rfc4226Codes[0]); !errors.Is(err, ErrNotTOTP) {
+        t.Errorf("ValidateCode on an HOTP generator: %v, want ErrNotTOTP", err)
+    }
+    for counter, want := range(rfc4226Codes) {
+        if code, err := g.GenerateCounter(uint64(counter)); err != nil || code.String() != want {
+            t.Errorf("GenerateCounter(%d): %s, %v, want %s", counter, code, err, want)
+        }
+    }
+    var matched uint64
+    if matched, err = g.ValidateCounter(rfc4226Codes[4], 2, 3); err != nil || matched != 4 {
+        t.Errorf("ValidateCounter: %d, %v, want 4", matched, err)
+    }
+}
@@ -0,0 +1,130 @@
+package otp
+
+import (
+    "crypto/subtle"
+)
+
+/*
+    Lamport hash chains
+
+    A chain of length N starts from a secret seed and hashes it over and over:
+        P_0 = seed
+        P_i = H(P_i-1)
+    The verifier is given only the anchor P_N. Passwords are used from the end
+    backwards, P_N-1 first, and each is checked by hashing it and comparing with the
+    last password accepted, which it then replaces. Neither side needs a clock or a
+    shared counter and the verifier holds nothing that lets anyone make a password,
+    which suits embedded and offline protocols. S/KEY (see GenerateSKey) is the same
+    idea with 64 bit folded hashes.
+
+    Passwords are raw hash outputs; encode them however the protocol wants, e.g. with
+    EncodeBase32.
+*/
+type HashChain struct {
+    Algorithm   Algorithm   // the hash, not an HMAC
+    Seed        []byte
+    Length      int         // N
+    Used        int         // how many passwords have been handed out, persist it with the chain
+}
+
+// NewHashChain returns a chain of length passwords from seed
+func NewHashChain(seed []byte, length int, algo Algorithm) (*HashChain, error) {
+    if (len(seed) == 0) {
+        return nil, ErrEmptySecret
+    }
+    if _, ok := algo.info(); !ok {
+        return nil, ErrInvalidAlgorithm
+    }
+    if err := checkStrict(algo, len(seed)); err != nil {
+        return nil, err
+    }
+    var c *HashChain = &HashChain{Algorithm: algo, Seed: make([]byte, len(seed)), Length: length}
+    copy(c.Seed, seed)
+    return c, nil
+}
+
+// hashN hashes b n times
+func hashN(algo Algorithm, b []byte, n int) []byte {
+    var newHash = algo.Hash()
+    for i := 0; i < n; i++ {
+        var h = newHash()
+        h.Write(b)
+        b = h.Sum(nil)
+    }
+    return b
+}
+
+// Anchor returns P_N, what the verifier starts from
+func (c *HashChain) Anchor() []byte {
+    return hashN(c.Algorithm, c.Seed, c.Length)
+}
+
+/*
+    Next returns the next password and counts it as used
+
+    The first call returns P_N-1 and the last P_0, the seed itself; after that the
+    chain is used up and Next fails with ErrChainExhausted.
+*/
+func (c *HashChain) Next() ([]byte, error) {
+    if (c.Used >= c.Length) {
+        return nil, ErrChainExhausted
+    }
+    c.Used++
+    return hashN(c.Algorithm, c.Seed, c.Length - c.Used), nil
+}
+
+// Remaining is how many passwords are left
+func (c *HashChain) Remaining() int {
+    return max(c.Length - c.Used, 0)
+}
+
+/*
+    HashChainVerifier checks passwords from a HashChain
+
+    Last starts as the chain's Anchor. Persist it and Position after each successful
+    Verify; they're all the verifier needs.
+*/
+type HashChainVerifier struct {
+    Algorithm   Algorithm
+    Last        []byte  // the last password accepted
+    Position    int     // the index of Last in the chain, counting down to 0
+}
+
+// NewHashChainVerifier starts verifying the chain whose anchor is anchor, length passwords long
+func NewHashChainVerifier(anchor []byte, length int, algo Algorithm) *HashChainVerifier {
+    var last []byte = make([]byte, len(anchor))
+    copy(last, anchor)
+    return &HashChainVerifier{Algorithm: algo, Last: last, Position: length}
+}
+
+/*
+    Verify checks password and moves the verifier to it
+
+    password is accepted if hashing it once gets Last, or up to window more times for
+    passwords the user skipped or lost; the skipped ones can't be used afterwards. It
+    fails with ErrCodeMismatch for anything else, including a password used before, and
+    ErrChainExhausted once P_0 has been accepted.
+*/
+func (v *HashChainVerifier) Verify(password []byte, window int) error {
+    if (v.Position <= 0) {
+        return ErrChainExhausted
+    }
+    if err := checkStrict(v.Algorithm, len(v.Last)); err != nil {
+        return err
+    }
+    var h []byte = password
+    var matched int
+    // every step is hashed and compared so the timing doesn't show how far the password was
+    for i := 1; i <= window + 1 && i <= v.Position; i++ {
+        h = hashN(v.Algorithm, h, 1)
+        if (subtle.ConstantTimeCompare(h, v.Last) == 1 && matched == 0) {
+            matched = i
+        }
+    }
+    if (matched == 0) {
+        return ErrCodeMismatch
+    }
+    v.Last = append([]byte(nil), password...)
+    v.Position -= matched
+    return nil
+}
@@ -0,0 +1,167 @@
+package otp
+
+import (
+    "fmt"
+    "strings"
+)
+
+/*
+    CheckSecretHealth is a sanity check to run on a secret before enrolling it
+
+    It rejects empty secrets with ErrEmptySecret. It also rejects secrets that are a
+    single repeated byte (all zeros being the usual result of a failed decode or an
+    uninitialized buffer), then generates `samples` consecutive time step codes and fails
+    if more of them repeat than chance allows. With a million 6 digit codes a few hundred
+    samples should basically never collide, so the limit is 1% of samples plus one.
+    Both of those fail with ErrDegenerateSecret. The samples start at clock's time step,
+    nil for the system clock.
+*/
+func CheckSecretHealth(secret []byte, samples int, clock Clock) error {
+    if (len(secret) == 0) {
+        return ErrEmptySecret
+    }
+    var repeated bool = true
+    for _, b := range(secret[1:]) {
+        if (b != secret[0]) {
+            repeated = false
+            break
+        }
+    }
+    if (repeated) {
+        return ErrDegenerateSecret
+    }
+
+    var seen map[string]bool = make(map[string]bool)
+    var collisions int = 0
+    var step int64 = timeStep(clockNow(clock), DefaultPeriod)
+    for i := 0; i < samples; i++ {
+        var generated, err = totpStep(secret, step + int64(i))
+        if (err != nil) {
+            return err
+        }
+        var code string = generated.String()
+        if (seen[code]) {
+            collisions++
+        }
+        seen[code] = true
+    }
+    if (collisions > samples / 100 + 1) {
+        return ErrDegenerateSecret
+    }
+    return nil
+}
+
+/*
+    CheckSecret checks a secret is fit to enroll with algo
+
+    It's stricter than CheckSecretHealth and meant to be run on secrets that come from
+    outside, e.g. typed in or imported from another system, so that problems turn up at
+    enrollment rather than as codes that never work:
+        - Base32 or hex text that was never decoded fails with ErrInvalidEncoding
+        - a single repeated byte (all zeros, usually), a counting sequence, bytes that
+          are nearly all the same few values, and printable text such as a password
+          used as the secret fail with ErrDegenerateSecret
+        - a secret shorter than SecretLength(algo) fails with ErrWeakSecret: RFC 4226
+          requires at least 128 bits and recommends 160, and RFC 6238 recommends the
+          hash's own length
+    Each error says what's wrong with the secret. Secrets from
+    GenerateSecret(SecretLength(algo)) pass, short of odds around one in a billion.
+*/
+func CheckSecret(secret []byte, algo Algorithm) error {
+    if (len(secret) == 0) {
+        return ErrEmptySecret
+    }
+    if _, ok := algo.info(); !ok {
+        return ErrInvalidAlgorithm
+    }
+
+    // text left undecoded: real secrets are random bytes, hardly any of which are letters
+    var base32Text, hexText, printable bool = true, true, true
+    var distinct map[byte]bool = map[byte]bool{}
+    var arithmetic bool = true
+    for i, b := range(secret) {
+        base32Text = base32Text && (b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z' || b >= '2' && b <= '7' || b == '=')
+        hexText = hexText && (b >= '0' && b <= '9' || b >= 'a' && b <= 'f' || b >= 'A' && b <= 'F')
+        printable = printable && b >= 0x20 && b < 0x7F
+        distinct[b] = true
+        if (i >= 2 && b - secret[i - 1] != secret[i - 1] - secret[i - 2]) {
+            arithmetic = false
+        }
+    }
+    if (len(secret) >= 8 && hexText) {
+        return fmt.Errorf("%w: the secret is hex text, decode it with DecodeHex first", ErrInvalidEncoding)
+    }
+    if (len(secret) >= 8 && base32Text) {
+        return fmt.Errorf("%w: the secret is Base32 text, decode it with DecodeBase32 first", ErrInvalidEncoding)
+    }
+
+    switch {
+    case (len(distinct) == 1):
+        return fmt.Errorf("%w: every byte is %#02x", ErrDegenerateSecret, secret[0])
+    case (arithmetic && len(secret) > 2):
+        return fmt.Errorf("%w: the bytes count up or down in equal steps", ErrDegenerateSecret)
+    case (len(distinct) < min(len(secret), 256) / 2):
+        return fmt.Errorf("%w: only %d different byte values in %d bytes", ErrDegenerateSecret, len(distinct), len(secret))
+    case (len(secret) >= 8 && printable):
+        return fmt.Errorf("%w: the secret is printable text, use random bytes from GenerateSecret or DeriveSecret", ErrDegenerateSecret)
+    }
+
+    if (len(secret) < SecretLength(algo)) {
+        return fmt.Errorf("%w: %d bytes, %s needs at least %d", ErrWeakSecret, len(secret), algo, SecretLength(algo))
+    }
+    return nil
+}
+
+// Check runs CheckSecret on the key's secret and algorithm
+func (k *Key) Check() error {
+    var secret, done, err = k.secret()
+    if (err != nil) {
+        return err
+    }
+    defer done()
+    return CheckSecret(secret, k.Algorithm)
+}
+
+/*
+    CheckBase32 looks for the usual mistakes in a typed or pasted Base32 secret
+
+    DecodeBase32 only says the input is bad; this says why, for showing to whoever
+    typed it. It fails with an error wrapping ErrInvalidEncoding for 0, 1 and 8,
+    which aren't in the alphabet and are usually O, I or L, and B misread; for a length
+    no Base32 string can have, from a character dropped or doubled; and for a last
+    character with bits set past the end of the last byte, which means it's mistyped
+    or the secret was cut short. Whitespace, dashes, case and padding are ignored, as
+    DecodeBase32 ignores them.
+*/
+func CheckBase32(s string) error {
+    s = strings.ReplaceAll(cleanSecret(s), "-", "")
+    s = strings.TrimRight(strings.ToUpper(s), "=")
+    if (s == "") {
+        return ErrEmptySecret
+    }
+    const alphabet string = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+    var lookalikes map[rune]string = map[rune]string{'0': "O", '1': "I or L", '8': "B"}
+    for i, r := range(s) {
+        if (strings.ContainsRune(alphabet, r)) {
+            continue
+        }
+        if like, ok := lookalikes[r]; ok {
+            return fmt.Errorf("%w: %q at position %d isn't Base32, it's probably %s", ErrInvalidEncoding, r, i + 1, like)
+        }
+        return fmt.Errorf("%w: %q at position %d isn't Base32, only A-Z and 2-7 are", ErrInvalidEncoding, r, i + 1)
+    }
+
+    // each group of 8 characters is 5 bytes; 1, 3 or 6 left over can't end on a byte
+    switch len(s) % 8 {
+    case 1, 3, 6:
+        return fmt.Errorf("%w: %d characters can't be Base32, one is probably missing or doubled", ErrInvalidEncoding, len(s))
+    }
+    var spare int = len(s) * 5 % 8
+    if (spare > 0) {
+        var last int = strings.IndexByte(alphabet, s[len(s) - 1])
+        if (last & (1 << spare - 1) != 0) {
+            return fmt.Errorf("%w: the last character %q doesn't end a whole byte, it's probably mistyped or the secret is cut short", ErrInvalidEncoding, s[len(s) - 1])
+        }
+    }
+    return nil
+}
@@ -0,0 +1,32 @@
+package otp
+
+import (
+    "bytes"
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestCheckSecretHealth(t *testing.T) {
+    var clock Clock = FixedClock(time.Unix(1_700_000_000, 0))
+    var tests = []struct {
+        name    string
+        secret  []byte
+        err     error
+    }{
+        {"rfc 4226 secret", RFC4226Secret, nil},
+        {"rfc 6238 sha256 secret", RFC6238Secrets[SHA256], nil},
+        {"empty", nil, ErrEmptySecret},
+        {"all zeros", make([]byte, 20), ErrDegenerateSecret},
+        {"one repeated byte", bytes.Repeat([]byte{0xA5}, 20), ErrDegenerateSecret},
+        {"one byte", []byte{0x42}, ErrDegenerateSecret},
+    }
+    for _, tt := range(tests) {
+        if err := CheckSecretHealth(tt.secret, 500, clock); !errors.Is(err, tt.err) {
+            t.Errorf("%s: %v, want %v", tt.name, err, tt.err)
+        }
+    }
+    if err := CheckSecretHealth(RFC4226Secret, 500, nil); err != nil {
+        t.Errorf("with the system clock: %v", err)
+    }
+}
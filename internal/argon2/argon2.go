@@ -0,0 +1,236 @@
+// Package argon2 is the Argon2id key derivation function (RFC 9106), which the standard library doesn't have
+package argon2
+
+import (
+    "encoding/binary"
+    "errors"
+    "math/bits"
+    "sync"
+)
+
+const (
+    version     uint32 = 0x13
+    typeID      uint32 = 2
+    syncPoints  uint32 = 4  // slices per pass
+    blockWords  int = 128   // a 1024 byte block as uint64s
+)
+
+type block [blockWords]uint64
+
+/*
+    IDKey derives a keyLen byte key from a password with Argon2id
+
+    time is the number of passes, memory the memory to use in KiB and threads the
+    number of lanes, which are filled in parallel. RFC 9106 recommends time 1 with 2
+    GiB, or time 3 with 64 MiB where that's too much.
+*/
+func IDKey(password []byte, salt []byte, time uint32, memory uint32, threads uint8, keyLen uint32) ([]byte, error) {
+    return deriveKey(password, salt, nil, nil, time, memory, threads, keyLen)
+}
+
+// deriveKey is Argon2id with the optional secret and associated data too, which the test vectors use
+func deriveKey(password []byte, salt []byte, secret []byte, data []byte, time uint32, memory uint32, threads uint8, keyLen uint32) ([]byte, error) {
+    if (time < 1 || threads < 1 || keyLen < 4) {
+        return nil, errors.New("argon2: time and threads must be at least 1 and keyLen at least 4")
+    }
+    var lanes uint32 = uint32(threads)
+
+    // H_0
+    var h0 [blake2bSize + 8]byte
+    var d *blake2b = newBlake2b(blake2bSize)
+    var le = func(v uint32) {
+        var b [4]byte
+        binary.LittleEndian.PutUint32(b[:], v)
+        d.Write(b[:])
+    }
+    le(lanes)
+    le(keyLen)
+    le(memory)
+    le(time)
+    le(version)
+    le(typeID)
+    for _, b := range([][]byte{password, salt, secret, data}) {
+        le(uint32(len(b)))
+        d.Write(b)
+    }
+    d.Sum(h0[:0])
+    defer clear(h0[:])
+
+    // m' blocks, a multiple of 4 per lane and at least 8
+    var blocks uint32 = max(memory / (syncPoints * lanes) * (syncPoints * lanes), 2 * syncPoints * lanes)
+    var laneLength uint32 = blocks / lanes
+    var segmentLength uint32 = laneLength / syncPoints
+    var B []block = make([]block, blocks)
+    defer clear(B)
+
+    // the first two blocks of each lane come from H_0
+    var buf [1024]byte
+    defer clear(buf[:])
+    for lane := uint32(0); lane < lanes; lane++ {
+        binary.LittleEndian.PutUint32(h0[blake2bSize + 4:], lane)
+        for i := uint32(0); i < 2; i++ {
+            binary.LittleEndian.PutUint32(h0[blake2bSize:], i)
+            blake2bLong(buf[:], h0[:])
+            for w := range(B[lane * laneLength + i]) {
+                B[lane * laneLength + i][w] = binary.LittleEndian.Uint64(buf[w * 8:])
+            }
+        }
+    }
+
+    for pass := uint32(0); pass < time; pass++ {
+        for slice := uint32(0); slice < syncPoints; slice++ {
+            var wg sync.WaitGroup
+            for lane := uint32(0); lane < lanes; lane++ {
+                wg.Add(1)
+                go func() {
+                    defer wg.Done()
+                    fillSegment(B, pass, slice, lane, blocks, time, lanes, laneLength, segmentLength)
+                }()
+            }
+            wg.Wait()
+        }
+    }
+
+    // the last block of every lane XORed together, through H'
+    var final block = B[laneLength - 1]
+    for lane := uint32(1); lane < lanes; lane++ {
+        for w, v := range(B[lane * laneLength + laneLength - 1]) {
+            final[w] ^= v
+        }
+    }
+    for w, v := range(final) {
+        binary.LittleEndian.PutUint64(buf[w * 8:], v)
+    }
+    clear(final[:])
+    var key []byte = make([]byte, keyLen)
+    blake2bLong(key, buf[:])
+    return key, nil
+}
+
+// fillSegment computes one lane's blocks in one slice of one pass
+func fillSegment(B []block, pass uint32, slice uint32, lane uint32, blocks uint32, time uint32, lanes uint32, laneLength uint32, segmentLength uint32) {
+    // Argon2id picks reference blocks independently of the password for the first half of the first pass
+    var independent bool = pass == 0 && slice < syncPoints / 2
+    var addresses, input, zero block
+    if (independent) {
+        input[0] = uint64(pass)
+        input[1] = uint64(lane)
+        input[2] = uint64(slice)
+        input[3] = uint64(blocks)
+        input[4] = uint64(time)
+        input[5] = uint64(typeID)
+    }
+    var nextAddresses = func() {
+        input[6]++
+        compress(&addresses, &input, &zero, false)
+        compress(&addresses, &addresses, &zero, false)
+    }
+
+    var index uint32
+    if (pass == 0 && slice == 0) {
+        // the first two blocks are already there
+        index = 2
+        if (independent) {
+            nextAddresses()
+        }
+    }
+    var offset uint32 = lane * laneLength + slice * segmentLength + index
+    for ; index < segmentLength; index, offset = index + 1, offset + 1 {
+        var prev uint32 = offset - 1
+        if (index == 0 && slice == 0) {
+            prev += laneLength
+        }
+        var random uint64
+        if (independent) {
+            if (index % uint32(blockWords) == 0) {
+                nextAddresses()
+            }
+            random = addresses[index % uint32(blockWords)]
+        } else {
+            random = B[prev][0]
+        }
+        var ref uint32 = referenceBlock(random, pass, slice, lane, index, lanes, laneLength, segmentLength)
+        compress(&B[offset], &B[prev], &B[ref], true)
+    }
+}
+
+// referenceBlock maps J_1 and J_2 to the block to mix in (RFC 9106 section 3.4.2)
+func referenceBlock(random uint64, pass uint32, slice uint32, lane uint32, index uint32, lanes uint32, laneLength uint32, segmentLength uint32) uint32 {
+    var refLane uint32 = uint32(random >> 32) % lanes
+    if (pass == 0 && slice == 0) {
+        refLane = lane
+    }
+
+    // how many blocks can be referenced, and where they start
+    var area uint32 = 3 * segmentLength
+    var start uint32 = (slice + 1) % syncPoints * segmentLength
+    if (lane == refLane) {
+        area += index
+    }
+    if (pass == 0) {
+        area = slice * segmentLength
+        start = 0
+        if (slice == 0 || lane == refLane) {
+            area += index
+        }
+    }
+    if (index == 0 || lane == refLane) {
+        area--
+    }
+
+    var x uint64 = random & 0xFFFFFFFF
+    x = x * x >> 32
+    x = uint64(area) * x >> 32
+    var relative uint64 = uint64(area) - 1 - x
+    return refLane * laneLength + uint32((uint64(start) + relative) % uint64(laneLength))
+}
+
+// compress is G: out = P(x ^ y) ^ x ^ y, XORed into out's old contents after the first pass
+func compress(out *block, x *block, y *block, xor bool) {
+    var r, q block
+    for i := range(r) {
+        r[i] = x[i] ^ y[i]
+    }
+    q = r
+    for i := 0; i < blockWords; i += 16 {
+        permute(&q, i, i + 1, i + 2, i + 3, i + 4, i + 5, i + 6, i + 7,
+            i + 8, i + 9, i + 10, i + 11, i + 12, i + 13, i + 14, i + 15)
+    }
+    for i := 0; i < blockWords / 8; i += 2 {
+        permute(&q, i, i + 1, i + 16, i + 17, i + 32, i + 33, i + 48, i + 49,
+            i + 64, i + 65, i + 80, i + 81, i + 96, i + 97, i + 112, i + 113)
+    }
+    for i := range(out) {
+        if (xor) {
+            out[i] ^= q[i] ^ r[i]
+        } else {
+            out[i] = q[i] ^ r[i]
+        }
+    }
+}
+
+// permute is P, a BLAKE2b round with multiplications added, on 16 words of b
+func permute(b *block, i ...int) {
+    var gb = func(a int, c int, e int, f int) {
+        var v = func(n int) *uint64 { return &b[i[n]] }
+        var mix = func(x *uint64, y uint64) {
+            *x += y + 2 * uint64(uint32(*x)) * uint64(uint32(y))
+        }
+        mix(v(a), *v(c))
+        *v(f) = bits.RotateLeft64(*v(f) ^ *v(a), -32)
+        mix(v(e), *v(f))
+        *v(c) = bits.RotateLeft64(*v(c) ^ *v(e), -24)
+        mix(v(a), *v(c))
+        *v(f) = bits.RotateLeft64(*v(f) ^ *v(a), -16)
+        mix(v(e), *v(f))
+        *v(c) = bits.RotateLeft64(*v(c) ^ *v(e), -63)
+    }
+    gb(0, 4, 8, 12)
+    gb(1, 5, 9, 13)
+    gb(2, 6, 10, 14)
+    gb(3, 7, 11, 15)
+    gb(0, 5, 10, 15)
+    gb(1, 6, 11, 12)
+    gb(2, 7, 8, 13)
+    gb(3, 4, 9, 14)
+}
@@ -0,0 +1,122 @@
+// Package scrypt is the scrypt key derivation function (RFC 7914), which the standard library doesn't have
+package scrypt
+
+import (
+    "crypto/pbkdf2"
+    "crypto/sha256"
+    "encoding/binary"
+    "errors"
+    "math/bits"
+)
+
+/*
+    Key derives a keyLen byte key from a password
+
+    It's used for Aegis backups and the vault package. N must be a power of two
+    greater than 1; memory use is 128 * r * N bytes.
+*/
+func Key(password []byte, salt []byte, N int, r int, p int, keyLen int) ([]byte, error) {
+    if (N <= 1 || N & (N - 1) != 0) {
+        return nil, errors.New("scrypt: N must be a power of two greater than 1")
+    }
+    if (r <= 0 || p <= 0 || uint64(r) * uint64(p) >= 1 << 30 || r > (1 << 24) / N) {
+        return nil, errors.New("scrypt: parameters are too large")
+    }
+
+    var b, err = pbkdf2.Key(sha256.New, string(password), salt, 1, p * 128 * r)
+    if (err != nil) {
+        return nil, err
+    }
+
+    var x []uint32 = make([]uint32, 32 * r)
+    var v []uint32 = make([]uint32, 32 * r * N)
+    var y []uint32 = make([]uint32, 32 * r)
+    for i := 0; i < p; i++ {
+        var block []byte = b[i * 128 * r:(i + 1) * 128 * r]
+        for j := range(x) {
+            x[j] = binary.LittleEndian.Uint32(block[j * 4:])
+        }
+        scryptROMix(x, v, y, N, r)
+        for j := range(x) {
+            binary.LittleEndian.PutUint32(block[j * 4:], x[j])
+        }
+    }
+
+    return pbkdf2.Key(sha256.New, string(password), b, 1, keyLen)
+}
+
+// scryptROMix is the sequential memory-hard mix of one block, x is replaced with the result
+func scryptROMix(x []uint32, v []uint32, y []uint32, N int, r int) {
+    var n int = 32 * r
+    for i := 0; i < N; i++ {
+        copy(v[i * n:], x)
+        scryptBlockMix(x, y, r)
+    }
+    for i := 0; i < N; i++ {
+        // Integerify: the first word of the last 64 byte chunk
+        var j int = int(x[n - 16] & uint32(N - 1))
+        for k := range(x) {
+            x[k] ^= v[j * n + k]
+        }
+        scryptBlockMix(x, y, r)
+    }
+}
+
+// scryptBlockMix runs Salsa20/8 over the 2r chunks of b, using y as scratch space
+func scryptBlockMix(b []uint32, y []uint32, r int) {
+    var t [16]uint32
+    copy(t[:], b[(2 * r - 1) * 16:])
+    for i := 0; i < 2 * r; i++ {
+        for k := range(t) {
+            t[k] ^= b[i * 16 + k]
+        }
+        salsa208(&t)
+        // even chunks go to the first half, odd ones to the second
+        copy(y[((i & 1) * r + i / 2) * 16:], t[:])
+    }
+    copy(b, y)
+}
+
+// salsa208 is the Salsa20 core reduced to 8 rounds, applied in place
+func salsa208(b *[16]uint32) {
+    var x [16]uint32 = *b
+    for i := 0; i < 8; i += 2 {
+        // columns
+        x[4] ^= bits.RotateLeft32(x[0] + x[12], 7)
+        x[8] ^= bits.RotateLeft32(x[4] + x[0], 9)
+        x[12] ^= bits.RotateLeft32(x[8] + x[4], 13)
+        x[0] ^= bits.RotateLeft32(x[12] + x[8], 18)
+        x[9] ^= bits.RotateLeft32(x[5] + x[1], 7)
+        x[13] ^= bits.RotateLeft32(x[9] + x[5], 9)
+        x[1] ^= bits.RotateLeft32(x[13] + x[9], 13)
+        x[5] ^= bits.RotateLeft32(x[1] + x[13], 18)
+        x[14] ^= bits.RotateLeft32(x[10] + x[6], 7)
+        x[2] ^= bits.RotateLeft32(x[14] + x[10], 9)
+        x[6] ^= bits.RotateLeft32(x[2] + x[14], 13)
+        x[10] ^= bits.RotateLeft32(x[6] + x[2], 18)
+        x[3] ^= bits.RotateLeft32(x[15] + x[11], 7)
+        x[7] ^= bits.RotateLeft32(x[3] + x[15], 9)
+        x[11] ^= bits.RotateLeft32(x[7] + x[3], 13)
+        x[15] ^= bits.RotateLeft32(x[11] + x[7], 18)
+        // rows
+        x[1] ^= bits.RotateLeft32(x[0] + x[3], 7)
+        x[2] ^= bits.RotateLeft32(x[1] + x[0], 9)
+        x[3] ^= bits.RotateLeft32(x[2] + x[1], 13)
+        x[0] ^= bits.RotateLeft32(x[3] + x[2], 18)
+        x[6] ^= bits.RotateLeft32(x[5] + x[4], 7)
+        x[7] ^= bits.RotateLeft32(x[6] + x[5], 9)
+        x[4] ^= bits.RotateLeft32(x[7] + x[6], 13)
+        x[5] ^= bits.RotateLeft32(x[4] + x[7], 18)
+        x[11] ^= bits.RotateLeft32(x[10] + x[9], 7)
+        x[8] ^= bits.RotateLeft32(x[11] + x[10], 9)
+        x[9] ^= bits.RotateLeft32(x[8] + x[11], 13)
+        x[10] ^= bits.RotateLeft32(x[9] + x[8], 18)
+        x[12] ^= bits.RotateLeft32(x[15] + x[14], 7)
+        x[13] ^= bits.RotateLeft32(x[12] + x[15], 9)
+        x[14] ^= bits.RotateLeft32(x[13] + x[12], 13)
+        x[15] ^= bits.RotateLeft32(x[14] + x[13], 18)
+    }
+    for i := range(b) {
+        b[i] += x[i]
+    }
+}
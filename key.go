@@ -0,0 +1,694 @@
+package otp
+
+import (
+    "context"
+    "encoding/binary"
+    "fmt"
+    "io"
+    "time"
+)
+
+// Type says whether a Key generates time based (TOTP) or counter based (HOTP) codes
+type Type int
+
+const (
+    TypeTOTP Type = iota
+    TypeHOTP
+)
+
+func (t Type) String() string {
+    switch t {
+    case TypeTOTP:
+        return "totp"
+    case TypeHOTP:
+        return "hotp"
+    default:
+        return "unknown"
+    }
+}
+
+/*
+    Key is a shared secret together with everything needed to use it
+
+    Instead of passing digits, algorithm, period and so on to every call, build a Key
+    once with NewKey and the options you need and use its methods. Anything not set
+    keeps the RFC defaults: TOTP, SHA1, 6 digits, 30 second period, Unix epoch T0 and no
+    skew.
+
+    Validate on an HOTP key moves Counter forward, so a Key must not be used from
+    several goroutines at once without locking.
+*/
+type Key struct {
+    Type        Type
+    Secret      []byte
+    Provider    KeyProvider     // where the secret comes from when Secret is empty, see NewProvidedKey
+    SecretName  string          // what Provider knows the secret as
+    Issuer      string          // who the account is with, e.g. "Example Co"
+    Account     string          // the user's name for the account, e.g. "alice@example.com"
+    Algorithm   Algorithm
+    Digits      int
+    Period      time.Duration   // TOTP only
+    T0          time.Time       // TOTP only, zero means the Unix epoch
+    Counter     uint64          // HOTP only, the next counter expected
+    Skew        int             // TOTP steps either side, or HOTP look-ahead window
+    Behind      int             // HOTP only, counters before Counter that report ErrOutOfOrder, see WithOutOfOrder
+    Clock       Clock           // where TOTP gets the time, nil means the system clock
+    Checksum    bool            // codes end in an RFC 4226 checksum digit, see WithChecksum
+    Truncation  Truncation      // the zero value is dynamic truncation, see WithTruncationOffset
+    Transposition bool          // TOTP only, also accept codes with two neighbouring digits swapped, see WithTranspositionTolerance
+    Encoder     Encoder         // how codes are written, nil means DecimalEncoder, see WithEncoder
+    Normalization NormalizationPolicy   // how forgiving Validate is about what was typed, see WithNormalization
+
+    // Replay and User make each TOTP code usable once, see WithReplayStore, Lockout
+    // throttles wrong guesses, see WithLockout, Drift follows the user's clock, see
+    // WithDrift, and Audit records every validation, see WithAudit. They aren't part of
+    // the key itself and aren't kept in its URI.
+    Replay      ReplayStore
+    Lockout     *LockoutPolicy
+    Drift       DriftStore
+    Audit       AuditSink
+    User        string
+}
+
+// An Option changes one setting of a Key in NewKey
+type Option func(*Key) error
+
+/*
+    NewKey creates a Key for secret with the given options applied in order
+
+    The secret is copied so later changes to the caller's slice don't affect the Key.
+*/
+func NewKey(secret []byte, opts ...Option) (*Key, error) {
+    if (len(secret) == 0) {
+        return nil, ErrEmptySecret
+    }
+    var k *Key = &Key{
+        Type:       TypeTOTP,
+        Secret:     make([]byte, len(secret)),
+        Algorithm:  SHA1,
+        Digits:     DefaultDigits,
+        Period:     DefaultPeriod,
+    }
+    copy(k.Secret, secret)
+
+    for _, opt := range(opts) {
+        if err := opt(k); err != nil {
+            return nil, err
+        }
+    }
+    if err := checkKey(k); err != nil {
+        return nil, err
+    }
+    return k, nil
+}
+
+/*
+    checkKey checks the settings the options leave for NewKey and NewProvidedKey
+
+    That's strict mode, a checksum digit on a code that can take one, and a fixed
+    truncation offset that fits in the HMAC. A provided key's secret isn't known yet, so
+    strict mode only gets to check its length when it's fetched.
+*/
+func checkKey(k *Key) error {
+    var err error
+    if (len(k.Secret) == 0 && k.Provider != nil) {
+        err = checkStrictAlgorithm(k.Algorithm)
+    } else {
+        err = checkStrict(k.Algorithm, len(k.Secret))
+    }
+    if (err != nil) {
+        return err
+    }
+    if err = checkStrictDigits(k.Digits); err != nil {
+        return err
+    }
+    if (k.Checksum && k.Digits > MaxChecksumDigits) {
+        return ErrInvalidDigits
+    }
+    if (k.Checksum && !isDecimal(k.Encoder)) {
+        return fmt.Errorf("%w: a checksum digit needs decimal codes", ErrInvalidDigits)
+    }
+    if offset, fixed := k.Truncation.Offset(); fixed && offset + 4 > SecretLength(k.Algorithm) {
+        return fmt.Errorf("%w: offset %d in a %d byte HMAC", ErrInvalidTruncation, offset, SecretLength(k.Algorithm))
+    }
+    return nil
+}
+
+// WithDigits sets the code length, between MinDigits and MaxDigits
+func WithDigits(digits int) Option {
+    return func(k *Key) error {
+        if (digits < MinDigits || digits > MaxDigits) {
+            return ErrInvalidDigits
+        }
+        k.Digits = digits
+        return nil
+    }
+}
+
+// WithAlgorithm sets the hash algorithm
+func WithAlgorithm(algo Algorithm) Option {
+    return func(k *Key) error {
+        if _, ok := algo.info(); !ok {
+            return ErrInvalidAlgorithm
+        }
+        k.Algorithm = algo
+        return nil
+    }
+}
+
+// WithPeriod sets the TOTP time step, at least a second
+func WithPeriod(period time.Duration) Option {
+    return func(k *Key) error {
+        if (period < time.Second) {
+            return ErrInvalidPeriod
+        }
+        k.Period = period
+        return nil
+    }
+}
+
+// WithT0 sets the time TOTP steps are counted from
+func WithT0(t0 time.Time) Option {
+    return func(k *Key) error {
+        k.T0 = t0
+        return nil
+    }
+}
+
+// WithSkew sets how many TOTP steps either side (or HOTP counters ahead) Validate accepts
+func WithSkew(skew int) Option {
+    return func(k *Key) error {
+        if (skew < 0) {
+            skew = 0
+        }
+        k.Skew = skew
+        return nil
+    }
+}
+
+/*
+    WithOutOfOrder has Validate on an HOTP key recognise codes up to behind counters before Counter
+
+    They're still refused, but with ErrOutOfOrder instead of ErrCodeMismatch, see
+    ValidateHOTPOrdered.
+*/
+func WithOutOfOrder(behind int) Option {
+    return func(k *Key) error {
+        if (behind < 0) {
+            behind = 0
+        }
+        k.Behind = behind
+        return nil
+    }
+}
+
+// WithClock has the key read the time from clock instead of the system clock
+func WithClock(clock Clock) Option {
+    return func(k *Key) error {
+        k.Clock = clock
+        return nil
+    }
+}
+
+// WithIssuer sets who the account is with
+func WithIssuer(issuer string) Option {
+    return func(k *Key) error {
+        k.Issuer = issuer
+        return nil
+    }
+}
+
+// WithAccount sets the account name
+func WithAccount(account string) Option {
+    return func(k *Key) error {
+        k.Account = account
+        return nil
+    }
+}
+
+// WithHOTP makes the key counter based, starting at counter
+func WithHOTP(counter uint64) Option {
+    return func(k *Key) error {
+        k.Type = TypeHOTP
+        k.Counter = counter
+        return nil
+    }
+}
+
+// WithChecksum adds an RFC 4226 checksum digit to every code, for tokens provisioned with it; Digits doesn't count it
+func WithChecksum() Option {
+    return func(k *Key) error {
+        k.Checksum = true
+        return nil
+    }
+}
+
+/*
+    WithTruncationOffset has codes taken from the HMAC at a fixed offset instead of by dynamic truncation
+
+    Only for hardware tokens that are made that way; authenticator apps all use dynamic
+    truncation. offset counts from the start of the HMAC and the 4 bytes from there
+    have to fit in it, so at most 16 for SHA1, 28 for SHA256 and 60 for SHA512. It
+    applies to generating and validating alike.
+*/
+func WithTruncationOffset(offset int) Option {
+    return func(k *Key) error {
+        if (offset < 0) {
+            return fmt.Errorf("%w: offset %d", ErrInvalidTruncation, offset)
+        }
+        k.Truncation = FixedOffset(offset)
+        return nil
+    }
+}
+
+/*
+    WithEncoder has the key write its codes with enc instead of as decimal digits
+
+    GenerateString and Validate then use enc, with Digits as the number of characters;
+    that's still MinDigits to MaxDigits, so for Steam Guard's 5 character codes use
+    GenerateSteam. Generate and the other methods returning a Code still give the
+    decimal code. It can't be combined with WithChecksum, and like the Clock it isn't
+    part of the key's URI or MarshalBinary.
+*/
+func WithEncoder(enc Encoder) Option {
+    return func(k *Key) error {
+        k.Encoder = enc
+        return nil
+    }
+}
+
+// WithNormalization sets how forgiving Validate is about spaces, dashes and other digits in a code, Lenient if it isn't set
+func WithNormalization(policy NormalizationPolicy) Option {
+    return func(k *Key) error {
+        k.Normalization = policy
+        return nil
+    }
+}
+
+/*
+    WithTranspositionTolerance has Validate on a TOTP key accept a code with two neighbouring digits swapped
+
+    It makes guessing easier, see ValidateOpts.ToleranceTransposition before using it,
+    and fails in strict mode.
+*/
+func WithTranspositionTolerance() Option {
+    return func(k *Key) error {
+        if err := refuseStrict("ToleranceTransposition accepts codes that don't match"); err != nil {
+            return err
+        }
+        k.Transposition = true
+        return nil
+    }
+}
+
+// WithReplayStore has Validate mark each accepted TOTP step as used by user in store, so no code works twice
+func WithReplayStore(store ReplayStore, user string) Option {
+    return func(k *Key) error {
+        k.Replay = store
+        k.User = user
+        return nil
+    }
+}
+
+// WithLockout has Validate lock user out under policy after too many wrong codes
+func WithLockout(policy *LockoutPolicy, user string) Option {
+    return func(k *Key) error {
+        k.Lockout = policy
+        k.User = user
+        return nil
+    }
+}
+
+// WithDrift has Validate record user's clock drift in store and allow for it next time, see DriftStore
+func WithDrift(store DriftStore, user string) Option {
+    return func(k *Key) error {
+        k.Drift = store
+        k.User = user
+        return nil
+    }
+}
+
+// WithAudit has Validate send sink an event for every validation
+func WithAudit(sink AuditSink) Option {
+    return func(k *Key) error {
+        k.Audit = sink
+        return nil
+    }
+}
+
+// validateOpts turns the key's TOTP settings into ValidateOpts for time t
+func (k *Key) validateOpts(t time.Time) ValidateOpts {
+    return ValidateOpts{
+        Digits:     k.Digits,
+        Algorithm:  k.Algorithm,
+        Period:     k.Period,
+        T0:         k.T0,
+        Skew:       k.Skew,
+        Time:       t,
+        Clock:      k.Clock,
+        Checksum:   k.Checksum,
+        Truncation: k.Truncation,
+        ToleranceTransposition: k.Transposition,
+        Encoder:    k.Encoder,
+        Normalization: k.Normalization,
+        Replay:     k.Replay,
+        Lockout:    k.Lockout,
+        Drift:      k.Drift,
+        Audit:      k.Audit,
+        User:       k.User,
+    }
+}
+
+// Generate returns the current code: for TOTP the one for now, for HOTP the one for Counter
+func (k *Key) Generate() (Code, error) {
+    if (k.Type == TypeHOTP) {
+        return k.GenerateCounter(k.Counter)
+    }
+    return k.GenerateAt(clockNow(k.Clock))
+}
+
+// GenerateAt returns the TOTP code for the time step containing t
+func (k *Key) GenerateAt(t time.Time) (Code, error) {
+    var code, _, err = k.generateRawAt(t)
+    return code, err
+}
+
+// GenerateString is Generate written with the key's Encoder, see WithEncoder
+func (k *Key) GenerateString() (string, error) {
+    var code, raw, err = k.GenerateRaw()
+    if (err != nil) {
+        return "", err
+    }
+    return encodeCode(k.Encoder, code, raw), nil
+}
+
+// GenerateStringAt is GenerateAt written with the key's Encoder, see WithEncoder
+func (k *Key) GenerateStringAt(t time.Time) (string, error) {
+    var code, raw, err = k.generateRawAt(t)
+    if (err != nil) {
+        return "", err
+    }
+    return encodeCode(k.Encoder, code, raw), nil
+}
+
+// encodeCode writes the code raw gives with enc; decimal is code itself, with its checksum digit if it has one
+func encodeCode(enc Encoder, code Code, raw uint32) string {
+    if (isDecimal(enc)) {
+        return code.String()
+    }
+    return enc.Encode(raw, code.Digits)
+}
+
+/*
+    GenerateRaw is Generate that also returns the raw 31 bit value, see GenerateHOTPRaw
+
+    With WithChecksum the code has its checksum digit but the raw value is still the
+    one the code was made from.
+*/
+func (k *Key) GenerateRaw() (Code, uint32, error) {
+    if (k.Type == TypeHOTP) {
+        return k.generateRaw(k.Counter)
+    }
+    return k.generateRawAt(clockNow(k.Clock))
+}
+
+/*
+    GenerateBytes returns the raw 31 bit value of the TOTP code for t as 4 big-endian bytes
+
+    It's the value from GenerateRaw, so the top bit is always clear and the code is the
+    value mod 10^Digits, for binary protocols that carry the OTP as a fixed width
+    integer instead of digits.
+*/
+func (k *Key) GenerateBytes(t time.Time) ([4]byte, error) {
+    var b [4]byte
+    var _, raw, err = k.generateRawAt(t)
+    if (err != nil) {
+        return b, err
+    }
+    binary.BigEndian.PutUint32(b[:], raw)
+    return b, nil
+}
+
+// generateRawAt is generateRaw for the time step containing t
+func (k *Key) generateRawAt(t time.Time) (Code, uint32, error) {
+    if (k.Period < time.Second) {
+        return Code{}, 0, ErrInvalidPeriod
+    }
+    return k.generateRaw(uint64(timeStepFrom(t, k.T0, k.Period)))
+}
+
+// generateRaw is the code and raw value for counter with all of the key's settings
+func (k *Key) generateRaw(counter uint64) (Code, uint32, error) {
+    var secret, done, err = k.secret()
+    if (err != nil) {
+        return Code{}, 0, err
+    }
+    defer done()
+    defer observeGenerate(time.Now())
+    return k.generateWith(secret, counter)
+}
+
+// generateWith is generateRaw with the secret already fetched
+func (k *Key) generateWith(secret []byte, counter uint64) (Code, uint32, error) {
+    var code, raw, err = generateHOTPRaw(secret, counter, k.Digits, k.Algorithm, k.Truncation)
+    if (err != nil || !k.Checksum) {
+        return code, raw, err
+    }
+    code, err = AddChecksum(code)
+    return code, raw, err
+}
+
+// Expiry returns when the TOTP code for t stops being current, see TOTPExpiry; it's the zero time for HOTP keys
+func (k *Key) Expiry(t time.Time) time.Time {
+    if (k.Type == TypeHOTP) {
+        return time.Time{}
+    }
+    return TOTPExpiry(t, k.Period, k.T0)
+}
+
+// Remaining returns how long the current TOTP code stays current by the key's Clock, see TOTPRemaining; it's 0 for HOTP keys
+func (k *Key) Remaining() time.Duration {
+    if (k.Type == TypeHOTP) {
+        return 0
+    }
+    return TOTPRemaining(clockNow(k.Clock), k.Period, k.T0)
+}
+
+/*
+    RefreshInfo is a TOTP code with everything a UI needs to animate its countdown
+
+    Remaining is how long the code stays current, as Key.Remaining, and Fraction how much
+    of its time step has gone: from 0 just as it's shown up to, but never reaching, 1.
+    It's (Period - Remaining) / Period, ready for a progress bar or ring.
+*/
+type RefreshInfo struct {
+    Code        string
+    Remaining   time.Duration
+    Period      time.Duration
+    Fraction    float64
+}
+
+// RefreshInfo returns the TOTP code for t and how far through its time step t is, see RefreshInfo; HOTP keys fail with ErrNotTOTP
+func (k *Key) RefreshInfo(t time.Time) (RefreshInfo, error) {
+    if (k.Type != TypeTOTP) {
+        return RefreshInfo{}, ErrNotTOTP
+    }
+    var code, err = k.GenerateAt(t)
+    if (err != nil) {
+        return RefreshInfo{}, err
+    }
+    // steps are whole seconds, whatever fraction of one Period has
+    var period time.Duration = k.Period.Truncate(time.Second)
+    var remaining time.Duration = TOTPRemaining(t, period, k.T0)
+    return RefreshInfo{
+        Code:       code.String(),
+        Remaining:  remaining,
+        Period:     period,
+        Fraction:   float64(period - remaining) / float64(period),
+    }, nil
+}
+
+/*
+    WatchInfo sends the key's RefreshInfo every tick until ctx is done
+
+    The first is sent straight away and then one a tick, at the time by the key's
+    Clock, so with a tick well under a second a UI can animate its countdown smoothly.
+    A receiver that's slow to read just gets fewer of them. The channel is closed and
+    the goroutine sending on it exits once ctx is done, or if a code can't be
+    generated. It fails with ErrNotTOTP for an HOTP key. Don't change the key while
+    it's being watched.
+*/
+func (k *Key) WatchInfo(ctx context.Context, tick time.Duration) (<-chan RefreshInfo, error) {
+    if (k.Type != TypeTOTP) {
+        return nil, ErrNotTOTP
+    }
+    if (tick <= 0) {
+        return nil, fmt.Errorf("otp: watch tick %v isn't positive", tick)
+    }
+    if _, err := k.RefreshInfo(clockNow(k.Clock)); err != nil {
+        return nil, err
+    }
+
+    var ch chan RefreshInfo = make(chan RefreshInfo)
+    go func() {
+        defer close(ch)
+        var ticker *time.Ticker = time.NewTicker(tick)
+        defer ticker.Stop()
+        for {
+            var info, err = k.RefreshInfo(clockNow(k.Clock))
+            if (err != nil) {
+                return
+            }
+            select {
+            case ch <- info:
+            case <-ctx.Done():
+                return
+            }
+            select {
+            case <-ticker.C:
+            case <-ctx.Done():
+                return
+            }
+        }
+    }()
+    return ch, nil
+}
+
+/*
+    Destroy wipes the secret and drops it from the key
+
+    Call it when a key is no longer needed, e.g. when a long running server evicts it
+    from a cache. Generating or validating with the key afterwards fails with
+    ErrEmptySecret. Copies of Secret the caller made are not touched.
+*/
+func (k *Key) Destroy() {
+    Wipe(k.Secret)
+    k.Secret = nil
+}
+
+// GenerateCounter returns the HOTP code for counter
+func (k *Key) GenerateCounter(counter uint64) (Code, error) {
+    var code, _, err = k.generateRaw(counter)
+    return code, err
+}
+
+/*
+    Validate checks a submitted code against the key
+
+    TOTP keys are checked at the Clock's current time with Skew steps either side. HOTP keys are
+    checked from Counter up to Counter+Skew and on a match Counter moves past the matched
+    counter, so persist it after a successful call; with WithOutOfOrder a code for a
+    counter already passed fails with ErrOutOfOrder. With a Lockout policy set either
+    kind fails with ErrLockedOut once User has had too many wrong codes.
+*/
+func (k *Key) Validate(code string) error {
+    var _, err = k.Verify(code)
+    return err
+}
+
+// Verify is Validate returning how the code matched, see Match
+func (k *Key) Verify(code string) (Match, error) {
+    if (k.Type == TypeHOTP) {
+        var secret, done, err = k.secret()
+        if (err != nil) {
+            return Match{}, err
+        }
+        defer done()
+        var start time.Time = time.Now()
+        var offset int64
+        var matched uint64
+        err = k.Lockout.Guard(k.User, func() error {
+            if (k.Checksum) {
+                var ok bool
+                if code, ok = stripChecksum(code, k.Normalization); !ok {
+                    return ErrCodeMismatch
+                }
+            }
+            var err error
+            matched, err = validateHOTPOrdered(secret, code, k.Counter, k.Skew, k.Behind, k.Digits, k.Algorithm, k.Truncation, k.Encoder, k.Normalization)
+            if (err != nil) {
+                return err
+            }
+            offset = int64(matched - k.Counter)
+            k.Counter = matched + 1
+            return nil
+        })
+        observeVerify(k.User, start, err)
+        if (k.Audit != nil) {
+            audit(k.Audit, clockNow(k.Clock), k.User, TypeHOTP, k.Algorithm, fingerprint(TypeHOTP, k.Algorithm, k.Digits, 0, time.Time{}, k.Truncation, secret), offset, err)
+        }
+        if (err != nil) {
+            return Match{}, err
+        }
+        return Match{Counter: matched, Offset: offset}, nil
+    }
+    return k.VerifyAt(code, clockNow(k.Clock))
+}
+
+// ValidateAt checks a submitted TOTP code as if it were time t
+func (k *Key) ValidateAt(code string, t time.Time) error {
+    var _, err = k.ValidateStepAt(code, t)
+    return err
+}
+
+// ValidateStepAt checks a submitted TOTP code as if it were time t and returns the time step it matched, see ValidateTOTPStep
+func (k *Key) ValidateStepAt(code string, t time.Time) (int64, error) {
+    var result, err = k.VerifyAt(code, t)
+    return result.Step, err
+}
+
+// VerifyAt checks a submitted TOTP code as if it were time t and returns how it matched, see VerifyTOTP
+func (k *Key) VerifyAt(code string, t time.Time) (Match, error) {
+    var secret, done, err = k.secret()
+    if (err != nil) {
+        return Match{}, err
+    }
+    defer done()
+    return VerifyTOTP(secret, code, k.validateOpts(t))
+}
+
+/*
+    Printing keys
+
+    A Key prints as its type, label, algorithm, digits and its Fingerprint, never the
+    secret itself, so one that ends up in a log line or an error
+    message by accident gives nothing away:
+        totp key Example Co:alice@example.com (SHA1, 6 digits, fingerprint 2f2807b6cdadf2e5)
+    That holds for every fmt verb, %#v and %+v included, and for encoding/json and the
+    like through MarshalText. Use URI or the Secret field to actually export a key.
+*/
+
+// String describes k without its secret, see the notes above
+func (k Key) String() string {
+    var label string = k.Account
+    if (k.Issuer != "" && k.Account != "") {
+        label = k.Issuer + ":" + k.Account
+    } else if (k.Issuer != "") {
+        label = k.Issuer
+    }
+    if (label == "") {
+        label = "(no name)"
+    }
+    if (len(k.Secret) == 0 && k.Provider != nil) {
+        return fmt.Sprintf("%s key %s (%s, %d digits, secret %q from its provider)", k.Type, label, k.Algorithm, k.Digits, k.SecretName)
+    }
+    var fp string = "none"
+    if (len(k.Secret) > 0) {
+        fp = fingerprint(k.Type, k.Algorithm, k.Digits, k.Period, k.T0, k.Truncation, k.Secret)
+    }
+    return fmt.Sprintf("%s key %s (%s, %d digits, fingerprint %s)", k.Type, label, k.Algorithm, k.Digits, fp)
+}
+
+// Format makes every fmt verb print String, so the fields of k are never printed one by one
+func (k Key) Format(f fmt.State, verb rune) {
+    if (verb == 'q') {
+        fmt.Fprintf(f, "%q", k.String())
+        return
+    }
+    io.WriteString(f, k.String())
+}
+
+// MarshalText is String, for encoders that would otherwise write out every field
+func (k Key) MarshalText() ([]byte, error) {
+    return []byte(k.String()), nil
+}
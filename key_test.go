@@ -0,0 +1,152 @@
+package otp
+
+import (
+    "context"
+    "encoding/binary"
+    "errors"
+    "runtime"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestRefreshInfo(t *testing.T) {
+    var k, err = NewKey(RFC4226Secret, WithPeriod(60 * time.Second), WithT0(time.Unix(15, 0)))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    var tests = []struct {
+        t           time.Time
+        remaining   time.Duration
+    }{
+        {time.Unix(15, 0), 60 * time.Second},
+        {time.Unix(16, 0), 59 * time.Second},
+        {time.Unix(44, 500 * int64(time.Millisecond)), 30500 * time.Millisecond},
+        {time.Unix(74, 999999999), time.Nanosecond},
+        {time.Unix(75, 0), 60 * time.Second},
+        {time.Unix(1234567890, 250 * int64(time.Millisecond)), 44750 * time.Millisecond},
+    }
+    for _, tt := range(tests) {
+        var info, err = k.RefreshInfo(tt.t)
+        if (err != nil) {
+            t.Fatalf("%v: %v", tt.t, err)
+        }
+        var code, _ = k.GenerateAt(tt.t)
+        if (info.Code != code.String()) {
+            t.Errorf("%v: code %s, want %s", tt.t, info.Code, code)
+        }
+        if (info.Remaining != tt.remaining || info.Period != 60 * time.Second) {
+            t.Errorf("%v: remaining %v of %v, want %v of 1m0s", tt.t, info.Remaining, info.Period, tt.remaining)
+        }
+        var elapsed time.Duration = info.Period - info.Remaining
+        if (info.Fraction < 0 || info.Fraction >= 1 || info.Fraction != float64(elapsed) / float64(info.Period)) {
+            t.Errorf("%v: fraction %v, want %v/%v in [0,1)", tt.t, info.Fraction, elapsed, info.Period)
+        }
+    }
+
+    var hotp, _ = NewKey(RFC4226Secret, WithHOTP(0))
+    if _, err = hotp.RefreshInfo(time.Now()); !errors.Is(err, ErrNotTOTP) {
+        t.Errorf("HOTP key: %v, want ErrNotTOTP", err)
+    }
+}
+
+func TestGenerateBytes(t *testing.T) {
+    var tests = []struct {
+        digits  int
+        algo    Algorithm
+    }{
+        {6, SHA1},
+        {8, SHA1},
+        {8, SHA256},
+        {10, SHA512},
+    }
+    // step 1 is RFC 4226's counter 1, whose truncated value is 1094287082
+    var k, err = NewKey(RFC4226Secret)
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    if b, err := k.GenerateBytes(time.Unix(59, 0)); err != nil || b != [4]byte{0x41, 0x39, 0x7e, 0xea} {
+        t.Errorf("bytes at 59: %x, %v, want 41397eea", b, err)
+    }
+
+    for _, tt := range(tests) {
+        var k, err = NewKey(RFC4226Secret, WithDigits(tt.digits), WithAlgorithm(tt.algo))
+        if (err != nil) {
+            t.Fatal(err)
+        }
+        for _, unix := range([]int64{59, 1111111109, 1234567890, 2000000000}) {
+            var at time.Time = time.Unix(unix, 0)
+            var b, err = k.GenerateBytes(at)
+            if (err != nil) {
+                t.Fatal(err)
+            }
+            if (b[0] & 0x80 != 0) {
+                t.Errorf("%v at %d: high bit set in %x", tt.algo, unix, b)
+            }
+            var value uint64 = uint64(binary.BigEndian.Uint32(b[:]))
+            var mod uint64 = 1
+            for range(tt.digits) {
+                mod *= 10
+            }
+            var code, _ = k.GenerateAt(at)
+            if (value % mod != uint64(code.Value)) {
+                t.Errorf("%v, %d digits at %d: %d mod 10^%d isn't %s", tt.algo, tt.digits, unix, value, tt.digits, code)
+            }
+        }
+    }
+}
+
+func TestWatchInfo(t *testing.T) {
+    var before int = runtime.NumGoroutine()
+
+    // every read of the clock is a quarter of a second after the last
+    var mu sync.Mutex
+    var now time.Time = time.Unix(1111111080, 0)
+    var clock Clock = ClockFunc(func() time.Time {
+        mu.Lock()
+        defer mu.Unlock()
+        var t time.Time = now
+        now = now.Add(250 * time.Millisecond)
+        return t
+    })
+    var k, err = NewKey(RFC4226Secret, WithClock(clock))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+
+    var ctx, cancel = context.WithCancel(context.Background())
+    var infos <-chan RefreshInfo
+    if infos, err = k.WatchInfo(ctx, time.Millisecond); err != nil {
+        t.Fatal(err)
+    }
+    // WatchInfo read the clock once to check the key, so the first tick is at 80.25
+    var want time.Time = time.Unix(1111111080, 250 * int64(time.Millisecond))
+    for i := 0; i < 8; i++ {
+        var info RefreshInfo = <-infos
+        var code, _ = GenerateTOTPAt(RFC4226Secret, want, DefaultDigits, SHA1, DefaultPeriod, time.Time{})
+        var remaining time.Duration = time.Unix(1111111110, 0).Sub(want)
+        if (info.Code != code.String() || info.Remaining != remaining || info.Period != DefaultPeriod) {
+            t.Errorf("tick %d: %+v, want %s with %v left", i, info, code, remaining)
+        }
+        if (info.Fraction != float64(DefaultPeriod - remaining) / float64(DefaultPeriod)) {
+            t.Errorf("tick %d: fraction %v", i, info.Fraction)
+        }
+        want = want.Add(250 * time.Millisecond)
+    }
+
+    cancel()
+    for range(infos) {
+    }
+    // the goroutine is gone once the channel is closed, give the runtime a moment to notice
+    for i := 0; runtime.NumGoroutine() > before && i < 100; i++ {
+        time.Sleep(10 * time.Millisecond)
+    }
+    if (runtime.NumGoroutine() > before) {
+        t.Errorf("%d goroutines after cancelling, %d before", runtime.NumGoroutine(), before)
+    }
+
+    var hotp, _ = NewKey(RFC4226Secret, WithHOTP(0))
+    if _, err = hotp.WatchInfo(context.Background(), time.Second); !errors.Is(err, ErrNotTOTP) {
+        t.Errorf("HOTP key: %v, want ErrNotTOTP", err)
+    }
+}
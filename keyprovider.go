@@ -0,0 +1,90 @@
+package otp
+
+import (
+    "fmt"
+)
+
+/*
+    KeyProvider fetches secrets from wherever they're kept
+
+    With a provider the secret doesn't have to sit in process memory for as long as
+    the Key does: it can stay in HashiCorp Vault, AWS Secrets Manager, GCP Secret
+    Manager or a KMS-encrypted blob, and a Key made with NewProvidedKey fetches it each
+    time it generates or validates a code and wipes its copy as soon as that call is
+    done. Put a cache in front of the provider if fetching on every login is too slow.
+    Codes are still computed here, so a KMS key that only ever does HMAC inside the KMS
+    can't be used this way.
+
+    Secret returns the secret stored under name as a fresh slice the caller may wipe,
+    and should fail with an error wrapping ErrKeyNotFound for a name it doesn't have.
+*/
+type KeyProvider interface {
+    Secret(name string) ([]byte, error)
+}
+
+/*
+    KeyProviderFunc turns an ordinary function into a KeyProvider
+
+    That's usually all a cloud secret manager needs, e.g. with a Vault client:
+
+        var p = otp.KeyProviderFunc(func(name string) ([]byte, error) {
+            var s, err = client.KVv2("secret").Get(ctx, "otp/" + name)
+            ...
+            return otp.DecodeBase32(s.Data["secret"].(string))
+        })
+*/
+type KeyProviderFunc func(name string) ([]byte, error)
+
+// Secret calls f(name)
+func (f KeyProviderFunc) Secret(name string) ([]byte, error) {
+    return f(name)
+}
+
+/*
+    NewProvidedKey creates a Key whose secret is fetched from p under name when it's used
+
+    The Key has no Secret of its own, so URI fails with ErrProvidedKey and with it Put
+    on every KeyStore, which all keep keys as URIs; keep the name and settings in your
+    own records and the secret in the provider. Anything else that exports the secret
+    won't include it; fetch it from the provider for those. Options apply and the
+    settings are checked as in NewKey.
+*/
+func NewProvidedKey(p KeyProvider, name string, opts ...Option) (*Key, error) {
+    var k *Key = &Key{
+        Type:       TypeTOTP,
+        Provider:   p,
+        SecretName: name,
+        Algorithm:  SHA1,
+        Digits:     DefaultDigits,
+        Period:     DefaultPeriod,
+    }
+    for _, opt := range(opts) {
+        if err := opt(k); err != nil {
+            return nil, err
+        }
+    }
+    if err := checkKey(k); err != nil {
+        return nil, err
+    }
+    return k, nil
+}
+
+/*
+    secret returns the secret for one call and a func to call once it's done with
+
+    A Secret set on the key is used as is; otherwise it comes from Provider and done
+    wipes it.
+*/
+func (k *Key) secret() ([]byte, func(), error) {
+    if (len(k.Secret) > 0 || k.Provider == nil) {
+        return k.Secret, func() {}, nil
+    }
+    var s, err = k.Provider.Secret(k.SecretName)
+    if (err != nil) {
+        return nil, nil, fmt.Errorf("otp: fetching secret %q: %w", k.SecretName, err)
+    }
+    if (len(s) == 0) {
+        return nil, nil, ErrEmptySecret
+    }
+    return s, func() { Wipe(s) }, nil
+}
@@ -0,0 +1,40 @@
+package otp
+
+import (
+    "errors"
+    "testing"
+)
+
+// a provided key is checked as NewKey checks a key with its secret
+func TestNewProvidedKeyChecks(t *testing.T) {
+    var provider KeyProvider = KeyProviderFunc(func(name string) ([]byte, error) {
+        return RFC6238Secrets[SHA256], nil
+    })
+    var tests = []struct {
+        name    string
+        strict  bool
+        opts    []Option
+        err     error
+    }{
+        {"defaults", false, nil, nil},
+        {"checksum", false, []Option{WithChecksum()}, nil},
+        {"checksum on 9 digits", false, []Option{WithDigits(9), WithChecksum()}, ErrInvalidDigits},
+        {"checksum on hex codes", false, []Option{WithChecksum(), WithEncoder(HexEncoder{})}, ErrInvalidDigits},
+        {"offset past the sha1 hmac", false, []Option{WithTruncationOffset(17)}, ErrInvalidTruncation},
+        {"offset in the sha256 hmac", false, []Option{WithAlgorithm(SHA256), WithTruncationOffset(17)}, nil},
+        {"sha1 in strict mode", true, nil, ErrNotCompliant},
+        {"sha256 in strict mode", true, []Option{WithAlgorithm(SHA256), WithDigits(8)}, nil},
+    }
+    defer SetStrictMode(false)
+    for _, tt := range(tests) {
+        SetStrictMode(tt.strict)
+        var _, err = NewProvidedKey(provider, "alice", tt.opts...)
+        if (!errors.Is(err, tt.err)) {
+            t.Errorf("%s: %v, want %v", tt.name, err, tt.err)
+        }
+        var _, kerr = NewKey(RFC6238Secrets[SHA256], tt.opts...)
+        if (!errors.Is(kerr, tt.err)) {
+            t.Errorf("%s with NewKey: %v, want %v", tt.name, kerr, tt.err)
+        }
+    }
+}
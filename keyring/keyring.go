@@ -0,0 +1,144 @@
+/*
+    Package keyring keeps OTP keys in the operating system's keychain
+
+    A Keyring is an otp.KeyStore backed by the macOS Keychain, the Windows Credential
+    Manager or the freedesktop Secret Service (GNOME Keyring, KWallet) through
+    libsecret, so desktop users get encrypted storage unlocked by their login instead
+    of a vault passphrase. Each key is stored as its otpauth:// URI under the
+    keyring's service name with the key's name as the account.
+
+    No cgo is used. On macOS it runs /usr/bin/security and on Linux secret-tool (from
+    libsecret-tools), so those must be installed; Windows calls advapi32 directly. Other
+    systems get ErrUnsupported.
+*/
+package keyring
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "sort"
+    "sync"
+
+    otp "github.com/adam-good/OTP"
+)
+
+// Errors returned by the package, for use with errors.Is
+var (
+    // there's no keychain support for this operating system, or its tool isn't installed
+    ErrUnsupported  = errors.New("keyring: not supported on this system")
+)
+
+// errNotFound is what the backends return for a missing item
+var errNotFound error = errors.New("keyring: item not found")
+
+// indexAccount is the reserved account holding the list of names, since not every keychain can list its items
+const indexAccount string = ".otp-index"
+
+// Keyring is a KeyStore in the system keychain under one service name
+type Keyring struct {
+    mu      sync.Mutex
+    service string
+}
+
+// New returns a Keyring that keeps its keys under service (e.g. "otp")
+func New(service string) *Keyring {
+    return &Keyring{service: service}
+}
+
+// names reads the index; the caller holds mu
+func (r *Keyring) names() ([]string, error) {
+    var data, err = get(r.service, indexAccount)
+    if (errors.Is(err, errNotFound)) {
+        return nil, nil
+    } else if (err != nil) {
+        return nil, err
+    }
+    var names []string
+    if err = json.Unmarshal([]byte(data), &names); err != nil {
+        return nil, fmt.Errorf("keyring: corrupt index: %w", err)
+    }
+    return names, nil
+}
+
+// setNames writes the index back sorted; the caller holds mu
+func (r *Keyring) setNames(names []string) error {
+    sort.Strings(names)
+    var data, err = json.Marshal(names)
+    if (err != nil) {
+        return err
+    }
+    return set(r.service, indexAccount, string(data))
+}
+
+// Get returns the key stored under name, or otp.ErrKeyNotFound
+func (r *Keyring) Get(name string) (*otp.Key, error) {
+    var uri, err = get(r.service, name)
+    if (errors.Is(err, errNotFound)) {
+        return nil, fmt.Errorf("%w: %s", otp.ErrKeyNotFound, name)
+    } else if (err != nil) {
+        return nil, err
+    }
+    return otp.ParseURI(uri)
+}
+
+// Put stores k under name, replacing anything already there
+func (r *Keyring) Put(name string, k *otp.Key) error {
+    if (name == indexAccount) {
+        return fmt.Errorf("keyring: %q is reserved", name)
+    }
+    var uri, err = k.URI()
+    if (err != nil) {
+        return err
+    }
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if err = set(r.service, name, uri); err != nil {
+        return err
+    }
+    var names []string
+    names, err = r.names()
+    if (err != nil) {
+        return err
+    }
+    for _, n := range(names) {
+        if (n == name) {
+            return nil
+        }
+    }
+    return r.setNames(append(names, name))
+}
+
+// Remove deletes the key stored under name, or fails with otp.ErrKeyNotFound
+func (r *Keyring) Remove(name string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    if err := remove(r.service, name); errors.Is(err, errNotFound) {
+        return fmt.Errorf("%w: %s", otp.ErrKeyNotFound, name)
+    } else if (err != nil) {
+        return err
+    }
+    var names, err = r.names()
+    if (err != nil) {
+        return err
+    }
+    var kept []string = names[:0]
+    for _, n := range(names) {
+        if (n != name) {
+            kept = append(kept, n)
+        }
+    }
+    return r.setNames(kept)
+}
+
+// Names lists the stored names in sorted order
+func (r *Keyring) Names() ([]string, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    return r.names()
+}
+
+// the keyring is a KeyStore
+var _ otp.KeyStore = (*Keyring)(nil)
@@ -0,0 +1,41 @@
+package keyring
+
+import (
+    "errors"
+    "os/exec"
+    "strings"
+)
+
+// security exits with this when the item isn't in the keychain
+const errSecItemNotFound int = 44
+
+// runSecurity runs /usr/bin/security, mapping its not found exit status to errNotFound
+func runSecurity(args ...string) (string, error) {
+    var out, err = exec.Command("/usr/bin/security", args...).Output()
+    var exit *exec.ExitError
+    if (errors.As(err, &exit) && exit.ExitCode() == errSecItemNotFound) {
+        return "", errNotFound
+    } else if (errors.Is(err, exec.ErrNotFound)) {
+        return "", ErrUnsupported
+    } else if (err != nil) {
+        return "", err
+    }
+    return string(out), nil
+}
+
+func get(service string, account string) (string, error) {
+    var out, err = runSecurity("find-generic-password", "-s", service, "-a", account, "-w")
+    return strings.TrimSuffix(out, "\n"), err
+}
+
+func set(service string, account string, secret string) error {
+    // -U updates an existing item. The secret is briefly visible in the process list,
+    // which is no worse than the keychain's own prompt and only to the same user.
+    var _, err = runSecurity("add-generic-password", "-U", "-s", service, "-a", account, "-w", secret)
+    return err
+}
+
+func remove(service string, account string) error {
+    var _, err = runSecurity("delete-generic-password", "-s", service, "-a", account)
+    return err
+}
@@ -0,0 +1,50 @@
+package keyring
+
+import (
+    "bytes"
+    "errors"
+    "os/exec"
+    "strings"
+)
+
+// secretTool runs secret-tool with stdin as its input
+func secretTool(stdin string, args ...string) (string, error) {
+    var cmd *exec.Cmd = exec.Command("secret-tool", args...)
+    cmd.Stdin = strings.NewReader(stdin)
+    var stderr bytes.Buffer
+    cmd.Stderr = &stderr
+    var out, err = cmd.Output()
+    if (errors.Is(err, exec.ErrNotFound)) {
+        return "", ErrUnsupported
+    } else if (err != nil) {
+        if msg := strings.TrimSpace(stderr.String()); msg != "" {
+            return "", errors.New("keyring: secret-tool: " + msg)
+        }
+        return "", err
+    }
+    return string(out), nil
+}
+
+func get(service string, account string) (string, error) {
+    // lookup prints nothing and fails when there's no such item
+    var out, err = secretTool("", "lookup", "service", service, "account", account)
+    if (err != nil && !errors.Is(err, ErrUnsupported)) {
+        return "", errNotFound
+    }
+    return out, err
+}
+
+func set(service string, account string, secret string) error {
+    // the secret goes in on stdin, not the command line
+    var _, err = secretTool(secret, "store", "--label=" + service + ": " + account, "service", service, "account", account)
+    return err
+}
+
+func remove(service string, account string) error {
+    // clear doesn't say whether it removed anything
+    if _, err := get(service, account); err != nil {
+        return err
+    }
+    var _, err = secretTool("", "clear", "service", service, "account", account)
+    return err
+}
@@ -0,0 +1,15 @@
+//go:build !darwin && !linux && !windows
+
+package keyring
+
+func get(service string, account string) (string, error) {
+    return "", ErrUnsupported
+}
+
+func set(service string, account string, secret string) error {
+    return ErrUnsupported
+}
+
+func remove(service string, account string) error {
+    return ErrUnsupported
+}
@@ -0,0 +1,104 @@
+package keyring
+
+import (
+    "errors"
+    "syscall"
+    "unsafe"
+)
+
+var (
+    advapi32        = syscall.NewLazyDLL("advapi32.dll")
+    procCredReadW   = advapi32.NewProc("CredReadW")
+    procCredWriteW  = advapi32.NewProc("CredWriteW")
+    procCredDeleteW = advapi32.NewProc("CredDeleteW")
+    procCredFree    = advapi32.NewProc("CredFree")
+)
+
+const (
+    credTypeGeneric         uint32 = 1
+    credPersistLocalMachine uint32 = 2
+    errorNotFound           syscall.Errno = 1168
+)
+
+// credential is CREDENTIALW from wincred.h
+type credential struct {
+    Flags               uint32
+    Type                uint32
+    TargetName          *uint16
+    Comment             *uint16
+    LastWritten         syscall.Filetime
+    CredentialBlobSize  uint32
+    CredentialBlob      *byte
+    Persist             uint32
+    AttributeCount      uint32
+    Attributes          uintptr
+    TargetAlias         *uint16
+    UserName            *uint16
+}
+
+// target is the credential name, e.g. "otp:github"
+func target(service string, account string) (*uint16, error) {
+    return syscall.UTF16PtrFromString(service + ":" + account)
+}
+
+// callError turns a failed call's error into errNotFound where it means that
+func callError(err error) error {
+    if (errors.Is(err, errorNotFound)) {
+        return errNotFound
+    }
+    return err
+}
+
+func get(service string, account string) (string, error) {
+    var name, err = target(service, account)
+    if (err != nil) {
+        return "", err
+    }
+    var cred *credential
+    var ok, _, callErr = procCredReadW.Call(uintptr(unsafe.Pointer(name)), uintptr(credTypeGeneric), 0, uintptr(unsafe.Pointer(&cred)))
+    if (ok == 0) {
+        return "", callError(callErr)
+    }
+    defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+    return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+func set(service string, account string, secret string) error {
+    var name, err = target(service, account)
+    if (err != nil) {
+        return err
+    }
+    var user *uint16
+    if user, err = syscall.UTF16PtrFromString(account); err != nil {
+        return err
+    }
+    var blob []byte = []byte(secret)
+    var cred credential = credential{
+        Type:               credTypeGeneric,
+        TargetName:         name,
+        CredentialBlobSize: uint32(len(blob)),
+        Persist:            credPersistLocalMachine,
+        UserName:           user,
+    }
+    if (len(blob) > 0) {
+        cred.CredentialBlob = &blob[0]
+    }
+    var ok, _, callErr = procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0)
+    if (ok == 0) {
+        return callError(callErr)
+    }
+    return nil
+}
+
+func remove(service string, account string) error {
+    var name, err = target(service, account)
+    if (err != nil) {
+        return err
+    }
+    var ok, _, callErr = procCredDeleteW.Call(uintptr(unsafe.Pointer(name)), uintptr(credTypeGeneric), 0)
+    if (ok == 0) {
+        return callError(callErr)
+    }
+    return nil
+}
@@ -0,0 +1,35 @@
+package otp
+
+/*
+    KeyStore keeps named Keys somewhere they don't sit on disk in plaintext
+
+    The vault package is an encrypted file implementation; other backends (an OS
+    keychain, a database) plug in by implementing the same four methods. Get fails with ErrKeyNotFound for a name that isn't stored, as
+    does Remove.
+*/
+type KeyStore interface {
+    // Get returns the key stored under name
+    Get(name string) (*Key, error)
+    // Put stores k under name, replacing anything already there
+    Put(name string, k *Key) error
+    // Remove deletes the key stored under name
+    Remove(name string) error
+    // Names lists the stored names in sorted order
+    Names() ([]string, error)
+}
+
+/*
+    KeyUpdater is implemented by KeyStores that can change a key atomically
+
+    Update loads the key stored under name, calls fn on it and stores the result, and
+    nobody else's change can land in between; if fn returns an error nothing is stored
+    and Update returns it. That's what validating an HOTP code needs, since Validate
+    moves the counter on: two requests with the same code must not both pass.
+
+        err = store.Update(user, func(k *Key) error {
+            return k.Validate(code)
+        })
+*/
+type KeyUpdater interface {
+    Update(name string, fn func(k *Key) error) error
+}
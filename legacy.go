@@ -0,0 +1,67 @@
+package otp
+
+import (
+    "crypto/sha1"
+    "hash"
+    "strconv"
+    "time"
+)
+
+/*
+    The legacy algorithm
+
+    Before it followed RFC 4226 this package turned the HMAC into a code by taking its
+    first 6 bytes and reducing each byte mod 10, and TOTP fed HMAC the time step as an
+    ASCII decimal string. Tokens enrolled back then still produce these codes, so the
+    old algorithm is kept here for ValidateDuringMigration and ValidateHOTPEncoding and
+    must not be "fixed".
+
+    That includes the old HMAC, which truncated keys longer than a block instead of
+    hashing them and left (K' ⊕ opad) out of the outer hash.
+*/
+func legacyHMAC(key []byte, message []byte) []byte {
+    var blocksize int = sha1.BlockSize
+
+    var key_ []byte = make([]byte, blocksize)
+    defer Wipe(key_)
+    copy(key_, key)
+
+    var key_xor_ipad []byte = make([]byte, blocksize)
+    defer Wipe(key_xor_ipad)
+    for i := 0; i < blocksize; i++ {
+        key_xor_ipad[i] = key_[i] ^ 0x36
+    }
+
+    var h hash.Hash = sha1.New()
+    h.Write(key_xor_ipad)
+    h.Write(message)
+    var sum1 []byte = h.Sum(nil)
+    var sum2 [sha1.Size]byte = sha1.Sum(sum1)
+
+    return sum2[:]
+}
+
+// legacyHOTP is the old per byte truncation on top of legacyHMAC
+func legacyHOTP(key []byte, counter []byte) Code {
+    var codeLen int = 6
+    var hmac []byte = legacyHMAC(key, counter)
+
+    // each of the first codeLen bytes mod 10 is one digit, most significant first
+    var value uint32 = 0
+    for i := 0; i < codeLen; i++ {
+        value = value * 10 + uint32(hmac[i] % 10)
+    }
+    return Code{Value: value, Digits: codeLen}
+}
+
+// legacyStep is the legacy TOTP time step for t, always 30 seconds
+func legacyStep(t time.Time) int64 {
+    return t.Unix() / 30
+}
+
+// legacyTOTP is the legacy TOTP code for time step step
+func legacyTOTP(key []byte, step int64) Code {
+    var tstr string = strconv.FormatInt(step, 10)
+
+    return legacyHOTP(key, []byte(tstr))
+}
@@ -0,0 +1,36 @@
+package otp
+
+import (
+    "errors"
+    "testing"
+)
+
+// legacyCodes are the legacy algorithm's codes for the RFC 4226 secret and counters 0 to 3
+var legacyCodes []string = []string{"796176", "166209", "537881", "577846"}
+
+func TestValidateHOTPEncoding(t *testing.T) {
+    var tests = []struct {
+        name    string
+        code    string
+        counter uint64
+        legacy  bool
+        err     error
+    }{
+        {"standard", rfc4226Codes[1], 1, false, nil},
+        {"legacy only", legacyCodes[1], 1, true, nil},
+        {"legacy at counter 0", legacyCodes[0], 0, true, nil},
+        {"legacy for another counter", legacyCodes[2], 3, false, ErrCodeMismatch},
+        {"neither", "000000", 1, false, ErrCodeMismatch},
+    }
+    for _, tt := range(tests) {
+        t.Run(tt.name, func(t *testing.T) {
+            var legacy, err = ValidateHOTPEncoding(RFC4226Secret, tt.code, tt.counter)
+            if (!errors.Is(err, tt.err)) {
+                t.Fatalf("error %v, want %v", err, tt.err)
+            }
+            if (legacy != tt.legacy) {
+                t.Errorf("legacy %v, want %v", legacy, tt.legacy)
+            }
+        })
+    }
+}
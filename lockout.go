@@ -0,0 +1,200 @@
+package otp
+
+import (
+    "errors"
+    "fmt"
+    "math"
+    "time"
+)
+
+/*
+    LockoutStore keeps each user's lockout on top of their failure count
+
+    Lockout returns when the user's current lockout ends (the zero time if they've
+    never been locked out) and how many lockouts they've had since their last
+    success; SetLockout records a new one. ResetFailures clears both along with the
+    failure count. A store may forget a lockout a day after it has ended.
+*/
+type LockoutStore interface {
+    AttemptStore
+    Lockout(user string) (until time.Time, lockouts int, err error)
+    SetLockout(user string, until time.Time, lockouts int) error
+}
+
+/*
+    LockoutPolicy throttles guessing by locking users out after repeated failures
+
+    RFC 4226 section 7.3 recommends a server stops accepting codes from a user after
+    a few wrong ones and makes them wait longer each time. Once MaxAttempts codes have
+    failed within Window the user is locked out for Duration, and every further
+    failure while the window is still running locks them out again for Backoff times
+    as long as the last time, up to MaxDuration. The count of lockouts is forgotten on
+    a success or once the last lockout has been over for MaxDuration.
+
+    Zero fields take the defaults: 5 attempts in 15 minutes, a 1 minute lockout that
+    doubles each time up to an hour. A nil *LockoutPolicy never locks anyone out, but
+    one without a Store fails every check with ErrNoLockoutStore rather than let codes
+    through unthrottled.
+
+    Failures are counted atomically by the store but checked before the code is, so
+    a burst of concurrent guesses can get a few past MaxAttempts before the lockout
+    is in place.
+
+    The On funcs, if set, let a service react to an attack: page someone, make the
+    user enroll again, or tell them. OnAlert is called once per window when a user's
+    failures reach AlertAt (MaxAttempts if zero, set it lower to hear of an attack
+    before the lockout), OnLockout each time a user is locked out and OnReplay when a
+    code that was already used is submitted again, which means someone else saw it.
+    They run in the goroutine doing the validation, so anything slow should be handed
+    off.
+*/
+type LockoutPolicy struct {
+    Store       LockoutStore
+    MaxAttempts int             // failures within Window that cause a lockout
+    Window      time.Duration   // how long failures are counted from the first one
+    Duration    time.Duration   // how long the first lockout lasts
+    Backoff     float64         // how much longer each further lockout lasts, 1 keeps them the same
+    MaxDuration time.Duration   // the longest a lockout can get
+    Clock       Clock           // nil means the system clock
+
+    AlertAt     int
+    OnAlert     func(user string, failures int)
+    OnLockout   func(user string, until time.Time, lockouts int)
+    OnReplay    func(user string)
+}
+
+// withDefaults fills in the zero fields of p
+func (p LockoutPolicy) withDefaults() LockoutPolicy {
+    if (p.MaxAttempts <= 0) {
+        p.MaxAttempts = 5
+    }
+    if (p.Window <= 0) {
+        p.Window = 15 * time.Minute
+    }
+    if (p.Duration <= 0) {
+        p.Duration = time.Minute
+    }
+    if (p.Backoff < 1) {
+        p.Backoff = 2
+    }
+    if (p.MaxDuration <= 0) {
+        p.MaxDuration = time.Hour
+    }
+    if (p.AlertAt <= 0) {
+        p.AlertAt = p.MaxAttempts
+    }
+    return p
+}
+
+// LockedUntil returns when user's lockout ends, or the zero time if they aren't locked out
+func (p *LockoutPolicy) LockedUntil(user string) (time.Time, error) {
+    if (p == nil) {
+        return time.Time{}, nil
+    }
+    if (p.Store == nil) {
+        return time.Time{}, ErrNoLockoutStore
+    }
+    var until, _, err = p.Store.Lockout(user)
+    if (err != nil || !clockNow(p.Clock).Before(until)) {
+        return time.Time{}, err
+    }
+    return until, nil
+}
+
+// Check fails with ErrLockedOut if user is locked out
+func (p *LockoutPolicy) Check(user string) error {
+    var until, err = p.LockedUntil(user)
+    if (err != nil) {
+        return err
+    }
+    if (!until.IsZero()) {
+        return fmt.Errorf("%w: %s until %s", ErrLockedOut, user, until.Format(time.RFC3339))
+    }
+    return nil
+}
+
+// Failure records a failed verification for user and returns ErrLockedOut if it locked them out
+func (p *LockoutPolicy) Failure(user string) error {
+    if (p == nil) {
+        return nil
+    }
+    if (p.Store == nil) {
+        return ErrNoLockoutStore
+    }
+    var policy LockoutPolicy = p.withDefaults()
+    var failures, err = policy.Store.RecordFailure(user, policy.Window)
+    if (err != nil) {
+        return err
+    }
+    if (failures == policy.AlertAt && policy.OnAlert != nil) {
+        policy.OnAlert(user, failures)
+    }
+    if (failures < policy.MaxAttempts) {
+        return nil
+    }
+
+    var last time.Time
+    var lockouts int
+    if last, lockouts, err = policy.Store.Lockout(user); err != nil {
+        return err
+    }
+    var now time.Time = clockNow(policy.Clock)
+    if (now.Sub(last) > policy.MaxDuration) {
+        lockouts = 0
+    }
+    var d time.Duration = policy.MaxDuration
+    if f := float64(policy.Duration) * math.Pow(policy.Backoff, float64(lockouts)); f < float64(policy.MaxDuration) {
+        d = time.Duration(f)
+    }
+    var until time.Time = now.Add(d)
+    if err = policy.Store.SetLockout(user, until, lockouts + 1); err != nil {
+        return err
+    }
+    currentObserver().OnLockout(user, until)
+    if (policy.OnLockout != nil) {
+        policy.OnLockout(user, until, lockouts + 1)
+    }
+    return fmt.Errorf("%w: %s until %s", ErrLockedOut, user, until.Format(time.RFC3339))
+}
+
+// Success clears user's failures and lockouts
+func (p *LockoutPolicy) Success(user string) error {
+    if (p == nil) {
+        return nil
+    }
+    if (p.Store == nil) {
+        return ErrNoLockoutStore
+    }
+    return p.Store.ResetFailures(user)
+}
+
+/*
+    Guard runs validate for user under the policy
+
+    A locked out user fails with ErrLockedOut without validate being called, and so
+    does every user with ErrNoLockoutStore if the policy has no Store. A wrong,
+    replayed or out of order code counts as a failure and, if that locks the user
+    out, the error returned matches both the validation error and ErrLockedOut. Any
+    other error from validate is returned as it is without counting.
+*/
+func (p *LockoutPolicy) Guard(user string, validate func() error) error {
+    if (p == nil) {
+        return validate()
+    }
+    if err := p.Check(user); err != nil {
+        return err
+    }
+    var err error = validate()
+    switch {
+    case err == nil:
+        return p.Success(user)
+    case errors.Is(err, ErrCodeMismatch), errors.Is(err, ErrCodeReused), errors.Is(err, ErrOutOfOrder):
+        if (errors.Is(err, ErrCodeReused) && p.OnReplay != nil) {
+            p.OnReplay(user)
+        }
+        if ferr := p.Failure(user); ferr != nil {
+            return fmt.Errorf("%w, %w", err, ferr)
+        }
+    }
+    return err
+}
@@ -0,0 +1,38 @@
+package otp
+
+import (
+    "errors"
+    "testing"
+)
+
+func TestLockoutPolicyWithoutStore(t *testing.T) {
+    var p *LockoutPolicy = &LockoutPolicy{MaxAttempts: 3}
+    var called bool = false
+    var err error = p.Guard("alice", func() error {
+        called = true
+        return nil
+    })
+    if (!errors.Is(err, ErrNoLockoutStore)) {
+        t.Errorf("Guard: %v, want ErrNoLockoutStore", err)
+    }
+    if (called) {
+        t.Error("Guard ran the validation without a store")
+    }
+    if err = p.Failure("alice"); !errors.Is(err, ErrNoLockoutStore) {
+        t.Errorf("Failure: %v, want ErrNoLockoutStore", err)
+    }
+    if err = p.Success("alice"); !errors.Is(err, ErrNoLockoutStore) {
+        t.Errorf("Success: %v, want ErrNoLockoutStore", err)
+    }
+
+    // through ValidateTOTP the same, rather than a panic
+    var opts ValidateOpts = ValidateOpts{Lockout: p, User: "alice"}
+    if err = ValidateTOTP(RFC4226Secret, "000000", opts); !errors.Is(err, ErrNoLockoutStore) {
+        t.Errorf("ValidateTOTP: %v, want ErrNoLockoutStore", err)
+    }
+
+    var none *LockoutPolicy
+    if err = none.Guard("alice", func() error { return nil }); err != nil {
+        t.Errorf("Guard on a nil policy: %v", err)
+    }
+}
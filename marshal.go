@@ -0,0 +1,211 @@
+package otp
+
+import (
+    "encoding/binary"
+    "fmt"
+    "math"
+    "time"
+)
+
+/*
+    Binary keys
+
+    MarshalBinary writes a Key in a compact format for storing many of them, a lot
+    smaller than JSON or a URI. The first byte is the format version and then come:
+
+        type, digits, flags and the fixed truncation offset, one byte each
+        period in nanoseconds, counter, skew and behind as uvarints
+        T0 as a varint of Unix seconds and a uvarint of nanoseconds, if flagged
+        algorithm name, issuer, account and secret, each a uvarint length and bytes
+
+    Like a URI it holds the key itself, not its Clock, Replay, Lockout, Drift, Audit or
+    User. Versions this package doesn't know fail with ErrInvalidKeyData.
+*/
+
+// keyBinaryVersion is the version of the binary key format MarshalBinary writes
+const keyBinaryVersion byte = 1
+
+// flags in a binary key
+const (
+    keyFlagChecksum         byte = 1 << iota
+    keyFlagFixedTruncation
+    keyFlagTransposition
+    keyFlagT0
+)
+
+// MarshalBinary writes the key in the binary format above; a key whose secret is only in its provider fails with ErrProvidedKey
+func (k *Key) MarshalBinary() ([]byte, error) {
+    if (len(k.Secret) == 0) {
+        if (k.Provider != nil) {
+            return nil, fmt.Errorf("%w: %q", ErrProvidedKey, k.SecretName)
+        }
+        return nil, ErrEmptySecret
+    }
+    if (k.Digits < 0 || k.Digits > 255 || k.Skew < 0 || k.Behind < 0 || k.Period < 0) {
+        return nil, fmt.Errorf("%w: settings out of range", ErrInvalidKeyData)
+    }
+    var flags, offset byte
+    if (k.Checksum) {
+        flags |= keyFlagChecksum
+    }
+    if n, fixed := k.Truncation.Offset(); fixed {
+        flags |= keyFlagFixedTruncation
+        offset = byte(n)
+    }
+    if (k.Transposition) {
+        flags |= keyFlagTransposition
+    }
+    if (!k.T0.IsZero()) {
+        flags |= keyFlagT0
+    }
+
+    var b []byte = make([]byte, 0, 64 + len(k.Secret) + len(k.Issuer) + len(k.Account))
+    b = append(b, keyBinaryVersion, byte(k.Type), byte(k.Digits), flags, offset)
+    b = binary.AppendUvarint(b, uint64(k.Period))
+    b = binary.AppendUvarint(b, k.Counter)
+    b = binary.AppendUvarint(b, uint64(k.Skew))
+    b = binary.AppendUvarint(b, uint64(k.Behind))
+    if (!k.T0.IsZero()) {
+        b = binary.AppendVarint(b, k.T0.Unix())
+        b = binary.AppendUvarint(b, uint64(k.T0.Nanosecond()))
+    }
+    for _, s := range([]string{k.Algorithm.String(), k.Issuer, k.Account, string(k.Secret)}) {
+        b = binary.AppendUvarint(b, uint64(len(s)))
+        b = append(b, s...)
+    }
+    return b, nil
+}
+
+// keyReader reads the fields of a binary key, remembering the first thing that went wrong
+type keyReader struct {
+    b   []byte
+    err error
+}
+
+func (r *keyReader) byte() byte {
+    if (r.err != nil || len(r.b) == 0) {
+        r.fail()
+        return 0
+    }
+    var c byte = r.b[0]
+    r.b = r.b[1:]
+    return c
+}
+
+func (r *keyReader) uvarint() uint64 {
+    var v, n = binary.Uvarint(r.b)
+    if (r.err != nil || n <= 0) {
+        r.fail()
+        return 0
+    }
+    r.b = r.b[n:]
+    return v
+}
+
+func (r *keyReader) varint() int64 {
+    var v, n = binary.Varint(r.b)
+    if (r.err != nil || n <= 0) {
+        r.fail()
+        return 0
+    }
+    r.b = r.b[n:]
+    return v
+}
+
+func (r *keyReader) bytes() []byte {
+    var n uint64 = r.uvarint()
+    if (r.err != nil || n > uint64(len(r.b))) {
+        r.fail()
+        return nil
+    }
+    var s []byte = r.b[:n]
+    r.b = r.b[n:]
+    return s
+}
+
+func (r *keyReader) fail() {
+    if (r.err == nil) {
+        r.err = fmt.Errorf("%w: truncated", ErrInvalidKeyData)
+    }
+}
+
+/*
+    UnmarshalBinary reads a key written by MarshalBinary into k
+
+    The settings are checked as NewKey checks them, so a key that NewKey would refuse
+    fails the same way. Anything malformed, or another version of the format, fails
+    with ErrInvalidKeyData. k is only changed on success.
+*/
+func (k *Key) UnmarshalBinary(data []byte) error {
+    var r *keyReader = &keyReader{b: data}
+    if version := r.byte(); r.err == nil && version != keyBinaryVersion {
+        return fmt.Errorf("%w: version %d", ErrInvalidKeyData, version)
+    }
+    var typ Type = Type(r.byte())
+    var digits int = int(r.byte())
+    var flags byte = r.byte()
+    var offset int = int(r.byte())
+    var period time.Duration = time.Duration(r.uvarint())
+    var counter uint64 = r.uvarint()
+    var skew, behind uint64 = r.uvarint(), r.uvarint()
+    var t0 time.Time
+    if (flags & keyFlagT0 != 0) {
+        var sec int64 = r.varint()
+        t0 = time.Unix(sec, int64(r.uvarint()))
+    }
+    var algoName, issuer, account, secret []byte = r.bytes(), r.bytes(), r.bytes(), r.bytes()
+    if (r.err != nil) {
+        return r.err
+    }
+    if (len(r.b) != 0) {
+        return fmt.Errorf("%w: %d bytes left over", ErrInvalidKeyData, len(r.b))
+    }
+    if (typ != TypeTOTP && typ != TypeHOTP) {
+        return fmt.Errorf("%w: type %d", ErrInvalidKeyData, int(typ))
+    }
+    if (flags &^ (keyFlagChecksum | keyFlagFixedTruncation | keyFlagTransposition | keyFlagT0) != 0) {
+        return fmt.Errorf("%w: unknown flags %#x", ErrInvalidKeyData, flags)
+    }
+    if (skew > math.MaxInt32 || behind > math.MaxInt32) {
+        return fmt.Errorf("%w: skew %d, behind %d", ErrInvalidKeyData, skew, behind)
+    }
+    var algo, ok = ParseAlgorithm(string(algoName))
+    if (!ok) {
+        return fmt.Errorf("%w: algorithm %q", ErrInvalidAlgorithm, algoName)
+    }
+
+    var opts []Option = []Option{
+        WithAlgorithm(algo),
+        WithDigits(digits),
+        WithSkew(int(skew)),
+        WithOutOfOrder(int(behind)),
+        WithIssuer(string(issuer)),
+        WithAccount(string(account)),
+    }
+    if (period != 0) {
+        opts = append(opts, WithPeriod(period))
+    }
+    if (flags & keyFlagT0 != 0) {
+        opts = append(opts, WithT0(t0))
+    }
+    if (typ == TypeHOTP) {
+        opts = append(opts, WithHOTP(counter))
+    } else if (counter != 0) {
+        return fmt.Errorf("%w: totp key with counter %d", ErrInvalidKeyData, counter)
+    }
+    if (flags & keyFlagChecksum != 0) {
+        opts = append(opts, WithChecksum())
+    }
+    if (flags & keyFlagFixedTruncation != 0) {
+        opts = append(opts, WithTruncationOffset(offset))
+    }
+    if (flags & keyFlagTransposition != 0) {
+        opts = append(opts, WithTranspositionTolerance())
+    }
+    var parsed, err = NewKey(secret, opts...)
+    if (err != nil) {
+        return err
+    }
+    *k = *parsed
+    return nil
+}
@@ -0,0 +1,90 @@
+package otp
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestKeyBinaryRoundTrip(t *testing.T) {
+    var tests = []struct {
+        name    string
+        opts    []Option
+    }{
+        {"totp defaults", nil},
+        {"totp with everything", []Option{WithIssuer("Example Co"), WithAccount("alice@example.com"),
+            WithAlgorithm(SHA512), WithDigits(8), WithPeriod(time.Minute), WithT0(time.Unix(-3600, 5)),
+            WithSkew(2), WithTruncationOffset(60), WithTranspositionTolerance()}},
+        {"hotp", []Option{WithHOTP(42), WithSkew(10), WithOutOfOrder(3)}},
+        {"hotp near the top", []Option{WithHOTP(1 << 63 + 7), WithAlgorithm(SHA256)}},
+        {"hotp with a checksum", []Option{WithHOTP(0), WithChecksum()}},
+    }
+    for _, tt := range(tests) {
+        t.Run(tt.name, func(t *testing.T) {
+            var k, err = NewKey(RFC4226Secret, tt.opts...)
+            if (err != nil) {
+                t.Fatal(err)
+            }
+            var b []byte
+            if b, err = k.MarshalBinary(); err != nil {
+                t.Fatal(err)
+            }
+            var got Key
+            if err = got.UnmarshalBinary(b); err != nil {
+                t.Fatalf("UnmarshalBinary(%x): %v", b, err)
+            }
+            if (!keysEqual(&got, k) || got.Behind != k.Behind || got.Transposition != k.Transposition) {
+                t.Errorf("read back as %+v, want %+v", got, *k)
+            }
+        })
+    }
+}
+
+func TestKeyUnmarshalBinaryRejects(t *testing.T) {
+    var k, err = NewKey(RFC4226Secret, WithHOTP(5))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    var good []byte
+    if good, err = k.MarshalBinary(); err != nil {
+        t.Fatal(err)
+    }
+    var tests = []struct {
+        name    string
+        data    []byte
+        err     error
+    }{
+        {"empty", nil, ErrInvalidKeyData},
+        {"unknown version", append([]byte{2}, good[1:]...), ErrInvalidKeyData},
+        {"version 0", append([]byte{0}, good[1:]...), ErrInvalidKeyData},
+        {"truncated", good[:len(good) - 1], ErrInvalidKeyData},
+        {"left over bytes", append(append([]byte(nil), good...), 0), ErrInvalidKeyData},
+        {"unknown type", append([]byte{1, 9}, good[2:]...), ErrInvalidKeyData},
+        {"unknown flags", append([]byte{1, 1, 6, 0x80}, good[4:]...), ErrInvalidKeyData},
+        {"too many digits", append([]byte{1, 1, 11}, good[3:]...), ErrInvalidDigits},
+    }
+    for _, tt := range(tests) {
+        t.Run(tt.name, func(t *testing.T) {
+            var got Key = Key{Issuer: "unchanged"}
+            if err := got.UnmarshalBinary(tt.data); !errors.Is(err, tt.err) {
+                t.Errorf("%v, want %v", err, tt.err)
+            }
+            if (got.Issuer != "unchanged") {
+                t.Error("key changed by a failed UnmarshalBinary")
+            }
+        })
+    }
+}
+
+func TestKeyMarshalBinaryProvidedKey(t *testing.T) {
+    var p KeyProviderFunc = func(name string) ([]byte, error) {
+        return RFC4226Secret, nil
+    }
+    var k, err = NewProvidedKey(p, "alice")
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    if _, err = k.MarshalBinary(); !errors.Is(err, ErrProvidedKey) {
+        t.Errorf("MarshalBinary of a provided key: %v, want ErrProvidedKey", err)
+    }
+}
@@ -0,0 +1,149 @@
+package memstore
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "time"
+
+    otp "github.com/adam-good/OTP"
+)
+
+// StateVersion is the version of the state format MarshalState writes
+const StateVersion int = 1
+
+// ErrInvalidState is returned by RestoreState for data it can't read, including other versions of the format
+var ErrInvalidState error = errors.New("memstore: invalid or unsupported state")
+
+// snapshot is the JSON form of a Store's contents
+type snapshot struct {
+    Version     int                         `json:"version"`
+    Keys        map[string][]byte           `json:"keys"`      // otp.Key.MarshalBinary
+    Used        []usedState                 `json:"used"`
+    Attempts    map[string]attemptsState    `json:"attempts"`
+    Drift       map[string]int64            `json:"drift"`
+    Counters    map[string]uint64           `json:"counters"`
+    Recovery    map[string][]string         `json:"recovery"`
+    Stats       map[string]SecretStats      `json:"stats,omitempty"`
+}
+
+type usedState struct {
+    User    string      `json:"user"`
+    Step    int64       `json:"step"`
+    Expires time.Time   `json:"expires"`
+}
+
+type attemptsState struct {
+    Failures    int         `json:"failures"`
+    Expires     time.Time   `json:"expires"`
+    LockedUntil time.Time   `json:"locked_until"`
+    Lockouts    int         `json:"lockouts"`
+}
+
+/*
+    MarshalState writes everything in the store so RestoreState can bring it back after a restart
+
+    That's the keys, the used codes that stop replays, failure counts and lockouts,
+    drift, counters, recovery code hashes and Stats, in a JSON format that carries its
+    version. Keys are kept as otp.Key.MarshalBinary keeps them, so their Clock, Lockout,
+    Audit and other non-key settings aren't; a key whose secret is only in its provider
+    fails with otp.ErrProvidedKey. The output has the secrets in it in the clear,
+    protect it as you would the keys.
+*/
+func (s *Store) MarshalState() ([]byte, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    var now time.Time = s.clock.Now()
+    var snap snapshot = snapshot{
+        Version:    StateVersion,
+        Keys:       make(map[string][]byte, len(s.keys)),
+        Used:       make([]usedState, 0, len(s.used)),
+        Attempts:   make(map[string]attemptsState, len(s.attempts)),
+        Drift:      s.drift,
+        Counters:   s.counters,
+        Recovery:   s.recovery,
+        Stats:      s.stats,
+    }
+    for name, k := range(s.keys) {
+        var b, err = k.MarshalBinary()
+        if (err != nil) {
+            return nil, fmt.Errorf("memstore: key %s: %w", name, err)
+        }
+        snap.Keys[name] = b
+    }
+    for key, expires := range(s.used) {
+        if (now.Before(expires)) {
+            snap.Used = append(snap.Used, usedState{key.user, key.step, expires})
+        }
+    }
+    for user, a := range(s.attempts) {
+        if (!a.expired(now)) {
+            snap.Attempts[user] = attemptsState{a.failures, a.expires, a.lockedUntil, a.lockouts}
+        }
+    }
+    return json.Marshal(snap)
+}
+
+/*
+    RestoreState replaces everything in the store with what MarshalState wrote
+
+    Entries that have expired since are dropped. Data that isn't a state of
+    StateVersion fails with ErrInvalidState, and a key in it that doesn't read back
+    with the error from otp.Key.UnmarshalBinary; either way the store is left as it was.
+*/
+func (s *Store) RestoreState(data []byte) error {
+    var snap snapshot
+    if err := json.Unmarshal(data, &snap); err != nil {
+        return fmt.Errorf("%w: %v", ErrInvalidState, err)
+    }
+    if (snap.Version != StateVersion) {
+        return fmt.Errorf("%w: version %d", ErrInvalidState, snap.Version)
+    }
+
+    var keys map[string]*otp.Key = make(map[string]*otp.Key, len(snap.Keys))
+    for name, b := range(snap.Keys) {
+        var k *otp.Key = &otp.Key{}
+        if err := k.UnmarshalBinary(b); err != nil {
+            return fmt.Errorf("memstore: key %s: %w", name, err)
+        }
+        keys[name] = k
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    var now time.Time = s.clock.Now()
+    s.keys = keys
+    s.used = make(map[usedKey]time.Time, len(snap.Used))
+    for _, u := range(snap.Used) {
+        if (now.Before(u.Expires)) {
+            s.used[usedKey{u.User, u.Step}] = u.Expires
+        }
+    }
+    s.attempts = make(map[string]attempts, len(snap.Attempts))
+    for user, a := range(snap.Attempts) {
+        var restored attempts = attempts{a.Failures, a.Expires, a.LockedUntil, a.Lockouts}
+        if (!restored.expired(now)) {
+            s.attempts[user] = restored
+        }
+    }
+    s.drift = map[string]int64{}
+    for user, steps := range(snap.Drift) {
+        s.drift[user] = steps
+    }
+    s.counters = map[string]uint64{}
+    for name, counter := range(snap.Counters) {
+        s.counters[name] = counter
+    }
+    s.recovery = map[string][]string{}
+    for user, hashes := range(snap.Recovery) {
+        s.recovery[user] = hashes
+    }
+    s.stats = map[string]SecretStats{}
+    for name, st := range(snap.Stats) {
+        s.stats[name] = st
+    }
+    s.swept = now
+    return nil
+}
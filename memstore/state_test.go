@@ -0,0 +1,95 @@
+package memstore
+
+import (
+    "errors"
+    "testing"
+    "time"
+
+    otp "github.com/adam-good/OTP"
+)
+
+func TestRestoreStateKeepsReplays(t *testing.T) {
+    var now time.Time = time.Unix(1_000_000_000, 0)
+    var clock otp.Clock = otp.FixedClock(now)
+    var s *Store = New(Options{Clock: clock})
+    var k, err = otp.NewKey(otp.RFC4226Secret, otp.WithClock(clock))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    if err = s.Put("alice", k); err != nil {
+        t.Fatal(err)
+    }
+    var code otp.Code
+    if code, err = k.Generate(); err != nil {
+        t.Fatal(err)
+    }
+    var opts otp.ValidateOpts = otp.ValidateOpts{Time: now, Replay: s, User: "alice"}
+    if err = otp.ValidateTOTP(otp.RFC4226Secret, code.String(), opts); err != nil {
+        t.Fatal(err)
+    }
+    if _, err = s.AdvanceCounter("yubico:cccccccccccb", 0x1302); err != nil {
+        t.Fatal(err)
+    }
+    if _, err = s.RecordFailure("bob", time.Minute); err != nil {
+        t.Fatal(err)
+    }
+    if err = s.SetLockout("bob", now.Add(time.Hour), 2); err != nil {
+        t.Fatal(err)
+    }
+    if err = s.SetDrift("alice", -1); err != nil {
+        t.Fatal(err)
+    }
+
+    var state []byte
+    if state, err = s.MarshalState(); err != nil {
+        t.Fatal(err)
+    }
+    var restored *Store = New(Options{Clock: clock})
+    if err = restored.RestoreState(state); err != nil {
+        t.Fatal(err)
+    }
+
+    // the code used before the restart is still refused
+    opts.Replay = restored
+    if err = otp.ValidateTOTP(otp.RFC4226Secret, code.String(), opts); !errors.Is(err, otp.ErrCodeReused) {
+        t.Errorf("code used before the restart: %v, want ErrCodeReused", err)
+    }
+    if ok, _ := restored.AdvanceCounter("yubico:cccccccccccb", 0x1302); ok {
+        t.Error("counter went back to where it was before the restart")
+    }
+    if until, lockouts, _ := restored.Lockout("bob"); !until.Equal(now.Add(time.Hour)) || lockouts != 2 {
+        t.Errorf("lockout %v, %d, want %v, 2", until, lockouts, now.Add(time.Hour))
+    }
+    if drift, _ := restored.Drift("alice"); drift != -1 {
+        t.Errorf("drift %d, want -1", drift)
+    }
+    var got *otp.Key
+    if got, err = restored.Get("alice"); err != nil || string(got.Secret) != string(otp.RFC4226Secret) {
+        t.Errorf("key after the restart: %v, %v", got, err)
+    }
+}
+
+func TestRestoreStateRejects(t *testing.T) {
+    var tests = []struct {
+        name    string
+        state   string
+    }{
+        {"not json", "{"},
+        {"no version", `{"keys": {}}`},
+        {"a later version", `{"version": 2}`},
+    }
+    for _, tt := range(tests) {
+        t.Run(tt.name, func(t *testing.T) {
+            var s *Store = New(Options{})
+            if err := s.SetDrift("alice", 3); err != nil {
+                t.Fatal(err)
+            }
+            if err := s.RestoreState([]byte(tt.state)); !errors.Is(err, ErrInvalidState) {
+                t.Errorf("%v, want ErrInvalidState", err)
+            }
+            if drift, _ := s.Drift("alice"); drift != 3 {
+                t.Error("store changed by a failed RestoreState")
+            }
+        })
+    }
+}
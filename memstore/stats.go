@@ -0,0 +1,51 @@
+package memstore
+
+import (
+    otp "github.com/adam-good/OTP"
+)
+
+// SecretStats counts how verifications with one key have gone
+type SecretStats struct {
+    Successes   uint64  `json:"successes"`
+    Failures    uint64  `json:"failures"`     // the code matched nothing
+    Replays     uint64  `json:"replays"`      // the code had been used before
+    Lockouts    uint64  `json:"lockouts"`     // the user was or got locked out
+    Errors      uint64  `json:"errors"`       // the key or a store failed
+}
+
+/*
+    Audit counts e towards the stats of e.User, which makes the store an otp.AuditSink
+
+    Pass the store to otp.WithAudit (or ValidateOpts.Audit), with the key's user the
+    name it's stored under, and Stats reports on it. To keep an audit trail as well,
+    send the events on to both from an otp.AuditFunc.
+*/
+func (s *Store) Audit(e otp.AuditEvent) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    var st SecretStats = s.stats[e.User]
+    switch e.Result {
+    case otp.ResultValid:
+        st.Successes++
+    case otp.ResultInvalid:
+        st.Failures++
+    case otp.ResultReplayed:
+        st.Replays++
+    case otp.ResultLockedOut:
+        st.Lockouts++
+    default:
+        st.Errors++
+    }
+    s.stats[e.User] = st
+}
+
+// Stats returns the counts for name so far, see Audit; a name nothing was audited for has all zeros
+func (s *Store) Stats(name string) SecretStats {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    return s.stats[name]
+}
+
+var _ otp.AuditSink = (*Store)(nil)
@@ -0,0 +1,70 @@
+package memstore
+
+import (
+    "sync"
+    "testing"
+    "time"
+
+    otp "github.com/adam-good/OTP"
+)
+
+func TestStats(t *testing.T) {
+    var clock otp.Clock = otp.FixedClock(time.Unix(1_000_000_000, 0))
+    var s *Store = New(Options{Clock: clock})
+    var policy *otp.LockoutPolicy = &otp.LockoutPolicy{Store: s, MaxAttempts: 3, Clock: clock}
+    var k, err = otp.NewKey(otp.RFC4226Secret, otp.WithClock(clock), otp.WithReplayStore(s, "alice"),
+        otp.WithLockout(policy, "alice"), otp.WithAudit(s))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    var code otp.Code
+    if code, err = k.Generate(); err != nil {
+        t.Fatal(err)
+    }
+
+    // a success, a replay and a wrong code are 2 failures towards the lockout, the next
+    // wrong code locks alice out and from then on every code is refused
+    for _, c := range([]string{code.String(), code.String(), "000000", "000000", code.String()}) {
+        _ = k.Validate(c)
+    }
+    var want SecretStats = SecretStats{Successes: 1, Replays: 1, Failures: 1, Lockouts: 2}
+    if got := s.Stats("alice"); got != want {
+        t.Errorf("stats %+v, want %+v", got, want)
+    }
+    if got := s.Stats("bob"); got != (SecretStats{}) {
+        t.Errorf("stats for a user with no verifications %+v", got)
+    }
+
+    // they survive a restart too
+    var state []byte
+    if state, err = s.MarshalState(); err != nil {
+        t.Fatal(err)
+    }
+    var restored *Store = New(Options{Clock: clock})
+    if err = restored.RestoreState(state); err != nil {
+        t.Fatal(err)
+    }
+    if got := restored.Stats("alice"); got != want {
+        t.Errorf("stats after RestoreState %+v, want %+v", got, want)
+    }
+}
+
+func TestStatsConcurrent(t *testing.T) {
+    var s *Store = New(Options{})
+    var results []otp.VerifyResult = []otp.VerifyResult{otp.ResultValid, otp.ResultInvalid, otp.ResultReplayed, otp.ResultLockedOut}
+    var wg sync.WaitGroup
+    for i := range(8) {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for range(100) {
+                s.Audit(otp.AuditEvent{User: "alice", Result: results[i % len(results)]})
+            }
+        }()
+    }
+    wg.Wait()
+    var want SecretStats = SecretStats{Successes: 200, Failures: 200, Replays: 200, Lockouts: 200}
+    if got := s.Stats("alice"); got != want {
+        t.Errorf("stats %+v, want %+v", got, want)
+    }
+}
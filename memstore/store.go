@@ -0,0 +1,384 @@
+/*
+    Package memstore keeps OTP keys and the state around verifying them in memory
+
+    A Store is an otp.KeyStore, otp.KeyUpdater, otp.ReplayStore, otp.LockoutStore,
+    otp.DriftStore, otp.CounterStore, otp.RecoveryStore and otp.AuditSink in one, safe
+    for concurrent use. It's meant for tests, single instance services and as the
+    reference for how the other stores should behave. Nothing survives a restart unless
+    it's saved with MarshalState and brought back with RestoreState.
+
+    Used codes expire after Options.ReplayTTL, failure counts when their window runs
+    out and lockouts a day after they end. Expired entries are never seen by the methods and are swept out on
+    writes now and then, so there's no background goroutine to stop.
+*/
+package memstore
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+    "sync"
+    "time"
+
+    otp "github.com/adam-good/OTP"
+)
+
+// Options configures a Store
+type Options struct {
+    ReplayTTL   time.Duration   // how long used steps are remembered, default 10 minutes
+    Clock       otp.Clock       // decides when entries expire, nil means the system clock
+}
+
+// sweepInterval is how often writes clear out expired entries
+const sweepInterval time.Duration = time.Minute
+
+// ErrConflict is returned by Update when the key was replaced or removed while fn ran
+var ErrConflict error = errors.New("memstore: key changed concurrently")
+
+// lockoutMemory is how long a lockout is remembered after it ends
+const lockoutMemory time.Duration = 24 * time.Hour
+
+type usedKey struct {
+    user    string
+    step    int64
+}
+
+type attempts struct {
+    failures    int
+    expires     time.Time   // end of the window that started with the first failure
+    lockedUntil time.Time
+    lockouts    int
+}
+
+// expired reports whether there's nothing left in a worth keeping at now
+func (a attempts) expired(now time.Time) bool {
+    return !now.Before(a.expires) && !now.Before(a.lockedUntil.Add(lockoutMemory))
+}
+
+// Store is an in-memory implementation of the store interfaces
+type Store struct {
+    mu          sync.Mutex
+    ttl         time.Duration
+    clock       otp.Clock
+    keys        map[string]*otp.Key
+    used        map[usedKey]time.Time   // -> when it expires
+    attempts    map[string]attempts
+    drift       map[string]int64
+    counters    map[string]uint64
+    recovery    map[string][]string
+    stats       map[string]SecretStats
+    updating    map[string]*nameLock    // names an Update is running on
+    swept       time.Time
+}
+
+// nameLock serializes Updates of one name; refs counts those holding or waiting for it
+type nameLock struct {
+    mu      sync.Mutex
+    refs    int
+}
+
+// New returns an empty Store
+func New(opts Options) *Store {
+    if (opts.ReplayTTL <= 0) {
+        opts.ReplayTTL = 10 * time.Minute
+    }
+    if (opts.Clock == nil) {
+        opts.Clock = otp.SystemClock{}
+    }
+    return &Store{
+        ttl:        opts.ReplayTTL,
+        clock:      opts.Clock,
+        keys:       map[string]*otp.Key{},
+        used:       map[usedKey]time.Time{},
+        attempts:   map[string]attempts{},
+        drift:      map[string]int64{},
+        counters:   map[string]uint64{},
+        recovery:   map[string][]string{},
+        stats:      map[string]SecretStats{},
+        updating:   map[string]*nameLock{},
+        swept:      opts.Clock.Now(),
+    }
+}
+
+// clone copies k so callers can't change what's stored, or see later changes
+func clone(k *otp.Key) *otp.Key {
+    var c otp.Key = *k
+    c.Secret = append([]byte(nil), k.Secret...)
+    return &c
+}
+
+// sweep drops expired entries if it's been a while; the caller holds mu
+func (s *Store) sweep(now time.Time) {
+    if (now.Sub(s.swept) < sweepInterval) {
+        return
+    }
+    s.swept = now
+    for k, expires := range(s.used) {
+        if (!now.Before(expires)) {
+            delete(s.used, k)
+        }
+    }
+    for user, a := range(s.attempts) {
+        if (a.expired(now)) {
+            delete(s.attempts, user)
+        }
+    }
+}
+
+// Get returns a copy of the key stored under name, or otp.ErrKeyNotFound
+func (s *Store) Get(name string) (*otp.Key, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    var k, ok = s.keys[name]
+    if (!ok) {
+        return nil, fmt.Errorf("%w: %s", otp.ErrKeyNotFound, name)
+    }
+    return clone(k), nil
+}
+
+// Put stores a copy of k under name, replacing anything already there
+func (s *Store) Put(name string, k *otp.Key) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.keys[name] = clone(k)
+    return nil
+}
+
+/*
+    Update calls fn on a copy of the key stored under name and stores it if fn succeeds, see otp.KeyUpdater
+
+    fn runs without the store locked so it can use the store itself, as a LockoutPolicy
+    kept in the same Store does. It runs exactly once per call, so what it records on
+    the way, a failure towards a lockout, a used code or an audit event, is recorded
+    once: Updates of the same name wait for each other instead of retrying. A Put,
+    Remove or RestoreState that lands while fn runs wins, and Update fails with
+    ErrConflict without storing fn's result. fn mustn't call Update on the same name.
+*/
+func (s *Store) Update(name string, fn func(k *otp.Key) error) error {
+    var unlock func() = s.lockName(name)
+    defer unlock()
+
+    s.mu.Lock()
+    var k, ok = s.keys[name]
+    s.mu.Unlock()
+    if (!ok) {
+        return fmt.Errorf("%w: %s", otp.ErrKeyNotFound, name)
+    }
+
+    var c *otp.Key = clone(k)
+    if err := fn(c); err != nil {
+        return err
+    }
+
+    // Put and Update always store a fresh copy, so the same pointer means unchanged
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if (s.keys[name] != k) {
+        return fmt.Errorf("%w: %s", ErrConflict, name)
+    }
+    s.keys[name] = c
+    return nil
+}
+
+// lockName waits for any other Update of name to finish and returns the func that lets the next one in
+func (s *Store) lockName(name string) func() {
+    s.mu.Lock()
+    var l, ok = s.updating[name]
+    if (!ok) {
+        l = &nameLock{}
+        s.updating[name] = l
+    }
+    l.refs++
+    s.mu.Unlock()
+
+    l.mu.Lock()
+    return func() {
+        l.mu.Unlock()
+        s.mu.Lock()
+        l.refs--
+        if (l.refs == 0) {
+            delete(s.updating, name)
+        }
+        s.mu.Unlock()
+    }
+}
+
+// Remove deletes the key stored under name, or fails with otp.ErrKeyNotFound
+func (s *Store) Remove(name string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if _, ok := s.keys[name]; !ok {
+        return fmt.Errorf("%w: %s", otp.ErrKeyNotFound, name)
+    }
+    delete(s.keys, name)
+    return nil
+}
+
+// Names lists the stored names in sorted order
+func (s *Store) Names() ([]string, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    var names []string = make([]string, 0, len(s.keys))
+    for name := range(s.keys) {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names, nil
+}
+
+// IsUsed reports whether user has used the code for step within the replay TTL
+func (s *Store) IsUsed(user string, step int64) (bool, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    var expires, ok = s.used[usedKey{user, step}]
+    return ok && s.clock.Now().Before(expires), nil
+}
+
+// MarkUsed records that user has used the code for step, or fails with otp.ErrCodeReused if they already had
+func (s *Store) MarkUsed(user string, step int64) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    var now time.Time = s.clock.Now()
+    s.sweep(now)
+    var key usedKey = usedKey{user, step}
+    if expires, ok := s.used[key]; ok && now.Before(expires) {
+        return otp.ErrCodeReused
+    }
+    s.used[key] = now.Add(s.ttl)
+    return nil
+}
+
+// RecordFailure counts a failed verification for user and returns the count in the current window
+func (s *Store) RecordFailure(user string, window time.Duration) (int, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    var now time.Time = s.clock.Now()
+    s.sweep(now)
+    var a attempts = s.attempts[user]
+    if (!now.Before(a.expires)) {
+        a.failures, a.expires = 0, now.Add(window)
+    }
+    a.failures++
+    s.attempts[user] = a
+    return a.failures, nil
+}
+
+// ResetFailures clears user's failure count and lockouts
+func (s *Store) ResetFailures(user string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    delete(s.attempts, user)
+    return nil
+}
+
+// Lockout returns when user's lockout ends and how many they've had, see otp.LockoutStore
+func (s *Store) Lockout(user string) (time.Time, int, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    var a, ok = s.attempts[user]
+    if (!ok || a.expired(s.clock.Now())) {
+        return time.Time{}, 0, nil
+    }
+    return a.lockedUntil, a.lockouts, nil
+}
+
+// SetLockout locks user out until until and records it as their lockouts'th lockout
+func (s *Store) SetLockout(user string, until time.Time, lockouts int) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    var a attempts = s.attempts[user]
+    a.lockedUntil, a.lockouts = until, lockouts
+    s.attempts[user] = a
+    return nil
+}
+
+// Drift returns user's recorded clock drift in time steps, see otp.DriftStore
+func (s *Store) Drift(user string) (int64, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    return s.drift[user], nil
+}
+
+// SetDrift records user's clock drift
+func (s *Store) SetDrift(user string, steps int64) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if (steps == 0) {
+        delete(s.drift, user)
+        return nil
+    }
+    s.drift[user] = steps
+    return nil
+}
+
+// AdvanceCounter records counter for name if it's past the one recorded, see otp.CounterStore
+func (s *Store) AdvanceCounter(name string, counter uint64) (bool, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if last, ok := s.counters[name]; ok && counter <= last {
+        return false, nil
+    }
+    s.counters[name] = counter
+    return true, nil
+}
+
+// RecoveryCodes returns user's recovery code hashes, see otp.RecoveryStore
+func (s *Store) RecoveryCodes(user string) ([]string, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    return append([]string(nil), s.recovery[user]...), nil
+}
+
+// SetRecoveryCodes replaces user's recovery code hashes
+func (s *Store) SetRecoveryCodes(user string, hashes []string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if (len(hashes) == 0) {
+        delete(s.recovery, user)
+        return nil
+    }
+    s.recovery[user] = append([]string(nil), hashes...)
+    return nil
+}
+
+// UseRecoveryCode removes hash from user's recovery codes, reporting whether it was there
+func (s *Store) UseRecoveryCode(user string, hash string) (bool, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    var hashes []string = s.recovery[user]
+    for i, h := range(hashes) {
+        if (h == hash) {
+            s.recovery[user] = append(hashes[:i:i], hashes[i + 1:]...)
+            return true, nil
+        }
+    }
+    return false, nil
+}
+
+// the store implements all of the store interfaces
+var (
+    _ otp.KeyStore      = (*Store)(nil)
+    _ otp.KeyUpdater    = (*Store)(nil)
+    _ otp.ReplayStore   = (*Store)(nil)
+    _ otp.AttemptStore  = (*Store)(nil)
+    _ otp.LockoutStore  = (*Store)(nil)
+    _ otp.DriftStore    = (*Store)(nil)
+    _ otp.CounterStore  = (*Store)(nil)
+    _ otp.RecoveryStore = (*Store)(nil)
+)
@@ -0,0 +1,164 @@
+package memstore
+
+import (
+    "errors"
+    "sync"
+    "testing"
+    "time"
+
+    otp "github.com/adam-good/OTP"
+)
+
+// the RFC 4226 codes for counters 0 to 3
+var codes []string = []string{"755224", "287082", "359152", "969429"}
+
+func TestPutGet(t *testing.T) {
+    var s *Store = New(Options{})
+    var k, err = otp.NewKey(otp.RFC4226Secret, otp.WithHOTP(1), otp.WithAccount("alice"))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    if err = s.Put("alice", k); err != nil {
+        t.Fatal(err)
+    }
+    // what's stored is a copy
+    k.Counter = 7
+    k.Secret[0] = 0
+
+    var got *otp.Key
+    if got, err = s.Get("alice"); err != nil {
+        t.Fatal(err)
+    }
+    if (got.Counter != 1 || string(got.Secret) != string(otp.RFC4226Secret) || got.Account != "alice") {
+        t.Errorf("Get returned %+v", *got)
+    }
+    if _, err = s.Get("bob"); !errors.Is(err, otp.ErrKeyNotFound) {
+        t.Errorf("Get of a missing key: %v, want ErrKeyNotFound", err)
+    }
+}
+
+func TestUpdate(t *testing.T) {
+    var s *Store = New(Options{})
+    var k, err = otp.NewKey(otp.RFC4226Secret, otp.WithHOTP(0), otp.WithSkew(3))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    if err = s.Put("alice", k); err != nil {
+        t.Fatal(err)
+    }
+    if err = s.Update("alice", func(k *otp.Key) error { return k.Validate(codes[2]) }); err != nil {
+        t.Fatal(err)
+    }
+    if k, err = s.Get("alice"); err != nil || k.Counter != 3 {
+        t.Fatalf("counter after Update: %v, %v, want 3", k, err)
+    }
+
+    // a failing fn leaves the key alone
+    if err = s.Update("alice", func(k *otp.Key) error { return k.Validate(codes[2]) }); !errors.Is(err, otp.ErrCodeMismatch) {
+        t.Errorf("Update with a used code: %v, want ErrCodeMismatch", err)
+    }
+    if k, err = s.Get("alice"); err != nil || k.Counter != 3 {
+        t.Errorf("counter after a failed Update: %v, %v, want 3", k, err)
+    }
+    if err = s.Update("bob", func(k *otp.Key) error { return nil }); !errors.Is(err, otp.ErrKeyNotFound) {
+        t.Errorf("Update of a missing key: %v, want ErrKeyNotFound", err)
+    }
+}
+
+func TestUpdateConflict(t *testing.T) {
+    var s *Store = New(Options{})
+    var k, err = otp.NewKey(otp.RFC4226Secret)
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    if err = s.Put("alice", k); err != nil {
+        t.Fatal(err)
+    }
+    // fn loses the race to a Put, and isn't run again
+    var runs int
+    err = s.Update("alice", func(c *otp.Key) error {
+        runs++
+        return s.Put("alice", k)
+    })
+    if (!errors.Is(err, ErrConflict) || runs != 1) {
+        t.Errorf("Update losing to a Put: %v after %d runs, want ErrConflict after 1", err, runs)
+    }
+}
+
+func TestUpdateRunsOnce(t *testing.T) {
+    // concurrent Updates of one HOTP key: each fn runs once and every counter is used once
+    var s *Store = New(Options{})
+    var k, err = otp.NewKey(otp.RFC4226Secret, otp.WithHOTP(0), otp.WithSkew(9))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    if err = s.Put("alice", k); err != nil {
+        t.Fatal(err)
+    }
+    var mu sync.Mutex
+    var runs, passed int
+    var wg sync.WaitGroup
+    for _, code := range(codes) {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            var err = s.Update("alice", func(k *otp.Key) error {
+                mu.Lock()
+                runs++
+                mu.Unlock()
+                return k.Validate(code)
+            })
+            if (err == nil) {
+                mu.Lock()
+                passed++
+                mu.Unlock()
+            }
+        }()
+    }
+    wg.Wait()
+    if (runs != len(codes)) {
+        t.Errorf("fn ran %d times for %d Updates", runs, len(codes))
+    }
+    // the code for counter 3 always gets in, earlier ones only if they came before it
+    if k, err = s.Get("alice"); err != nil || k.Counter != 4 || passed < 1 {
+        t.Errorf("after the Updates: %v, %v with %d passed, want counter 4", k, err, passed)
+    }
+    if (len(s.updating) != 0) {
+        t.Errorf("%d name locks left", len(s.updating))
+    }
+}
+
+func TestUpdateWithLockoutInSameStore(t *testing.T) {
+    // the lockout policy reads and writes the store from inside Update's fn
+    var s *Store = New(Options{})
+    var policy *otp.LockoutPolicy = &otp.LockoutPolicy{Store: s, MaxAttempts: 2}
+    var k, err = otp.NewKey(otp.RFC4226Secret, otp.WithHOTP(0), otp.WithLockout(policy, "alice"))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    if err = s.Put("alice", k); err != nil {
+        t.Fatal(err)
+    }
+
+    var done = make(chan error, 1)
+    go func() {
+        var errs error
+        for _, code := range([]string{"000000", codes[0], "000000", "000000"}) {
+            errs = errors.Join(errs, s.Update("alice", func(k *otp.Key) error {
+                return k.Validate(code)
+            }))
+        }
+        done <- errs
+    }()
+    select {
+    case err = <-done:
+    case <-time.After(5 * time.Second):
+        t.Fatal("Update deadlocked with a LockoutPolicy on the same store")
+    }
+    if (!errors.Is(err, otp.ErrCodeMismatch) || !errors.Is(err, otp.ErrLockedOut)) {
+        t.Errorf("wrong codes: %v, want ErrCodeMismatch and then ErrLockedOut", err)
+    }
+    if k, err = s.Get("alice"); err != nil || k.Counter != 1 {
+        t.Errorf("counter: %v, %v, want 1", k, err)
+    }
+}
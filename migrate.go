@@ -0,0 +1,98 @@
+package otp
+
+import (
+    "errors"
+)
+
+/*
+    Migrating off the legacy codes
+
+    Older versions of this package produced codes no standard authenticator app could
+    match (ASCII decimal counter, per byte digits instead of RFC 4226 truncation). The
+    secret itself is fine though, so users can re-enroll the same secret into a standard
+    app using the URI from MigrateLegacy, and while they do ValidateDuringMigration
+    accepts codes from either side.
+*/
+
+/*
+    MigrateLegacy returns a standard otpauth://totp URI for an existing legacy secret
+
+    The URI describes plain RFC 6238 codes: SHA1, 6 digits, 30 second period.
+*/
+func MigrateLegacy(secret []byte) (newURI string, err error) {
+    var k *Key
+    if k, err = NewKey(secret, WithAccount("OTP")); err != nil {
+        return "", err
+    }
+    return k.URI()
+}
+
+/*
+    ValidateDuringMigration accepts both legacy and standard codes
+
+    The standard code is checked by VerifyTOTP with opts, so the caller's digits,
+    algorithm, period, skew and the rest apply to it as they do to ValidateTOTP; its
+    Replay and Drift only ever see the standard code. Legacy codes always have 30
+    second steps and 6 digits and are accepted with the same skew. legacy is only
+    true when the code matched the legacy algorithm alone, so callers can tell when a
+    user has finished re-enrolling. A code matching neither fails with ErrCodeMismatch,
+    and with opts.Lockout set that counts against the user once. opts.Audit gets one
+    event either way, with the Scheme that matched.
+*/
+func ValidateDuringMigration(key []byte, code string, opts ValidateOpts) (legacy bool, err error) {
+    if (len(key) == 0) {
+        return false, ErrEmptySecret
+    }
+    if err = refuseStrict("ValidateDuringMigration accepts legacy codes"); err != nil {
+        return false, err
+    }
+    opts = opts.withDefaults()
+
+    // every legacy code in the window is compared, as VerifyTOTP compares every standard one
+    var legacyMatch bool = false
+    var legacyOffset int64
+    var step int64 = legacyStep(opts.Time)
+    for offset := -int64(opts.Skew); offset <= int64(opts.Skew); offset++ {
+        if (codeMatches(legacyTOTP(key, step + offset), code, opts.Normalization) && !legacyMatch) {
+            legacyMatch, legacyOffset = true, offset
+        }
+    }
+
+    // the lockout and audit are done here so a legacy code isn't counted or logged as a failed standard one
+    var lockout *LockoutPolicy = opts.Lockout
+    var sink AuditSink = opts.Audit
+    opts.Lockout, opts.Audit = nil, nil
+    var match Match
+    err = lockout.Guard(opts.User, func() error {
+        var err error
+        match, err = VerifyTOTP(key, code, opts)
+        if (errors.Is(err, ErrCodeMismatch) && legacyMatch) {
+            legacy = true
+            return nil
+        }
+        return err
+    })
+    if (sink != nil) {
+        var e AuditEvent = AuditEvent{
+            Time:       clockNow(opts.Clock),
+            User:       opts.User,
+            Type:       TypeTOTP,
+            Algorithm:  opts.Algorithm,
+            Fingerprint: fingerprint(TypeTOTP, opts.Algorithm, opts.Digits, opts.Period, opts.T0, opts.Truncation, key),
+            Result:     VerifyResultOf(err),
+            Offset:     match.Offset,
+            Err:        err,
+        }
+        switch {
+        case (err == nil && legacy):
+            e.Scheme, e.Offset = "legacy", legacyOffset
+        case (err == nil):
+            e.Scheme = "standard"
+        }
+        sink.Audit(e)
+    }
+    if (err != nil) {
+        return false, err
+    }
+    return legacy, nil
+}
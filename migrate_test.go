@@ -0,0 +1,80 @@
+package otp
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestValidateDuringMigration(t *testing.T) {
+    // at 59 the standard code is RFC 4226 counter 1 and the legacy one legacy counter 1
+    var tests = []struct {
+        name    string
+        code    string
+        opts    ValidateOpts
+        legacy  bool
+        err     error
+    }{
+        {"standard", rfc4226Codes[1], ValidateOpts{Time: time.Unix(59, 0)}, false, nil},
+        {"legacy", legacyCodes[1], ValidateOpts{Time: time.Unix(59, 0)}, true, nil},
+        {"legacy in the skew", legacyCodes[0], ValidateOpts{Time: time.Unix(59, 0), Skew: 1}, true, nil},
+        {"legacy outside the skew", legacyCodes[0], ValidateOpts{Time: time.Unix(59, 0)}, false, ErrCodeMismatch},
+        {"standard with the caller's digits", "94287082", ValidateOpts{Time: time.Unix(59, 0), Digits: 8}, false, nil},
+        {"standard with the caller's period", rfc4226Codes[1], ValidateOpts{Time: time.Unix(119, 0), Period: time.Minute}, false, nil},
+        {"neither", "000000", ValidateOpts{Time: time.Unix(59, 0)}, false, ErrCodeMismatch},
+    }
+    for _, tt := range(tests) {
+        t.Run(tt.name, func(t *testing.T) {
+            var legacy, err = ValidateDuringMigration(RFC4226Secret, tt.code, tt.opts)
+            if (!errors.Is(err, tt.err)) {
+                t.Fatalf("error %v, want %v", err, tt.err)
+            }
+            if (legacy != tt.legacy) {
+                t.Errorf("legacy %v, want %v", legacy, tt.legacy)
+            }
+        })
+    }
+}
+
+func TestMigrateLegacy(t *testing.T) {
+    var uri, err = MigrateLegacy(RFC4226Secret)
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    var k *Key
+    if k, err = ParseURI(uri); err != nil {
+        t.Fatalf("ParseURI(%s): %v", uri, err)
+    }
+    if err = k.ValidateAt(rfc4226Codes[1], time.Unix(59, 0)); err != nil {
+        t.Errorf("standard code for the migrated key: %v", err)
+    }
+}
+
+func TestValidateDuringMigrationAudit(t *testing.T) {
+    var tests = []struct {
+        name    string
+        code    string
+        result  VerifyResult
+        scheme  string
+    }{
+        {"standard", rfc4226Codes[1], ResultValid, "standard"},
+        {"legacy", legacyCodes[1], ResultValid, "legacy"},
+        {"neither", "000000", ResultInvalid, ""},
+    }
+    for _, tt := range(tests) {
+        var events []AuditEvent
+        var opts ValidateOpts = ValidateOpts{
+            Time:   time.Unix(59, 0),
+            User:   "alice",
+            Audit:  AuditFunc(func(e AuditEvent) { events = append(events, e) }),
+        }
+        ValidateDuringMigration(RFC4226Secret, tt.code, opts)
+        if (len(events) != 1) {
+            t.Errorf("%s: %d audit events, want 1", tt.name, len(events))
+            continue
+        }
+        if (events[0].Result != tt.result || events[0].Scheme != tt.scheme || events[0].User != "alice") {
+            t.Errorf("%s: %+v, want result %s and scheme %q", tt.name, events[0], tt.result, tt.scheme)
+        }
+    }
+}
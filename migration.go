@@ -0,0 +1,338 @@
+package otp
+
+import (
+    "crypto/rand"
+    "encoding/base64"
+    "encoding/binary"
+    "fmt"
+    "net/url"
+    "strings"
+)
+
+/*
+    Google Authenticator migration payloads
+
+    "Transfer accounts" in Google Authenticator exports accounts as QR codes holding
+        otpauth-migration://offline?data=BASE64
+    where the data is this protobuf message:
+
+        message MigrationPayload {
+            message OtpParameters {
+                bytes     secret    = 1;
+                string    name      = 2;
+                string    issuer    = 3;
+                Algorithm algorithm = 4;    // 1 SHA1, 2 SHA256, 3 SHA512, 4 MD5
+                DigitCount digits   = 5;    // 1 six, 2 eight
+                OtpType   type      = 6;    // 1 HOTP, 2 TOTP
+                int64     counter   = 7;
+            }
+            repeated OtpParameters otp_parameters = 1;
+            int32 version     = 2;
+            int32 batch_size  = 3;
+            int32 batch_index = 4;
+            int32 batch_id    = 5;
+        }
+
+    The app has no period field (it's always 30 seconds) and only knows 6 and 8 digits,
+    so keys outside of that can't be exported and fail with ErrNotMigratable.
+*/
+
+// protobuf wire types used by the payload
+const (
+    wireVarint  int = 0
+    wireBytes   int = 2
+)
+
+func appendVarint(b []byte, v uint64) []byte {
+    return binary.AppendUvarint(b, v)
+}
+
+func appendTag(b []byte, field int, wire int) []byte {
+    return appendVarint(b, uint64(field << 3 | wire))
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+    return appendVarint(appendTag(b, field, wireVarint), v)
+}
+
+func appendBytesField(b []byte, field int, data []byte) []byte {
+    b = appendTag(b, field, wireBytes)
+    b = appendVarint(b, uint64(len(data)))
+    return append(b, data...)
+}
+
+/*
+    migrationParameters encodes one key as an OtpParameters message
+
+    A key whose secret is only in its provider fails with ErrProvidedKey, and one with
+    a checksum digit, a fixed truncation offset or codes that aren't decimal, which the
+    app would get wrong, with ErrNotMigratable.
+*/
+func migrationParameters(k *Key) ([]byte, error) {
+    switch {
+    case (len(k.Secret) == 0 && k.Provider != nil):
+        return nil, fmt.Errorf("%w: %w: %q", ErrNotMigratable, ErrProvidedKey, k.SecretName)
+    case (len(k.Secret) == 0):
+        return nil, fmt.Errorf("%w: %w", ErrNotMigratable, ErrEmptySecret)
+    case (k.Checksum):
+        return nil, fmt.Errorf("%w: checksum digit", ErrNotMigratable)
+    case (!isDecimal(k.Encoder)):
+        return nil, fmt.Errorf("%w: codes aren't decimal", ErrNotMigratable)
+    }
+    if offset, fixed := k.Truncation.Offset(); fixed {
+        return nil, fmt.Errorf("%w: fixed truncation offset %d", ErrNotMigratable, offset)
+    }
+
+    var algo uint64
+    switch k.Algorithm {
+    case SHA1:
+        algo = 1
+    case SHA256:
+        algo = 2
+    case SHA512:
+        algo = 3
+    default:
+        return nil, fmt.Errorf("%w: algorithm %v", ErrNotMigratable, k.Algorithm)
+    }
+
+    var digits uint64
+    switch k.Digits {
+    case 6:
+        digits = 1
+    case 8:
+        digits = 2
+    default:
+        return nil, fmt.Errorf("%w: %d digits", ErrNotMigratable, k.Digits)
+    }
+
+    var otpType uint64 = 2
+    if (k.Type == TypeHOTP) {
+        otpType = 1
+    } else if (k.Period != DefaultPeriod || !k.T0.IsZero()) {
+        return nil, fmt.Errorf("%w: period %v", ErrNotMigratable, k.Period)
+    }
+
+    var b []byte
+    b = appendBytesField(b, 1, k.Secret)
+    b = appendBytesField(b, 2, []byte(k.Account))
+    if (k.Issuer != "") {
+        b = appendBytesField(b, 3, []byte(k.Issuer))
+    }
+    b = appendVarintField(b, 4, algo)
+    b = appendVarintField(b, 5, digits)
+    b = appendVarintField(b, 6, otpType)
+    if (k.Type == TypeHOTP) {
+        b = appendVarintField(b, 7, k.Counter)
+    }
+    return b, nil
+}
+
+/*
+    MigrationURIs exports keys as otpauth-migration:// URIs for Google Authenticator
+
+    The app scans one QR code per URI (render them with QRCodePNG or PrintQR), and
+    a QR code only holds so much, so the keys are split into batches of perBatch keys;
+    perBatch of 0 or less puts them all in one batch. The batches share a random batch
+    id so the app knows they belong together. A key the app can't take as it is fails
+    the export with ErrNotMigratable, one whose secret is in a KeyProvider also with
+    ErrProvidedKey.
+*/
+func MigrationURIs(keys []*Key, perBatch int) ([]string, error) {
+    if (perBatch <= 0 || perBatch > len(keys)) {
+        perBatch = max(len(keys), 1)
+    }
+    // an empty export is still one (empty) batch
+    var batches int = max((len(keys) + perBatch - 1) / perBatch, 1)
+
+    var id [4]byte
+    if _, err := rand.Read(id[:]); err != nil {
+        return nil, err
+    }
+    var batchID uint64 = uint64(binary.BigEndian.Uint32(id[:]) & 0x7FFFFFFF)
+
+    var uris []string
+    for i := 0; i < batches; i++ {
+        var payload []byte
+        for _, k := range(keys[i * perBatch:min((i + 1) * perBatch, len(keys))]) {
+            var params, err = migrationParameters(k)
+            if (err != nil) {
+                return nil, err
+            }
+            payload = appendBytesField(payload, 1, params)
+        }
+        payload = appendVarintField(payload, 2, 1)
+        payload = appendVarintField(payload, 3, uint64(batches))
+        payload = appendVarintField(payload, 4, uint64(i))
+        payload = appendVarintField(payload, 5, batchID)
+
+        uris = append(uris, "otpauth-migration://offline?data=" + url.QueryEscape(base64.StdEncoding.EncodeToString(payload)))
+    }
+    return uris, nil
+}
+
+// MigrationURI exports all keys as a single otpauth-migration:// URI, see MigrationURIs
+func MigrationURI(keys []*Key) (string, error) {
+    var uris, err = MigrationURIs(keys, 0)
+    if (err != nil) {
+        return "", err
+    }
+    return uris[0], nil
+}
+
+// protoReader walks the fields of a protobuf message
+type protoReader struct {
+    data    []byte
+}
+
+// next returns the next field; for varints value is set, for length-delimited fields bytes
+func (r *protoReader) next() (field int, wire int, value uint64, bytes []byte, err error) {
+    var tag uint64
+    if tag, err = r.varint(); err != nil {
+        return 0, 0, 0, nil, err
+    }
+    field, wire = int(tag >> 3), int(tag & 7)
+    switch wire {
+    case 0:
+        value, err = r.varint()
+    case 1:
+        bytes, err = r.take(8)
+    case 2:
+        var n uint64
+        if n, err = r.varint(); err == nil {
+            bytes, err = r.take(n)
+        }
+    case 5:
+        bytes, err = r.take(4)
+    default:
+        err = fmt.Errorf("unsupported wire type %d", wire)
+    }
+    return field, wire, value, bytes, err
+}
+
+func (r *protoReader) varint() (uint64, error) {
+    var v, n = binary.Uvarint(r.data)
+    if (n <= 0) {
+        return 0, fmt.Errorf("bad varint")
+    }
+    r.data = r.data[n:]
+    return v, nil
+}
+
+func (r *protoReader) take(n uint64) ([]byte, error) {
+    if (n > uint64(len(r.data))) {
+        return nil, fmt.Errorf("truncated field")
+    }
+    var b []byte = r.data[:n]
+    r.data = r.data[n:]
+    return b, nil
+}
+
+// trimIssuer strips an "Issuer:" prefix matching issuer off an account name, as apps often store the whole label
+func trimIssuer(name string, issuer string) string {
+    if i := len(issuer); i > 0 && len(name) > i && name[:i] == issuer && name[i] == ':' {
+        return strings.TrimLeft(name[i+1:], " ")
+    }
+    return name
+}
+
+// parseMigrationParameters decodes one OtpParameters message into a Key
+func parseMigrationParameters(data []byte) (*Key, error) {
+    var r protoReader = protoReader{data: data}
+    var secret []byte
+    var name, issuer string
+    var algo, digits, otpType, counter uint64
+
+    for len(r.data) > 0 {
+        var field, _, value, b, err = r.next()
+        if (err != nil) {
+            return nil, err
+        }
+        switch field {
+        case 1:
+            secret = b
+        case 2:
+            name = string(b)
+        case 3:
+            issuer = string(b)
+        case 4:
+            algo = value
+        case 5:
+            digits = value
+        case 6:
+            otpType = value
+        case 7:
+            counter = value
+        }
+    }
+
+    var opts []Option
+    switch algo {
+    case 0, 1:
+        opts = append(opts, WithAlgorithm(SHA1))
+    case 2:
+        opts = append(opts, WithAlgorithm(SHA256))
+    case 3:
+        opts = append(opts, WithAlgorithm(SHA512))
+    default:
+        // 4 is MD5, which is too short for RFC 4226 truncation
+        return nil, fmt.Errorf("unsupported algorithm %d", algo)
+    }
+    switch digits {
+    case 0, 1:
+        opts = append(opts, WithDigits(6))
+    case 2:
+        opts = append(opts, WithDigits(8))
+    default:
+        return nil, fmt.Errorf("unsupported digit count %d", digits)
+    }
+    if (otpType == 1) {
+        opts = append(opts, WithHOTP(counter))
+    }
+
+    opts = append(opts, WithIssuer(issuer), WithAccount(trimIssuer(name, issuer)))
+
+    return NewKey(secret, opts...)
+}
+
+/*
+    ParseMigrationURI decodes an otpauth-migration:// URI exported by Google Authenticator
+
+    It returns every account in the batch. An export of many accounts comes as several
+    URIs (one per QR code); parse each of them and concatenate the results. Anything
+    malformed fails with an error wrapping ErrInvalidURI.
+*/
+func ParseMigrationURI(uri string) ([]*Key, error) {
+    var u, err = url.Parse(uri)
+    if (err != nil) {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidURI, err)
+    }
+    if (u.Scheme != "otpauth-migration" || u.Host != "offline") {
+        return nil, fmt.Errorf("%w: not an otpauth-migration://offline URI", ErrInvalidURI)
+    }
+
+    // an unescaped + in the base64 comes out of the query as a space
+    var data string = strings.ReplaceAll(u.Query().Get("data"), " ", "+")
+    var payload []byte
+    if payload, err = DecodeBase64(data); err != nil {
+        return nil, fmt.Errorf("%w: data: %v", ErrInvalidURI, err)
+    }
+
+    var keys []*Key
+    var r protoReader = protoReader{data: payload}
+    for len(r.data) > 0 {
+        var field, wire, _, b, err = r.next()
+        if (err != nil) {
+            return nil, fmt.Errorf("%w: payload: %v", ErrInvalidURI, err)
+        }
+        if (field != 1 || wire != wireBytes) {
+            // version and batch information
+            continue
+        }
+        var k *Key
+        if k, err = parseMigrationParameters(b); err != nil {
+            return nil, fmt.Errorf("%w: account %d: %v", ErrInvalidURI, len(keys), err)
+        }
+        keys = append(keys, k)
+    }
+    return keys, nil
+}
@@ -0,0 +1,57 @@
+package otp
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestMigrationURIRoundTrip(t *testing.T) {
+    var totp, _ = NewKey(RFC4226Secret, WithIssuer("Example"), WithAccount("alice@example.com"))
+    var hotp, _ = NewKey(RFC4226Secret, WithHOTP(7), WithDigits(8), WithAccount("bob"))
+    var uri, err = MigrationURI([]*Key{totp, hotp})
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    var keys []*Key
+    if keys, err = ParseMigrationURI(uri); err != nil {
+        t.Fatal(err)
+    }
+    if (len(keys) != 2 || !keysEqual(keys[0], totp) || !keysEqual(keys[1], hotp)) {
+        t.Errorf("round trip: %+v", keys)
+    }
+}
+
+func TestMigrationURIRejects(t *testing.T) {
+    var provider KeyProvider = KeyProviderFunc(func(name string) ([]byte, error) {
+        return RFC4226Secret, nil
+    })
+    var provided, perr = NewProvidedKey(provider, "alice")
+    if (perr != nil) {
+        t.Fatal(perr)
+    }
+    var key = func(opts ...Option) *Key {
+        var k, err = NewKey(RFC4226Secret, opts...)
+        if (err != nil) {
+            t.Fatal(err)
+        }
+        return k
+    }
+    var tests = []struct {
+        name    string
+        key     *Key
+        err     error
+    }{
+        {"provided key", provided, ErrProvidedKey},
+        {"checksum digit", key(WithChecksum()), ErrNotMigratable},
+        {"fixed truncation offset", key(WithTruncationOffset(4)), ErrNotMigratable},
+        {"hex codes", key(WithEncoder(HexEncoder{})), ErrNotMigratable},
+        {"60 second period", key(WithPeriod(time.Minute)), ErrNotMigratable},
+        {"7 digits", key(WithDigits(7)), ErrNotMigratable},
+    }
+    for _, tt := range(tests) {
+        if _, err := MigrationURI([]*Key{tt.key}); !errors.Is(err, tt.err) {
+            t.Errorf("%s: %v, want %v", tt.name, err, tt.err)
+        }
+    }
+}
@@ -0,0 +1,138 @@
+package otp
+
+import (
+    "crypto/sha256"
+    _ "embed"
+    "fmt"
+    "strings"
+)
+
+/*
+    Mnemonic secrets
+
+    A secret written down as Base32 is easy to copy wrong and nothing notices until the
+    codes don't work. EncodeMnemonic writes it as words the way BIP-39 does: the bytes
+    followed by the first len/32 bits of their SHA-256, cut into 11 bit indexes into a
+    2048 word list. DecodeMnemonic checks that checksum, so a misspelt or swapped word
+    is caught when the secret is typed back in.
+
+    Pass BIP39English to get words other BIP-39 tools understand, or any other list of
+    2048 different words, in order. BIP-39 itself only covers 16 to 32 bytes; anything
+    in multiples of 4 bytes up to 64 works here, a 20 byte SHA1 secret being 15 words.
+*/
+
+//go:embed wordlists/bip39-english.txt
+var bip39English string
+
+// BIP39English is the BIP-39 English word list, in order
+var BIP39English []string = strings.Fields(bip39English)
+
+// mnemonicWords is how many words a mnemonic word list has, 11 bits' worth
+const mnemonicWords int = 2048
+
+// checkWordlist makes sure wordlist can be used for a mnemonic
+func checkWordlist(wordlist []string) error {
+    if (len(wordlist) != mnemonicWords) {
+        return fmt.Errorf("%w: word list has %d words, not %d", ErrInvalidMnemonic, len(wordlist), mnemonicWords)
+    }
+    // a word that's in twice could decode to either index
+    var seen map[string]bool = make(map[string]bool, mnemonicWords)
+    for _, w := range(wordlist) {
+        w = strings.ToLower(w)
+        if (seen[w]) {
+            return fmt.Errorf("%w: %q is in the word list twice", ErrInvalidMnemonic, w)
+        }
+        seen[w] = true
+    }
+    return nil
+}
+
+// EncodeMnemonic writes secret as words from wordlist, see the notes above
+func EncodeMnemonic(secret []byte, wordlist []string) (string, error) {
+    if err := checkWordlist(wordlist); err != nil {
+        return "", err
+    }
+    if (len(secret) == 0 || len(secret) % 4 != 0 || len(secret) > 64) {
+        return "", fmt.Errorf("%w: a %d byte secret, it has to be a multiple of 4 bytes up to 64", ErrInvalidMnemonic, len(secret))
+    }
+
+    var sum [sha256.Size]byte = sha256.Sum256(secret)
+    var bits []byte = make([]byte, len(secret) + len(sum))
+    defer Wipe(bits)
+    copy(bits, secret)
+    copy(bits[len(secret):], sum[:])
+    var total int = len(secret) * 8 * 33 / 32
+    var words []string = make([]string, total / 11)
+    for i := range(words) {
+        var index int
+        for j := 0; j < 11; j++ {
+            var bit int = i * 11 + j
+            index = index << 1 | int(bits[bit / 8] >> (7 - bit % 8) & 1)
+        }
+        words[i] = wordlist[index]
+    }
+    return strings.Join(words, " "), nil
+}
+
+/*
+    DecodeMnemonic reads a secret written by EncodeMnemonic
+
+    Words are matched ignoring case, and like BIP-39 the first four letters of a word
+    are enough when they're unique in the list. A word that isn't in the list, a word
+    count that can't be a secret or a bad checksum fails with ErrInvalidMnemonic.
+*/
+func DecodeMnemonic(mnemonic string, wordlist []string) ([]byte, error) {
+    if err := checkWordlist(wordlist); err != nil {
+        return nil, err
+    }
+    var words []string = strings.Fields(strings.ToLower(mnemonic))
+    if (len(words) == 0 || len(words) % 3 != 0 || len(words) > 48) {
+        return nil, fmt.Errorf("%w: %d words", ErrInvalidMnemonic, len(words))
+    }
+
+    var index map[string]int = make(map[string]int, 2 * mnemonicWords)
+    var prefixes map[string]int = map[string]int{}
+    for i, w := range(wordlist) {
+        w = strings.ToLower(w)
+        index[w] = i
+        if (len(w) >= 4) {
+            if _, dup := prefixes[w[:4]]; dup {
+                prefixes[w[:4]] = -1
+            } else {
+                prefixes[w[:4]] = i
+            }
+        }
+    }
+
+    var bits []byte = make([]byte, (len(words) * 11 + 7) / 8)
+    defer Wipe(bits)
+    for i, word := range(words) {
+        var n, ok = index[word]
+        if (!ok && len(word) >= 4) {
+            n, ok = prefixes[word[:4]]
+            ok = ok && n >= 0
+        }
+        if (!ok) {
+            return nil, fmt.Errorf("%w: %q isn't in the word list", ErrInvalidMnemonic, word)
+        }
+        for j := 0; j < 11; j++ {
+            if (n >> (10 - j) & 1 != 0) {
+                var bit int = i * 11 + j
+                bits[bit / 8] |= 1 << (7 - bit % 8)
+            }
+        }
+    }
+
+    // words*11 bits are ENT bits of secret and ENT/32 of checksum
+    var size int = len(words) * 11 * 32 / 33 / 8
+    var secret []byte = bits[:size]
+    var sum [sha256.Size]byte = sha256.Sum256(secret)
+    for bit := 0; bit < size * 8 / 32; bit++ {
+        var want byte = sum[bit / 8] >> (7 - bit % 8) & 1
+        var got byte = bits[size + bit / 8] >> (7 - bit % 8) & 1
+        if (want != got) {
+            return nil, fmt.Errorf("%w: bad checksum, check the words and their order", ErrInvalidMnemonic)
+        }
+    }
+    return append([]byte(nil), secret...), nil
+}
@@ -0,0 +1,146 @@
+package otp
+
+import (
+    "crypto/subtle"
+    "unicode"
+)
+
+/*
+    NormalizationPolicy controls how forgiving validation is about what a user typed
+
+    Lenient     strip spaces and dashes so "123 456" and "1234-5678" are read as plain
+                digits, whichever way Code.Group showed them (the default)
+    Strict      only ASCII digits are accepted, anything else fails validation
+    VeryLenient like Lenient but also strips any Unicode space or dash and dots, and
+                reads any Unicode decimal digit (full-width, Arabic-Indic, ...) as its
+                ASCII digit
+
+    Set it for one validation with ValidateOpts.Normalization or for a key with
+    WithNormalization; everything else reads codes as Lenient does.
+*/
+type NormalizationPolicy int
+
+const (
+    Lenient NormalizationPolicy = iota
+    Strict
+    VeryLenient
+)
+
+/*
+    Normalize turns a submitted code into plain ASCII digits under policy
+
+    ok is false if after normalizing anything other than ASCII digits is left.
+*/
+func Normalize(code string, policy NormalizationPolicy) (string, bool) {
+    var buf [16]byte
+    var normalized, ok = appendNormalized(buf[:0], code, policy)
+    return string(normalized), ok
+}
+
+// appendNormalized is Normalize appended to b, which doesn't allocate when b has room
+func appendNormalized(b []byte, code string, policy NormalizationPolicy) ([]byte, bool) {
+    for _, r := range(code) {
+        switch {
+        case (r >= '0' && r <= '9'):
+            b = append(b, byte(r))
+        case (policy == Lenient && (r == ' ' || r == '-')):
+            continue
+        case (policy == VeryLenient && (unicode.IsSpace(r) || unicode.Is(unicode.Pd, r) || r == '.')):
+            continue
+        case (policy == VeryLenient && unicode.IsDigit(r)):
+            b = append(b, byte('0' + digitValue(r)))
+        default:
+            return b[:0], false
+        }
+    }
+    return b, true
+}
+
+/*
+    digitValue returns the value of a Unicode decimal digit
+
+    Every script's digits are encoded as a contiguous run of ten starting at zero and the
+    runs in unicode.Nd are made of whole runs, so the position inside the range gives the value.
+*/
+func digitValue(r rune) rune {
+    for _, rng := range(unicode.Nd.R16) {
+        if (r >= rune(rng.Lo) && r <= rune(rng.Hi)) {
+            return (r - rune(rng.Lo)) % 10
+        }
+    }
+    for _, rng := range(unicode.Nd.R32) {
+        if (r >= rune(rng.Lo) && r <= rune(rng.Hi)) {
+            return (r - rune(rng.Lo)) % 10
+        }
+    }
+    return 0
+}
+
+/*
+    codeMatches normalizes a submitted code under policy and compares it to the expected code
+
+    The comparison is constant time (only the length of the code can leak, and that's
+    public anyway). Every validation path compares codes through here or stringMatches,
+    never with == or bytes.Equal.
+*/
+func codeMatches(expected Code, code string, policy NormalizationPolicy) bool {
+    if (expected.IsZero()) {
+        return false
+    }
+    var buf [16]byte
+    return bytesMatch(expected.appendTo(buf[:0]), code, policy)
+}
+
+/*
+    transposedMatches reports whether code is expected with one pair of neighbouring digits swapped
+
+    Every swap is compared, in constant time, whether or not an earlier one matched.
+    Swapping two equal digits gives expected itself, which isn't counted.
+*/
+func transposedMatches(expected Code, code string, policy NormalizationPolicy) bool {
+    if (expected.IsZero()) {
+        return false
+    }
+    var buf, got [16]byte
+    var want []byte = expected.appendTo(buf[:0])
+    var normalized, ok = appendNormalized(got[:0], code, policy)
+    if (!ok || len(normalized) != len(want)) {
+        return false
+    }
+    var match int
+    for i := 0; i + 1 < len(want); i++ {
+        var same int = subtle.ConstantTimeByteEq(want[i], want[i+1])
+        want[i], want[i+1] = want[i+1], want[i]
+        match |= subtle.ConstantTimeCompare(want, normalized) & (1 ^ same)
+        want[i], want[i+1] = want[i+1], want[i]
+    }
+    return match == 1
+}
+
+// stringMatches is codeMatches for an expected code that is already a string
+func stringMatches(expected string, code string, policy NormalizationPolicy) bool {
+    return bytesMatch([]byte(expected), code, policy)
+}
+
+// bytesMatch is stringMatches with the expected code as bytes; neither allocates for codes of up to 16 digits
+func bytesMatch(expected []byte, code string, policy NormalizationPolicy) bool {
+    var buf [16]byte
+    var normalized, ok = appendNormalized(buf[:0], code, policy)
+    if (!ok) {
+        return false
+    }
+    return subtle.ConstantTimeCompare(expected, normalized) == 1
+}
+
+/*
+    matchedEither combines the results of checking a preferred and a fallback candidate
+
+    Both candidates must already have been compared. fallback is true only when the
+    preferred one didn't match, and if neither matched the error is ErrCodeMismatch.
+*/
+func matchedEither(preferred bool, alternate bool) (fallback bool, err error) {
+    if (!preferred && !alternate) {
+        return false, ErrCodeMismatch
+    }
+    return !preferred, nil
+}
@@ -0,0 +1,97 @@
+package otp
+
+import (
+    "errors"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestNormalize(t *testing.T) {
+    var tests = []struct {
+        code        string
+        policy      NormalizationPolicy
+        normalized  string
+        ok          bool
+    }{
+        {"123456", Lenient, "123456", true},
+        {"123456", Strict, "123456", true},
+        {"123456", VeryLenient, "123456", true},
+        {"123 456", Lenient, "123456", true},
+        {"123 456", Strict, "", false},
+        {"123 456", VeryLenient, "123456", true},
+        {"123-456", Lenient, "123456", true},
+        {"１２３４５６", Lenient, "", false},
+        {"１２３４５６", Strict, "", false},
+        {"１２３４５６", VeryLenient, "123456", true},
+        {"１２３　４５６", VeryLenient, "123456", true},
+        {"123.456", Lenient, "", false},
+        {"123.456", VeryLenient, "123456", true},
+    }
+    for _, tt := range(tests) {
+        var normalized, ok = Normalize(tt.code, tt.policy)
+        if (ok != tt.ok || (ok && normalized != tt.normalized)) {
+            t.Errorf("Normalize(%q, %d) = %q, %v, want %q, %v", tt.code, tt.policy, normalized, ok, tt.normalized, tt.ok)
+        }
+    }
+}
+
+// the RFC 4226 secret's TOTP code at t=59 is 287082
+func TestValidateNormalization(t *testing.T) {
+    var at time.Time = time.Unix(59, 0)
+    var tests = []struct {
+        code    string
+        policy  NormalizationPolicy
+        err     error
+    }{
+        {"287082", Lenient, nil},
+        {"287082", Strict, nil},
+        {"287082", VeryLenient, nil},
+        {"287 082", Lenient, nil},
+        {"287 082", Strict, ErrCodeMismatch},
+        {"287 082", VeryLenient, nil},
+        {"２８７０８２", Lenient, ErrCodeMismatch},
+        {"２８７０８２", Strict, ErrCodeMismatch},
+        {"２８７０８２", VeryLenient, nil},
+    }
+    for _, tt := range(tests) {
+        var err error = ValidateTOTP(RFC4226Secret, tt.code, ValidateOpts{Time: at, Normalization: tt.policy})
+        if (!errors.Is(err, tt.err)) {
+            t.Errorf("ValidateTOTP(%q) with policy %d: %v, want %v", tt.code, tt.policy, err, tt.err)
+        }
+
+        var key, kerr = NewKey(RFC4226Secret, WithClock(FixedClock(at)), WithNormalization(tt.policy))
+        if (kerr != nil) {
+            t.Fatal(kerr)
+        }
+        if err = key.Validate(tt.code); !errors.Is(err, tt.err) {
+            t.Errorf("Key.Validate(%q) with policy %d: %v, want %v", tt.code, tt.policy, err, tt.err)
+        }
+    }
+}
+
+func TestNormalizationPerKey(t *testing.T) {
+    var at time.Time = time.Unix(59, 0)
+    var strict, kerr = NewKey(RFC4226Secret, WithClock(FixedClock(at)), WithNormalization(Strict))
+    if (kerr != nil) {
+        t.Fatal(kerr)
+    }
+    var lenient, _ = NewKey(RFC4226Secret, WithClock(FixedClock(at)))
+    var wg sync.WaitGroup
+    for range(8) {
+        wg.Add(2)
+        go func() {
+            defer wg.Done()
+            if err := strict.Validate("287 082"); !errors.Is(err, ErrCodeMismatch) {
+                t.Errorf("strict key: %v, want %v", err, ErrCodeMismatch)
+            }
+        }()
+        go func() {
+            defer wg.Done()
+            if err := lenient.Validate("287 082"); err != nil {
+                t.Errorf("lenient key: %v", err)
+            }
+        }()
+    }
+    wg.Wait()
+}
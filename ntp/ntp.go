@@ -0,0 +1,146 @@
+/*
+    Package ntp checks the local clock against an NTP server
+
+    TOTP codes are only as good as the clock they're made with, and a machine whose
+    clock is a minute out produces codes no server accepts. Query asks a server for
+    the time with a single SNTP request (RFC 4330) and reports how far off the local
+    clock is; Clock applies that offset, so it can be handed to otp.WithClock:
+
+        var offset, err = ntp.Offset(ntp.DefaultServer)
+        ...
+        var k, err = otp.NewKey(secret, otp.WithClock(ntp.Clock{Offset: offset}))
+
+    One query is plenty for TOTP's 30 second steps; this isn't a replacement for an
+    NTP daemon keeping the system clock right.
+*/
+package ntp
+
+import (
+    "encoding/binary"
+    "errors"
+    "net"
+    "time"
+
+    otp "github.com/adam-good/OTP"
+)
+
+// DefaultServer is the public NTP pool
+const DefaultServer string = "pool.ntp.org"
+
+// DefaultTimeout is how long Offset waits for an answer
+const DefaultTimeout time.Duration = 5 * time.Second
+
+var (
+    // the reply wasn't a valid answer to our request
+    ErrInvalidResponse  = errors.New("ntp: invalid response")
+    // the server sent a kiss-o'-death packet, telling us to go away or slow down
+    ErrKissOfDeath      = errors.New("ntp: server refused the request")
+    // the server says its own clock isn't synchronized
+    ErrUnsynchronized   = errors.New("ntp: server clock is not synchronized")
+)
+
+// Response is what one query found out
+type Response struct {
+    Offset  time.Duration   // what to add to the local clock to get the server's time
+    RTT     time.Duration   // round trip time, not counting the server's processing
+    Stratum int             // how many hops the server is from a reference clock
+}
+
+// seconds between the NTP epoch (1900) and the Unix epoch
+const ntpEpochOffset int64 = 2208988800
+
+// toNTP encodes t as a 64 bit NTP timestamp, 32 bits of seconds and 32 of fraction
+func toNTP(t time.Time) uint64 {
+    var seconds uint64 = uint64(t.Unix() + ntpEpochOffset)
+    var fraction uint64 = (uint64(t.Nanosecond()) << 32) / uint64(time.Second)
+    return seconds << 32 | fraction
+}
+
+// fromNTP decodes an NTP timestamp
+func fromNTP(v uint64) time.Time {
+    var seconds int64 = int64(v >> 32)
+    // RFC 4330 section 3: with the top bit clear the seconds have rolled over into the
+    // next era, which starts in 2036
+    if (v >> 63 == 0) {
+        seconds += 1 << 32
+    }
+    var nanos int64 = int64((v & 0xFFFFFFFF) * uint64(time.Second) >> 32)
+    return time.Unix(seconds - ntpEpochOffset, nanos)
+}
+
+/*
+    Query asks server for the time and compares it with the local clock
+
+    server is a host name or address, with ":123" added if it has no port. The reply
+    has to echo our transmit timestamp back, so one from anywhere else is refused
+    with ErrInvalidResponse.
+*/
+func Query(server string, timeout time.Duration) (Response, error) {
+    if _, _, err := net.SplitHostPort(server); err != nil {
+        server = net.JoinHostPort(server, "123")
+    }
+    var conn, err = net.DialTimeout("udp", server, timeout)
+    if (err != nil) {
+        return Response{}, err
+    }
+    defer conn.Close()
+    conn.SetDeadline(time.Now().Add(timeout))
+
+    // leap indicator 0, version 4, mode 3 (client); everything else zero except our
+    // transmit timestamp
+    var request [48]byte
+    request[0] = 0 << 6 | 4 << 3 | 3
+    var t0 time.Time = time.Now()
+    var sent uint64 = toNTP(t0)
+    binary.BigEndian.PutUint64(request[40:], sent)
+    if _, err = conn.Write(request[:]); err != nil {
+        return Response{}, err
+    }
+
+    var reply [48]byte
+    var n int
+    if n, err = conn.Read(reply[:]); err != nil {
+        return Response{}, err
+    }
+    var t3 time.Time = time.Now()
+    if (n < 48) {
+        return Response{}, ErrInvalidResponse
+    }
+
+    var leap, mode, stratum = reply[0] >> 6, reply[0] & 7, int(reply[1])
+    var originate uint64 = binary.BigEndian.Uint64(reply[24:])
+    var received, transmitted = binary.BigEndian.Uint64(reply[32:]), binary.BigEndian.Uint64(reply[40:])
+    switch {
+    case mode != 4 || originate != sent || transmitted == 0:
+        return Response{}, ErrInvalidResponse
+    case stratum == 0:
+        return Response{}, ErrKissOfDeath
+    case leap == 3:
+        return Response{}, ErrUnsynchronized
+    }
+
+    // RFC 4330 section 5: offset = ((t1 - t0) + (t2 - t3)) / 2, delay = (t3 - t0) - (t2 - t1)
+    var t1, t2 time.Time = fromNTP(received), fromNTP(transmitted)
+    return Response{
+        Offset:     (t1.Sub(t0) + t2.Sub(t3)) / 2,
+        RTT:        t3.Sub(t0) - t2.Sub(t1),
+        Stratum:    stratum,
+    }, nil
+}
+
+// Offset is how far the local clock is behind server's time (negative if it's ahead), see Query
+func Offset(server string) (time.Duration, error) {
+    var r, err = Query(server, DefaultTimeout)
+    return r.Offset, err
+}
+
+// Clock is the local clock corrected by Offset, an otp.Clock
+type Clock struct {
+    Offset  time.Duration
+}
+
+func (c Clock) Now() time.Time {
+    return time.Now().Add(c.Offset)
+}
+
+var _ otp.Clock = Clock{}
@@ -0,0 +1,73 @@
+package otp
+
+import (
+    "sync"
+    "time"
+)
+
+/*
+    Observer is told what the package does, for metrics and tracing
+
+    The package calls it rather than importing a metrics library, so wire it to
+    whatever the service uses. OnGenerate is called for each code generated through
+    GenerateHOTP, HOTP, GenerateTOTP, GenerateTOTPAt, TOTPAt and the Key methods (the
+    codes a validation generates to compare against don't count). OnVerifySuccess and
+    OnVerifyFailure are called for each ValidateTOTP, ValidateTOTPStep, ValidateHOTP
+    and Key validation, with the user from the options ("" if none); err says why it
+    failed, e.g. ErrCodeMismatch, ErrCodeReused or ErrLockedOut. OnLockout is called
+    each time a LockoutPolicy locks a user out. Every duration is how long the call
+    took, store round trips included.
+
+    The methods are called synchronously from the goroutine doing the work and must be
+    safe for concurrent use. Embed NopObserver to only implement some of them.
+*/
+type Observer interface {
+    OnGenerate(took time.Duration)
+    OnVerifySuccess(user string, took time.Duration)
+    OnVerifyFailure(user string, err error, took time.Duration)
+    OnLockout(user string, until time.Time)
+}
+
+// NopObserver ignores everything, it's the Observer until SetObserver is called
+type NopObserver struct{}
+
+func (NopObserver) OnGenerate(took time.Duration) {}
+func (NopObserver) OnVerifySuccess(user string, took time.Duration) {}
+func (NopObserver) OnVerifyFailure(user string, err error, took time.Duration) {}
+func (NopObserver) OnLockout(user string, until time.Time) {}
+
+var (
+    observerMu  sync.RWMutex
+    observer    Observer = NopObserver{}
+)
+
+// SetObserver makes o the package's Observer, nil goes back to NopObserver
+func SetObserver(o Observer) {
+    if (o == nil) {
+        o = NopObserver{}
+    }
+    observerMu.Lock()
+    defer observerMu.Unlock()
+    observer = o
+}
+
+// currentObserver returns the Observer set by SetObserver
+func currentObserver() Observer {
+    observerMu.RLock()
+    defer observerMu.RUnlock()
+    return observer
+}
+
+// observeGenerate reports a generation that started at start
+func observeGenerate(start time.Time) {
+    currentObserver().OnGenerate(time.Since(start))
+}
+
+// observeVerify reports a validation for user that started at start and ended with err
+func observeVerify(user string, start time.Time, err error) {
+    if (err == nil) {
+        currentObserver().OnVerifySuccess(user, time.Since(start))
+    } else {
+        currentObserver().OnVerifyFailure(user, err, time.Since(start))
+    }
+}
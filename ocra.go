@@ -0,0 +1,354 @@
+package otp
+
+import (
+    "crypto/rand"
+    "encoding/binary"
+    "encoding/hex"
+    "fmt"
+    "hash"
+    "io"
+    "math/big"
+    "strconv"
+    "strings"
+    "time"
+)
+
+/*
+    OCRASuite is a parsed RFC 6287 OCRA suite
+
+    A suite such as "OCRA-1:HOTP-SHA1-6:QN08" or "OCRA-1:HOTP-SHA512-8:C-QN08-PSHA1-T1M"
+    fixes the hash, the code length and which inputs go into each response: a counter,
+    a challenge (the question) of QuestionLength characters in the given format, a
+    hash of the user's PIN, session information and a timestamp. Both sides have to
+    agree on it, so it's usually provisioned with the key.
+*/
+type OCRASuite struct {
+    Algorithm       Algorithm       // hash under the HMAC
+    Digits          int             // response length, 4 to 10
+    Counter         bool            // C: the response depends on a counter
+    QuestionFormat  byte            // 'N' numeric, 'A' alphanumeric or 'H' hex
+    QuestionLength  int             // the challenge length GenerateOCRAChallenge uses, 4 to 64 characters
+    Password        bool            // P: a hash of the PIN goes in
+    PasswordHash    Algorithm       // the hash the PIN goes in as, when Password is set
+    SessionLength   int             // S: bytes of session information, 0 for none
+    TimeStep        time.Duration   // T: the timestamp step, 0 for no timestamp
+
+    suite           string
+}
+
+// OCRAInput is what goes into one OCRA response, the fields the suite doesn't use are ignored
+type OCRAInput struct {
+    Counter         uint64
+    Question        string      // the challenge, in the suite's QuestionFormat
+    PIN             string      // hashed with the suite's PasswordHash
+    PINHash         []byte      // the PIN already hashed, used instead of PIN if set
+    Session         []byte      // up to SessionLength bytes
+    Time            time.Time   // zero means the system clock
+}
+
+/*
+    ParseOCRASuite parses an OCRA suite string
+
+    It's three parts separated by colons: the version (only OCRA-1 exists), the
+    crypto function HOTP-SHAx-t and the data inputs [C-]Qxnn[-PSHAx][-Snnn][-Tg], see
+    RFC 6287 section 6. A truncation length of 0 (the whole HMAC) isn't supported.
+    Anything it can't read fails with ErrInvalidOCRASuite.
+*/
+func ParseOCRASuite(s string) (OCRASuite, error) {
+    var suite OCRASuite = OCRASuite{suite: s}
+    var parts []string = strings.Split(s, ":")
+    if (len(parts) != 3 || parts[0] != "OCRA-1") {
+        return OCRASuite{}, fmt.Errorf("%w: %q", ErrInvalidOCRASuite, s)
+    }
+
+    // the crypto function, HOTP-SHA1-6
+    var function []string = strings.Split(parts[1], "-")
+    if (len(function) != 3 || function[0] != "HOTP") {
+        return OCRASuite{}, fmt.Errorf("%w: bad crypto function %q", ErrInvalidOCRASuite, parts[1])
+    }
+    var ok bool
+    if suite.Algorithm, ok = ParseAlgorithm(function[1]); !ok {
+        return OCRASuite{}, fmt.Errorf("%w: unknown hash %q", ErrInvalidOCRASuite, function[1])
+    }
+    var err error
+    if suite.Digits, err = strconv.Atoi(function[2]); err != nil || suite.Digits < 4 || suite.Digits > 10 {
+        return OCRASuite{}, fmt.Errorf("%w: truncation %q", ErrInvalidOCRASuite, function[2])
+    }
+
+    // the data inputs, in order: C, Q, P, S and T
+    var inputs []string = strings.Split(parts[2], "-")
+    if (len(inputs) > 0 && inputs[0] == "C") {
+        suite.Counter = true
+        inputs = inputs[1:]
+    }
+    if (len(inputs) == 0 || len(inputs[0]) != 4 || inputs[0][0] != 'Q' || !strings.ContainsRune("ANH", rune(inputs[0][1]))) {
+        return OCRASuite{}, fmt.Errorf("%w: the data inputs need a question, QN08 or similar", ErrInvalidOCRASuite)
+    }
+    suite.QuestionFormat = inputs[0][1]
+    if suite.QuestionLength, err = strconv.Atoi(inputs[0][2:]); err != nil || suite.QuestionLength < 4 || suite.QuestionLength > 64 {
+        return OCRASuite{}, fmt.Errorf("%w: question length %q", ErrInvalidOCRASuite, inputs[0][2:])
+    }
+    inputs = inputs[1:]
+    if (len(inputs) > 0 && strings.HasPrefix(inputs[0], "P")) {
+        suite.Password = true
+        if suite.PasswordHash, ok = ParseAlgorithm(inputs[0][1:]); !ok {
+            return OCRASuite{}, fmt.Errorf("%w: unknown PIN hash %q", ErrInvalidOCRASuite, inputs[0][1:])
+        }
+        inputs = inputs[1:]
+    }
+    if (len(inputs) > 0 && strings.HasPrefix(inputs[0], "S")) {
+        suite.SessionLength = 64
+        if (len(inputs[0]) > 1) {
+            if suite.SessionLength, err = strconv.Atoi(inputs[0][1:]); err != nil || suite.SessionLength <= 0 || suite.SessionLength > 512 {
+                return OCRASuite{}, fmt.Errorf("%w: session length %q", ErrInvalidOCRASuite, inputs[0][1:])
+            }
+        }
+        inputs = inputs[1:]
+    }
+    if (len(inputs) > 0 && strings.HasPrefix(inputs[0], "T")) {
+        if suite.TimeStep, err = parseOCRATimeStep(inputs[0][1:]); err != nil {
+            return OCRASuite{}, err
+        }
+        inputs = inputs[1:]
+    }
+    if (len(inputs) > 0) {
+        return OCRASuite{}, fmt.Errorf("%w: unexpected data input %q", ErrInvalidOCRASuite, inputs[0])
+    }
+    return suite, nil
+}
+
+// parseOCRATimeStep reads the G of a Tg data input: 1-59S, 1-59M or 0-48H, 1M if it's empty
+func parseOCRATimeStep(g string) (time.Duration, error) {
+    if (g == "") {
+        return time.Minute, nil
+    }
+    var n, err = strconv.Atoi(g[:len(g) - 1])
+    var unit time.Duration
+    var limit int
+    switch g[len(g) - 1] {
+    case 'S':
+        unit, limit = time.Second, 59
+    case 'M':
+        unit, limit = time.Minute, 59
+    case 'H':
+        unit, limit = time.Hour, 48
+    }
+    if (err != nil || unit == 0 || n < 1 || n > limit) {
+        return 0, fmt.Errorf("%w: time step %q", ErrInvalidOCRASuite, g)
+    }
+    return time.Duration(n) * unit, nil
+}
+
+// String returns the suite string it was parsed from
+func (s OCRASuite) String() string {
+    return s.suite
+}
+
+/*
+    writeMessage writes the HMAC input for in to w
+
+    The suite string and a zero byte, then each input the suite uses: the counter as 8
+    bytes, the question padded to 128 bytes, the PIN hash, the session information left
+    padded to SessionLength and the number of time steps since the epoch as 8 bytes.
+    Every input is checked before anything is written.
+*/
+func (s OCRASuite) writeMessage(w io.Writer, in OCRAInput) error {
+    var q, err = s.question(in.Question)
+    if (err != nil) {
+        return err
+    }
+
+    var pin []byte
+    if (s.Password) {
+        pin = in.PINHash
+        if (pin == nil) {
+            var h = s.PasswordHash.Hash()()
+            h.Write([]byte(in.PIN))
+            pin = h.Sum(nil)
+        }
+        if (len(pin) != s.PasswordHash.Hash()().Size()) {
+            return fmt.Errorf("%w: PIN hash is %d bytes, %s is %d", ErrInvalidOCRAInput, len(pin), s.PasswordHash, s.PasswordHash.Hash()().Size())
+        }
+    }
+    if (s.SessionLength > 0 && len(in.Session) > s.SessionLength) {
+        return fmt.Errorf("%w: session information is over %d bytes", ErrInvalidOCRAInput, s.SessionLength)
+    }
+
+    var number [8]byte
+    io.WriteString(w, s.suite)
+    w.Write([]byte{0})
+    if (s.Counter) {
+        binary.BigEndian.PutUint64(number[:], in.Counter)
+        w.Write(number[:])
+    }
+    w.Write(q)
+    if (s.Password) {
+        w.Write(pin)
+    }
+    if (s.SessionLength > 0) {
+        w.Write(make([]byte, s.SessionLength - len(in.Session)))
+        w.Write(in.Session)
+    }
+    if (s.TimeStep > 0) {
+        var t time.Time = in.Time
+        if (t.IsZero()) {
+            t = time.Now()
+        }
+        binary.BigEndian.PutUint64(number[:], uint64(timeStep(t, s.TimeStep)))
+        w.Write(number[:])
+    }
+    return nil
+}
+
+/*
+    question encodes a challenge as its 128 byte data input
+
+    Only the 128 bytes limit it, not QuestionLength: in mutual challenge-response each
+    side answers both challenges run together, "CLI22220SRV11110" for a QA08 suite.
+*/
+func (s OCRASuite) question(q string) ([]byte, error) {
+    if (len(q) == 0) {
+        return nil, fmt.Errorf("%w: question is empty", ErrInvalidOCRAInput)
+    }
+    var out []byte
+    switch s.QuestionFormat {
+    case 'N':
+        // the decimal number as hex digits, left aligned
+        var n, ok = new(big.Int).SetString(q, 10)
+        if (!ok || n.Sign() < 0) {
+            return nil, fmt.Errorf("%w: question %q isn't numeric", ErrInvalidOCRAInput, q)
+        }
+        var digits string = n.Text(16)
+        if (len(digits) % 2 == 1) {
+            digits += "0"
+        }
+        out, _ = hex.DecodeString(digits)
+    case 'A':
+        out = []byte(q)
+    case 'H':
+        var digits string = q
+        if (len(digits) % 2 == 1) {
+            digits += "0"
+        }
+        var err error
+        if out, err = hex.DecodeString(digits); err != nil {
+            return nil, fmt.Errorf("%w: question %q isn't hex", ErrInvalidOCRAInput, q)
+        }
+    }
+    if (len(out) > 128) {
+        return nil, fmt.Errorf("%w: question %q is too long", ErrInvalidOCRAInput, q)
+    }
+    return append(out, make([]byte, 128 - len(out))...), nil
+}
+
+/*
+    GenerateOCRA computes the OCRA response to in under suite
+
+    It's HOTP's dynamic truncation applied to the HMAC of the suite's data inputs, see
+    RFC 6287 section 5. It fails with ErrEmptySecret for an empty key and
+    ErrInvalidOCRAInput when an input doesn't fit the suite.
+*/
+func GenerateOCRA(key []byte, suite OCRASuite, in OCRAInput) (Code, error) {
+    defer observeGenerate(time.Now())
+    return generateOCRA(key, suite, in)
+}
+
+// generateOCRA is GenerateOCRA without the observer
+func generateOCRA(key []byte, suite OCRASuite, in OCRAInput) (Code, error) {
+    if (len(key) == 0) {
+        return Code{}, ErrEmptySecret
+    }
+    if (suite.Digits < 4 || suite.Digits > 10) {
+        return Code{}, ErrInvalidDigits
+    }
+    if err := checkStrictDigits(suite.Digits); err != nil {
+        return Code{}, err
+    }
+    if _, ok := suite.Algorithm.info(); !ok {
+        return Code{}, ErrInvalidAlgorithm
+    }
+    if err := checkStrict(suite.Algorithm, len(key)); err != nil {
+        return Code{}, err
+    }
+    var mac hash.Hash = NewHMAC(suite.Algorithm.Hash(), key)
+    if err := suite.writeMessage(mac, in); err != nil {
+        return Code{}, err
+    }
+    var truncated, err = truncateHMAC(mac.Sum(nil), Truncation{})
+    if (err != nil) {
+        return Code{}, err
+    }
+    var mod uint64 = 1
+    for i := 0; i < suite.Digits; i++ {
+        mod *= 10
+    }
+    return Code{Value: uint32(uint64(truncated) % mod), Digits: suite.Digits}, nil
+}
+
+/*
+    ValidateOCRA checks a response to the challenge in in
+
+    For suites with a timestamp, skew is how many time steps either side of in.Time are
+    also accepted; otherwise it's ignored. Counter based suites check in.Counter only, so
+    move the stored counter on after each success. A wrong response fails with
+    ErrCodeMismatch.
+*/
+func ValidateOCRA(key []byte, suite OCRASuite, in OCRAInput, code string, skew int) error {
+    var start time.Time = time.Now()
+    var err error = validateOCRA(key, suite, in, code, skew)
+    observeVerify("", start, err)
+    return err
+}
+
+// validateOCRA is ValidateOCRA without the observer
+func validateOCRA(key []byte, suite OCRASuite, in OCRAInput, code string, skew int) error {
+    if (suite.TimeStep == 0 || skew < 0) {
+        skew = 0
+    }
+    if (in.Time.IsZero()) {
+        in.Time = time.Now()
+    }
+    var match bool = false
+    for i := -skew; i <= skew; i++ {
+        var at OCRAInput = in
+        at.Time = in.Time.Add(time.Duration(i) * suite.TimeStep)
+        var expected, err = generateOCRA(key, suite, at)
+        if (err != nil) {
+            return err
+        }
+        match = codeMatches(expected, code, Lenient) || match
+    }
+    if (!match) {
+        return ErrCodeMismatch
+    }
+    return nil
+}
+
+/*
+    GenerateOCRAChallenge returns a random challenge for suite
+
+    It's as long as the suite allows, in its question format, for a server to send to
+    the user.
+*/
+func GenerateOCRAChallenge(suite OCRASuite) (string, error) {
+    var alphabet string
+    switch suite.QuestionFormat {
+    case 'N':
+        alphabet = "0123456789"
+    case 'A':
+        alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+    case 'H':
+        alphabet = hexAlphabet
+    default:
+        return "", ErrInvalidOCRASuite
+    }
+    var out []byte = make([]byte, suite.QuestionLength)
+    for i := range(out) {
+        var n, err = rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+        if (err != nil) {
+            return "", err
+        }
+        out[i] = alphabet[n.Int64()]
+    }
+    return string(out), nil
+}
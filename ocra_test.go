@@ -0,0 +1,63 @@
+package otp
+
+import (
+    "errors"
+    "strings"
+    "testing"
+)
+
+func TestOCRAVectors(t *testing.T) {
+    for _, v := range(RFC6287Vectors) {
+        t.Run(v.Suite + "/" + v.Input.Question, func(t *testing.T) {
+            var suite, err = ParseOCRASuite(v.Suite)
+            if (err != nil) {
+                t.Fatal(err)
+            }
+            var code Code
+            if code, err = GenerateOCRA(v.Key, suite, v.Input); err != nil {
+                t.Fatal(err)
+            }
+            if (code.String() != v.Code) {
+                t.Errorf("GenerateOCRA: %s, want %s", code, v.Code)
+            }
+            if err = ValidateOCRA(v.Key, suite, v.Input, v.Code, 0); err != nil {
+                t.Errorf("ValidateOCRA: %v", err)
+            }
+        })
+    }
+}
+
+func TestOCRAQuestionLength(t *testing.T) {
+    var suite, err = ParseOCRASuite("OCRA-1:HOTP-SHA256-8:QA08")
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    var tests = []struct {
+        name        string
+        question    string
+        ok          bool
+    }{
+        {"the suite's length", "SIG10000", true},
+        {"two challenges run together", "CLI22220SRV11110", true},
+        {"128 bytes", strings.Repeat("A", 128), true},
+        {"over 128 bytes", strings.Repeat("A", 129), false},
+        {"empty", "", false},
+    }
+    for _, tt := range(tests) {
+        t.Run(tt.name, func(t *testing.T) {
+            var _, err = GenerateOCRA(RFC6238Secrets[SHA256], suite, OCRAInput{Question: tt.question})
+            if (tt.ok && err != nil) {
+                t.Errorf("GenerateOCRA: %v", err)
+            }
+            if (!tt.ok && !errors.Is(err, ErrInvalidOCRAInput)) {
+                t.Errorf("GenerateOCRA: %v, want ErrInvalidOCRAInput", err)
+            }
+        })
+    }
+}
+
+func TestSelfTest(t *testing.T) {
+    if err := SelfTest(); err != nil {
+        t.Fatal(err)
+    }
+}
@@ -1,8 +1,11 @@
 package otp
 
 import (
+    "crypto/subtle"
+    "encoding/binary"
     "fmt"
-    "crypto/sha1"
+    "hash"
+    "sync"
     "time"
     "strconv"
 )
@@ -22,59 +25,169 @@ where
     ⊕ denotes exclusive or (XOR),
     opad is the outer padding (0x5c5c5c…5c5c, one-block-long hexadecimal constant),
     ipad is the inner padding (0x363636…3636, one-block-long hexadecimal constant).
+
+    HMAC uses SHA-1 for H, see HMACHash for any other hash.
 */
 
 func HMAC(key []byte, message []byte) []byte {
-    var blocksize int = sha1.BlockSize
+    return HMACHash(SHA1.Hash(), key, message)
+}
 
-    /*
-    *   First ensure that the len(key) = blocksize
-    *       if len(key) < blocksize pad key with 0s
-    *       if len(key) > blocksize hash key
-    */
-    if (len(key) < blocksize) {
-        // initialize slice of 0s to pad the key
-        var pad []byte = make([]byte, blocksize-len(key))
-        key = append(key, pad...)
-    } else if (len(key) > blocksize) {
-        // TODO: hash key
-        key = key[:blocksize]
-    }
+// HMACHash is HMAC with H built by newHash, the same constructor crypto/hmac takes
+func HMACHash(newHash func() hash.Hash, key []byte, message []byte) []byte {
+    var b *hmacBuffers = getBuffers()
+    defer putBuffers(b)
+    return append([]byte(nil), hmacSum(newHash(), b, key, message)...)
+}
 
-    /*
-    *   opad (outer padding) = 0x5C5C5C...
-    *   ipad is inner padding = 0x363636...
-    *       len(opad) = blocksize
-    *       len(ipad) = blocksize
-    */
-    var opad []byte
-    var ipad []byte
-    for len(opad) < blocksize {
-        opad = append(opad, 0x5C)
-        ipad = append(ipad, 0x36)
-    }
+/*
+    hmacBuffers is the memory one HMAC needs
 
-    /*
-    *   Calculate
-    *       (K' ⊕ opad)
-    *       (K' ⊕ ipad)
-    */
-    var key_xor_opad []byte = make([]byte, blocksize)
-    var key_xor_ipad []byte = make([]byte, blocksize)
-    for i := 0; i < blocksize; i++ {
-        key_xor_opad[i] = key[i] ^ opad[i]
-        key_xor_ipad[i] = key[i] ^ ipad[i]
+    They're pooled so that generating a code doesn't allocate: the padded key and the
+    inner and outer sums are written over the same two slices every time, which grow
+    to the largest block and hash size they've been used with.
+*/
+type hmacBuffers struct {
+    pad     []byte
+    sum     []byte
+    message [8]byte     // a counter, for the callers that need one
+}
+
+var bufferPool sync.Pool = sync.Pool{New: func() any { return &hmacBuffers{} }}
+
+func getBuffers() *hmacBuffers {
+    return bufferPool.Get().(*hmacBuffers)
+}
+
+// putBuffers wipes b and returns it to the pool
+func putBuffers(b *hmacBuffers) {
+    clear(b.pad)
+    clear(b.sum[:cap(b.sum)])
+    clear(b.message[:])
+    bufferPool.Put(b)
+}
+
+/*
+    hmacSum computes HMAC(key, message) with h, writing it into b.sum
+
+    The result is only good until b goes back to the pool.
+*/
+func hmacSum(h hash.Hash, b *hmacBuffers, key []byte, message []byte) []byte {
+    var blocksize int = h.BlockSize()
+    if (cap(b.pad) < blocksize) {
+        b.pad = make([]byte, blocksize)
     }
+    b.pad = b.pad[:blocksize]
+    b.sum = hmacKey(h, key, b.pad, b.sum)
 
     /*
     *   Calculate:
     *       sum1 = H((K' ⊕ ipad) || m)
     *       sum2 = H( (K' ⊕ opad) || H((K' ⊕ ipad) || m) )
+    *   where opad = 0x5C5C5C... and ipad = 0x363636..., each blocksize bytes
+    *   K' is XORed with ipad in place, then with ipad ^ opad to turn it into K' ⊕ opad;
+    *   both halves are written to H separately so neither is ever copied
     */
-    var sum1 [sha1.Size]byte = sha1.Sum(append(key_xor_ipad, message...))
-    var sum2 [sha1.Size]byte = sha1.Sum(sum1[:])//sha1.Sum(append(key_xor_opad, sum1[:]...))
+    for i := range(b.pad) {
+        b.pad[i] ^= 0x36
+    }
+    h.Write(b.pad)
+    h.Write(message)
+    b.sum = h.Sum(b.sum[:0])
+
+    for i := range(b.pad) {
+        b.pad[i] ^= 0x36 ^ 0x5C
+    }
+    h.Reset()
+    h.Write(b.pad)
+    h.Write(b.sum)
+    b.sum = h.Sum(b.sum[:0])
+    h.Reset()
+
+    return b.sum
+}
+
+/*
+    hmacKey writes K' into pad, which is h's block size long, and leaves h reset
+
+    First ensure that the len(key) = blocksize
+        if len(key) > blocksize hash key (which makes it shorter than blocksize)
+        then pad key with 0s
+    K' is built in pad so the caller's key is never modified. sum is scratch space for
+    hashing a long key, returned so its memory can be reused.
+*/
+func hmacKey(h hash.Hash, key []byte, pad []byte, sum []byte) []byte {
+    clear(pad)
+    h.Reset()
+    if (len(key) > len(pad)) {
+        h.Write(key)
+        sum = h.Sum(sum[:0])
+        copy(pad, sum)
+        clear(sum)
+        h.Reset()
+    } else {
+        copy(pad, key)
+    }
+    return sum
+}
+
+/*
+    NewHMAC returns a keyed hash computing HMAC with H built by newHash
+
+    It's the streaming form of HMACHash, for messages that come in pieces or are too
+    big to hold at once: Write the message as it arrives and Sum appends the HMAC of
+    everything written since it was made or last Reset. It behaves like crypto/hmac's
+    and gives the same results, and it's what OCRA and the YubiCloud client sign with.
+    Like crypto/hmac it keeps the padded key until it's garbage collected.
+*/
+func NewHMAC(newHash func() hash.Hash, key []byte) hash.Hash {
+    var m *hmacWriter = &hmacWriter{inner: newHash(), outer: newHash()}
+    var blocksize int = m.inner.BlockSize()
+    m.ipad = make([]byte, blocksize)
+    m.opad = make([]byte, blocksize)
+    m.sum = hmacKey(m.inner, key, m.ipad, nil)
+    for i := range(m.ipad) {
+        m.opad[i] = m.ipad[i] ^ 0x5C
+        m.ipad[i] ^= 0x36
+    }
+    m.Reset()
+    return m
+}
+
+// hmacWriter is the hash.Hash NewHMAC returns
+type hmacWriter struct {
+    inner   hash.Hash   // H((K' ⊕ ipad) || m) so far
+    outer   hash.Hash
+    ipad    []byte      // K' ⊕ ipad
+    opad    []byte      // K' ⊕ opad
+    sum     []byte
+}
 
-    return sum2[:]
+func (m *hmacWriter) Write(p []byte) (int, error) {
+    return m.inner.Write(p)
+}
+
+// Sum appends HMAC(K, m) to b without changing the state, so more can still be written
+func (m *hmacWriter) Sum(b []byte) []byte {
+    m.sum = m.inner.Sum(m.sum[:0])
+    m.outer.Reset()
+    m.outer.Write(m.opad)
+    m.outer.Write(m.sum)
+    return m.outer.Sum(b)
+}
+
+// Reset starts a new message under the same key
+func (m *hmacWriter) Reset() {
+    m.inner.Reset()
+    m.inner.Write(m.ipad)
+}
+
+func (m *hmacWriter) Size() int {
+    return m.outer.Size()
+}
+
+func (m *hmacWriter) BlockSize() int {
+    return m.inner.BlockSize()
 }
 
 /*
@@ -91,30 +204,160 @@ The mask 0x7FFFFFFF sets the result's most significant bit to zero. This avoids
 For HOTP to be useful for an individual to input to a system, the result must be converted into a HOTP value, a 6–8 digits number that is implementation dependent.
 HOTP-Value = HOTP(K,C) mod 10d, where d is the desired number of digits
 */
-func HOTP(key []byte, counter []byte) []byte {
+func HOTP(key []byte, counter uint64) (Code, error) {
+    return GenerateHOTP(key, counter, DefaultDigits, SHA1)
+}
+
+// The range of code lengths supported; RFC 4226 asks for at least 6 and 10 is all a 31 bit value can fill
+const (
+    DefaultDigits   int = 6
+    MinDigits       int = 6
+    MaxDigits       int = 10
+)
+
+/*
+    GenerateHOTP is HOTP with a choice of code length and hash algorithm
+
+    It fails with ErrEmptySecret for an empty key, ErrInvalidDigits unless digits is
+    between MinDigits and MaxDigits, and ErrInvalidAlgorithm for an algorithm that isn't
+    registered or whose hash is too short (under 20 bytes) to truncate.
+*/
+func GenerateHOTP(key []byte, counter uint64, digits int, algo Algorithm) (Code, error) {
+    defer observeGenerate(time.Now())
+    return generateHOTP(key, counter, digits, algo, Truncation{})
+}
+
+/*
+    GenerateHOTPRaw is GenerateHOTP that also returns the raw 31 bit value
+
+    The raw value is the truncated HMAC before it's reduced mod 10^digits (Snum in
+    RFC 4226 section 5.3), for protocols that encode it some other way or add their own
+    check digits. It's the same whatever digits is.
+*/
+func GenerateHOTPRaw(key []byte, counter uint64, digits int, algo Algorithm) (Code, uint32, error) {
+    defer observeGenerate(time.Now())
+    return generateHOTPRaw(key, counter, digits, algo, Truncation{})
+}
+
+// generateHOTP is GenerateHOTP without telling the Observer, for codes generated to check against, and with a choice of truncation
+func generateHOTP(key []byte, counter uint64, digits int, algo Algorithm, trunc Truncation) (Code, error) {
+    var code, _, err = generateHOTPRaw(key, counter, digits, algo, trunc)
+    return code, err
+}
+
+// generateHOTPRaw is generateHOTP that also returns the 31 bit value before the modulo
+func generateHOTPRaw(key []byte, counter uint64, digits int, algo Algorithm, trunc Truncation) (Code, uint32, error) {
     /*
-    *   Define the code length and the slice to contain the code
+    *   RFC 4226 feeds the counter to HMAC as an 8 byte big-endian integer
     */
-    var codeLen int = 6
-    var code []byte
+    var b *hmacBuffers = getBuffers()
+    defer putBuffers(b)
+    binary.BigEndian.PutUint64(b.message[:], counter)
+
+    return hotpMessageRaw(algo, key, b.message[:], digits, trunc)
+}
+
+// hotpMessageRaw is HOTP with the counter already encoded as the HMAC message, also returning the 31 bit value before the modulo
+func hotpMessageRaw(algo Algorithm, key []byte, message []byte, digits int, trunc Truncation) (Code, uint32, error) {
+    if (len(key) == 0) {
+        return Code{}, 0, ErrEmptySecret
+    }
+    if (digits < MinDigits || digits > MaxDigits) {
+        return Code{}, 0, ErrInvalidDigits
+    }
+    if _, ok := algo.info(); !ok {
+        return Code{}, 0, ErrInvalidAlgorithm
+    }
+    if err := checkStrictDigits(digits); err != nil {
+        return Code{}, 0, err
+    }
+
+    /*
+    *   Define the code length
+    */
+    var codeLen int = digits
+
+    var truncated, err = truncate(algo, key, message, trunc)
+    if (err != nil) {
+        return Code{}, 0, err
+    }
+
+    /*
+    *   value = truncated mod 10^codeLen
+    *   Code keeps codeLen around so leading zeros are kept when it's printed
+    */
+    var mod uint64 = 1
+    for i := 0; i < codeLen; i++ {
+        mod *= 10
+    }
+    return Code{Value: uint32(uint64(truncated) % mod), Digits: codeLen}, truncated, nil
+}
+
+/*
+    Truncation picks which 4 bytes of the HMAC a code is made from
+
+    The zero Truncation is RFC 4226's dynamic truncation, which every authenticator app
+    uses: the low 4 bits of the HMAC's last byte give the offset. A few hardware token
+    vendors fix the offset instead, as RFC 4226's reference code allows with its
+    truncationOffset parameter; FixedOffset(n) always takes bytes n to n+3.
+*/
+type Truncation struct {
+    fixed   bool
+    offset  int
+}
+
+// FixedOffset is the Truncation that always starts at byte offset of the HMAC, from 0 to its length less 4
+func FixedOffset(offset int) Truncation {
+    return Truncation{fixed: true, offset: offset}
+}
+
+// Offset returns the fixed offset, with false for dynamic truncation
+func (t Truncation) Offset() (int, bool) {
+    return t.offset, t.fixed
+}
+
+// truncate is the HMAC of message and RFC 4226 truncation, the 31 bit value every code is made from
+func truncate(algo Algorithm, key []byte, message []byte, trunc Truncation) (uint32, error) {
+    if err := checkStrict(algo, len(key)); err != nil {
+        return 0, err
+    }
+
+    var info, ok = algo.info()
+    if (!ok) {
+        return 0, ErrInvalidAlgorithm
+    }
 
     /*
-    *   Generate the hmac
-    *       NOTE: Some implementations make sure the first byte is positive
-    *               I don't think I need to do that in my implementation
+    *   Generate the hmac, with a hash and buffers from their pools
     */
-    var hmac []byte = HMAC(key, counter)
+    var h hash.Hash = info.hashes.Get().(hash.Hash)
+    defer info.hashes.Put(h)
+    var b *hmacBuffers = getBuffers()
+    defer putBuffers(b)
+    return truncateHMAC(hmacSum(h, b, key, message), trunc)
+}
 
+// truncateHMAC is the truncation half of truncate, for an HMAC already computed
+func truncateHMAC(hmac []byte, trunc Truncation) (uint32, error) {
     /*
-    *   Grab the first X bytes for the code
-    *   mod the bytes to get our code
-    *   TODO: Redo this...it's kinda hacky
+    *   Dynamic truncation (RFC 4226 section 5.3)
+    *       the low 4 bits of the last byte are an offset into the hmac
+    *       take the 4 bytes starting there as a big-endian integer
+    *       and mask off the top bit so it's 31 bits
+    *   a fixed offset just replaces the first step
     */
-    code = hmac[0:codeLen]
-    for i,b := range(code) {
-        code[i] = b % 10
+    var offset int = int(hmac[len(hmac)-1] & 0x0F)
+    if (trunc.fixed) {
+        if (trunc.offset < 0 || trunc.offset + 4 > len(hmac)) {
+            return 0, fmt.Errorf("%w: offset %d in a %d byte HMAC", ErrInvalidTruncation, trunc.offset, len(hmac))
+        }
+        offset = trunc.offset
+    }
+    if (offset + 4 > len(hmac)) {
+        // only happens for hashes shorter than 20 bytes, which can't be truncated this way
+        return 0, ErrInvalidAlgorithm
     }
-    return code
+    return binary.BigEndian.Uint32(hmac[offset:offset+4]) & 0x7FFFFFFF, nil
 }
 
 /*
@@ -123,10 +366,196 @@ func HOTP(key []byte, counter []byte) []byte {
 
     This function is exactly like HOTP but uses the current time as the counter
     Usually we round the time to 30 seconds or so to ensure the codes last long enough to be used
+    The time step goes through HOTP so it's encoded the same way as any other counter
+*/
+func TOTP(key []byte) (Code, error) {
+    return TOTPAt(key, time.Now())
+}
+
+// DefaultPeriod is the time step RFC 6238 recommends and most authenticator apps assume
+const DefaultPeriod time.Duration = 30 * time.Second
+
+/*
+    GenerateTOTP is TOTP with a choice of code length, hash algorithm, period and T0
+
+    The period is used in whole seconds and must be at least one second, otherwise it
+    fails with ErrInvalidPeriod. t0 is the time steps count from (T0 in RFC 6238); the zero
+    time.Time means the Unix epoch like it does everywhere else. See GenerateHOTP for
+    digits and algo.
+*/
+func GenerateTOTP(key []byte, digits int, algo Algorithm, period time.Duration, t0 time.Time) (Code, error) {
+    return GenerateTOTPAt(key, time.Now(), digits, algo, period, t0)
+}
+
+// GenerateTOTPAt is GenerateTOTP for the time step containing t, see TOTPAt
+func GenerateTOTPAt(key []byte, t time.Time, digits int, algo Algorithm, period time.Duration, t0 time.Time) (Code, error) {
+    if (period < time.Second) {
+        return Code{}, ErrInvalidPeriod
+    }
+    defer observeGenerate(time.Now())
+    return generateHOTP(key, uint64(timeStepFrom(t, t0, period)), digits, algo, Truncation{})
+}
+
+// GenerateTOTPRawAt is GenerateTOTPAt that also returns the raw 31 bit value, see GenerateHOTPRaw
+func GenerateTOTPRawAt(key []byte, t time.Time, digits int, algo Algorithm, period time.Duration, t0 time.Time) (Code, uint32, error) {
+    if (period < time.Second) {
+        return Code{}, 0, ErrInvalidPeriod
+    }
+    defer observeGenerate(time.Now())
+    return generateHOTPRaw(key, uint64(timeStepFrom(t, t0, period)), digits, algo, Truncation{})
+}
+
+// timeStep is the RFC 6238 time step T = floor(unix / period) for time t
+func timeStep(t time.Time, period time.Duration) int64 {
+    return timeStepFrom(t, time.Time{}, period)
+}
+
+/*
+    timeStepFrom is the RFC 6238 time step T = floor((unix - T0) / period)
+
+    A zero t0 means T0 = 0. Times before T0 round down to negative steps.
+*/
+func timeStepFrom(t time.Time, t0 time.Time, period time.Duration) int64 {
+    var seconds int64 = t.Unix()
+    if (!t0.IsZero()) {
+        seconds -= t0.Unix()
+    }
+    var p int64 = int64(period / time.Second)
+    var step int64 = seconds / p
+    if (seconds < 0 && seconds % p != 0) {
+        step--
+    }
+    return step
+}
+
+// stepStart is when time step step begins, the inverse of timeStepFrom
+func stepStart(step int64, t0 time.Time, period time.Duration) time.Time {
+    var seconds int64 = step * int64(period / time.Second)
+    if (!t0.IsZero()) {
+        seconds += t0.Unix()
+    }
+    return time.Unix(seconds, 0)
+}
+
+/*
+    TOTPExpiry returns when the code for the time step containing t stops being current
+
+    That's the start of the next step; a validator with a skew window goes on accepting
+    the code for a while after it. A period under a second gives the zero time.
+*/
+func TOTPExpiry(t time.Time, period time.Duration, t0 time.Time) time.Time {
+    if (period < time.Second) {
+        return time.Time{}
+    }
+    return stepStart(timeStepFrom(t, t0, period) + 1, t0, period)
+}
+
+/*
+    TOTPRemaining returns how long the code for the time step containing t stays current
+
+    It's always more than 0 and at most the period, for countdowns and for waiting out a
+    code that's about to roll over rather than submitting it. Round it up to whole
+    seconds for display, as authenticator apps do.
+*/
+func TOTPRemaining(t time.Time, period time.Duration, t0 time.Time) time.Duration {
+    var expiry time.Time = TOTPExpiry(t, period, t0)
+    if (expiry.IsZero()) {
+        return 0
+    }
+    return expiry.Sub(t)
+}
+
+/*
+    TOTPAt is TOTP for the time step containing t instead of the current time
+
+    Use it for tests, precomputing codes or checking a submission that was delayed.
+*/
+func TOTPAt(key []byte, t time.Time) (Code, error) {
+    defer observeGenerate(time.Now())
+    return totpStep(key, timeStep(t, DefaultPeriod))
+}
+
+// totpStep is the TOTP code for an already computed time step
+func totpStep(key []byte, step int64) (Code, error) {
+    return generateHOTP(key, uint64(step), DefaultDigits, SHA1, Truncation{})
+}
+
+/*
+    GenerateAndCompare is a convenience for tests that check against golden values
+
+    It generates the TOTP code for key at time t, renders it as a string and reports
+    whether it matches expected. The comparison is constant time so it is also
+    safe to use outside of tests. If no code can be generated for key the code is ""
+    and it never matches.
+*/
+func GenerateAndCompare(key []byte, t time.Time, expected string) (string, bool) {
+    var generated, err = TOTPAt(key, t)
+    if (err != nil) {
+        return "", false
+    }
+    var code string = generated.String()
+    var match bool = subtle.ConstantTimeCompare([]byte(code), []byte(expected)) == 1
+
+    return code, match
+}
+
+/*
+    ValidateRoundingVariant is a diagnostic for clients that round the time step up
+
+    RFC 6238 uses floor(unix / period) for the time step but some buggy clients use ceil.
+    This checks the normal floored step first and only then tries the ceiling, so
+    ceil is only true when the code matched the buggy variant alone. Use it during
+    incident response to find affected clients, not as the default validation.
+    A code matching neither fails with ErrCodeMismatch.
 */
-func TOTP(key []byte) []byte {
-    var t int64 = time.Now().Unix() / 30;
-    var tstr string = strconv.FormatInt(t, 10)
+func ValidateRoundingVariant(key []byte, code string, t time.Time) (ceil bool, err error) {
+    if err = refuseStrict("ValidateRoundingVariant accepts non-standard codes"); err != nil {
+        return false, err
+    }
+    var unix int64 = t.Unix()
+    var period int64 = int64(DefaultPeriod / time.Second)
+    var floorStep int64 = unix / period
+    var ceilStep int64 = (unix + period - 1) / period
+
+    var floorCode, ceilCode Code
+    if floorCode, err = totpStep(key, floorStep); err != nil {
+        return false, err
+    }
+    if ceilCode, err = totpStep(key, ceilStep); err != nil {
+        return false, err
+    }
+
+    // compare against both before deciding so the timing doesn't give away which matched
+    var floorMatch bool = codeMatches(floorCode, code, Lenient)
+    var ceilMatch bool = codeMatches(ceilCode, code, Lenient)
+
+    return matchedEither(floorMatch, ceilMatch && ceilStep != floorStep)
+}
+
+/*
+    ValidateHOTPEncoding checks an HOTP code under both counter encodings
+
+    RFC 4226 feeds the counter to HMAC as an 8 byte big-endian integer, but earlier
+    versions of this package fed it the ASCII decimal string instead, and tokens enrolled
+    against those versions are still out there. Their codes also came from the old HMAC
+    and per byte digits, so the legacy side is the whole legacy algorithm, see
+    legacyHOTP, not just the other encoding. The standard code is tried first; legacy is
+    only true when the code matched the legacy one alone, which marks a token that needs
+    to be migrated. A code matching neither fails with ErrCodeMismatch.
+*/
+func ValidateHOTPEncoding(key []byte, code string, counter uint64) (legacy bool, err error) {
+    if err = refuseStrict("ValidateHOTPEncoding accepts non-standard codes"); err != nil {
+        return false, err
+    }
+    var ascii []byte = []byte(strconv.FormatUint(counter, 10))
+
+    var standardCode Code
+    if standardCode, err = generateHOTP(key, counter, DefaultDigits, SHA1, Truncation{}); err != nil {
+        return false, err
+    }
+
+    var standardMatch bool = codeMatches(standardCode, code, Lenient)
+    var legacyMatch bool = codeMatches(legacyHOTP(key, ascii), code, Lenient)
 
-    return HOTP(key, []byte(tstr));
+    return matchedEither(standardMatch, legacyMatch)
 }
@@ -0,0 +1,197 @@
+package otp
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestRFC4226Vectors(t *testing.T) {
+    for _, v := range(RFC4226Vectors) {
+        var code, truncated, err = GenerateHOTPRaw(RFC4226Secret, v.Counter, 6, SHA1)
+        if (err != nil) {
+            t.Fatalf("counter %d: %v", v.Counter, err)
+        }
+        if (truncated != v.Truncated || code.String() != v.Code) {
+            t.Errorf("counter %d: %s (%d), want %s (%d)", v.Counter, code, truncated, v.Code, v.Truncated)
+        }
+        var matched uint64
+        if matched, err = ValidateHOTP(RFC4226Secret, v.Code, 0, 9); err != nil || matched != v.Counter {
+            t.Errorf("ValidateHOTP(%s): %d, %v, want %d", v.Code, matched, err, v.Counter)
+        }
+    }
+}
+
+func TestRFC6238Vectors(t *testing.T) {
+    for _, v := range(RFC6238Vectors) {
+        var key []byte = RFC6238Secrets[v.Algorithm]
+        var code, err = GenerateTOTPAt(key, v.Time, 8, v.Algorithm, DefaultPeriod, time.Time{})
+        if (err != nil) {
+            t.Fatalf("%s at %d: %v", v.Algorithm, v.Time.Unix(), err)
+        }
+        if (code.String() != v.Code) {
+            t.Errorf("%s at %d: %s, want %s", v.Algorithm, v.Time.Unix(), code, v.Code)
+        }
+        var opts ValidateOpts = ValidateOpts{Digits: 8, Algorithm: v.Algorithm, Time: v.Time}
+        if err = ValidateTOTP(key, v.Code, opts); err != nil {
+            t.Errorf("ValidateTOTP(%s) for %s at %d: %v", v.Code, v.Algorithm, v.Time.Unix(), err)
+        }
+    }
+}
+
+func TestTOTPT0(t *testing.T) {
+    // moving T0 on by a period moves every step back by one
+    var t0 time.Time = time.Unix(30, 0)
+    var code, err = GenerateTOTPAt(RFC4226Secret, time.Unix(89, 0), 6, SHA1, DefaultPeriod, t0)
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    if (code.String() != rfc4226Codes[1]) {
+        t.Errorf("at 89 with T0 30: %s, want %s", code, rfc4226Codes[1])
+    }
+    var opts ValidateOpts = ValidateOpts{T0: t0, Time: time.Unix(89, 0)}
+    if err = ValidateTOTP(RFC4226Secret, rfc4226Codes[1], opts); err != nil {
+        t.Errorf("ValidateTOTP with T0 30: %v", err)
+    }
+}
+
+func TestGenerateAndCompare(t *testing.T) {
+    var tests = []struct {
+        name        string
+        key         []byte
+        t           time.Time
+        expected    string
+        code        string
+        match       bool
+    }{
+        {"matching", RFC4226Secret, time.Unix(59, 0), "287082", "287082", true},
+        {"other step", RFC4226Secret, time.Unix(60, 0), "287082", rfc4226Codes[2], false},
+        {"wrong code", RFC4226Secret, time.Unix(59, 0), "000000", "287082", false},
+        {"not normalized", RFC4226Secret, time.Unix(59, 0), "287 082", "287082", false},
+        {"too short", RFC4226Secret, time.Unix(59, 0), "28708", "287082", false},
+        {"empty secret", nil, time.Unix(59, 0), "287082", "", false},
+    }
+    for _, tt := range(tests) {
+        var code, match = GenerateAndCompare(tt.key, tt.t, tt.expected)
+        if (code != tt.code || match != tt.match) {
+            t.Errorf("%s: %q, %v, want %q, %v", tt.name, code, match, tt.code, tt.match)
+        }
+    }
+}
+
+// at t=31 the floored step is 1 and the ceiling 2, at t=60 both are 2
+func TestValidateRoundingVariant(t *testing.T) {
+    var tests = []struct {
+        name    string
+        code    string
+        t       time.Time
+        ceil    bool
+        err     error
+    }{
+        {"floored step", rfc4226Codes[1], time.Unix(31, 0), false, nil},
+        {"ceiling step", rfc4226Codes[2], time.Unix(31, 0), true, nil},
+        {"on a step boundary", rfc4226Codes[2], time.Unix(60, 0), false, nil},
+        {"step before on a boundary", rfc4226Codes[1], time.Unix(60, 0), false, ErrCodeMismatch},
+        {"neither", rfc4226Codes[5], time.Unix(31, 0), false, ErrCodeMismatch},
+        {"grouped", "359 152", time.Unix(31, 0), true, nil},
+    }
+    for _, tt := range(tests) {
+        var ceil, err = ValidateRoundingVariant(RFC4226Secret, tt.code, tt.t)
+        if (!errors.Is(err, tt.err) || ceil != tt.ceil) {
+            t.Errorf("%s: %v, %v, want %v, %v", tt.name, ceil, err, tt.ceil, tt.err)
+        }
+    }
+
+    SetStrictMode(true)
+    defer SetStrictMode(false)
+    if _, err := ValidateRoundingVariant(RFC4226Secret, rfc4226Codes[1], time.Unix(31, 0)); !errors.Is(err, ErrNotCompliant) {
+        t.Errorf("in strict mode: %v, want %v", err, ErrNotCompliant)
+    }
+}
+
+// benchmarkKey is a fresh SHA1 key with a window of one step either side
+func benchmarkKey(tb testing.TB) (*Key, *Generator) {
+    var secret, err = GenerateSecret(SecretLength(SHA1))
+    if (err != nil) {
+        tb.Fatal(err)
+    }
+    var k *Key
+    if k, err = NewKey(secret, WithSkew(1)); err != nil {
+        tb.Fatal(err)
+    }
+    var g *Generator
+    if g, err = NewGenerator(k); err != nil {
+        tb.Fatal(err)
+    }
+    tb.Cleanup(g.Destroy)
+    return k, g
+}
+
+func BenchmarkGenerateTOTP(b *testing.B) {
+    var k, g = benchmarkKey(b)
+    var now time.Time = time.Now()
+    b.Run("GenerateTOTPAt", func(b *testing.B) {
+        b.ReportAllocs()
+        for i := 0; i < b.N; i++ {
+            GenerateTOTPAt(k.Secret, now, DefaultDigits, SHA1, DefaultPeriod, time.Time{})
+        }
+    })
+    b.Run("Generator", func(b *testing.B) {
+        b.ReportAllocs()
+        for i := 0; i < b.N; i++ {
+            g.GenerateCounter(uint64(i))
+        }
+    })
+}
+
+func BenchmarkValidate(b *testing.B) {
+    var k, g = benchmarkKey(b)
+    var now time.Time = time.Now()
+    var code, err = k.GenerateAt(now)
+    if (err != nil) {
+        b.Fatal(err)
+    }
+    var submitted string = code.String()
+    b.Run("Key", func(b *testing.B) {
+        b.ReportAllocs()
+        for i := 0; i < b.N; i++ {
+            k.ValidateAt(submitted, now)
+        }
+    })
+    b.Run("Generator", func(b *testing.B) {
+        b.ReportAllocs()
+        for i := 0; i < b.N; i++ {
+            g.ValidateAt(submitted, now)
+        }
+    })
+}
+
+// the hot path mustn't allocate, see the pools in otp.go and algorithm.go
+func TestZeroAllocs(t *testing.T) {
+    if (raceEnabled) {
+        t.Skip("the race detector makes sync.Pool allocate")
+    }
+    var k, g = benchmarkKey(t)
+    var now time.Time = time.Now()
+    var code, err = k.GenerateAt(now)
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    var submitted string = code.String()
+    var tests = []struct {
+        name    string
+        run     func()
+    }{
+        {"GenerateHOTP", func() { GenerateHOTP(k.Secret, 1, DefaultDigits, SHA1) }},
+        {"GenerateTOTPAt", func() { GenerateTOTPAt(k.Secret, now, DefaultDigits, SHA1, DefaultPeriod, time.Time{}) }},
+        {"Generator.GenerateCounter", func() { g.GenerateCounter(1) }},
+        {"Generator.ValidateAt", func() { g.ValidateAt(submitted, now) }},
+        {"Key.ValidateAt", func() { k.ValidateAt(submitted, now) }},
+        {"ValidateTOTP", func() { ValidateTOTP(k.Secret, submitted, ValidateOpts{Skew: 1, Time: now}) }},
+    }
+    for _, tt := range(tests) {
+        if allocs := testing.AllocsPerRun(100, tt.run); allocs != 0 {
+            t.Errorf("%s: %v allocations, want 0", tt.name, allocs)
+        }
+    }
+}
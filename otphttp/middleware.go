@@ -0,0 +1,193 @@
+/*
+    Package otphttp protects net/http handlers with one-time passwords
+
+    Wrap a handler with a Middleware and every request has to carry a valid code for
+    its user, in a header or a form field:
+
+        var mw = otphttp.New(store, func(r *http.Request) string {
+            return sessionUser(r)
+        })
+        http.Handle("/admin/", mw.Wrap(adminHandler))
+
+    Requests without a user, without a code, for a user with no key, or with a wrong or
+    replayed code get a 401 and never reach the handler. Too many wrong codes lock the
+    user out and get a 429 until the lockout ends.
+*/
+package otphttp
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+    "time"
+
+    otp "github.com/adam-good/OTP"
+    "github.com/adam-good/OTP/memstore"
+)
+
+// Defaults used by New
+const (
+    DefaultHeader   string = "X-OTP-Code"
+    DefaultField    string = "otp_code"
+    DefaultSkew     int = 1
+)
+
+/*
+    Middleware checks the one-time password on each request before passing it on
+
+    The fields can be changed after New but not while requests are being served.
+*/
+type Middleware struct {
+    Store   otp.KeyStore                    // where users' keys are looked up, by the name User returns
+    User    func(r *http.Request) string    // who the request is from, "" if nobody
+    Header  string                          // request header holding the code, checked first
+    Field   string                          // form field holding the code otherwise
+    Skew    int                             // TOTP steps either side to accept, overriding the key's own Skew
+
+    // Unauthorized writes the response for a rejected request, by default a plain 401
+    Unauthorized    http.Handler
+
+    // Replay is where used TOTP codes are recorded, by default in memory. Share one
+    // between instances of a service so a code used on one can't be replayed on another.
+    // Setting it to nil turns replay protection off.
+    Replay          otp.ReplayStore
+
+    // Lockout locks users out after too many wrong codes, by default with the
+    // otp.LockoutPolicy defaults and counts kept in memory. nil turns it off.
+    Lockout         *otp.LockoutPolicy
+
+    // Drift follows each user's TOTP clock drift so a slow phone keeps working without
+    // a wider Skew, by default in memory. nil turns it off.
+    Drift           otp.DriftStore
+
+    // Audit, if set, is sent an event with the client's address for every code checked
+    Audit           otp.AuditSink
+
+    // Clock is the time TOTP codes are checked at, nil means the system clock
+    Clock           otp.Clock
+}
+
+// New returns a Middleware looking keys up in store for the user named by user, with the package defaults
+func New(store otp.KeyStore, user func(r *http.Request) string) *Middleware {
+    var mem *memstore.Store = memstore.New(memstore.Options{})
+    return &Middleware{
+        Store:      store,
+        User:       user,
+        Header:     DefaultHeader,
+        Field:      DefaultField,
+        Skew:       DefaultSkew,
+        Replay:     mem,
+        Lockout:    &otp.LockoutPolicy{Store: mem},
+        Drift:      mem,
+    }
+}
+
+// code pulls the submitted code out of the request
+func (m *Middleware) code(r *http.Request) string {
+    if (m.Header != "") {
+        if c := r.Header.Get(m.Header); c != "" {
+            return c
+        }
+    }
+    if (m.Field != "") {
+        return r.FormValue(m.Field)
+    }
+    return ""
+}
+
+func (m *Middleware) now() time.Time {
+    if (m.Clock == nil) {
+        return time.Now()
+    }
+    return m.Clock.Now()
+}
+
+// auditFor returns the audit sink for a request from remoteAddr
+func (m *Middleware) auditFor(remoteAddr string) otp.AuditSink {
+    if (m.Audit == nil) {
+        return nil
+    }
+    return otp.AuditFunc(func(e otp.AuditEvent) {
+        e.RemoteAddr = remoteAddr
+        m.Audit.Audit(e)
+    })
+}
+
+/*
+    check validates code for user
+
+    TOTP codes are marked used in Replay so each code works once and the user's drift
+    is kept in Drift. HOTP keys move their
+    counter on and the key is saved back. Wrong and replayed codes count towards
+    Lockout.
+*/
+func (m *Middleware) check(user string, code string, remoteAddr string) error {
+    var k, err = m.Store.Get(user)
+    if (err != nil) {
+        return err
+    }
+    if (m.Skew > 0) {
+        k.Skew = m.Skew
+    }
+    k.Lockout, k.User = m.Lockout, user
+    k.Audit = m.auditFor(remoteAddr)
+
+    if (k.Type == otp.TypeHOTP) {
+        // validate and save the new counter as one step if the store can, so two
+        // requests with the same code can't both get in
+        if updater, ok := m.Store.(otp.KeyUpdater); ok {
+            return updater.Update(user, func(k *otp.Key) error {
+                if (m.Skew > 0) {
+                    k.Skew = m.Skew
+                }
+                k.Lockout, k.User = m.Lockout, user
+                k.Audit = m.auditFor(remoteAddr)
+                return k.Validate(code)
+            })
+        }
+        if err = k.Validate(code); err != nil {
+            return err
+        }
+        return m.Store.Put(user, k)
+    }
+
+    k.Replay, k.Clock, k.Drift = m.Replay, m.Clock, m.Drift
+    return k.Validate(code)
+}
+
+// unauthorized rejects a request
+func (m *Middleware) unauthorized(w http.ResponseWriter, r *http.Request) {
+    if (m.Unauthorized != nil) {
+        m.Unauthorized.ServeHTTP(w, r)
+        return
+    }
+    http.Error(w, "a valid one-time password is required", http.StatusUnauthorized)
+}
+
+// Wrap returns a handler that only calls next for requests with a valid code
+func (m *Middleware) Wrap(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var user string = m.User(r)
+        var code string = m.code(r)
+        if (user == "" || code == "") {
+            m.unauthorized(w, r)
+            return
+        }
+
+        var err error = m.check(user, code, r.RemoteAddr)
+        switch {
+        case errors.Is(err, otp.ErrLockedOut):
+            if until, _ := m.Lockout.LockedUntil(user); !until.IsZero() {
+                w.Header().Set("Retry-After", strconv.Itoa(int(until.Sub(m.now()).Seconds()) + 1))
+            }
+            http.Error(w, "too many failed attempts, try again later", http.StatusTooManyRequests)
+        case err == nil:
+            next.ServeHTTP(w, r)
+        case errors.Is(err, otp.ErrCodeMismatch), errors.Is(err, otp.ErrCodeReused), errors.Is(err, otp.ErrOutOfOrder), errors.Is(err, otp.ErrKeyNotFound):
+            m.unauthorized(w, r)
+        default:
+            // the store or the key is broken, that's not the client's fault
+            http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+        }
+    })
+}
@@ -0,0 +1,85 @@
+package otp
+
+import (
+    "crypto/pbkdf2"
+    "crypto/sha256"
+    "fmt"
+
+    "github.com/adam-good/OTP/internal/argon2"
+)
+
+/*
+    Passphrase-derived secrets
+
+    For air-gapped setups where the token has to be rebuilt from something a person
+    can remember, DeriveSecret makes the shared secret from a passphrase and a salt
+    instead of crypto/rand. The same passphrase, salt and options always give the same
+    secret, so all three are what has to be remembered or written down: use the
+    account's issuer and name as the salt so one passphrase gives each account its own
+    secret, and keep the options at their defaults, which won't change, unless they're
+    recorded with the salt.
+
+    The secret is only as strong as the passphrase; a slow KDF makes guessing it
+    expensive but not impossible, so use a long one, e.g. six or more random words.
+*/
+
+// PassphraseKDF is the key derivation function DeriveSecret uses
+type PassphraseKDF int
+
+const (
+    Argon2id        PassphraseKDF = iota    // RFC 9106, the default
+    PBKDF2SHA256                            // RFC 8018, for where Argon2 isn't allowed
+)
+
+// PassphraseOpts says how DeriveSecret derives a secret, zero fields take the defaults
+type PassphraseOpts struct {
+    KDF         PassphraseKDF
+    Length      int     // bytes of secret, default SecretLength(SHA1)
+    Time        uint32  // Argon2id passes, default 3
+    Memory      uint32  // Argon2id memory in KiB, default 64 MiB
+    Threads     uint8   // Argon2id lanes, default 4
+    Iterations  int     // PBKDF2 iterations, default 600000
+}
+
+// withDefaults fills in the zero fields of opts
+func (opts PassphraseOpts) withDefaults() PassphraseOpts {
+    if (opts.Length <= 0) {
+        opts.Length = SecretLength(SHA1)
+    }
+    if (opts.Time == 0) {
+        opts.Time = 3
+    }
+    if (opts.Memory == 0) {
+        opts.Memory = 64 * 1024
+    }
+    if (opts.Threads == 0) {
+        opts.Threads = 4
+    }
+    if (opts.Iterations <= 0) {
+        opts.Iterations = 600000
+    }
+    return opts
+}
+
+/*
+    DeriveSecret derives a shared secret from passphrase and salt, see the notes above
+
+    It fails with ErrInvalidPassphrase for an empty passphrase or a salt under 8 bytes.
+    With the defaults it takes a fraction of a second and 64 MiB of memory.
+*/
+func DeriveSecret(passphrase string, salt []byte, opts PassphraseOpts) ([]byte, error) {
+    if (passphrase == "" || len(salt) < 8) {
+        return nil, fmt.Errorf("%w: the passphrase can't be empty and the salt needs at least 8 bytes", ErrInvalidPassphrase)
+    }
+    opts = opts.withDefaults()
+    switch opts.KDF {
+    case Argon2id:
+        var password []byte = []byte(passphrase)
+        defer Wipe(password)
+        return argon2.IDKey(password, salt, opts.Time, opts.Memory, opts.Threads, uint32(opts.Length))
+    case PBKDF2SHA256:
+        return pbkdf2.Key(sha256.New, passphrase, salt, opts.Iterations, opts.Length)
+    default:
+        return nil, fmt.Errorf("%w: unknown key derivation %d", ErrInvalidPassphrase, opts.KDF)
+    }
+}
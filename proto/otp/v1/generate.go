@@ -0,0 +1,15 @@
+/*
+    Package otpv1 is where the Go code generated from otp.proto goes
+
+    Only the service definition is here so far. The generated messages and client
+    stubs (otp.pb.go, otp_grpc.pb.go) aren't checked in because they need
+    google.golang.org/protobuf and google.golang.org/grpc, which this module doesn't
+    depend on, and cmd/otpd only serves the JSON API; nothing implements OTPService
+    yet. To generate the code, with protoc, protoc-gen-go and protoc-gen-go-grpc on
+    the PATH and those modules available:
+
+        go generate ./proto/otp/v1
+*/
+package otpv1
+
+//go:generate protoc --proto_path=. --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative otp.proto
@@ -0,0 +1,17 @@
+package pskc
+
+import (
+    "errors"
+)
+
+// Errors returned by the package, for use with errors.Is
+var (
+    // the XML isn't a PSKC container or is missing something a key needs
+    ErrInvalidContainer = errors.New("pskc: invalid key container")
+    // the container has encrypted values but no decryption key was given
+    ErrNoKey            = errors.New("pskc: container is encrypted but no key was given")
+    // the decryption key doesn't open the encrypted values
+    ErrWrongKey         = errors.New("pskc: wrong decryption key")
+    // an algorithm, encryption method or response encoding isn't supported
+    ErrUnsupported      = errors.New("pskc: unsupported algorithm")
+)
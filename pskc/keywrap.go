@@ -0,0 +1,182 @@
+package pskc
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/subtle"
+    "encoding/binary"
+    "errors"
+)
+
+// keyWrapIV is the default initial value from RFC 3394 section 2.2.3.1
+var keyWrapIV []byte = []byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+
+// keyWrapPadIV is the constant half of the RFC 5649 alternative initial value, the other half is the length
+var keyWrapPadIV []byte = []byte{0xA6, 0x59, 0x59, 0xA6}
+
+/*
+    wrapKey encrypts key under kek with the AES Key Wrap algorithm of RFC 3394
+
+    The key must be a multiple of 8 bytes and at least 16; the result is 8 bytes longer.
+*/
+func wrapKey(kek []byte, key []byte) ([]byte, error) {
+    if (len(key) < 16 || len(key) % 8 != 0) {
+        return nil, errors.New("key wrap: key must be a multiple of 8 bytes and at least 16")
+    }
+    return wrap(kek, keyWrapIV, key)
+}
+
+// unwrapKey reverses wrapKey, failing if the integrity check value doesn't come out right
+func unwrapKey(kek []byte, wrapped []byte) ([]byte, error) {
+    if (len(wrapped) < 24 || len(wrapped) % 8 != 0) {
+        return nil, errors.New("key wrap: wrapped key has the wrong length")
+    }
+    var iv, key, err = unwrap(kek, wrapped)
+    if (err != nil) {
+        return nil, err
+    }
+    if (subtle.ConstantTimeCompare(iv, keyWrapIV) != 1) {
+        return nil, errors.New("key wrap: integrity check failed")
+    }
+    return key, nil
+}
+
+/*
+    wrapKeyPad encrypts key under kek with AES Key Wrap with Padding (RFC 5649)
+
+    Unlike plain key wrap it takes keys of any length, which matters for OTP secrets:
+    the usual 20 byte SHA1 secret isn't a multiple of 8.
+*/
+func wrapKeyPad(kek []byte, key []byte) ([]byte, error) {
+    if (len(key) == 0) {
+        return nil, errors.New("key wrap: empty key")
+    }
+    var iv []byte = binary.BigEndian.AppendUint32(append([]byte(nil), keyWrapPadIV...), uint32(len(key)))
+    var padded []byte = make([]byte, (len(key) + 7) / 8 * 8)
+    copy(padded, key)
+
+    if (len(padded) == 8) {
+        // a single block is just encrypted with the IV in front
+        var block, err = aes.NewCipher(kek)
+        if (err != nil) {
+            return nil, err
+        }
+        var out []byte = append(iv, padded...)
+        block.Encrypt(out, out)
+        return out, nil
+    }
+    return wrap(kek, iv, padded)
+}
+
+// unwrapKeyPad reverses wrapKeyPad, checking the length and padding in the recovered IV
+func unwrapKeyPad(kek []byte, wrapped []byte) ([]byte, error) {
+    if (len(wrapped) < 16 || len(wrapped) % 8 != 0) {
+        return nil, errors.New("key wrap: wrapped key has the wrong length")
+    }
+    var iv, padded []byte
+    if (len(wrapped) == 16) {
+        var block, err = aes.NewCipher(kek)
+        if (err != nil) {
+            return nil, err
+        }
+        var out []byte = make([]byte, 16)
+        block.Decrypt(out, wrapped)
+        iv, padded = out[:8], out[8:]
+    } else {
+        var err error
+        if iv, padded, err = unwrap(kek, wrapped); err != nil {
+            return nil, err
+        }
+    }
+
+    var length int = int(binary.BigEndian.Uint32(iv[4:]))
+    var ok int = subtle.ConstantTimeCompare(iv[:4], keyWrapPadIV)
+    if (ok != 1 || length > len(padded) || length <= len(padded) - 8) {
+        return nil, errors.New("key wrap: integrity check failed")
+    }
+    for _, b := range(padded[length:]) {
+        if (b != 0) {
+            return nil, errors.New("key wrap: integrity check failed")
+        }
+    }
+    return padded[:length], nil
+}
+
+// wrap is the RFC 3394 wrapping process with the given initial value
+func wrap(kek []byte, iv []byte, key []byte) ([]byte, error) {
+    var block, err = aes.NewCipher(kek)
+    if (err != nil) {
+        return nil, err
+    }
+
+    var n int = len(key) / 8
+    var out []byte = make([]byte, 8 + len(key))
+    copy(out, iv)
+    copy(out[8:], key)
+
+    var b [16]byte
+    for j := 0; j < 6; j++ {
+        for i := 1; i <= n; i++ {
+            // B = AES(K, A | R[i]), A = MSB(64, B) ^ t, R[i] = LSB(64, B)
+            copy(b[:8], out[:8])
+            copy(b[8:], out[i * 8:i * 8 + 8])
+            block.Encrypt(b[:], b[:])
+            var t uint64 = uint64(n * j + i)
+            binary.BigEndian.PutUint64(out[:8], binary.BigEndian.Uint64(b[:8]) ^ t)
+            copy(out[i * 8:], b[8:])
+        }
+    }
+    return out, nil
+}
+
+// unwrap is the RFC 3394 unwrapping process, returning the recovered initial value for the caller to check
+func unwrap(kek []byte, wrapped []byte) (iv []byte, key []byte, err error) {
+    var block cipher.Block
+    if block, err = aes.NewCipher(kek); err != nil {
+        return nil, nil, err
+    }
+
+    var n int = len(wrapped) / 8 - 1
+    var a []byte = make([]byte, 8)
+    copy(a, wrapped[:8])
+    var r []byte = make([]byte, len(wrapped) - 8)
+    copy(r, wrapped[8:])
+
+    var b [16]byte
+    for j := 5; j >= 0; j-- {
+        for i := n; i >= 1; i-- {
+            // B = AES-1(K, (A ^ t) | R[i]), A = MSB(64, B), R[i] = LSB(64, B)
+            var t uint64 = uint64(n * j + i)
+            binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(a) ^ t)
+            copy(b[8:], r[(i - 1) * 8:i * 8])
+            block.Decrypt(b[:], b[:])
+            copy(a, b[:8])
+            copy(r[(i - 1) * 8:], b[8:])
+        }
+    }
+    return a, r, nil
+}
+
+// decryptCBC decrypts an IV-prefixed AES-CBC value and strips its PKCS#7 padding
+func decryptCBC(key []byte, data []byte) ([]byte, error) {
+    var block, err = aes.NewCipher(key)
+    if (err != nil) {
+        return nil, err
+    }
+    if (len(data) < 2 * aes.BlockSize || len(data) % aes.BlockSize != 0) {
+        return nil, errors.New("cbc: ciphertext has the wrong length")
+    }
+    var plain []byte = make([]byte, len(data) - aes.BlockSize)
+    cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(plain, data[aes.BlockSize:])
+
+    var pad int = int(plain[len(plain) - 1])
+    if (pad == 0 || pad > aes.BlockSize) {
+        return nil, errors.New("cbc: bad padding")
+    }
+    for _, p := range(plain[len(plain) - pad:]) {
+        if (int(p) != pad) {
+            return nil, errors.New("cbc: bad padding")
+        }
+    }
+    return plain[:len(plain) - pad], nil
+}
@@ -0,0 +1,398 @@
+/*
+    Package pskc reads and writes Portable Symmetric Key Containers (RFC 6030)
+
+    PSKC is the XML format hardware token vendors ship seed files in. Parse turns a
+    container into *otp.Key values and Marshal writes keys back out for provisioning
+    systems. Secrets may be in the clear or encrypted with a pre-shared AES key, using
+    AES key wrap (RFC 3394 or, for lengths that aren't a multiple of 8, RFC 5649) or
+    AES-CBC with an HMAC over each value.
+
+    Only HOTP and TOTP keys with decimal responses are supported.
+*/
+package pskc
+
+import (
+    "bytes"
+    "crypto/hmac"
+    "crypto/sha1"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/binary"
+    "encoding/xml"
+    "fmt"
+    "hash"
+    "strconv"
+    "strings"
+    "time"
+
+    otp "github.com/adam-good/OTP"
+)
+
+// container mirrors the parts of the PSKC schema this package understands
+type container struct {
+    XMLName     xml.Name        `xml:"KeyContainer"`
+    Version     string          `xml:"Version,attr"`
+    MACMethod   *macMethod      `xml:"MACMethod"`
+    Packages    []keyPackage    `xml:"KeyPackage"`
+}
+
+type macMethod struct {
+    Algorithm   string          `xml:"Algorithm,attr"`
+    Key         *encryptedValue `xml:"MACKey"`
+}
+
+type keyPackage struct {
+    Device  struct {
+        Manufacturer    string  `xml:"Manufacturer"`
+        SerialNo        string  `xml:"SerialNo"`
+    } `xml:"DeviceInfo"`
+    Key     *key    `xml:"Key"`
+}
+
+type key struct {
+    ID          string  `xml:"Id,attr"`
+    Algorithm   string  `xml:"Algorithm,attr"`
+    Issuer      string  `xml:"Issuer"`
+    Parameters  struct {
+        Suite       string  `xml:"Suite"`
+        Response    struct {
+            Length      int     `xml:"Length,attr"`
+            Encoding    string  `xml:"Encoding,attr"`
+        } `xml:"ResponseFormat"`
+    } `xml:"AlgorithmParameters"`
+    Data        struct {
+        Secret          *value  `xml:"Secret"`
+        Counter         *value  `xml:"Counter"`
+        Time            *value  `xml:"Time"`
+        TimeInterval    *value  `xml:"TimeInterval"`
+    } `xml:"Data"`
+    UserID      string  `xml:"UserId"`
+}
+
+// value is a PSKC data item, in the clear or encrypted
+type value struct {
+    Plain       string          `xml:"PlainValue"`
+    Encrypted   *encryptedValue `xml:"EncryptedValue"`
+    MAC         string          `xml:"ValueMAC"`
+}
+
+type encryptedValue struct {
+    Method  struct {
+        Algorithm   string  `xml:"Algorithm,attr"`
+    } `xml:"EncryptionMethod"`
+    Cipher  string  `xml:"CipherData>CipherValue"`
+}
+
+// algorithm URIs
+const (
+    algoHOTP        string = "urn:ietf:params:xml:ns:keyprov:pskc:hotp"
+    algoTOTP        string = "urn:ietf:params:xml:ns:keyprov:pskc:totp"
+    methodKW        string = "http://www.w3.org/2001/04/xmlenc#kw-aes"
+    methodKWPad     string = "http://www.w3.org/2009/xmlenc11#kw-aes-"
+    methodCBC       string = "http://www.w3.org/2001/04/xmlenc#aes"
+    macHMACSHA1     string = "http://www.w3.org/2000/09/xmldsig#hmac-sha1"
+    macHMACSHA256   string = "http://www.w3.org/2001/04/xmldsig-more#hmac-sha256"
+)
+
+// decrypter opens the encrypted values of one container
+type decrypter struct {
+    key     []byte              // the pre-shared key, nil if none was given
+    macKey  []byte              // the decrypted MACKey, nil if the container has none
+    newMAC  func() hash.Hash
+}
+
+/*
+    methodKeySize returns the AES key size an EncryptionMethod URI calls for
+
+    The URIs end in the key size in bits, e.g. kw-aes128, kw-aes-256-pad or aes192-cbc.
+*/
+func methodKeySize(method string) int {
+    for _, bits := range([]string{"128", "192", "256"}) {
+        if (strings.Contains(method, bits)) {
+            var n, _ = strconv.Atoi(bits)
+            return n / 8
+        }
+    }
+    return 0
+}
+
+// decrypt opens one encrypted value
+func (d *decrypter) decrypt(v *encryptedValue) ([]byte, error) {
+    if (d.key == nil) {
+        return nil, ErrNoKey
+    }
+    var data, err = base64.StdEncoding.DecodeString(strings.TrimSpace(v.Cipher))
+    if (err != nil) {
+        return nil, fmt.Errorf("%w: cipher value: %v", ErrInvalidContainer, err)
+    }
+    var method string = v.Method.Algorithm
+    if (methodKeySize(method) != len(d.key)) {
+        return nil, fmt.Errorf("%w: %s needs a %d byte key", ErrWrongKey, method, methodKeySize(method))
+    }
+
+    var plain []byte
+    switch {
+    case strings.HasPrefix(method, methodKWPad) && strings.HasSuffix(method, "-pad"):
+        plain, err = unwrapKeyPad(d.key, data)
+    case strings.HasPrefix(method, methodKW):
+        plain, err = unwrapKey(d.key, data)
+    case strings.HasPrefix(method, methodCBC) && strings.HasSuffix(method, "-cbc"):
+        plain, err = decryptCBC(d.key, data)
+    default:
+        return nil, fmt.Errorf("%w: encryption method %s", ErrUnsupported, method)
+    }
+    if (err != nil) {
+        return nil, fmt.Errorf("%w: %v", ErrWrongKey, err)
+    }
+    return plain, nil
+}
+
+// open returns the raw bytes of a value, checking its MAC if it has one
+func (d *decrypter) open(v *value) ([]byte, error) {
+    if (v.Encrypted == nil) {
+        var plain, err = base64.StdEncoding.DecodeString(strings.TrimSpace(v.Plain))
+        if (err != nil) {
+            return nil, fmt.Errorf("%w: plain value: %v", ErrInvalidContainer, err)
+        }
+        return plain, nil
+    }
+
+    if (v.MAC != "" && d.macKey != nil) {
+        // the MAC is over the encrypted value as it appears in the container
+        var expected, err = base64.StdEncoding.DecodeString(strings.TrimSpace(v.MAC))
+        if (err != nil) {
+            return nil, fmt.Errorf("%w: value MAC: %v", ErrInvalidContainer, err)
+        }
+        var cipher []byte
+        if cipher, err = base64.StdEncoding.DecodeString(strings.TrimSpace(v.Encrypted.Cipher)); err != nil {
+            return nil, fmt.Errorf("%w: cipher value: %v", ErrInvalidContainer, err)
+        }
+        var mac hash.Hash = hmac.New(d.newMAC, d.macKey)
+        mac.Write(cipher)
+        if (!hmac.Equal(mac.Sum(nil), expected)) {
+            return nil, fmt.Errorf("%w: value MAC doesn't match", ErrInvalidContainer)
+        }
+    }
+    return d.decrypt(v.Encrypted)
+}
+
+// integer reads a numeric value: plain ones are decimal, encrypted ones big-endian bytes
+func (d *decrypter) integer(v *value) (uint64, error) {
+    if (v.Encrypted == nil) {
+        var n, err = strconv.ParseUint(strings.TrimSpace(v.Plain), 10, 64)
+        if (err != nil) {
+            return 0, fmt.Errorf("%w: %v", ErrInvalidContainer, err)
+        }
+        return n, nil
+    }
+    var b, err = d.open(v)
+    if (err != nil) {
+        return 0, err
+    }
+    if (len(b) > 8) {
+        return 0, fmt.Errorf("%w: integer value too long", ErrInvalidContainer)
+    }
+    var padded [8]byte
+    copy(padded[8 - len(b):], b)
+    return binary.BigEndian.Uint64(padded[:]), nil
+}
+
+// suiteAlgorithm reads the hash out of a Suite such as "HMAC-SHA256" or "HMAC-SHA-256"
+func suiteAlgorithm(suite string) (otp.Algorithm, bool) {
+    if (suite == "") {
+        return otp.SHA1, true
+    }
+    var name string = strings.ToUpper(strings.TrimSpace(suite))
+    name = strings.TrimPrefix(name, "HMAC-")
+    name = strings.ReplaceAll(name, "-", "")
+    return otp.ParseAlgorithm(name)
+}
+
+// toKey converts one KeyPackage to an otp.Key
+func (d *decrypter) toKey(p keyPackage) (*otp.Key, error) {
+    var k *key = p.Key
+    if (k == nil || k.Data.Secret == nil) {
+        return nil, fmt.Errorf("%w: no secret", ErrInvalidContainer)
+    }
+    if (k.Parameters.Response.Encoding != "" && !strings.EqualFold(k.Parameters.Response.Encoding, "DECIMAL")) {
+        return nil, fmt.Errorf("%w: response encoding %s", ErrUnsupported, k.Parameters.Response.Encoding)
+    }
+    var algo, ok = suiteAlgorithm(k.Parameters.Suite)
+    if (!ok) {
+        return nil, fmt.Errorf("%w: suite %s", ErrUnsupported, k.Parameters.Suite)
+    }
+
+    var secret, err = d.open(k.Data.Secret)
+    if (err != nil) {
+        return nil, err
+    }
+
+    // the account is the user if there is one, otherwise the token's serial number
+    var account string = k.UserID
+    if (account == "") {
+        account = p.Device.SerialNo
+    }
+    var opts []otp.Option = []otp.Option{
+        otp.WithAlgorithm(algo),
+        otp.WithIssuer(k.Issuer),
+        otp.WithAccount(account),
+    }
+    if (k.Parameters.Response.Length != 0) {
+        opts = append(opts, otp.WithDigits(k.Parameters.Response.Length))
+    }
+
+    switch {
+    case strings.EqualFold(k.Algorithm, algoHOTP):
+        var counter uint64
+        if (k.Data.Counter != nil) {
+            if counter, err = d.integer(k.Data.Counter); err != nil {
+                return nil, err
+            }
+        }
+        opts = append(opts, otp.WithHOTP(counter))
+    case strings.EqualFold(k.Algorithm, algoTOTP):
+        if (k.Data.TimeInterval != nil) {
+            var interval uint64
+            if interval, err = d.integer(k.Data.TimeInterval); err != nil {
+                return nil, err
+            }
+            opts = append(opts, otp.WithPeriod(time.Duration(interval) * time.Second))
+        }
+        if (k.Data.Time != nil) {
+            var t0 uint64
+            if t0, err = d.integer(k.Data.Time); err != nil {
+                return nil, err
+            }
+            if (t0 != 0) {
+                opts = append(opts, otp.WithT0(time.Unix(int64(t0), 0)))
+            }
+        }
+    default:
+        return nil, fmt.Errorf("%w: key algorithm %s", ErrUnsupported, k.Algorithm)
+    }
+    return otp.NewKey(secret, opts...)
+}
+
+/*
+    Parse reads a PSKC container and returns a Key for each KeyPackage
+
+    psk is the pre-shared AES key protecting encrypted values, nil if the container
+    is in the clear. If the container has a MACKey, every encrypted value's ValueMAC is
+    checked before it is decrypted.
+*/
+func Parse(data []byte, psk []byte) ([]*otp.Key, error) {
+    var c container
+    if err := xml.Unmarshal(data, &c); err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidContainer, err)
+    }
+
+    var d *decrypter = &decrypter{key: psk}
+    if (c.MACMethod != nil && c.MACMethod.Key != nil) {
+        switch c.MACMethod.Algorithm {
+        case macHMACSHA1:
+            d.newMAC = sha1.New
+        case macHMACSHA256:
+            d.newMAC = sha256.New
+        default:
+            return nil, fmt.Errorf("%w: MAC method %s", ErrUnsupported, c.MACMethod.Algorithm)
+        }
+        var err error
+        if d.macKey, err = d.decrypt(c.MACMethod.Key); err != nil {
+            return nil, err
+        }
+    }
+
+    var keys []*otp.Key
+    for i, p := range(c.Packages) {
+        var k, err = d.toKey(p)
+        if (err != nil) {
+            return nil, fmt.Errorf("key package %d: %w", i, err)
+        }
+        keys = append(keys, k)
+    }
+    return keys, nil
+}
+
+// escape returns s escaped for XML character data and attributes
+func escape(s string) string {
+    var b bytes.Buffer
+    xml.EscapeText(&b, []byte(s))
+    return b.String()
+}
+
+/*
+    Marshal writes keys as a PSKC container
+
+    With a nil psk the secrets are written in the clear. Otherwise psk must be a 16, 24
+    or 32 byte AES key and the secrets are encrypted with AES key wrap with padding
+    (RFC 5649), which authenticates them without a separate MAC.
+*/
+func Marshal(keys []*otp.Key, psk []byte) ([]byte, error) {
+    var method string
+    if (psk != nil) {
+        switch len(psk) {
+        case 16, 24, 32:
+            method = methodKWPad + strconv.Itoa(len(psk) * 8) + "-pad"
+        default:
+            return nil, fmt.Errorf("%w: pre-shared key must be 16, 24 or 32 bytes", ErrWrongKey)
+        }
+    }
+
+    var b bytes.Buffer
+    b.WriteString(xml.Header)
+    b.WriteString(`<KeyContainer Version="1.0" xmlns="urn:ietf:params:xml:ns:keyprov:pskc"` +
+        ` xmlns:ds="http://www.w3.org/2000/09/xmldsig#" xmlns:xenc="http://www.w3.org/2001/04/xmlenc#">` + "\n")
+    if (psk != nil) {
+        b.WriteString("  <EncryptionKey><ds:KeyName>Pre-shared-key</ds:KeyName></EncryptionKey>\n")
+    }
+
+    for i, k := range(keys) {
+        var secret string
+        if (psk == nil) {
+            secret = "<PlainValue>" + base64.StdEncoding.EncodeToString(k.Secret) + "</PlainValue>"
+        } else {
+            var wrapped, err = wrapKeyPad(psk, k.Secret)
+            if (err != nil) {
+                return nil, err
+            }
+            secret = `<EncryptedValue><xenc:EncryptionMethod Algorithm="` + method + `"/>` +
+                "<xenc:CipherData><xenc:CipherValue>" + base64.StdEncoding.EncodeToString(wrapped) +
+                "</xenc:CipherValue></xenc:CipherData></EncryptedValue>"
+        }
+
+        var algorithm string = algoTOTP
+        if (k.Type == otp.TypeHOTP) {
+            algorithm = algoHOTP
+        }
+
+        b.WriteString("  <KeyPackage>\n")
+        fmt.Fprintf(&b, "    <Key Id=\"%d\" Algorithm=\"%s\">\n", i + 1, algorithm)
+        if (k.Issuer != "") {
+            fmt.Fprintf(&b, "      <Issuer>%s</Issuer>\n", escape(k.Issuer))
+        }
+        b.WriteString("      <AlgorithmParameters>\n")
+        if (k.Algorithm != otp.SHA1) {
+            fmt.Fprintf(&b, "        <Suite>HMAC-%s</Suite>\n", escape(k.Algorithm.String()))
+        }
+        fmt.Fprintf(&b, "        <ResponseFormat Length=\"%d\" Encoding=\"DECIMAL\"/>\n", k.Digits)
+        b.WriteString("      </AlgorithmParameters>\n")
+        b.WriteString("      <Data>\n")
+        fmt.Fprintf(&b, "        <Secret>%s</Secret>\n", secret)
+        if (k.Type == otp.TypeHOTP) {
+            fmt.Fprintf(&b, "        <Counter><PlainValue>%d</PlainValue></Counter>\n", k.Counter)
+        } else {
+            if (!k.T0.IsZero()) {
+                fmt.Fprintf(&b, "        <Time><PlainValue>%d</PlainValue></Time>\n", k.T0.Unix())
+            }
+            fmt.Fprintf(&b, "        <TimeInterval><PlainValue>%d</PlainValue></TimeInterval>\n", int64(k.Period / time.Second))
+        }
+        b.WriteString("      </Data>\n")
+        if (k.Account != "") {
+            fmt.Fprintf(&b, "      <UserId>%s</UserId>\n", escape(k.Account))
+        }
+        b.WriteString("    </Key>\n")
+        b.WriteString("  </KeyPackage>\n")
+    }
+    b.WriteString("</KeyContainer>\n")
+    return b.Bytes(), nil
+}
@@ -0,0 +1,741 @@
+package otp
+
+import (
+    "bytes"
+    "image"
+    "image/color"
+    "image/png"
+    "io"
+    "strings"
+)
+
+/*
+    QR codes
+
+    Enrollment means getting a provisioning URI into an authenticator app, and the way
+    everyone does that is a QR code. This is a small encoder for exactly that job: byte
+    mode only (a URI is just bytes), any version from 1 to 40 and all four error
+    correction levels, following ISO/IEC 18004.
+
+    The steps are the ones in the standard:
+        1. pick the smallest version the data fits in
+        2. build the bit stream: mode, length, data, terminator and padding
+        3. split it into blocks and add Reed-Solomon error correction to each
+        4. draw the function patterns (finders, timing, alignment, ...)
+        5. lay the interleaved codewords out in the zigzag order
+        6. try all 8 masks and keep the one with the lowest penalty
+*/
+
+// QRLevel is the error correction level, the share of the symbol that can be damaged and still read
+type QRLevel int
+
+const (
+    QRLevelL QRLevel = iota // ~7%
+    QRLevelM                // ~15%
+    QRLevelQ                // ~25%
+    QRLevelH                // ~30%
+)
+
+/*
+    qrBlocks is the error correction block layout for each version and level
+
+    Each entry is one or two groups of (number of blocks, total codewords per block,
+    data codewords per block).
+*/
+var qrBlocks = [40][4][]int{
+    {{1, 26, 19}, {1, 26, 16}, {1, 26, 13}, {1, 26, 9}}, // 1
+    {{1, 44, 34}, {1, 44, 28}, {1, 44, 22}, {1, 44, 16}}, // 2
+    {{1, 70, 55}, {1, 70, 44}, {2, 35, 17}, {2, 35, 13}}, // 3
+    {{1, 100, 80}, {2, 50, 32}, {2, 50, 24}, {4, 25, 9}}, // 4
+    {{1, 134, 108}, {2, 67, 43}, {2, 33, 15, 2, 34, 16}, {2, 33, 11, 2, 34, 12}}, // 5
+    {{2, 86, 68}, {4, 43, 27}, {4, 43, 19}, {4, 43, 15}}, // 6
+    {{2, 98, 78}, {4, 49, 31}, {2, 32, 14, 4, 33, 15}, {4, 39, 13, 1, 40, 14}}, // 7
+    {{2, 121, 97}, {2, 60, 38, 2, 61, 39}, {4, 40, 18, 2, 41, 19}, {4, 40, 14, 2, 41, 15}}, // 8
+    {{2, 146, 116}, {3, 58, 36, 2, 59, 37}, {4, 36, 16, 4, 37, 17}, {4, 36, 12, 4, 37, 13}}, // 9
+    {{2, 86, 68, 2, 87, 69}, {4, 69, 43, 1, 70, 44}, {6, 43, 19, 2, 44, 20}, {6, 43, 15, 2, 44, 16}}, // 10
+    {{4, 101, 81}, {1, 80, 50, 4, 81, 51}, {4, 50, 22, 4, 51, 23}, {3, 36, 12, 8, 37, 13}}, // 11
+    {{2, 116, 92, 2, 117, 93}, {6, 58, 36, 2, 59, 37}, {4, 46, 20, 6, 47, 21}, {7, 42, 14, 4, 43, 15}}, // 12
+    {{4, 133, 107}, {8, 59, 37, 1, 60, 38}, {8, 44, 20, 4, 45, 21}, {12, 33, 11, 4, 34, 12}}, // 13
+    {{3, 145, 115, 1, 146, 116}, {4, 64, 40, 5, 65, 41}, {11, 36, 16, 5, 37, 17}, {11, 36, 12, 5, 37, 13}}, // 14
+    {{5, 109, 87, 1, 110, 88}, {5, 65, 41, 5, 66, 42}, {5, 54, 24, 7, 55, 25}, {11, 36, 12}}, // 15
+    {{5, 122, 98, 1, 123, 99}, {7, 73, 45, 3, 74, 46}, {15, 43, 19, 2, 44, 20}, {3, 45, 15, 13, 46, 16}}, // 16
+    {{1, 135, 107, 5, 136, 108}, {10, 74, 46, 1, 75, 47}, {1, 50, 22, 15, 51, 23}, {2, 42, 14, 17, 43, 15}}, // 17
+    {{5, 150, 120, 1, 151, 121}, {9, 69, 43, 4, 70, 44}, {17, 50, 22, 1, 51, 23}, {2, 42, 14, 19, 43, 15}}, // 18
+    {{3, 141, 113, 4, 142, 114}, {3, 70, 44, 11, 71, 45}, {17, 47, 21, 4, 48, 22}, {9, 39, 13, 16, 40, 14}}, // 19
+    {{3, 135, 107, 5, 136, 108}, {3, 67, 41, 13, 68, 42}, {15, 54, 24, 5, 55, 25}, {15, 43, 15, 10, 44, 16}}, // 20
+    {{4, 144, 116, 4, 145, 117}, {17, 68, 42}, {17, 50, 22, 6, 51, 23}, {19, 46, 16, 6, 47, 17}}, // 21
+    {{2, 139, 111, 7, 140, 112}, {17, 74, 46}, {7, 54, 24, 16, 55, 25}, {34, 37, 13}}, // 22
+    {{4, 151, 121, 5, 152, 122}, {4, 75, 47, 14, 76, 48}, {11, 54, 24, 14, 55, 25}, {16, 45, 15, 14, 46, 16}}, // 23
+    {{6, 147, 117, 4, 148, 118}, {6, 73, 45, 14, 74, 46}, {11, 54, 24, 16, 55, 25}, {30, 46, 16, 2, 47, 17}}, // 24
+    {{8, 132, 106, 4, 133, 107}, {8, 75, 47, 13, 76, 48}, {7, 54, 24, 22, 55, 25}, {22, 45, 15, 13, 46, 16}}, // 25
+    {{10, 142, 114, 2, 143, 115}, {19, 74, 46, 4, 75, 47}, {28, 50, 22, 6, 51, 23}, {33, 46, 16, 4, 47, 17}}, // 26
+    {{8, 152, 122, 4, 153, 123}, {22, 73, 45, 3, 74, 46}, {8, 53, 23, 26, 54, 24}, {12, 45, 15, 28, 46, 16}}, // 27
+    {{3, 147, 117, 10, 148, 118}, {3, 73, 45, 23, 74, 46}, {4, 54, 24, 31, 55, 25}, {11, 45, 15, 31, 46, 16}}, // 28
+    {{7, 146, 116, 7, 147, 117}, {21, 73, 45, 7, 74, 46}, {1, 53, 23, 37, 54, 24}, {19, 45, 15, 26, 46, 16}}, // 29
+    {{5, 145, 115, 10, 146, 116}, {19, 75, 47, 10, 76, 48}, {15, 54, 24, 25, 55, 25}, {23, 45, 15, 25, 46, 16}}, // 30
+    {{13, 145, 115, 3, 146, 116}, {2, 74, 46, 29, 75, 47}, {42, 54, 24, 1, 55, 25}, {23, 45, 15, 28, 46, 16}}, // 31
+    {{17, 145, 115}, {10, 74, 46, 23, 75, 47}, {10, 54, 24, 35, 55, 25}, {19, 45, 15, 35, 46, 16}}, // 32
+    {{17, 145, 115, 1, 146, 116}, {14, 74, 46, 21, 75, 47}, {29, 54, 24, 19, 55, 25}, {11, 45, 15, 46, 46, 16}}, // 33
+    {{13, 145, 115, 6, 146, 116}, {14, 74, 46, 23, 75, 47}, {44, 54, 24, 7, 55, 25}, {59, 46, 16, 1, 47, 17}}, // 34
+    {{12, 151, 121, 7, 152, 122}, {12, 75, 47, 26, 76, 48}, {39, 54, 24, 14, 55, 25}, {22, 45, 15, 41, 46, 16}}, // 35
+    {{6, 151, 121, 14, 152, 122}, {6, 75, 47, 34, 76, 48}, {46, 54, 24, 10, 55, 25}, {2, 45, 15, 64, 46, 16}}, // 36
+    {{17, 152, 122, 4, 153, 123}, {29, 74, 46, 14, 75, 47}, {49, 54, 24, 10, 55, 25}, {24, 45, 15, 46, 46, 16}}, // 37
+    {{4, 152, 122, 18, 153, 123}, {13, 74, 46, 32, 75, 47}, {48, 54, 24, 14, 55, 25}, {42, 45, 15, 32, 46, 16}}, // 38
+    {{20, 147, 117, 4, 148, 118}, {40, 75, 47, 7, 76, 48}, {43, 54, 24, 22, 55, 25}, {10, 45, 15, 67, 46, 16}}, // 39
+    {{19, 148, 118, 6, 149, 119}, {18, 75, 47, 31, 76, 48}, {34, 54, 24, 34, 55, 25}, {20, 45, 15, 61, 46, 16}}, // 40
+}
+
+// qrAlignment is where the alignment patterns' centres go on each axis for each version
+var qrAlignment = [40][]int{
+    {},
+    {6, 18},
+    {6, 22},
+    {6, 26},
+    {6, 30},
+    {6, 34},
+    {6, 22, 38},
+    {6, 24, 42},
+    {6, 26, 46},
+    {6, 28, 50},
+    {6, 30, 54},
+    {6, 32, 58},
+    {6, 34, 62},
+    {6, 26, 46, 66},
+    {6, 26, 48, 70},
+    {6, 26, 50, 74},
+    {6, 30, 54, 78},
+    {6, 30, 56, 82},
+    {6, 30, 58, 86},
+    {6, 34, 62, 90},
+    {6, 28, 50, 72, 94},
+    {6, 26, 50, 74, 98},
+    {6, 30, 54, 78, 102},
+    {6, 28, 54, 80, 106},
+    {6, 32, 58, 84, 110},
+    {6, 30, 58, 86, 114},
+    {6, 34, 62, 90, 118},
+    {6, 26, 50, 74, 98, 122},
+    {6, 30, 54, 78, 102, 126},
+    {6, 26, 52, 78, 104, 130},
+    {6, 30, 56, 82, 108, 134},
+    {6, 34, 60, 86, 112, 138},
+    {6, 30, 58, 86, 114, 142},
+    {6, 34, 62, 90, 118, 146},
+    {6, 30, 54, 78, 102, 126, 150},
+    {6, 24, 50, 76, 102, 128, 154},
+    {6, 28, 54, 80, 106, 132, 158},
+    {6, 32, 58, 84, 110, 136, 162},
+    {6, 26, 54, 82, 110, 138, 166},
+    {6, 30, 58, 86, 114, 142, 170},
+}
+
+// qrLevelBits is the 2 bit code for each level in the format information
+var qrLevelBits = [4]int{QRLevelL: 1, QRLevelM: 0, QRLevelQ: 3, QRLevelH: 2}
+
+/*
+    QR is an encoded QR code
+
+    Modules[y][x] is true for a dark module. There is no quiet zone in Modules; the
+    renderers add the 4 module border the standard asks for.
+*/
+type QR struct {
+    Version int
+    Level   QRLevel
+    Modules [][]bool
+}
+
+// Size is the width (and height) of the symbol in modules
+func (q *QR) Size() int {
+    return len(q.Modules)
+}
+
+/*
+    Reed-Solomon over GF(256)
+
+    QR codes use the field with the primitive polynomial x^8 + x^4 + x^3 + x^2 + 1 (0x11D)
+    and generator x = 2. gfExp/gfLog are the usual lookup tables.
+*/
+var gfExp [512]byte
+var gfLog [256]int
+
+func init() {
+    var x int = 1
+    for i := 0; i < 255; i++ {
+        gfExp[i] = byte(x)
+        gfLog[x] = i
+        x <<= 1
+        if (x & 0x100 != 0) {
+            x ^= 0x11D
+        }
+    }
+    // doubled up so gfMul doesn't need a mod
+    for i := 255; i < 512; i++ {
+        gfExp[i] = gfExp[i-255]
+    }
+}
+
+func gfMul(a byte, b byte) byte {
+    if (a == 0 || b == 0) {
+        return 0
+    }
+    return gfExp[gfLog[a] + gfLog[b]]
+}
+
+// rsGenerator is the generator polynomial (x - 2^0)(x - 2^1)...(x - 2^(n-1)), highest power first
+func rsGenerator(n int) []byte {
+    var g []byte = []byte{1}
+    for i := 0; i < n; i++ {
+        var next []byte = make([]byte, len(g) + 1)
+        for j, c := range(g) {
+            next[j] ^= c
+            next[j+1] ^= gfMul(c, gfExp[i])
+        }
+        g = next
+    }
+    return g
+}
+
+// rsRemainder is the n error correction codewords for data: data * x^n mod generator
+func rsRemainder(data []byte, n int) []byte {
+    var g []byte = rsGenerator(n)
+    var rem []byte = make([]byte, n)
+    for _, d := range(data) {
+        var factor byte = d ^ rem[0]
+        copy(rem, rem[1:])
+        rem[n-1] = 0
+        for i := 0; i < n; i++ {
+            rem[i] ^= gfMul(g[i+1], factor)
+        }
+    }
+    return rem
+}
+
+// qrBitBuffer collects the data bit stream most significant bit first
+type qrBitBuffer struct {
+    data    []byte
+    length  int
+}
+
+func (b *qrBitBuffer) put(value int, bits int) {
+    for i := bits - 1; i >= 0; i-- {
+        if (b.length % 8 == 0) {
+            b.data = append(b.data, 0)
+        }
+        if ((value >> uint(i)) & 1 == 1) {
+            b.data[b.length / 8] |= 0x80 >> uint(b.length % 8)
+        }
+        b.length++
+    }
+}
+
+// qrDataCodewords is how many data codewords a version and level hold
+func qrDataCodewords(version int, level QRLevel) int {
+    var blocks []int = qrBlocks[version-1][level]
+    var n int = 0
+    for i := 0; i < len(blocks); i += 3 {
+        n += blocks[i] * blocks[i+2]
+    }
+    return n
+}
+
+// qrCountBits is the width of the byte mode character count for a version
+func qrCountBits(version int) int {
+    if (version < 10) {
+        return 8
+    }
+    return 16
+}
+
+/*
+    EncodeQR encodes data as a QR code at the given error correction level
+
+    The smallest version that fits is used. Data that doesn't fit even in version 40
+    fails with ErrQRTooLong.
+*/
+func EncodeQR(data []byte, level QRLevel) (*QR, error) {
+    if (level < QRLevelL || level > QRLevelH) {
+        level = QRLevelM
+    }
+    var version int = 0
+    for v := 1; v <= 40; v++ {
+        if (4 + qrCountBits(v) + 8 * len(data) <= 8 * qrDataCodewords(v, level)) {
+            version = v
+            break
+        }
+    }
+    if (version == 0) {
+        return nil, ErrQRTooLong
+    }
+
+    var codewords []byte = qrCodewords(data, version, level)
+
+    /*
+    *   Try every mask and keep the best one
+    */
+    var best [][]bool
+    var bestPenalty int = -1
+    for mask := 0; mask < 8; mask++ {
+        var m [][]bool = qrMatrix(codewords, version, level, mask)
+        var p int = qrPenalty(m)
+        if (bestPenalty < 0 || p < bestPenalty) {
+            best, bestPenalty = m, p
+        }
+    }
+    return &QR{Version: version, Level: level, Modules: best}, nil
+}
+
+// qrCodewords builds the final interleaved data and error correction codewords
+func qrCodewords(data []byte, version int, level QRLevel) []byte {
+    var capacity int = qrDataCodewords(version, level)
+
+    /*
+    *   mode indicator 0100 (byte mode), character count, the data itself,
+    *   up to 4 bits of terminator, zero bits up to a byte boundary and
+    *   then alternating pad bytes 0xEC 0x11 until it's full
+    */
+    var buf qrBitBuffer
+    buf.put(4, 4)
+    buf.put(len(data), qrCountBits(version))
+    for _, b := range(data) {
+        buf.put(int(b), 8)
+    }
+    buf.put(0, min(4, capacity * 8 - buf.length))
+    if (buf.length % 8 != 0) {
+        buf.put(0, 8 - buf.length % 8)
+    }
+    for pad := 0; len(buf.data) < capacity; pad++ {
+        if (pad % 2 == 0) {
+            buf.put(0xEC, 8)
+        } else {
+            buf.put(0x11, 8)
+        }
+    }
+
+    /*
+    *   Split into blocks and compute each block's error correction
+    */
+    var dataBlocks [][]byte
+    var ecBlocks [][]byte
+    var groups []int = qrBlocks[version-1][level]
+    var offset int = 0
+    for g := 0; g < len(groups); g += 3 {
+        var count, total, dataCount int = groups[g], groups[g+1], groups[g+2]
+        for i := 0; i < count; i++ {
+            var block []byte = buf.data[offset:offset+dataCount]
+            offset += dataCount
+            dataBlocks = append(dataBlocks, block)
+            ecBlocks = append(ecBlocks, rsRemainder(block, total - dataCount))
+        }
+    }
+
+    /*
+    *   Interleave: the first codeword of every block, then the second, ...
+    *   data first, then error correction
+    */
+    var out []byte
+    var interleave = func(blocks [][]byte) {
+        var longest int = 0
+        for _, b := range(blocks) {
+            longest = max(longest, len(b))
+        }
+        for i := 0; i < longest; i++ {
+            for _, b := range(blocks) {
+                if (i < len(b)) {
+                    out = append(out, b[i])
+                }
+            }
+        }
+    }
+    interleave(dataBlocks)
+    interleave(ecBlocks)
+    return out
+}
+
+/*
+    qrMatrix draws the symbol for the codewords using mask
+
+    reserved marks the function pattern modules so the data placement skips them.
+*/
+func qrMatrix(codewords []byte, version int, level QRLevel, mask int) [][]bool {
+    var size int = version * 4 + 17
+    var m [][]bool = make([][]bool, size)
+    var reserved [][]bool = make([][]bool, size)
+    for i := range(m) {
+        m[i] = make([]bool, size)
+        reserved[i] = make([]bool, size)
+    }
+    var set = func(x int, y int, dark bool) {
+        m[y][x] = dark
+        reserved[y][x] = true
+    }
+
+    /*
+    *   Finder patterns in three corners, each with a light separator around it
+    */
+    for _, corner := range([][2]int{{0, 0}, {size - 7, 0}, {0, size - 7}}) {
+        for dy := -1; dy <= 7; dy++ {
+            for dx := -1; dx <= 7; dx++ {
+                var x, y int = corner[0] + dx, corner[1] + dy
+                if (x < 0 || y < 0 || x >= size || y >= size) {
+                    continue
+                }
+                var ring int = max(abs(dx - 3), abs(dy - 3))
+                set(x, y, ring != 2 && ring != 4)
+            }
+        }
+    }
+
+    /*
+    *   Alignment patterns, everywhere on the grid except over the finders
+    */
+    var centres []int = qrAlignment[version-1]
+    for _, cy := range(centres) {
+        for _, cx := range(centres) {
+            if (reserved[cy][cx]) {
+                continue
+            }
+            for dy := -2; dy <= 2; dy++ {
+                for dx := -2; dx <= 2; dx++ {
+                    set(cx + dx, cy + dy, max(abs(dx), abs(dy)) != 1)
+                }
+            }
+        }
+    }
+
+    /*
+    *   Timing patterns along row and column 6
+    */
+    for i := 8; i < size - 8; i++ {
+        set(i, 6, i % 2 == 0)
+        set(6, i, i % 2 == 0)
+    }
+
+    /*
+    *   Format information (level and mask) twice, next to the top left finder
+    *   and split between the other two, plus the module that is always dark
+    */
+    var format int = qrFormatBits(level, mask)
+    for i := 0; i < 15; i++ {
+        var dark bool = (format >> uint(i)) & 1 == 1
+        // around the top left finder: up column 8 then along row 8
+        if (i < 6) {
+            set(8, i, dark)
+        } else if (i < 8) {
+            set(8, i + 1, dark)
+        } else if (i == 8) {
+            set(7, 8, dark)
+        } else {
+            set(14 - i, 8, dark)
+        }
+        // the other copy: along row 8 from the right, then up column 8 from the bottom
+        if (i < 8) {
+            set(size - 1 - i, 8, dark)
+        } else {
+            set(8, size - 15 + i, dark)
+        }
+    }
+    set(8, size - 8, true)
+
+    /*
+    *   Version information for version 7 and up, in two 6x3 blocks
+    */
+    if (version >= 7) {
+        var bits int = qrVersionBits(version)
+        for i := 0; i < 18; i++ {
+            var dark bool = (bits >> uint(i)) & 1 == 1
+            set(size - 11 + i % 3, i / 3, dark)
+            set(i / 3, size - 11 + i % 3, dark)
+        }
+    }
+
+    /*
+    *   Data: two columns at a time from the right, going up then down,
+    *   skipping the vertical timing pattern, masking each data bit as it goes in
+    */
+    var bit int = 0
+    var upward bool = true
+    for right := size - 1; right >= 1; right -= 2 {
+        if (right == 6) {
+            right--
+        }
+        for i := 0; i < size; i++ {
+            var y int = i
+            if (upward) {
+                y = size - 1 - i
+            }
+            for dx := 0; dx < 2; dx++ {
+                var x int = right - dx
+                if (reserved[y][x]) {
+                    continue
+                }
+                var dark bool = false
+                if (bit < len(codewords) * 8) {
+                    dark = (codewords[bit / 8] >> uint(7 - bit % 8)) & 1 == 1
+                }
+                bit++
+                m[y][x] = dark != qrMask(mask, x, y)
+            }
+        }
+        upward = !upward
+    }
+    return m
+}
+
+// qrMask reports whether mask pattern inverts the module at column x, row y
+func qrMask(mask int, x int, y int) bool {
+    switch mask {
+    case 0:
+        return (x + y) % 2 == 0
+    case 1:
+        return y % 2 == 0
+    case 2:
+        return x % 3 == 0
+    case 3:
+        return (x + y) % 3 == 0
+    case 4:
+        return (y / 2 + x / 3) % 2 == 0
+    case 5:
+        return (x * y) % 2 + (x * y) % 3 == 0
+    case 6:
+        return ((x * y) % 2 + (x * y) % 3) % 2 == 0
+    default:
+        return ((x + y) % 2 + (x * y) % 3) % 2 == 0
+    }
+}
+
+// bchRemainder is value * x^(degree of poly) mod poly over GF(2)
+func bchRemainder(value int, poly int) int {
+    var degree int = 0
+    for p := poly; p > 1; p >>= 1 {
+        degree++
+    }
+    var r int = value << uint(degree)
+    for i := 0; ; i++ {
+        var top int = 0
+        for t := r; t > 1; t >>= 1 {
+            top++
+        }
+        if (r == 0 || top < degree) {
+            break
+        }
+        r ^= poly << uint(top - degree)
+    }
+    return r
+}
+
+// qrFormatBits is the 15 bit format information: level and mask, BCH(15,5) protected and masked
+func qrFormatBits(level QRLevel, mask int) int {
+    var data int = qrLevelBits[level] << 3 | mask
+    return (data << 10 | bchRemainder(data, 0x537)) ^ 0x5412
+}
+
+// qrVersionBits is the 18 bit version information: the version, BCH(18,6) protected
+func qrVersionBits(version int) int {
+    return version << 12 | bchRemainder(version, 0x1F25)
+}
+
+/*
+    qrPenalty scores a masked symbol with the four rules from the standard
+
+    1. runs of 5 or more same coloured modules in a row or column
+    2. 2x2 blocks of the same colour
+    3. finder-like 1:1:3:1:1 patterns with 4 light modules on either side
+    4. how far the share of dark modules is from 50%
+*/
+func qrPenalty(m [][]bool) int {
+    var size int = len(m)
+    var penalty int = 0
+    var at = func(x int, y int, vertical bool) bool {
+        if (vertical) {
+            return m[x][y]
+        }
+        return m[y][x]
+    }
+
+    for _, vertical := range([]bool{false, true}) {
+        for y := 0; y < size; y++ {
+            // rule 1
+            var run int = 1
+            for x := 1; x < size; x++ {
+                if (at(x, y, vertical) == at(x - 1, y, vertical)) {
+                    run++
+                    continue
+                }
+                if (run >= 5) {
+                    penalty += run - 2
+                }
+                run = 1
+            }
+            if (run >= 5) {
+                penalty += run - 2
+            }
+            // rule 3
+            for x := 0; x + 11 <= size; x++ {
+                var pattern []bool = []bool{true, false, true, true, true, false, true}
+                var ok bool = true
+                for i, dark := range(pattern) {
+                    if (at(x + i, y, vertical) != dark) {
+                        ok = false
+                        break
+                    }
+                }
+                if (!ok) {
+                    continue
+                }
+                var lightAfter, lightBefore bool = true, true
+                for i := 7; i < 11; i++ {
+                    if (at(x + i, y, vertical)) {
+                        lightAfter = false
+                    }
+                }
+                for i := 1; i <= 4; i++ {
+                    if (x - i >= 0 && at(x - i, y, vertical)) {
+                        lightBefore = false
+                    }
+                }
+                if (lightAfter) {
+                    penalty += 40
+                }
+                if (lightBefore && x >= 4) {
+                    penalty += 40
+                }
+            }
+        }
+    }
+
+    // rule 2
+    var dark int = 0
+    for y := 0; y < size; y++ {
+        for x := 0; x < size; x++ {
+            if (m[y][x]) {
+                dark++
+            }
+            if (x + 1 < size && y + 1 < size && m[y][x] == m[y][x+1] && m[y][x] == m[y+1][x] && m[y][x] == m[y+1][x+1]) {
+                penalty += 3
+            }
+        }
+    }
+
+    // rule 4
+    var percent int = dark * 100 / (size * size)
+    penalty += abs(percent - 50) / 5 * 10
+    return penalty
+}
+
+func abs(x int) int {
+    if (x < 0) {
+        return -x
+    }
+    return x
+}
+
+/*
+    Image renders the code as a black on white image
+
+    Each module is scale pixels square (at least 1) and there's the standard 4 module
+    quiet zone around it.
+*/
+func (q *QR) Image(scale int) image.Image {
+    if (scale < 1) {
+        scale = 1
+    }
+    const quiet int = 4
+    var width int = (q.Size() + 2 * quiet) * scale
+    var img *image.Gray = image.NewGray(image.Rect(0, 0, width, width))
+    for i := range(img.Pix) {
+        img.Pix[i] = 0xFF
+    }
+    for y, row := range(q.Modules) {
+        for x, dark := range(row) {
+            if (!dark) {
+                continue
+            }
+            for py := 0; py < scale; py++ {
+                for px := 0; px < scale; px++ {
+                    img.SetGray((x + quiet) * scale + px, (y + quiet) * scale + py, color.Gray{Y: 0})
+                }
+            }
+        }
+    }
+    return img
+}
+
+// PNG renders the code as a PNG, see Image
+func (q *QR) PNG(scale int) ([]byte, error) {
+    var buf bytes.Buffer
+    if err := png.Encode(&buf, q.Image(scale)); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+/*
+    QRCodePNG turns a provisioning URI into a PNG of its QR code
+
+    It uses level M, which leaves room for a bit of glare on a phone camera while
+    keeping the symbol small, and 8 pixels per module.
+*/
+func QRCodePNG(uri string) ([]byte, error) {
+    var q, err = EncodeQR([]byte(uri), QRLevelM)
+    if (err != nil) {
+        return nil, err
+    }
+    return q.PNG(8)
+}
+
+// QRCodePNG is the enrollment QR code for the key's provisioning URI
+func (k *Key) QRCodePNG() ([]byte, error) {
+    var uri, err = k.URI()
+    if (err != nil) {
+        return nil, err
+    }
+    return QRCodePNG(uri)
+}
+
+/*
+    Terminal renders the code with Unicode half blocks for a terminal
+
+    Each character covers two rows of modules, so the code comes out roughly square in
+    a normal terminal font. Light modules are drawn and dark ones left as spaces, which
+    is what scans on the usual light-on-dark terminal; turn invert on for a terminal with
+    a light background.
+*/
+func (q *QR) Terminal(invert bool) string {
+    const quiet int = 4
+    var size int = q.Size() + 2 * quiet
+    // lit reports whether the cell at x, y (quiet zone included) gets drawn
+    var lit = func(x int, y int) bool {
+        x, y = x - quiet, y - quiet
+        var dark bool = x >= 0 && y >= 0 && x < q.Size() && y < q.Size() && q.Modules[y][x]
+        return dark == invert
+    }
+
+    var b strings.Builder
+    for y := 0; y < size; y += 2 {
+        for x := 0; x < size; x++ {
+            var top, bottom bool = lit(x, y), y + 1 < size && lit(x, y + 1)
+            switch {
+            case (top && bottom):
+                b.WriteRune('█')
+            case (top):
+                b.WriteRune('▀')
+            case (bottom):
+                b.WriteRune('▄')
+            default:
+                b.WriteRune(' ')
+            }
+        }
+        b.WriteRune('\n')
+    }
+    return b.String()
+}
+
+// PrintQR writes the QR code for a provisioning URI to w (usually os.Stdout) with Terminal
+func PrintQR(w io.Writer, uri string, invert bool) error {
+    var q, err = EncodeQR([]byte(uri), QRLevelM)
+    if (err != nil) {
+        return err
+    }
+    _, err = io.WriteString(w, q.Terminal(invert))
+    return err
+}
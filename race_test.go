@@ -0,0 +1,6 @@
+//go:build race
+
+package otp
+
+// raceEnabled is whether the tests are built with -race, where sync.Pool drops items on purpose and so allocates
+const raceEnabled bool = true
@@ -0,0 +1,206 @@
+package otp
+
+import (
+    "crypto/pbkdf2"
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/subtle"
+    "encoding/base64"
+    "fmt"
+    "math/big"
+    "strconv"
+    "strings"
+
+    "github.com/adam-good/OTP/internal/scrypt"
+)
+
+/*
+    RecoveryStore keeps users' recovery codes, hashed
+
+    Recovery codes get a user in when their authenticator is lost. Only hashes are
+    stored (see HashRecoveryCode), like passwords. SetRecoveryCodes replaces all of a
+    user's hashes and RecoveryCodes lists them. UseRecoveryCode removes one and reports
+    whether it was still there; it must be atomic, since it's all that stops one code
+    being used twice at the same moment.
+*/
+type RecoveryStore interface {
+    RecoveryCodes(user string) ([]string, error)
+    SetRecoveryCodes(user string, hashes []string) error
+    UseRecoveryCode(user string, hash string) (bool, error)
+}
+
+// DefaultRecoveryAlphabet leaves out 0, 1, i, l and o, which are easy to misread on paper
+const DefaultRecoveryAlphabet string = "23456789abcdefghjkmnpqrstuvwxyz"
+
+// RecoveryOpts describes the recovery codes to generate
+type RecoveryOpts struct {
+    Count       int     // how many codes, default 10
+    Length      int     // characters in each, default 10
+    Alphabet    string  // characters to use, default DefaultRecoveryAlphabet; compared ignoring case
+    Group       int     // put a - every Group characters to make them easier to read, default 5, -1 for never
+}
+
+// withDefaults fills in the zero fields of opts
+func (opts RecoveryOpts) withDefaults() RecoveryOpts {
+    if (opts.Count <= 0) {
+        opts.Count = 10
+    }
+    if (opts.Length <= 0) {
+        opts.Length = 10
+    }
+    if (opts.Alphabet == "") {
+        opts.Alphabet = DefaultRecoveryAlphabet
+    }
+    if (opts.Group == 0) {
+        opts.Group = 5
+    }
+    return opts
+}
+
+/*
+    GenerateRecoveryCodes returns new random recovery codes
+
+    Each character is drawn uniformly from the alphabet; the defaults give ten codes
+    of about 50 bits each, e.g. "k7m2x-9qvtd".
+*/
+func GenerateRecoveryCodes(opts RecoveryOpts) ([]string, error) {
+    opts = opts.withDefaults()
+    var codes []string = make([]string, opts.Count)
+    var size *big.Int = big.NewInt(int64(len(opts.Alphabet)))
+    for i := range(codes) {
+        var b strings.Builder
+        for j := 0; j < opts.Length; j++ {
+            if (j > 0 && opts.Group > 0 && j % opts.Group == 0) {
+                b.WriteByte('-')
+            }
+            var n, err = rand.Int(rand.Reader, size)
+            if (err != nil) {
+                return nil, err
+            }
+            b.WriteByte(opts.Alphabet[n.Int64()])
+        }
+        codes[i] = b.String()
+    }
+    return codes, nil
+}
+
+// normalizeRecoveryCode drops the separators and case from a typed recovery code
+func normalizeRecoveryCode(code string) string {
+    return strings.ToLower(strings.Map(func(r rune) rune {
+        if (r == '-' || r == ' ') {
+            return -1
+        }
+        return r
+    }, code))
+}
+
+// scrypt parameters for recovery code hashes: light, every stored hash may be tried on each use
+const (
+    recoveryN       int = 1 << 12
+    recoveryR       int = 8
+    recoveryP       int = 1
+)
+
+/*
+    HashRecoveryCode hashes a recovery code for storage
+
+    The hash is "scrypt$N$r$p$salt$key" with a random salt, base64 without padding.
+    "pbkdf2-sha256$iterations$salt$key" hashes made elsewhere are checked too.
+*/
+func HashRecoveryCode(code string) (string, error) {
+    var salt []byte = make([]byte, 16)
+    if _, err := rand.Read(salt); err != nil {
+        return "", err
+    }
+    var key, err = scrypt.Key([]byte(normalizeRecoveryCode(code)), salt, recoveryN, recoveryR, recoveryP, 32)
+    if (err != nil) {
+        return "", err
+    }
+    return fmt.Sprintf("scrypt$%d$%d$%d$%s$%s", recoveryN, recoveryR, recoveryP,
+        base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// recoveryCodeMatches reports whether code hashes to hash, false for a hash it can't read
+func recoveryCodeMatches(hash string, code string) bool {
+    var parts []string = strings.Split(hash, "$")
+    var params []int
+    var salt, want []byte
+    var err error
+    if (len(parts) >= 3) {
+        for _, p := range(parts[1:len(parts) - 2]) {
+            var n int
+            if n, err = strconv.Atoi(p); err != nil {
+                return false
+            }
+            params = append(params, n)
+        }
+        if salt, err = base64.RawStdEncoding.DecodeString(parts[len(parts) - 2]); err != nil {
+            return false
+        }
+        if want, err = base64.RawStdEncoding.DecodeString(parts[len(parts) - 1]); err != nil {
+            return false
+        }
+    }
+
+    var got []byte
+    var password []byte = []byte(normalizeRecoveryCode(code))
+    switch {
+    case (parts[0] == "scrypt" && len(params) == 3):
+        got, err = scrypt.Key(password, salt, params[0], params[1], params[2], len(want))
+    case (parts[0] == "pbkdf2-sha256" && len(params) == 1):
+        got, err = pbkdf2.Key(sha256.New, string(password), salt, params[0], len(want))
+    default:
+        return false
+    }
+    return err == nil && len(want) > 0 && subtle.ConstantTimeCompare(got, want) == 1
+}
+
+/*
+    SetupRecoveryCodes gives user a fresh set of recovery codes
+
+    Any codes they had stop working. The codes are returned to show the user once;
+    only their hashes are kept in store.
+*/
+func SetupRecoveryCodes(store RecoveryStore, user string, opts RecoveryOpts) ([]string, error) {
+    var codes, err = GenerateRecoveryCodes(opts)
+    if (err != nil) {
+        return nil, err
+    }
+    var hashes []string = make([]string, len(codes))
+    for i, code := range(codes) {
+        if hashes[i], err = HashRecoveryCode(code); err != nil {
+            return nil, err
+        }
+    }
+    if err = store.SetRecoveryCodes(user, hashes); err != nil {
+        return nil, err
+    }
+    return codes, nil
+}
+
+/*
+    VerifyRecoveryCode checks a recovery code and uses it up
+
+    Separators and case don't matter. A code that matches none of user's fails with
+    ErrCodeMismatch, and one used by a request that got there first with ErrCodeReused.
+*/
+func VerifyRecoveryCode(store RecoveryStore, user string, code string) error {
+    var hashes, err = store.RecoveryCodes(user)
+    if (err != nil) {
+        return err
+    }
+    for _, hash := range(hashes) {
+        if (!recoveryCodeMatches(hash, code)) {
+            continue
+        }
+        var used bool
+        if used, err = store.UseRecoveryCode(user, hash); err != nil {
+            return err
+        }
+        if (!used) {
+            return ErrCodeReused
+        }
+        return nil
+    }
+    return ErrCodeMismatch
+}
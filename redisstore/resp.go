@@ -0,0 +1,151 @@
+package redisstore
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "strconv"
+    "time"
+)
+
+/*
+    A minimal RESP2 client
+
+    Just enough of the Redis protocol for the store: commands go out as arrays of bulk
+    strings and replies come back as simple strings, errors, integers, bulk strings or
+    arrays of those.
+*/
+
+// redisError is an error reply from the server, e.g. "WRONGTYPE ..."
+type redisError string
+
+func (e redisError) Error() string {
+    return "redis: " + string(e)
+}
+
+// errNil is a nil bulk string or array reply, e.g. GET of a missing key or an aborted EXEC
+var errNil error = errors.New("redis: nil reply")
+
+// conn is one connection to the server
+type conn struct {
+    nc      net.Conn
+    r       *bufio.Reader
+    w       *bufio.Writer
+    timeout time.Duration
+}
+
+// dial connects and runs AUTH and SELECT if they're needed
+func dial(addr string, opts Options) (*conn, error) {
+    var nc, err = net.DialTimeout("tcp", addr, opts.Timeout)
+    if (err != nil) {
+        return nil, err
+    }
+    var c *conn = &conn{nc: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc), timeout: opts.Timeout}
+    if (opts.Password != "") {
+        if _, err = c.do("AUTH", opts.Password); err != nil {
+            nc.Close()
+            return nil, err
+        }
+    }
+    if (opts.DB != 0) {
+        if _, err = c.do("SELECT", strconv.Itoa(opts.DB)); err != nil {
+            nc.Close()
+            return nil, err
+        }
+    }
+    return c, nil
+}
+
+// do sends one command and reads its reply
+func (c *conn) do(args ...string) (any, error) {
+    c.nc.SetDeadline(time.Now().Add(c.timeout))
+    fmt.Fprintf(c.w, "*%d\r\n", len(args))
+    for _, a := range(args) {
+        fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(a), a)
+    }
+    if err := c.w.Flush(); err != nil {
+        return nil, err
+    }
+    return c.read()
+}
+
+// line reads one CRLF terminated line without the CRLF
+func (c *conn) line() (string, error) {
+    var s, err = c.r.ReadString('\n')
+    if (err != nil) {
+        return "", err
+    }
+    if (len(s) < 2 || s[len(s) - 2] != '\r') {
+        return "", errors.New("redis: malformed reply")
+    }
+    return s[:len(s) - 2], nil
+}
+
+/*
+    read reads one reply
+
+    Simple strings and bulk strings come back as string, integers as int64 and arrays
+    as []any. Error replies are a redisError and nil replies errNil.
+*/
+func (c *conn) read() (any, error) {
+    var s, err = c.line()
+    if (err != nil) {
+        return nil, err
+    }
+    if (len(s) == 0) {
+        return nil, errors.New("redis: empty reply")
+    }
+
+    switch s[0] {
+    case '+':
+        return s[1:], nil
+    case '-':
+        return nil, redisError(s[1:])
+    case ':':
+        return strconv.ParseInt(s[1:], 10, 64)
+    case '$':
+        var n, err = strconv.Atoi(s[1:])
+        if (err != nil) {
+            return nil, err
+        }
+        if (n < 0) {
+            return nil, errNil
+        }
+        var b []byte = make([]byte, n + 2)
+        if _, err = io.ReadFull(c.r, b); err != nil {
+            return nil, err
+        }
+        return string(b[:n]), nil
+    case '*':
+        var n, err = strconv.Atoi(s[1:])
+        if (err != nil) {
+            return nil, err
+        }
+        if (n < 0) {
+            return nil, errNil
+        }
+        var items []any = make([]any, n)
+        for i := range(items) {
+            // an error inside an array (from EXEC) is kept as the item, not returned
+            var item, err = c.read()
+            if (err != nil && !isReplyError(err)) {
+                return nil, err
+            }
+            if (err != nil) {
+                item = err
+            }
+            items[i] = item
+        }
+        return items, nil
+    default:
+        return nil, fmt.Errorf("redis: unknown reply type %q", s[0])
+    }
+}
+
+// isReplyError reports whether err came from the server rather than the connection
+func isReplyError(err error) bool {
+    var re redisError
+    return errors.As(err, &re) || errors.Is(err, errNil)
+}
@@ -0,0 +1,502 @@
+/*
+    Package redisstore keeps OTP keys and the state around verifying them in Redis
+
+    A Store is an otp.KeyStore, otp.KeyUpdater, otp.ReplayStore, otp.LockoutStore,
+    otp.DriftStore, otp.CounterStore and otp.RecoveryStore, so several instances of a
+    service can share keys, HOTP counters, replay protection, lockouts, drift, token
+    counters and recovery codes. Keys
+    are stored as their otpauth:// URI under PREFIX:key:NAME with the names in the set
+    PREFIX:names. Used TOTP steps are PREFIX:used:USER:STEP, set with NX and a TTL so
+    they clean themselves up, and failure counts (PREFIX:failures:USER) and lockouts
+    (PREFIX:lockout:USER) expire the same way. Drift is kept in PREFIX:drift:USER,
+    token counters in PREFIX:counter:NAME and recovery code hashes in the set
+    PREFIX:recovery:USER. Update uses WATCH/MULTI/EXEC, retrying if another
+    instance changed the key first.
+
+    It talks RESP directly over TCP and needs no client library.
+*/
+package redisstore
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    otp "github.com/adam-good/OTP"
+)
+
+// Options configures a Store; the zero value works for a local Redis
+type Options struct {
+    Password    string          // for AUTH, empty means none
+    DB          int             // database number to SELECT
+    Prefix      string          // key prefix, default "otp"
+    ReplayTTL   time.Duration   // how long used steps are remembered, default 10 minutes
+    PoolSize    int             // idle connections kept, default 4
+    Timeout     time.Duration   // dial and per-command timeout, default 5 seconds
+    Clock       otp.Clock       // for lockout times, nil means the system clock; TTLs run on the server's clock
+}
+
+// withDefaults fills in the zero fields of opts
+func (opts Options) withDefaults() Options {
+    if (opts.Prefix == "") {
+        opts.Prefix = "otp"
+    }
+    if (opts.ReplayTTL <= 0) {
+        opts.ReplayTTL = 10 * time.Minute
+    }
+    if (opts.PoolSize <= 0) {
+        opts.PoolSize = 4
+    }
+    if (opts.Timeout <= 0) {
+        opts.Timeout = 5 * time.Second
+    }
+    if (opts.Clock == nil) {
+        opts.Clock = otp.SystemClock{}
+    }
+    return opts
+}
+
+// lockoutMemory is how long a lockout is kept after it ends
+const lockoutMemory time.Duration = 24 * time.Hour
+
+// maxUpdateRetries bounds how often Update retries after losing a race
+const maxUpdateRetries int = 16
+
+// ErrConflict is returned by Update when the key kept changing under it
+var ErrConflict error = errors.New("redisstore: key changed concurrently too many times")
+
+// Store is a connection pool to one Redis server
+type Store struct {
+    addr    string
+    opts    Options
+    pool    chan *conn
+}
+
+// New returns a Store for the Redis server at addr (host:port); connections are made as they're needed
+func New(addr string, opts Options) *Store {
+    opts = opts.withDefaults()
+    return &Store{addr: addr, opts: opts, pool: make(chan *conn, opts.PoolSize)}
+}
+
+// get takes an idle connection or dials a new one
+func (s *Store) get() (*conn, error) {
+    select {
+    case c := <-s.pool:
+        return c, nil
+    default:
+        return dial(s.addr, s.opts)
+    }
+}
+
+// put returns a connection to the pool, or closes it if err means it can't be trusted any more
+func (s *Store) put(c *conn, err error) {
+    if (err != nil && !isReplyError(err)) {
+        c.nc.Close()
+        return
+    }
+    select {
+    case s.pool <- c:
+    default:
+        c.nc.Close()
+    }
+}
+
+// do runs one command on a pooled connection
+func (s *Store) do(args ...string) (any, error) {
+    var c, err = s.get()
+    if (err != nil) {
+        return nil, err
+    }
+    var reply any
+    reply, err = c.do(args...)
+    s.put(c, err)
+    return reply, err
+}
+
+// Ping checks the server is reachable
+func (s *Store) Ping() error {
+    var _, err = s.do("PING")
+    return err
+}
+
+// Close closes the idle connections
+func (s *Store) Close() error {
+    for {
+        select {
+        case c := <-s.pool:
+            c.nc.Close()
+        default:
+            return nil
+        }
+    }
+}
+
+func (s *Store) keyName(name string) string {
+    return s.opts.Prefix + ":key:" + name
+}
+
+func (s *Store) namesKey() string {
+    return s.opts.Prefix + ":names"
+}
+
+func (s *Store) usedKey(user string, step int64) string {
+    return s.opts.Prefix + ":used:" + user + ":" + strconv.FormatInt(step, 10)
+}
+
+func (s *Store) failuresKey(user string) string {
+    return s.opts.Prefix + ":failures:" + user
+}
+
+func (s *Store) lockoutKey(user string) string {
+    return s.opts.Prefix + ":lockout:" + user
+}
+
+func (s *Store) driftKey(user string) string {
+    return s.opts.Prefix + ":drift:" + user
+}
+
+func (s *Store) counterKey(name string) string {
+    return s.opts.Prefix + ":counter:" + name
+}
+
+func (s *Store) recoveryKey(user string) string {
+    return s.opts.Prefix + ":recovery:" + user
+}
+
+// Get returns the key stored under name, or otp.ErrKeyNotFound
+func (s *Store) Get(name string) (*otp.Key, error) {
+    var reply, err = s.do("GET", s.keyName(name))
+    if (errors.Is(err, errNil)) {
+        return nil, fmt.Errorf("%w: %s", otp.ErrKeyNotFound, name)
+    } else if (err != nil) {
+        return nil, err
+    }
+    return otp.ParseURI(reply.(string))
+}
+
+// Put stores k under name, replacing anything already there
+func (s *Store) Put(name string, k *otp.Key) error {
+    var uri, err = k.URI()
+    if (err != nil) {
+        return err
+    }
+    var c *conn
+    if c, err = s.get(); err != nil {
+        return err
+    }
+    // key and name set change together
+    if _, err = c.do("MULTI"); err == nil {
+        c.do("SET", s.keyName(name), uri)
+        c.do("SADD", s.namesKey(), name)
+        _, err = c.do("EXEC")
+    }
+    s.put(c, err)
+    return err
+}
+
+// Remove deletes the key stored under name, or fails with otp.ErrKeyNotFound
+func (s *Store) Remove(name string) error {
+    var reply, err = s.do("DEL", s.keyName(name))
+    if (err != nil) {
+        return err
+    }
+    if _, err = s.do("SREM", s.namesKey(), name); err != nil {
+        return err
+    }
+    if (reply.(int64) == 0) {
+        return fmt.Errorf("%w: %s", otp.ErrKeyNotFound, name)
+    }
+    return nil
+}
+
+// Names lists the stored names in sorted order
+func (s *Store) Names() ([]string, error) {
+    var reply, err = s.do("SMEMBERS", s.namesKey())
+    if (err != nil) {
+        return nil, err
+    }
+    var names []string
+    for _, item := range(reply.([]any)) {
+        if name, ok := item.(string); ok {
+            names = append(names, name)
+        }
+    }
+    sort.Strings(names)
+    return names, nil
+}
+
+/*
+    Update calls fn on the key stored under name and stores the result atomically
+
+    The key is WATCHed while fn runs; if another client changes it before EXEC the
+    transaction is dropped and Update starts again with the new value.
+*/
+func (s *Store) Update(name string, fn func(k *otp.Key) error) error {
+    var c, err = s.get()
+    if (err != nil) {
+        return err
+    }
+    // only a failure talking to the server means the connection can't go back in the pool
+    var connErr error
+    defer func() {
+        s.put(c, connErr)
+    }()
+
+    for range(maxUpdateRetries) {
+        if _, connErr = c.do("WATCH", s.keyName(name)); connErr != nil {
+            return connErr
+        }
+        var reply any
+        if reply, connErr = c.do("GET", s.keyName(name)); errors.Is(connErr, errNil) {
+            _, connErr = c.do("UNWATCH")
+            return fmt.Errorf("%w: %s", otp.ErrKeyNotFound, name)
+        } else if (connErr != nil) {
+            return connErr
+        }
+
+        var k *otp.Key
+        var uri string
+        if k, err = otp.ParseURI(reply.(string)); err == nil {
+            err = fn(k)
+        }
+        if (err == nil) {
+            uri, err = k.URI()
+        }
+        if (err != nil) {
+            _, connErr = c.do("UNWATCH")
+            return err
+        }
+
+        if _, connErr = c.do("MULTI"); connErr != nil {
+            return connErr
+        }
+        if _, connErr = c.do("SET", s.keyName(name), uri); connErr != nil {
+            return connErr
+        }
+        if _, connErr = c.do("EXEC"); errors.Is(connErr, errNil) {
+            // someone else changed it first, start again from their value
+            continue
+        }
+        return connErr
+    }
+    // not errNil from the last EXEC, or put would think the connection was fine
+    connErr = ErrConflict
+    return connErr
+}
+
+// IsUsed reports whether user has already used the code for step
+func (s *Store) IsUsed(user string, step int64) (bool, error) {
+    var reply, err = s.do("EXISTS", s.usedKey(user, step))
+    if (err != nil) {
+        return false, err
+    }
+    return reply.(int64) == 1, nil
+}
+
+// MarkUsed records that user has used the code for step, or fails with otp.ErrCodeReused if they already had
+func (s *Store) MarkUsed(user string, step int64) error {
+    var ttl string = strconv.FormatInt(s.opts.ReplayTTL.Milliseconds(), 10)
+    var _, err = s.do("SET", s.usedKey(user, step), "1", "NX", "PX", ttl)
+    if (errors.Is(err, errNil)) {
+        return otp.ErrCodeReused
+    }
+    return err
+}
+
+/*
+    RecordFailure counts a failed verification for user and returns the count in the current window
+
+    The counter is created with the window as its TTL and INCR keeps the TTL, so the
+    window runs from the first failure and the count starts again once it expires.
+*/
+func (s *Store) RecordFailure(user string, window time.Duration) (int, error) {
+    var c, err = s.get()
+    if (err != nil) {
+        return 0, err
+    }
+    var reply any
+    if _, err = c.do("MULTI"); err == nil {
+        c.do("SET", s.failuresKey(user), "0", "NX", "PX", strconv.FormatInt(max(window.Milliseconds(), 1), 10))
+        c.do("INCR", s.failuresKey(user))
+        reply, err = c.do("EXEC")
+    }
+    s.put(c, err)
+    if (err != nil) {
+        return 0, err
+    }
+    var items []any = reply.([]any)
+    if (len(items) != 2) {
+        return 0, errors.New("redis: unexpected EXEC reply")
+    }
+    var n, ok = items[1].(int64)
+    if (!ok) {
+        return 0, fmt.Errorf("redis: INCR failed: %v", items[1])
+    }
+    return int(n), nil
+}
+
+// ResetFailures clears user's failure count and lockouts
+func (s *Store) ResetFailures(user string) error {
+    var _, err = s.do("DEL", s.failuresKey(user), s.lockoutKey(user))
+    return err
+}
+
+// Lockout returns when user's lockout ends and how many they've had, see otp.LockoutStore
+func (s *Store) Lockout(user string) (time.Time, int, error) {
+    var reply, err = s.do("GET", s.lockoutKey(user))
+    if (errors.Is(err, errNil)) {
+        return time.Time{}, 0, nil
+    } else if (err != nil) {
+        return time.Time{}, 0, err
+    }
+    // stored as UNTIL_MS:LOCKOUTS
+    var until, lockouts, _ = strings.Cut(reply.(string), ":")
+    var ms int64
+    var n int
+    if ms, err = strconv.ParseInt(until, 10, 64); err == nil {
+        n, err = strconv.Atoi(lockouts)
+    }
+    if (err != nil) {
+        return time.Time{}, 0, fmt.Errorf("redisstore: bad lockout for %s: %w", user, err)
+    }
+    return time.UnixMilli(ms), n, nil
+}
+
+// SetLockout locks user out until until and records it as their lockouts'th lockout
+func (s *Store) SetLockout(user string, until time.Time, lockouts int) error {
+    var ttl time.Duration = until.Sub(s.opts.Clock.Now()) + lockoutMemory
+    var value string = strconv.FormatInt(until.UnixMilli(), 10) + ":" + strconv.Itoa(lockouts)
+    var _, err = s.do("SET", s.lockoutKey(user), value, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+    return err
+}
+
+// Drift returns user's recorded clock drift in time steps, see otp.DriftStore
+func (s *Store) Drift(user string) (int64, error) {
+    var reply, err = s.do("GET", s.driftKey(user))
+    if (errors.Is(err, errNil)) {
+        return 0, nil
+    } else if (err != nil) {
+        return 0, err
+    }
+    var steps int64
+    if steps, err = strconv.ParseInt(reply.(string), 10, 64); err != nil {
+        return 0, fmt.Errorf("redisstore: bad drift for %s: %w", user, err)
+    }
+    return steps, nil
+}
+
+// SetDrift records user's clock drift
+func (s *Store) SetDrift(user string, steps int64) error {
+    if (steps == 0) {
+        var _, err = s.do("DEL", s.driftKey(user))
+        return err
+    }
+    var _, err = s.do("SET", s.driftKey(user), strconv.FormatInt(steps, 10))
+    return err
+}
+
+// AdvanceCounter records counter for name if it's past the one recorded, with WATCH like Update, see otp.CounterStore
+func (s *Store) AdvanceCounter(name string, counter uint64) (bool, error) {
+    var c, err = s.get()
+    if (err != nil) {
+        return false, err
+    }
+    defer func() {
+        s.put(c, err)
+    }()
+
+    for range(maxUpdateRetries) {
+        if _, err = c.do("WATCH", s.counterKey(name)); err != nil {
+            return false, err
+        }
+        var reply any
+        if reply, err = c.do("GET", s.counterKey(name)); err != nil && !errors.Is(err, errNil) {
+            return false, err
+        } else if (err == nil) {
+            var last, perr = strconv.ParseUint(reply.(string), 10, 64)
+            if (perr != nil || counter <= last) {
+                _, err = c.do("UNWATCH")
+                if (perr != nil) {
+                    return false, fmt.Errorf("redisstore: bad counter for %s: %w", name, perr)
+                }
+                return false, err
+            }
+        }
+
+        if _, err = c.do("MULTI"); err != nil {
+            return false, err
+        }
+        if _, err = c.do("SET", s.counterKey(name), strconv.FormatUint(counter, 10)); err != nil {
+            return false, err
+        }
+        if _, err = c.do("EXEC"); errors.Is(err, errNil) {
+            // someone else moved it first, look again
+            continue
+        }
+        return err == nil, err
+    }
+    // as in Update, so put doesn't take the last EXEC's errNil for success
+    err = ErrConflict
+    return false, err
+}
+
+// RecoveryCodes returns user's recovery code hashes, see otp.RecoveryStore
+func (s *Store) RecoveryCodes(user string) ([]string, error) {
+    var reply, err = s.do("SMEMBERS", s.recoveryKey(user))
+    if (err != nil) {
+        return nil, err
+    }
+    var hashes []string
+    for _, item := range(reply.([]any)) {
+        hashes = append(hashes, item.(string))
+    }
+    return hashes, nil
+}
+
+// SetRecoveryCodes replaces user's recovery code hashes in one MULTI/EXEC
+func (s *Store) SetRecoveryCodes(user string, hashes []string) error {
+    var c, err = s.get()
+    if (err != nil) {
+        return err
+    }
+    defer func() {
+        s.put(c, err)
+    }()
+
+    if _, err = c.do("MULTI"); err != nil {
+        return err
+    }
+    if _, err = c.do("DEL", s.recoveryKey(user)); err != nil {
+        return err
+    }
+    if (len(hashes) > 0) {
+        if _, err = c.do(append([]string{"SADD", s.recoveryKey(user)}, hashes...)...); err != nil {
+            return err
+        }
+    }
+    _, err = c.do("EXEC")
+    return err
+}
+
+// UseRecoveryCode removes hash from user's recovery codes, reporting whether it was there
+func (s *Store) UseRecoveryCode(user string, hash string) (bool, error) {
+    var reply, err = s.do("SREM", s.recoveryKey(user), hash)
+    if (err != nil) {
+        return false, err
+    }
+    return reply.(int64) == 1, nil
+}
+
+// the store implements all of the store interfaces
+var (
+    _ otp.KeyStore      = (*Store)(nil)
+    _ otp.KeyUpdater    = (*Store)(nil)
+    _ otp.ReplayStore   = (*Store)(nil)
+    _ otp.LockoutStore  = (*Store)(nil)
+    _ otp.DriftStore    = (*Store)(nil)
+    _ otp.CounterStore  = (*Store)(nil)
+    _ otp.RecoveryStore = (*Store)(nil)
+)
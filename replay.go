@@ -0,0 +1,22 @@
+package otp
+
+/*
+    ReplayStore remembers which TOTP codes have been used
+
+    A TOTP code stays valid for its whole time step (and longer with skew), so someone
+    who sees a code being typed can use it again straight away. With a ReplayStore in
+    ValidateOpts (or on a Key, see WithReplayStore) a successful validation marks the
+    matched step as used for that user and any later attempt at the same step fails
+    with ErrCodeReused.
+
+    Entries only need to be kept for (2 * Skew + 1) periods, after that the step is
+    out of the window anyway.
+*/
+type ReplayStore interface {
+    // IsUsed reports whether user has already used the code for step
+    IsUsed(user string, step int64) (bool, error)
+    // MarkUsed records that user has used the code for step. It must be atomic and fail
+    // with ErrCodeReused if the step was already marked, which is what stops two
+    // concurrent requests with the same code from both getting in.
+    MarkUsed(user string, step int64) error
+}
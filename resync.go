@@ -0,0 +1,116 @@
+package otp
+
+import (
+    "time"
+)
+
+// DefaultResyncWindow is how far ahead of the stored counter Key.Resync looks
+const DefaultResyncWindow int = 1000
+
+/*
+    ResyncHOTP finds a run of consecutive HOTP codes far ahead of counter
+
+    A token whose button has been pressed more times than the normal look-ahead window
+    allows is out of sync. RFC 4226 section 7.4 resynchronizes it by having the user
+    submit two or three codes in a row and searching a much larger window for them: a
+    single code found that far out could be a guess, several consecutive ones can't.
+    The run must start within window counters of counter and every code must match,
+    in order. The counter after the last code is returned, ready to be stored.
+
+    It fails with ErrResyncCodes given fewer than two codes and ErrCodeMismatch when no
+    run matches.
+*/
+func ResyncHOTP(key []byte, codes []string, counter uint64, window int, digits int, algo Algorithm) (uint64, error) {
+    var start time.Time = time.Now()
+    var next, err = resyncHOTP(key, codes, counter, window, digits, algo, Truncation{}, Lenient)
+    observeVerify("", start, err)
+    return next, err
+}
+
+// resyncHOTP is ResyncHOTP without the observer
+func resyncHOTP(key []byte, codes []string, counter uint64, window int, digits int, algo Algorithm, trunc Truncation, policy NormalizationPolicy) (uint64, error) {
+    if (len(codes) < 2) {
+        return 0, ErrResyncCodes
+    }
+    if (window < 0) {
+        window = 0
+    }
+
+    // every counter a run could cover, generated once
+    var expected []Code = make([]Code, 0, window + len(codes))
+    for i := 0; i < window + len(codes); i++ {
+        var c uint64 = counter + uint64(i)
+        if (c < counter) {
+            // ran past the end of the counter space
+            break
+        }
+        var code, err = generateHOTP(key, c, digits, algo, trunc)
+        if (err != nil) {
+            return 0, err
+        }
+        expected = append(expected, code)
+    }
+
+    var matched uint64
+    var ok bool
+    // like validateHOTP, the whole window is checked so the timing doesn't show where the run was
+    for i := 0; i + len(codes) <= len(expected); i++ {
+        var run bool = true
+        for j, code := range(codes) {
+            run = codeMatches(expected[i + j], code, policy) && run
+        }
+        if (run && !ok) {
+            matched, ok = counter + uint64(i + len(codes) - 1), true
+        }
+    }
+    if (!ok) {
+        return 0, ErrCodeMismatch
+    }
+    return matched + 1, nil
+}
+
+/*
+    Resync fast-forwards an HOTP key's counter to a run of consecutive codes
+
+    codes are two or three codes the token showed one after another. They're searched
+    for up to DefaultResyncWindow counters ahead, see ResyncHOTP, and on a match the
+    counter moves past the last of them, so none can be used again; save the key
+    afterwards. A failed resync counts against the key's Lockout like any wrong code.
+    It fails with ErrNotHOTP for a TOTP key.
+*/
+func (k *Key) Resync(codes ...string) error {
+    if (k.Type != TypeHOTP) {
+        return ErrNotHOTP
+    }
+    var secret, done, err = k.secret()
+    if (err != nil) {
+        return err
+    }
+    defer done()
+    var start time.Time = time.Now()
+    var offset int64
+    err = k.Lockout.Guard(k.User, func() error {
+        if (k.Checksum) {
+            var stripped []string = make([]string, len(codes))
+            for i, code := range(codes) {
+                var ok bool
+                if stripped[i], ok = stripChecksum(code, k.Normalization); !ok {
+                    return ErrCodeMismatch
+                }
+            }
+            codes = stripped
+        }
+        var next, err = resyncHOTP(secret, codes, k.Counter, DefaultResyncWindow, k.Digits, k.Algorithm, k.Truncation, k.Normalization)
+        if (err != nil) {
+            return err
+        }
+        offset = int64(next - 1 - k.Counter)
+        k.Counter = next
+        return nil
+    })
+    observeVerify(k.User, start, err)
+    if (k.Audit != nil) {
+        audit(k.Audit, clockNow(k.Clock), k.User, TypeHOTP, k.Algorithm, fingerprint(TypeHOTP, k.Algorithm, k.Digits, 0, time.Time{}, k.Truncation, secret), offset, err)
+    }
+    return err
+}
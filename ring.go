@@ -0,0 +1,104 @@
+package otp
+
+import (
+    "sync"
+    "time"
+)
+
+// ringEntry is one secret in a SecretRing and when it was added
+type ringEntry struct {
+    secret  []byte
+    added   time.Time
+}
+
+/*
+    SecretRing holds the last few secrets of a rotating credential
+
+    During a rotation a user's authenticator may still have the old secret, so the server
+    keeps the most recent secrets around and accepts codes from any of them. Rotate
+    pushes a new secret in and drops the oldest once the ring is full.
+*/
+type SecretRing struct {
+    mu      sync.RWMutex
+    size    int
+    entries []ringEntry // oldest first
+    clock   Clock
+}
+
+// NewSecretRing creates an empty ring that holds up to size secrets
+func NewSecretRing(size int) *SecretRing {
+    if (size < 1) {
+        size = 1
+    }
+    return &SecretRing{size: size}
+}
+
+// SetClock has the ring read the time from clock, nil for the system clock
+func (r *SecretRing) SetClock(clock Clock) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.clock = clock
+}
+
+// Rotate adds secret as the newest secret, evicting the oldest one if the ring is full
+func (r *SecretRing) Rotate(secret []byte) error {
+    if (len(secret) == 0) {
+        return ErrEmptySecret
+    }
+    var s []byte = make([]byte, len(secret))
+    copy(s, secret)
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    r.entries = append(r.entries, ringEntry{secret: s, added: clockNow(r.clock)})
+    if (len(r.entries) > r.size) {
+        // zero the evicted secret so it doesn't hang around in memory
+        for i := range(r.entries[0].secret) {
+            r.entries[0].secret[i] = 0
+        }
+        r.entries = r.entries[1:]
+    }
+    return nil
+}
+
+// Added returns when each secret in the ring was added, oldest first
+func (r *SecretRing) Added() []time.Time {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    var times []time.Time = make([]time.Time, len(r.entries))
+    for i, e := range(r.entries) {
+        times[i] = e.added
+    }
+    return times
+}
+
+/*
+    Validate checks code against every secret in the ring
+
+    Each secret is tried at the current time step and up to window steps either side.
+    Every candidate is compared, even after a match, so the time taken doesn't reveal
+    which secret (or whether any) matched. A code matching none of them fails with
+    ErrCodeMismatch.
+*/
+func (r *SecretRing) Validate(code string, window int) error {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    var step int64 = timeStep(clockNow(r.clock), DefaultPeriod)
+    var match bool = false
+    for _, e := range(r.entries) {
+        for i := -window; i <= window; i++ {
+            // Rotate only lets in non-empty secrets so this can't fail
+            var expected, _ = totpStep(e.secret, step + int64(i))
+            if (codeMatches(expected, code, Lenient)) {
+                match = true
+            }
+        }
+    }
+    if (!match) {
+        return ErrCodeMismatch
+    }
+    return nil
+}
@@ -0,0 +1,68 @@
+package otp
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestSecretRing(t *testing.T) {
+    var at time.Time = time.Unix(59, 0)
+    var first, second, third []byte = []byte("first secret 0123456"), []byte("second secret 012345"), []byte("third secret 0123456")
+    var ring *SecretRing = NewSecretRing(2)
+    ring.SetClock(FixedClock(at))
+    if err := ring.Rotate(nil); !errors.Is(err, ErrEmptySecret) {
+        t.Errorf("empty secret: %v, want %v", err, ErrEmptySecret)
+    }
+    for _, secret := range([][]byte{first, second, third}) {
+        if err := ring.Rotate(secret); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    var code = func(secret []byte, offset int64) string {
+        var c, err = totpStep(secret, timeStep(at, DefaultPeriod) + offset)
+        if (err != nil) {
+            t.Fatal(err)
+        }
+        return c.String()
+    }
+    var tests = []struct {
+        name    string
+        code    string
+        window  int
+        err     error
+    }{
+        {"newest secret", code(third, 0), 0, nil},
+        {"previous secret", code(second, 0), 0, nil},
+        {"evicted secret", code(first, 0), 0, ErrCodeMismatch},
+        {"next step in the window", code(second, 1), 1, nil},
+        {"next step outside the window", code(second, 1), 0, ErrCodeMismatch},
+        {"previous step in the window", code(third, -1), 1, nil},
+        {"wrong code", "000000", 1, ErrCodeMismatch},
+    }
+    for _, tt := range(tests) {
+        if err := ring.Validate(tt.code, tt.window); !errors.Is(err, tt.err) {
+            t.Errorf("%s: %v, want %v", tt.name, err, tt.err)
+        }
+    }
+
+    if added := ring.Added(); len(added) != 2 || !added[0].Equal(at) || !added[1].Equal(at) {
+        t.Errorf("Added: %v, want two secrets added at %v", added, at)
+    }
+}
+
+func TestSecretRingCopiesSecret(t *testing.T) {
+    var secret []byte = []byte("copied secret 012345")
+    var want string
+    var ring *SecretRing = NewSecretRing(1)
+    ring.SetClock(FixedClock(time.Unix(59, 0)))
+    if c, err := totpStep(secret, 1); err == nil {
+        want = c.String()
+    }
+    ring.Rotate(secret)
+    secret[0] ^= 0xFF
+    if err := ring.Validate(want, 0); err != nil {
+        t.Errorf("after changing the caller's slice: %v", err)
+    }
+}
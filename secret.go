@@ -0,0 +1,118 @@
+package otp
+
+import (
+    "crypto/rand"
+    "encoding/base32"
+    "encoding/base64"
+    "encoding/hex"
+    "fmt"
+    "strings"
+    "unicode"
+)
+
+/*
+    SecretLength is the recommended secret length in bytes for an algorithm
+
+    That's the size of the hash output: 20 bytes (160 bits, the RFC 4226 recommendation)
+    for SHA1, 32 for SHA256 and 64 for SHA512.
+*/
+func SecretLength(algo Algorithm) int {
+    return algo.Hash()().Size()
+}
+
+/*
+    GenerateSecret returns length random bytes from crypto/rand to use as a shared secret
+
+    A length of 0 or less means SecretLength(SHA1); use SecretLength for the other hashes.
+*/
+func GenerateSecret(length int) ([]byte, error) {
+    if (length <= 0) {
+        length = SecretLength(SHA1)
+    }
+    var secret []byte = make([]byte, length)
+    if _, err := rand.Read(secret); err != nil {
+        return nil, err
+    }
+    return secret, nil
+}
+
+/*
+    Wipe overwrites b with zeros
+
+    Use it on secrets once they're done with, so they don't sit in memory until the
+    garbage collector gets round to reusing it. It only reaches b itself; copies made
+    by appending to or converting it are separate and need wiping on their own.
+*/
+func Wipe(b []byte) {
+    clear(b)
+}
+
+/*
+    Secret encodings
+
+    Authenticator apps exchange secrets as Base32 without padding, hardware token seed
+    files usually use hex or base64. The decoders are forgiving about what people paste:
+    whitespace is ignored everywhere, Base32 and hex also ignore dashes and case, and
+    padding is optional. Bad input fails with an error wrapping ErrInvalidEncoding.
+*/
+
+var base32NoPad = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// EncodeBase32 encodes a secret the way otpauth:// URIs carry it: upper case, no padding
+func EncodeBase32(secret []byte) string {
+    return base32NoPad.EncodeToString(secret)
+}
+
+// DecodeBase32 decodes a user supplied Base32 secret
+func DecodeBase32(s string) ([]byte, error) {
+    s = strings.ReplaceAll(cleanSecret(s), "-", "")
+    s = strings.TrimRight(strings.ToUpper(s), "=")
+    var secret, err = base32NoPad.DecodeString(s)
+    if (err != nil) {
+        return nil, fmt.Errorf("%w: base32: %v", ErrInvalidEncoding, err)
+    }
+    return secret, nil
+}
+
+// EncodeHex encodes a secret as lower case hex
+func EncodeHex(secret []byte) string {
+    return hex.EncodeToString(secret)
+}
+
+// DecodeHex decodes a hex secret, with or without a leading 0x
+func DecodeHex(s string) ([]byte, error) {
+    s = strings.ToLower(cleanSecret(s))
+    s = strings.TrimPrefix(s, "0x")
+    s = strings.ReplaceAll(s, "-", "")
+    var secret, err = hex.DecodeString(s)
+    if (err != nil) {
+        return nil, fmt.Errorf("%w: hex: %v", ErrInvalidEncoding, err)
+    }
+    return secret, nil
+}
+
+// EncodeBase64 encodes a secret as standard padded base64
+func EncodeBase64(secret []byte) string {
+    return base64.StdEncoding.EncodeToString(secret)
+}
+
+// DecodeBase64 decodes a base64 secret in either the standard or URL alphabet
+func DecodeBase64(s string) ([]byte, error) {
+    s = strings.TrimRight(cleanSecret(s), "=")
+    s = strings.NewReplacer("-", "+", "_", "/").Replace(s)
+    var secret, err = base64.RawStdEncoding.DecodeString(s)
+    if (err != nil) {
+        return nil, fmt.Errorf("%w: base64: %v", ErrInvalidEncoding, err)
+    }
+    return secret, nil
+}
+
+// cleanSecret drops the whitespace people paste along with a secret
+func cleanSecret(s string) string {
+    return strings.Map(func(r rune) rune {
+        if (unicode.IsSpace(r)) {
+            return -1
+        }
+        return r
+    }, s)
+}
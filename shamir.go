@@ -0,0 +1,126 @@
+package otp
+
+import (
+    "crypto/rand"
+    "fmt"
+)
+
+/*
+    Shamir secret sharing
+
+    SplitSecret cuts a secret into n shares so that any k of them put it back together
+    and k-1 say nothing at all about it, for escrowing seeds with several custodians
+    none of whom can use one alone. Each byte of the secret is the constant term of its
+    own random polynomial of degree k-1 over GF(2^8), and share x holds every
+    polynomial's value at x. CombineShares interpolates them back at 0.
+
+    A share is its x coordinate (1 to 255) followed by one byte per byte of the
+    secret; write it down with EncodeBase32 or EncodeHex like any other secret.
+*/
+
+// shamirMul multiplies in GF(2^8) with the AES polynomial, without tables so the timing doesn't depend on the values
+func shamirMul(a byte, b byte) byte {
+    var p byte
+    for i := 0; i < 8; i++ {
+        // p ^= a if the low bit of b is set
+        p ^= a & -(b & 1)
+        // a *= x, reducing by x^8 + x^4 + x^3 + x + 1 when it overflows
+        a = a << 1 ^ 0x1B & -(a >> 7)
+        b >>= 1
+    }
+    return p
+}
+
+// shamirInv is the multiplicative inverse, a^254
+func shamirInv(a byte) byte {
+    var r byte = 1
+    for i := 0; i < 254; i++ {
+        r = shamirMul(r, a)
+    }
+    return r
+}
+
+/*
+    SplitSecret splits secret into n shares, any k of which recover it
+
+    k must be at least 2 and at most n, and n at most 255; anything else fails with
+    ErrInvalidShares.
+*/
+func SplitSecret(secret []byte, n int, k int) ([][]byte, error) {
+    if (len(secret) == 0) {
+        return nil, ErrEmptySecret
+    }
+    if (k < 2 || k > n || n > 255) {
+        return nil, fmt.Errorf("%w: can't make %d shares needing %d", ErrInvalidShares, n, k)
+    }
+
+    var shares [][]byte = make([][]byte, n)
+    for i := range(shares) {
+        shares[i] = make([]byte, len(secret) + 1)
+        shares[i][0] = byte(i + 1)
+    }
+    // coefficients for one byte at a time, the constant term being the secret byte
+    var coefficients []byte = make([]byte, k)
+    defer clear(coefficients)
+    for b := range(secret) {
+        coefficients[0] = secret[b]
+        if _, err := rand.Read(coefficients[1:]); err != nil {
+            return nil, err
+        }
+        for _, share := range(shares) {
+            // Horner's rule
+            var y byte
+            for c := k - 1; c >= 0; c-- {
+                y = shamirMul(y, share[0]) ^ coefficients[c]
+            }
+            share[b + 1] = y
+        }
+    }
+    return shares, nil
+}
+
+/*
+    CombineShares recovers a secret from its shares
+
+    Give it at least the k shares SplitSecret was asked for; fewer don't fail, they
+    just give the wrong secret, so check the result (a key's codes, a fingerprint)
+    before trusting it. Shares of different lengths or with the same x fail with
+    ErrInvalidShares.
+*/
+func CombineShares(shares [][]byte) ([]byte, error) {
+    if (len(shares) < 2) {
+        return nil, fmt.Errorf("%w: need at least two shares", ErrInvalidShares)
+    }
+    var size int = len(shares[0])
+    var seen [256]bool
+    for _, share := range(shares) {
+        if (len(share) != size || size < 2) {
+            return nil, fmt.Errorf("%w: shares are different lengths", ErrInvalidShares)
+        }
+        if (share[0] == 0 || seen[share[0]]) {
+            return nil, fmt.Errorf("%w: share %d is zero or repeated", ErrInvalidShares, share[0])
+        }
+        seen[share[0]] = true
+    }
+
+    // Lagrange basis polynomials at 0: l_i = prod x_j / (x_j - x_i), and - is ^ here
+    var basis []byte = make([]byte, len(shares))
+    for i, si := range(shares) {
+        var num, den byte = 1, 1
+        for j, sj := range(shares) {
+            if (i != j) {
+                num = shamirMul(num, sj[0])
+                den = shamirMul(den, sj[0] ^ si[0])
+            }
+        }
+        basis[i] = shamirMul(num, shamirInv(den))
+    }
+
+    var secret []byte = make([]byte, size - 1)
+    for b := range(secret) {
+        for i, share := range(shares) {
+            secret[b] ^= shamirMul(share[b + 1], basis[i])
+        }
+    }
+    return secret, nil
+}
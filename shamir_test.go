@@ -0,0 +1,38 @@
+package otp
+
+import (
+    "bytes"
+    "testing"
+)
+
+func TestSplitSecretCombines(t *testing.T) {
+    var tests = []struct {
+        n   int
+        k   int
+    }{
+        {2, 2},
+        {3, 2},
+        {5, 3},
+        {5, 5},
+    }
+    for _, tt := range(tests) {
+        var shares, err = SplitSecret(RFC4226Secret, tt.n, tt.k)
+        if (err != nil) {
+            t.Fatalf("%d of %d: %v", tt.k, tt.n, err)
+        }
+        // every run of k shares in a row, wrapping round
+        for first := range(shares) {
+            var some [][]byte
+            for i := range(tt.k) {
+                some = append(some, shares[(first + i) % tt.n])
+            }
+            var secret []byte
+            if secret, err = CombineShares(some); err != nil {
+                t.Fatalf("%d of %d from share %d: %v", tt.k, tt.n, first + 1, err)
+            }
+            if (!bytes.Equal(secret, RFC4226Secret)) {
+                t.Errorf("%d of %d from share %d: got %x", tt.k, tt.n, first + 1, secret)
+            }
+        }
+    }
+}
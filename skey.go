@@ -0,0 +1,255 @@
+package otp
+
+import (
+    "crypto/md5"
+    "crypto/sha1"
+    "crypto/subtle"
+    _ "embed"
+    "encoding/binary"
+    "encoding/hex"
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+/*
+    S/KEY one-time passwords (RFC 2289)
+
+    S/KEY, also known as OPIE or OTP, needs no shared secret on the server. The user's
+    passphrase and a seed are hashed into a 64 bit value, which is hashed again count
+    times; the server keeps only the last password it accepted. The next one the user
+    gives is one hash earlier in the chain, so the server hashes it once and compares,
+    and seeing a password tells nobody what the following one is.
+
+    Passwords are shown as 16 hex digits or as six words from the RFC's dictionary of
+    2048 short words; pass SKeyDictionary wherever words are wanted.
+*/
+
+// SKeyAlgorithm is the hash an S/KEY chain is built with
+type SKeyAlgorithm int
+
+const (
+    SKeyMD5     SKeyAlgorithm = iota     // otp-md5
+    SKeySHA1                            // otp-sha1
+)
+
+// String returns the algorithm's name in an S/KEY challenge, e.g. "otp-md5"
+func (a SKeyAlgorithm) String() string {
+    switch a {
+    case SKeyMD5:
+        return "otp-md5"
+    case SKeySHA1:
+        return "otp-sha1"
+    }
+    return "otp-" + strconv.Itoa(int(a))
+}
+
+//go:embed wordlists/rfc2289.txt
+var skeyDictionary string
+
+// SKeyDictionary is the RFC 2289 appendix D dictionary, in order
+var SKeyDictionary []string = strings.Fields(skeyDictionary)
+
+// skeyWords is how many words the RFC 2289 dictionary has, 11 bits' worth
+const skeyWords int = 2048
+
+/*
+    fold hashes data and folds the digest to 64 bits
+
+    MD5 XORs the two halves of its digest. SHA1 XORs its five 32 bit words down to two
+    and writes them little endian, as RFC 2289 appendix A does.
+*/
+func (a SKeyAlgorithm) fold(data []byte) ([8]byte, error) {
+    var out [8]byte
+    if err := refuseStrict("S/KEY is MD5 or SHA1"); err != nil {
+        return out, err
+    }
+    switch a {
+    case SKeyMD5:
+        var sum [md5.Size]byte = md5.Sum(data)
+        for i := range(out) {
+            out[i] = sum[i] ^ sum[i + 8]
+        }
+    case SKeySHA1:
+        var sum [sha1.Size]byte = sha1.Sum(data)
+        var w [5]uint32
+        for i := range(w) {
+            w[i] = binary.BigEndian.Uint32(sum[4 * i:])
+        }
+        binary.LittleEndian.PutUint32(out[0:], w[0] ^ w[2] ^ w[4])
+        binary.LittleEndian.PutUint32(out[4:], w[1] ^ w[3])
+    default:
+        return out, fmt.Errorf("%w: unknown S/KEY algorithm %d", ErrInvalidAlgorithm, int(a))
+    }
+    return out, nil
+}
+
+/*
+    GenerateSKey returns the S/KEY password at position count in the chain
+
+    The seed is 1 to 16 letters and digits, case insensitive, and the passphrase at least
+    10 characters; anything else fails with ErrInvalidSKey. Position 0 is the folded
+    hash of seed and passphrase and each position after it hashes it once more.
+*/
+func GenerateSKey(algo SKeyAlgorithm, seed string, passphrase string, count int) ([8]byte, error) {
+    if (len(seed) < 1 || len(seed) > 16 || strings.IndexFunc(seed, func(r rune) bool {
+        return !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+    }) >= 0) {
+        return [8]byte{}, fmt.Errorf("%w: the seed must be 1 to 16 letters and digits", ErrInvalidSKey)
+    }
+    if (len(passphrase) < 10) {
+        return [8]byte{}, fmt.Errorf("%w: the passphrase must be at least 10 characters", ErrInvalidSKey)
+    }
+    if (count < 0) {
+        return [8]byte{}, fmt.Errorf("%w: negative count", ErrInvalidSKey)
+    }
+
+    var value, err = algo.fold([]byte(strings.ToLower(seed) + passphrase))
+    for i := 0; i < count && err == nil; i++ {
+        value, err = algo.fold(value[:])
+    }
+    return value, err
+}
+
+// SKeyHex renders an S/KEY password as 16 upper case hex digits
+func SKeyHex(value [8]byte) string {
+    return strings.ToUpper(hex.EncodeToString(value[:]))
+}
+
+// skeyChecksum is the two bit checksum appended to a password's 64 bits, the sum of its bit pairs
+func skeyChecksum(value uint64) uint64 {
+    var sum uint64
+    for i := 0; i < 64; i += 2 {
+        sum += (value >> i) & 3
+    }
+    return sum & 3
+}
+
+/*
+    SKeyWords renders an S/KEY password as six words from dict
+
+    The 64 bits and a 2 bit checksum make six 11 bit indexes into dict, which must be
+    SKeyDictionary for the words to mean anything to other implementations.
+*/
+func SKeyWords(value [8]byte, dict []string) (string, error) {
+    if (len(dict) != skeyWords) {
+        return "", fmt.Errorf("%w: dictionary has %d words, not %d", ErrInvalidSKey, len(dict), skeyWords)
+    }
+    var bits uint64 = binary.BigEndian.Uint64(value[:])
+    var check uint64 = skeyChecksum(bits)
+    var words []string = make([]string, 6)
+    for i := range(words) {
+        // bit 0 of the 66 is the top bit of value, the last two are the checksum
+        var index uint64
+        if (i < 5) {
+            index = (bits >> (53 - 11 * i)) & 0x7FF
+        } else {
+            index = ((bits & 0x1FF) << 2) | check
+        }
+        words[i] = dict[index]
+    }
+    return strings.Join(words, " "), nil
+}
+
+/*
+    ParseSKey reads a password as typed by the user
+
+    Sixteen hex digits are read as hex, spaces allowed; anything else is read as six
+    words from dict, ignoring case, and has to have a good checksum. dict may be nil if
+    only hex is accepted.
+*/
+func ParseSKey(response string, dict []string) ([8]byte, error) {
+    var value [8]byte
+    var compact string = strings.ReplaceAll(strings.TrimSpace(response), " ", "")
+    if (len(compact) == 16) {
+        if b, err := hex.DecodeString(compact); err == nil {
+            copy(value[:], b)
+            return value, nil
+        }
+    }
+
+    var words []string = strings.Fields(response)
+    if (len(words) != 6 || len(dict) != skeyWords) {
+        return value, fmt.Errorf("%w: expected 16 hex digits or six words", ErrInvalidSKey)
+    }
+    var bits uint64
+    var check uint64
+    for i, word := range(words) {
+        var index int = -1
+        for j, w := range(dict) {
+            if (strings.EqualFold(w, word)) {
+                index = j
+                break
+            }
+        }
+        if (index < 0) {
+            return value, fmt.Errorf("%w: %q isn't in the dictionary", ErrInvalidSKey, word)
+        }
+        if (i < 5) {
+            bits |= uint64(index) << (53 - 11 * i)
+        } else {
+            bits |= uint64(index) >> 2
+            check = uint64(index) & 3
+        }
+    }
+    if (skeyChecksum(bits) != check) {
+        return value, fmt.Errorf("%w: bad checksum", ErrInvalidSKey)
+    }
+    binary.BigEndian.PutUint64(value[:], bits)
+    return value, nil
+}
+
+/*
+    SKeyVerifier is the server side of an S/KEY chain
+
+    It holds the last password accepted and its position; a response is accepted if it
+    hashes to that, and then takes its place. Keep the verifier, its fields are all it
+    needs, and refuse further logins once Count reaches 0 until the user sets up a new
+    chain.
+*/
+type SKeyVerifier struct {
+    Algorithm   SKeyAlgorithm
+    Seed        string
+    Count       int     // the position of Last, the next response is at Count-1
+    Last        [8]byte
+}
+
+// NewSKeyVerifier sets up a chain of count passwords from passphrase, the first login uses count-1
+func NewSKeyVerifier(algo SKeyAlgorithm, seed string, passphrase string, count int) (*SKeyVerifier, error) {
+    var last, err = GenerateSKey(algo, seed, passphrase, count)
+    if (err != nil) {
+        return nil, err
+    }
+    return &SKeyVerifier{Algorithm: algo, Seed: strings.ToLower(seed), Count: count, Last: last}, nil
+}
+
+// Challenge is what to show the user, e.g. "otp-md5 98 test"
+func (v *SKeyVerifier) Challenge() string {
+    return fmt.Sprintf("%s %d %s", v.Algorithm, v.Count - 1, v.Seed)
+}
+
+/*
+    Verify checks a response to Challenge, see ParseSKey for what it takes
+
+    On success the verifier moves to the response, so it can't be used again. A
+    response that doesn't hash to Last fails with ErrCodeMismatch, and a chain that's
+    run out with ErrChainExhausted.
+*/
+func (v *SKeyVerifier) Verify(response string, dict []string) error {
+    if (v.Count <= 0) {
+        return ErrChainExhausted
+    }
+    var value, err = ParseSKey(response, dict)
+    if (err != nil) {
+        return err
+    }
+    var next [8]byte
+    if next, err = v.Algorithm.fold(value[:]); err != nil {
+        return err
+    }
+    if (subtle.ConstantTimeCompare(next[:], v.Last[:]) != 1) {
+        return ErrCodeMismatch
+    }
+    v.Last, v.Count = value, v.Count - 1
+    return nil
+}
@@ -0,0 +1,99 @@
+package otp
+
+import (
+    "errors"
+    "strings"
+    "testing"
+)
+
+func TestSKeyDictionary(t *testing.T) {
+    if (len(SKeyDictionary) != skeyWords) {
+        t.Fatalf("%d words, want %d", len(SKeyDictionary), skeyWords)
+    }
+    var seen map[string]bool = map[string]bool{}
+    for i, w := range(SKeyDictionary) {
+        if (seen[w]) {
+            t.Errorf("%s is in the dictionary twice", w)
+        }
+        seen[w] = true
+        // the 1 to 3 letter words come first, then the 4 letter ones, each sorted
+        if (i > 0 && (len(SKeyDictionary[i - 1]) == 4) == (len(w) == 4) && SKeyDictionary[i - 1] >= w) {
+            t.Errorf("%s comes after %s", w, SKeyDictionary[i - 1])
+        }
+    }
+    if (SKeyDictionary[0] != "A" || SKeyDictionary[570] != "YOU" || SKeyDictionary[571] != "ABED" || SKeyDictionary[2047] != "YOKE") {
+        t.Error("the dictionary doesn't start and end where RFC 2289 appendix D does")
+    }
+}
+
+// rfc2289Vectors are the RFC 2289 appendix C vectors
+var rfc2289Vectors = []struct {
+    algo        SKeyAlgorithm
+    passphrase  string
+    seed        string
+    count       int
+    hex         string
+    words       string
+}{
+    {SKeyMD5, "This is a test.", "TeSt", 0, "9E876134D90499DD", "INCH SEA ANNE LONG AHEM TOUR"},
+    {SKeyMD5, "This is a test.", "TeSt", 1, "7965E05436F5029F", "EASE OIL FUM CURE AWRY AVIS"},
+    {SKeyMD5, "This is a test.", "TeSt", 99, "50FE1962C4965880", "BAIL TUFT BITS GANG CHEF THY"},
+    {SKeyMD5, "AbCdEfGhIjK", "alpha1", 0, "87066DD9644BF206", "FULL PEW DOWN ONCE MORT ARC"},
+    {SKeyMD5, "AbCdEfGhIjK", "alpha1", 1, "7CD34C1040ADD14B", "FACT HOOF AT FIST SITE KENT"},
+    {SKeyMD5, "AbCdEfGhIjK", "alpha1", 99, "5AA37A81F212146C", "BODE HOP JAKE STOW JUT RAP"},
+    {SKeyMD5, "OTP's are good", "correct", 0, "F205753943DE4CF9", "ULAN NEW ARMY FUSE SUIT EYED"},
+    {SKeyMD5, "OTP's are good", "correct", 1, "DDCDAC956F234937", "SKIM CULT LOB SLAM POE HOWL"},
+    {SKeyMD5, "OTP's are good", "correct", 99, "B203E28FA525BE47", "LONG IVY JULY AJAR BOND LEE"},
+    {SKeySHA1, "This is a test.", "TeSt", 0, "BB9E6AE1979D8FF4", "MILT VARY MAST OK SEES WENT"},
+    {SKeySHA1, "This is a test.", "TeSt", 1, "63D936639734385B", "CART OTTO HIVE ODE VAT NUT"},
+    {SKeySHA1, "This is a test.", "TeSt", 99, "87FEC7768B73CCF9", "GAFF WAIT SKID GIG SKY EYED"},
+    {SKeySHA1, "AbCdEfGhIjK", "alpha1", 0, "AD85F658EBE383C9", "LEST OR HEEL SCOT ROB SUIT"},
+    {SKeySHA1, "AbCdEfGhIjK", "alpha1", 1, "D07CE229B5CF119B", "RITE TAKE GELD COST TUNE RECK"},
+    {SKeySHA1, "AbCdEfGhIjK", "alpha1", 99, "27BC71035AAF3DC6", "MAY STAR TIN LYON VEDA STAN"},
+    {SKeySHA1, "OTP's are good", "correct", 0, "D51F3E99BF8E6F0B", "RUST WELT KICK FELL TAIL FRAU"},
+    {SKeySHA1, "OTP's are good", "correct", 1, "82AEB52D943774E4", "FLIT DOSE ALSO MEW DRUM DEFY"},
+    {SKeySHA1, "OTP's are good", "correct", 99, "4F296A74FE1567EC", "AURA ALOE HURL WING BERG WAIT"},
+}
+
+func TestSKeyVectors(t *testing.T) {
+    for _, tt := range(rfc2289Vectors) {
+        var value, err = GenerateSKey(tt.algo, tt.seed, tt.passphrase, tt.count)
+        if (err != nil) {
+            t.Fatalf("%s %d %s: %v", tt.algo, tt.count, tt.seed, err)
+        }
+        if got := SKeyHex(value); got != tt.hex {
+            t.Errorf("%s %d %s: %s, want %s", tt.algo, tt.count, tt.seed, got, tt.hex)
+        }
+        var words string
+        if words, err = SKeyWords(value, SKeyDictionary); err != nil {
+            t.Fatal(err)
+        }
+        if (words != tt.words) {
+            t.Errorf("%s %d %s: %q, want %q", tt.algo, tt.count, tt.seed, words, tt.words)
+        }
+        for _, response := range([]string{tt.hex, tt.words, strings.ToLower(tt.words)}) {
+            if got, err := ParseSKey(response, SKeyDictionary); err != nil || got != value {
+                t.Errorf("ParseSKey(%q) = %X, %v, want %s", response, got, err, tt.hex)
+            }
+        }
+    }
+}
+
+func TestSKeyVerifier(t *testing.T) {
+    var v, err = NewSKeyVerifier(SKeyMD5, "TeSt", "This is a test.", 100)
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    if (v.Challenge() != "otp-md5 99 test") {
+        t.Errorf("challenge %q", v.Challenge())
+    }
+    if err = v.Verify("BAIL TUFT BITS GANG CHEF THY", SKeyDictionary); err != nil {
+        t.Fatalf("the password at 99: %v", err)
+    }
+    if err = v.Verify("BAIL TUFT BITS GANG CHEF THY", SKeyDictionary); !errors.Is(err, ErrCodeMismatch) {
+        t.Errorf("the same password again: %v, want ErrCodeMismatch", err)
+    }
+    if err = v.Verify("BAIL TUFT BITS GANG CHEF TOY", SKeyDictionary); !errors.Is(err, ErrInvalidSKey) {
+        t.Errorf("a word changed: %v, want ErrInvalidSKey", err)
+    }
+}
@@ -0,0 +1,68 @@
+package sqlstore
+
+// A migration is the statements that take the schema from one version to the next
+type migration []string
+
+/*
+    migrations returns the schema history for a dialect, oldest first
+
+    Never edit a migration once it's released, add a new one: databases that already
+    ran it won't run it again. Times are Unix milliseconds in a BIGINT, which every
+    dialect handles the same way.
+*/
+func migrations(d Dialect) []migration {
+    var text string = "TEXT"
+    if (d == MySQL) {
+        // MySQL can't index TEXT without a prefix length
+        text = "VARCHAR(2048)"
+    }
+    return []migration{
+        // 1: keys, used codes and failed attempts
+        {
+            `CREATE TABLE otp_keys (
+                name        VARCHAR(255) NOT NULL PRIMARY KEY,
+                uri         ` + text + ` NOT NULL,
+                updated_at  BIGINT NOT NULL
+            )`,
+            `CREATE TABLE otp_used (
+                user_name   VARCHAR(255) NOT NULL,
+                step        BIGINT NOT NULL,
+                expires_at  BIGINT NOT NULL,
+                PRIMARY KEY (user_name, step)
+            )`,
+            `CREATE INDEX otp_used_expires ON otp_used (expires_at)`,
+            `CREATE TABLE otp_attempts (
+                user_name       VARCHAR(255) NOT NULL PRIMARY KEY,
+                failures        INTEGER NOT NULL,
+                window_start    BIGINT NOT NULL
+            )`,
+        },
+        // 2: lockouts, one column at a time for SQLite
+        {
+            `ALTER TABLE otp_attempts ADD COLUMN locked_until BIGINT NOT NULL DEFAULT 0`,
+            `ALTER TABLE otp_attempts ADD COLUMN lockouts INTEGER NOT NULL DEFAULT 0`,
+        },
+        // 3: clock drift
+        {
+            `CREATE TABLE otp_drift (
+                user_name   VARCHAR(255) NOT NULL PRIMARY KEY,
+                steps       BIGINT NOT NULL
+            )`,
+        },
+        // 4: token counters
+        {
+            `CREATE TABLE otp_counters (
+                name        VARCHAR(255) NOT NULL PRIMARY KEY,
+                counter     BIGINT NOT NULL
+            )`,
+        },
+        // 5: recovery codes
+        {
+            `CREATE TABLE otp_recovery (
+                user_name   VARCHAR(255) NOT NULL,
+                hash        VARCHAR(255) NOT NULL,
+                PRIMARY KEY (user_name, hash)
+            )`,
+        },
+    }
+}
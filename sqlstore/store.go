@@ -0,0 +1,511 @@
+/*
+    Package sqlstore keeps OTP keys and the state around verifying them in a SQL database
+
+    A Store works with any database/sql driver for PostgreSQL, MySQL or SQLite; bring
+    your own driver and tell New which dialect it speaks. Call Migrate once at start
+    up to create or upgrade the tables (otp_keys, otp_used, otp_attempts, otp_drift,
+    otp_counters, otp_recovery and otp_schema_version).
+
+    Store is an otp.KeyStore, otp.KeyUpdater, otp.ReplayStore, otp.LockoutStore,
+    otp.DriftStore, otp.CounterStore and otp.RecoveryStore.
+    Update compares and swaps on the stored URI rather than locking rows, which works
+    the same on all three databases.
+*/
+package sqlstore
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    otp "github.com/adam-good/OTP"
+)
+
+// Dialect is the SQL flavour of the database
+type Dialect int
+
+const (
+    Postgres Dialect = iota
+    MySQL
+    SQLite
+)
+
+// Options configures a Store
+type Options struct {
+    ReplayTTL   time.Duration   // how long used steps are remembered, default 10 minutes
+    Timeout     time.Duration   // per-operation timeout, default 5 seconds
+    Clock       otp.Clock       // decides when rows expire, nil means the system clock
+}
+
+// maxUpdateRetries bounds how often Update retries after losing a race
+const maxUpdateRetries int = 16
+
+// ErrConflict is returned by Update when the key kept changing under it
+var ErrConflict error = errors.New("sqlstore: key changed concurrently too many times")
+
+// Store is a set of OTP tables in one database
+type Store struct {
+    db      *sql.DB
+    dialect Dialect
+    opts    Options
+}
+
+// New returns a Store using db, which must be a database of the given dialect
+func New(db *sql.DB, dialect Dialect, opts Options) *Store {
+    if (opts.ReplayTTL <= 0) {
+        opts.ReplayTTL = 10 * time.Minute
+    }
+    if (opts.Timeout <= 0) {
+        opts.Timeout = 5 * time.Second
+    }
+    if (opts.Clock == nil) {
+        opts.Clock = otp.SystemClock{}
+    }
+    return &Store{db: db, dialect: dialect, opts: opts}
+}
+
+// q rewrites the ? placeholders in query for the dialect
+func (s *Store) q(query string) string {
+    if (s.dialect != Postgres) {
+        return query
+    }
+    var b strings.Builder
+    var n int = 0
+    for _, r := range(query) {
+        if (r == '?') {
+            n++
+            b.WriteString("$" + strconv.Itoa(n))
+        } else {
+            b.WriteRune(r)
+        }
+    }
+    return b.String()
+}
+
+// onConflict starts the dialect's "insert, or if the key is already there update" clause, the SET list follows
+func (s *Store) onConflict(key string) string {
+    if (s.dialect == MySQL) {
+        return " ON DUPLICATE KEY UPDATE "
+    }
+    return " ON CONFLICT (" + key + ") DO UPDATE SET "
+}
+
+// excluded refers to the value a clashing insert tried to put in column
+func (s *Store) excluded(column string) string {
+    if (s.dialect == MySQL) {
+        return "VALUES(" + column + ")"
+    }
+    return "excluded." + column
+}
+
+// ctx is a context with the per-operation timeout
+func (s *Store) ctx() (context.Context, context.CancelFunc) {
+    return context.WithTimeout(context.Background(), s.opts.Timeout)
+}
+
+// now is the clock's time in Unix milliseconds, as the tables store it
+func (s *Store) now() int64 {
+    return s.opts.Clock.Now().UnixMilli()
+}
+
+/*
+    Migrate brings the schema up to date
+
+    The version is kept in otp_schema_version and each migration runs in its own
+    transaction. On MySQL, where DDL commits implicitly, a migration that fails half
+    way has to be cleaned up by hand.
+*/
+func (s *Store) Migrate(ctx context.Context) error {
+    if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS otp_schema_version (version INTEGER NOT NULL)`); err != nil {
+        return err
+    }
+    var version int
+    var err error = s.db.QueryRowContext(ctx, `SELECT version FROM otp_schema_version`).Scan(&version)
+    if (errors.Is(err, sql.ErrNoRows)) {
+        if _, err = s.db.ExecContext(ctx, `INSERT INTO otp_schema_version (version) VALUES (0)`); err != nil {
+            return err
+        }
+    } else if (err != nil) {
+        return err
+    }
+
+    var all []migration = migrations(s.dialect)
+    for v := version; v < len(all); v++ {
+        var tx *sql.Tx
+        if tx, err = s.db.BeginTx(ctx, nil); err != nil {
+            return err
+        }
+        for _, stmt := range(all[v]) {
+            if _, err = tx.ExecContext(ctx, stmt); err != nil {
+                tx.Rollback()
+                return fmt.Errorf("sqlstore: migration %d: %w", v + 1, err)
+            }
+        }
+        if _, err = tx.ExecContext(ctx, s.q(`UPDATE otp_schema_version SET version = ?`), v + 1); err != nil {
+            tx.Rollback()
+            return err
+        }
+        if err = tx.Commit(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Get returns the key stored under name, or otp.ErrKeyNotFound
+func (s *Store) Get(name string) (*otp.Key, error) {
+    var ctx, cancel = s.ctx()
+    defer cancel()
+
+    var uri string
+    var err error = s.db.QueryRowContext(ctx, s.q(`SELECT uri FROM otp_keys WHERE name = ?`), name).Scan(&uri)
+    if (errors.Is(err, sql.ErrNoRows)) {
+        return nil, fmt.Errorf("%w: %s", otp.ErrKeyNotFound, name)
+    } else if (err != nil) {
+        return nil, err
+    }
+    return otp.ParseURI(uri)
+}
+
+// Put stores k under name, replacing anything already there
+func (s *Store) Put(name string, k *otp.Key) error {
+    var uri, err = k.URI()
+    if (err != nil) {
+        return err
+    }
+    var ctx, cancel = s.ctx()
+    defer cancel()
+
+    _, err = s.db.ExecContext(ctx,
+        s.q(`INSERT INTO otp_keys (name, uri, updated_at) VALUES (?, ?, ?)` + s.onConflict("name") +
+            `uri = ` + s.excluded("uri") + `, updated_at = ` + s.excluded("updated_at")),
+        name, uri, s.now())
+    return err
+}
+
+// Remove deletes the key stored under name, or fails with otp.ErrKeyNotFound
+func (s *Store) Remove(name string) error {
+    var ctx, cancel = s.ctx()
+    defer cancel()
+
+    var res, err = s.db.ExecContext(ctx, s.q(`DELETE FROM otp_keys WHERE name = ?`), name)
+    if (err != nil) {
+        return err
+    }
+    var n int64
+    if n, err = res.RowsAffected(); err == nil && n == 0 {
+        return fmt.Errorf("%w: %s", otp.ErrKeyNotFound, name)
+    }
+    return err
+}
+
+// Names lists the stored names in sorted order
+func (s *Store) Names() ([]string, error) {
+    var ctx, cancel = s.ctx()
+    defer cancel()
+
+    var rows, err = s.db.QueryContext(ctx, `SELECT name FROM otp_keys ORDER BY name`)
+    if (err != nil) {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var names []string
+    for rows.Next() {
+        var name string
+        if err = rows.Scan(&name); err != nil {
+            return nil, err
+        }
+        names = append(names, name)
+    }
+    return names, rows.Err()
+}
+
+/*
+    Update calls fn on the key stored under name and stores the result atomically
+
+    The new URI is only written if the row still holds the URI fn was given; if another
+    instance changed it in between, Update starts again from the new value.
+*/
+func (s *Store) Update(name string, fn func(k *otp.Key) error) error {
+    for range(maxUpdateRetries) {
+        var ctx, cancel = s.ctx()
+        var old string
+        var err error = s.db.QueryRowContext(ctx, s.q(`SELECT uri FROM otp_keys WHERE name = ?`), name).Scan(&old)
+        cancel()
+        if (errors.Is(err, sql.ErrNoRows)) {
+            return fmt.Errorf("%w: %s", otp.ErrKeyNotFound, name)
+        } else if (err != nil) {
+            return err
+        }
+
+        var k *otp.Key
+        if k, err = otp.ParseURI(old); err != nil {
+            return err
+        }
+        if err = fn(k); err != nil {
+            return err
+        }
+        var uri string
+        if uri, err = k.URI(); err != nil {
+            return err
+        }
+        if (uri == old) {
+            // nothing to write, and MySQL would report 0 rows for an unchanged row anyway
+            return nil
+        }
+
+        ctx, cancel = s.ctx()
+        var res sql.Result
+        res, err = s.db.ExecContext(ctx, s.q(`UPDATE otp_keys SET uri = ?, updated_at = ? WHERE name = ? AND uri = ?`), uri, s.now(), name, old)
+        cancel()
+        if (err != nil) {
+            return err
+        }
+        var n int64
+        if n, err = res.RowsAffected(); err != nil {
+            return err
+        }
+        if (n == 1) {
+            return nil
+        }
+    }
+    return ErrConflict
+}
+
+// IsUsed reports whether user has already used the code for step
+func (s *Store) IsUsed(user string, step int64) (bool, error) {
+    var ctx, cancel = s.ctx()
+    defer cancel()
+
+    var n int
+    var err error = s.db.QueryRowContext(ctx,
+        s.q(`SELECT COUNT(*) FROM otp_used WHERE user_name = ? AND step = ? AND expires_at > ?`),
+        user, step, s.now()).Scan(&n)
+    return n > 0, err
+}
+
+// MarkUsed records that user has used the code for step, or fails with otp.ErrCodeReused if they already had
+func (s *Store) MarkUsed(user string, step int64) error {
+    var ctx, cancel = s.ctx()
+    defer cancel()
+
+    // clear this user's expired steps first so an old row can't block a new insert
+    var t int64 = s.now()
+    if _, err := s.db.ExecContext(ctx, s.q(`DELETE FROM otp_used WHERE user_name = ? AND expires_at <= ?`), user, t); err != nil {
+        return err
+    }
+
+    var insert string = `INSERT INTO otp_used (user_name, step, expires_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`
+    if (s.dialect == MySQL) {
+        insert = `INSERT IGNORE INTO otp_used (user_name, step, expires_at) VALUES (?, ?, ?)`
+    }
+    var res, err = s.db.ExecContext(ctx, s.q(insert), user, step, t + s.opts.ReplayTTL.Milliseconds())
+    if (err != nil) {
+        return err
+    }
+    var n int64
+    if n, err = res.RowsAffected(); err != nil {
+        return err
+    }
+    if (n == 0) {
+        return otp.ErrCodeReused
+    }
+    return nil
+}
+
+// Purge deletes every expired used-code record; MarkUsed only clears the user it's called for
+func (s *Store) Purge(ctx context.Context) error {
+    var _, err = s.db.ExecContext(ctx, s.q(`DELETE FROM otp_used WHERE expires_at <= ?`), s.now())
+    return err
+}
+
+// RecordFailure counts a failed verification for user and returns the count in the current window
+func (s *Store) RecordFailure(user string, window time.Duration) (int, error) {
+    var ctx, cancel = s.ctx()
+    defer cancel()
+
+    // one statement, so concurrent failures can't lose a count; a window that has
+    // run out starts again at one
+    var t int64 = s.now()
+    var expired int64 = t - window.Milliseconds()
+    var _, err = s.db.ExecContext(ctx,
+        s.q(`INSERT INTO otp_attempts (user_name, failures, window_start) VALUES (?, 1, ?)` + s.onConflict("user_name") +
+            `failures = CASE WHEN otp_attempts.window_start <= ? THEN 1 ELSE otp_attempts.failures + 1 END, ` +
+            `window_start = CASE WHEN otp_attempts.window_start <= ? THEN ? ELSE otp_attempts.window_start END`),
+        user, t, expired, expired, t)
+    if (err != nil) {
+        return 0, err
+    }
+
+    var failures int
+    err = s.db.QueryRowContext(ctx, s.q(`SELECT failures FROM otp_attempts WHERE user_name = ?`), user).Scan(&failures)
+    return failures, err
+}
+
+// ResetFailures clears user's failure count and lockouts
+func (s *Store) ResetFailures(user string) error {
+    var ctx, cancel = s.ctx()
+    defer cancel()
+
+    var _, err = s.db.ExecContext(ctx, s.q(`DELETE FROM otp_attempts WHERE user_name = ?`), user)
+    return err
+}
+
+// Lockout returns when user's lockout ends and how many they've had, see otp.LockoutStore
+func (s *Store) Lockout(user string) (time.Time, int, error) {
+    var ctx, cancel = s.ctx()
+    defer cancel()
+
+    var until int64
+    var lockouts int
+    var err error = s.db.QueryRowContext(ctx,
+        s.q(`SELECT locked_until, lockouts FROM otp_attempts WHERE user_name = ?`), user).Scan(&until, &lockouts)
+    if (errors.Is(err, sql.ErrNoRows) || (err == nil && until == 0)) {
+        return time.Time{}, 0, nil
+    } else if (err != nil) {
+        return time.Time{}, 0, err
+    }
+    return time.UnixMilli(until), lockouts, nil
+}
+
+// SetLockout locks user out until until and records it as their lockouts'th lockout
+func (s *Store) SetLockout(user string, until time.Time, lockouts int) error {
+    var ctx, cancel = s.ctx()
+    defer cancel()
+
+    // a new row has an empty failure window, so the next failure starts one
+    var _, err = s.db.ExecContext(ctx,
+        s.q(`INSERT INTO otp_attempts (user_name, failures, window_start, locked_until, lockouts) VALUES (?, 0, 0, ?, ?)` +
+            s.onConflict("user_name") +
+            `locked_until = ` + s.excluded("locked_until") + `, lockouts = ` + s.excluded("lockouts")),
+        user, until.UnixMilli(), lockouts)
+    return err
+}
+
+// Drift returns user's recorded clock drift in time steps, see otp.DriftStore
+func (s *Store) Drift(user string) (int64, error) {
+    var ctx, cancel = s.ctx()
+    defer cancel()
+
+    var steps int64
+    var err error = s.db.QueryRowContext(ctx, s.q(`SELECT steps FROM otp_drift WHERE user_name = ?`), user).Scan(&steps)
+    if (errors.Is(err, sql.ErrNoRows)) {
+        return 0, nil
+    }
+    return steps, err
+}
+
+// SetDrift records user's clock drift
+func (s *Store) SetDrift(user string, steps int64) error {
+    var ctx, cancel = s.ctx()
+    defer cancel()
+
+    var _, err = s.db.ExecContext(ctx,
+        s.q(`INSERT INTO otp_drift (user_name, steps) VALUES (?, ?)` + s.onConflict("user_name") + `steps = ` + s.excluded("steps")),
+        user, steps)
+    return err
+}
+
+/*
+    AdvanceCounter records counter for name if it's past the one recorded, see otp.CounterStore
+
+    The conditional UPDATE is atomic on its own; a name seen for the first time is
+    inserted instead, and of two racing inserts only one gets in. Counters are kept as
+    BIGINT, so they have to fit in 63 bits.
+*/
+func (s *Store) AdvanceCounter(name string, counter uint64) (bool, error) {
+    var ctx, cancel = s.ctx()
+    defer cancel()
+
+    var res, err = s.db.ExecContext(ctx, s.q(`UPDATE otp_counters SET counter = ? WHERE name = ? AND counter < ?`), int64(counter), name, int64(counter))
+    if (err != nil) {
+        return false, err
+    }
+    var n int64
+    if n, err = res.RowsAffected(); err != nil || n > 0 {
+        return n > 0, err
+    }
+
+    var insert string = `INSERT INTO otp_counters (name, counter) VALUES (?, ?) ON CONFLICT DO NOTHING`
+    if (s.dialect == MySQL) {
+        insert = `INSERT IGNORE INTO otp_counters (name, counter) VALUES (?, ?)`
+    }
+    if res, err = s.db.ExecContext(ctx, s.q(insert), name, int64(counter)); err != nil {
+        return false, err
+    }
+    n, err = res.RowsAffected()
+    return n > 0, err
+}
+
+// RecoveryCodes returns user's recovery code hashes, see otp.RecoveryStore
+func (s *Store) RecoveryCodes(user string) ([]string, error) {
+    var ctx, cancel = s.ctx()
+    defer cancel()
+
+    var rows, err = s.db.QueryContext(ctx, s.q(`SELECT hash FROM otp_recovery WHERE user_name = ?`), user)
+    if (err != nil) {
+        return nil, err
+    }
+    defer rows.Close()
+    var hashes []string
+    for rows.Next() {
+        var hash string
+        if err = rows.Scan(&hash); err != nil {
+            return nil, err
+        }
+        hashes = append(hashes, hash)
+    }
+    return hashes, rows.Err()
+}
+
+// SetRecoveryCodes replaces user's recovery code hashes in one transaction
+func (s *Store) SetRecoveryCodes(user string, hashes []string) error {
+    var ctx, cancel = s.ctx()
+    defer cancel()
+
+    var tx, err = s.db.BeginTx(ctx, nil)
+    if (err != nil) {
+        return err
+    }
+    defer tx.Rollback()
+    if _, err = tx.ExecContext(ctx, s.q(`DELETE FROM otp_recovery WHERE user_name = ?`), user); err != nil {
+        return err
+    }
+    for _, hash := range(hashes) {
+        if _, err = tx.ExecContext(ctx, s.q(`INSERT INTO otp_recovery (user_name, hash) VALUES (?, ?)`), user, hash); err != nil {
+            return err
+        }
+    }
+    return tx.Commit()
+}
+
+// UseRecoveryCode removes hash from user's recovery codes, reporting whether it was there
+func (s *Store) UseRecoveryCode(user string, hash string) (bool, error) {
+    var ctx, cancel = s.ctx()
+    defer cancel()
+
+    var res, err = s.db.ExecContext(ctx, s.q(`DELETE FROM otp_recovery WHERE user_name = ? AND hash = ?`), user, hash)
+    if (err != nil) {
+        return false, err
+    }
+    var n int64
+    n, err = res.RowsAffected()
+    return n > 0, err
+}
+
+// the store implements all of the store interfaces
+var (
+    _ otp.KeyStore      = (*Store)(nil)
+    _ otp.KeyUpdater    = (*Store)(nil)
+    _ otp.ReplayStore   = (*Store)(nil)
+    _ otp.AttemptStore  = (*Store)(nil)
+    _ otp.LockoutStore  = (*Store)(nil)
+    _ otp.DriftStore    = (*Store)(nil)
+    _ otp.CounterStore  = (*Store)(nil)
+    _ otp.RecoveryStore = (*Store)(nil)
+)
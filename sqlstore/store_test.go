@@ -0,0 +1,270 @@
+package sqlstore
+
+import (
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "errors"
+    "fmt"
+    "io"
+    "sort"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    otp "github.com/adam-good/OTP"
+)
+
+/*
+    fakeDB is a database/sql driver that only knows the otp_keys statements
+
+    It keeps the table in a map, which is all the key methods need, and runs
+    beforeUpdate (if set) just before the compare and swap in Update so a test can
+    change the row under it.
+*/
+type fakeDB struct {
+    mu              sync.Mutex
+    keys            map[string]string
+    beforeUpdate    func()
+}
+
+func (db *fakeDB) Connect(ctx context.Context) (driver.Conn, error) {
+    return fakeConn{db}, nil
+}
+
+func (db *fakeDB) Driver() driver.Driver {
+    return db
+}
+
+func (db *fakeDB) Open(name string) (driver.Conn, error) {
+    return fakeConn{db}, nil
+}
+
+type fakeConn struct {
+    db  *fakeDB
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+    return fakeStmt{c.db, query}, nil
+}
+
+func (c fakeConn) Close() error {
+    return nil
+}
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+    return nil, errors.New("fakedb: no transactions")
+}
+
+type fakeStmt struct {
+    db      *fakeDB
+    query   string
+}
+
+func (s fakeStmt) Close() error {
+    return nil
+}
+
+func (s fakeStmt) NumInput() int {
+    return -1
+}
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+    if (strings.HasPrefix(s.query, "UPDATE otp_keys") && s.db.beforeUpdate != nil) {
+        s.db.beforeUpdate()
+    }
+    s.db.mu.Lock()
+    defer s.db.mu.Unlock()
+
+    switch {
+    case strings.HasPrefix(s.query, "INSERT INTO otp_keys"):
+        s.db.keys[args[0].(string)] = args[1].(string)
+        return driver.RowsAffected(1), nil
+    case strings.HasPrefix(s.query, "UPDATE otp_keys SET uri = ?, updated_at = ? WHERE name = ? AND uri = ?"):
+        var name string = args[2].(string)
+        if old, ok := s.db.keys[name]; !ok || old != args[3].(string) {
+            return driver.RowsAffected(0), nil
+        }
+        s.db.keys[name] = args[0].(string)
+        return driver.RowsAffected(1), nil
+    case strings.HasPrefix(s.query, "DELETE FROM otp_keys WHERE name = ?"):
+        if _, ok := s.db.keys[args[0].(string)]; !ok {
+            return driver.RowsAffected(0), nil
+        }
+        delete(s.db.keys, args[0].(string))
+        return driver.RowsAffected(1), nil
+    }
+    return nil, fmt.Errorf("fakedb: can't exec %q", s.query)
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+    s.db.mu.Lock()
+    defer s.db.mu.Unlock()
+
+    switch {
+    case strings.HasPrefix(s.query, "SELECT uri FROM otp_keys WHERE name = ?"):
+        var rows *fakeRows = &fakeRows{column: "uri"}
+        if uri, ok := s.db.keys[args[0].(string)]; ok {
+            rows.values = []string{uri}
+        }
+        return rows, nil
+    case strings.HasPrefix(s.query, "SELECT name FROM otp_keys ORDER BY name"):
+        var rows *fakeRows = &fakeRows{column: "name"}
+        for name := range(s.db.keys) {
+            rows.values = append(rows.values, name)
+        }
+        sort.Strings(rows.values)
+        return rows, nil
+    }
+    return nil, fmt.Errorf("fakedb: can't query %q", s.query)
+}
+
+// fakeRows is a result of one string column
+type fakeRows struct {
+    column  string
+    values  []string
+}
+
+func (r *fakeRows) Columns() []string {
+    return []string{r.column}
+}
+
+func (r *fakeRows) Close() error {
+    return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+    if (len(r.values) == 0) {
+        return io.EOF
+    }
+    dest[0], r.values = r.values[0], r.values[1:]
+    return nil
+}
+
+// newFakeStore is a SQLite flavoured Store on an empty fakeDB
+func newFakeStore(t *testing.T) (*Store, *fakeDB) {
+    var fake *fakeDB = &fakeDB{keys: map[string]string{}}
+    var db *sql.DB = sql.OpenDB(fake)
+    t.Cleanup(func() { db.Close() })
+    return New(db, SQLite, Options{}), fake
+}
+
+func TestPutGet(t *testing.T) {
+    var s, _ = newFakeStore(t)
+    var k, err = otp.NewKey(otp.RFC4226Secret, otp.WithIssuer("Example Co"), otp.WithAccount("alice"),
+        otp.WithT0(time.Unix(1_000_000_000, 0)), otp.WithSkew(1), otp.WithChecksum(), otp.WithTruncationOffset(5))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    if err = s.Put("alice", k); err != nil {
+        t.Fatal(err)
+    }
+    var got *otp.Key
+    if got, err = s.Get("alice"); err != nil {
+        t.Fatal(err)
+    }
+    if (got.Issuer != k.Issuer || got.Account != k.Account || !got.T0.Equal(k.T0) || got.Skew != k.Skew ||
+        !got.Checksum || got.Truncation != k.Truncation) {
+        t.Errorf("read back %+v, want %+v", *got, *k)
+    }
+    if _, err = s.Get("bob"); !errors.Is(err, otp.ErrKeyNotFound) {
+        t.Errorf("Get of a missing key: %v, want ErrKeyNotFound", err)
+    }
+
+    var names []string
+    if names, err = s.Names(); err != nil || len(names) != 1 || names[0] != "alice" {
+        t.Errorf("Names: %v, %v", names, err)
+    }
+    if err = s.Remove("alice"); err != nil {
+        t.Errorf("Remove: %v", err)
+    }
+    if err = s.Remove("alice"); !errors.Is(err, otp.ErrKeyNotFound) {
+        t.Errorf("Remove twice: %v, want ErrKeyNotFound", err)
+    }
+}
+
+func TestPutRefusesProvidedKey(t *testing.T) {
+    var s, fake = newFakeStore(t)
+    var k, err = otp.NewProvidedKey(otp.KeyProviderFunc(func(name string) ([]byte, error) {
+        return otp.RFC4226Secret, nil
+    }), "alice")
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    if err = s.Put("alice", k); !errors.Is(err, otp.ErrProvidedKey) {
+        t.Errorf("Put of a provided key: %v, want ErrProvidedKey", err)
+    }
+    if (len(fake.keys) != 0) {
+        t.Errorf("a row was written: %v", fake.keys)
+    }
+}
+
+func TestUpdate(t *testing.T) {
+    var s, fake = newFakeStore(t)
+    var k, err = otp.NewKey(otp.RFC4226Secret, otp.WithHOTP(0), otp.WithSkew(3))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    if err = s.Put("alice", k); err != nil {
+        t.Fatal(err)
+    }
+
+    // another instance moves the counter to 1 just before the first write, so fn runs again on that
+    var raced bool = false
+    fake.beforeUpdate = func() {
+        if (!raced) {
+            raced = true
+            var other otp.Key = *k
+            other.Counter = 1
+            if err := s.Put("alice", &other); err != nil {
+                t.Error(err)
+            }
+        }
+    }
+    var runs int
+    err = s.Update("alice", func(k *otp.Key) error {
+        runs++
+        return k.Validate("359152")
+    })
+    if (err != nil || runs != 2) {
+        t.Fatalf("Update: %v after %d runs, want success after 2", err, runs)
+    }
+    if k, err = s.Get("alice"); err != nil || k.Counter != 3 {
+        t.Errorf("counter after Update: %v, %v, want 3", k, err)
+    }
+
+    fake.beforeUpdate = nil
+    if err = s.Update("alice", func(k *otp.Key) error { return k.Validate("359152") }); !errors.Is(err, otp.ErrCodeMismatch) {
+        t.Errorf("Update with a used code: %v, want ErrCodeMismatch", err)
+    }
+    if err = s.Update("bob", func(k *otp.Key) error { return nil }); !errors.Is(err, otp.ErrKeyNotFound) {
+        t.Errorf("Update of a missing key: %v, want ErrKeyNotFound", err)
+    }
+}
+
+func TestUpdateConflict(t *testing.T) {
+    var s, fake = newFakeStore(t)
+    var k, err = otp.NewKey(otp.RFC4226Secret, otp.WithHOTP(0))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    if err = s.Put("alice", k); err != nil {
+        t.Fatal(err)
+    }
+    // the row changes before every write
+    var n uint64
+    fake.beforeUpdate = func() {
+        n++
+        var other otp.Key = *k
+        other.Counter = 100 + n
+        s.Put("alice", &other)
+    }
+    err = s.Update("alice", func(k *otp.Key) error {
+        k.Counter++
+        return nil
+    })
+    if (!errors.Is(err, ErrConflict) || n != uint64(maxUpdateRetries)) {
+        t.Errorf("Update always losing: %v after %d writes, want ErrConflict after %d", err, n, maxUpdateRetries)
+    }
+}
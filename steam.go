@@ -0,0 +1,96 @@
+package otp
+
+import (
+    "crypto/subtle"
+    "encoding/binary"
+    "strings"
+    "time"
+)
+
+// SteamDigits is the length of a Steam Guard code
+const SteamDigits int = 5
+
+/*
+    GenerateSteamAt returns the Steam Guard code for time t
+
+    Steam Guard is TOTP with SHA1 and a 30 second period, with the truncated value
+    written in SteamEncoder's 26 character alphabet instead of as decimal digits, e.g.
+    "2F9J5". key is the raw shared secret; Steam hands it out in base64 (the
+    shared_secret in a .maFile), see DecodeBase64.
+*/
+func GenerateSteamAt(key []byte, t time.Time) (string, error) {
+    defer observeGenerate(time.Now())
+    return generateSteam(key, timeStep(t, DefaultPeriod))
+}
+
+// GenerateSteam returns the current Steam Guard code, see GenerateSteamAt
+func GenerateSteam(key []byte) (string, error) {
+    return GenerateSteamAt(key, time.Now())
+}
+
+// generateSteam is the Steam Guard code for one time step
+func generateSteam(key []byte, step int64) (string, error) {
+    if (len(key) == 0) {
+        return "", ErrEmptySecret
+    }
+    var message []byte = make([]byte, 8)
+    binary.BigEndian.PutUint64(message, uint64(step))
+    var value, err = truncate(SHA1, key, message, Truncation{})
+    if (err != nil) {
+        return "", err
+    }
+    return SteamEncoder{}.Encode(value, SteamDigits), nil
+}
+
+/*
+    ValidateSteam checks a submitted Steam Guard code
+
+    It takes the same options as ValidateTOTP, except that Digits, Algorithm, Period and
+    T0 are Steam's and can't be changed. Codes are compared ignoring case and spaces.
+    Replay, Lockout, Audit and the observer work as they do for TOTP; Drift is not used.
+*/
+func ValidateSteam(key []byte, code string, opts ValidateOpts) error {
+    var start time.Time = time.Now()
+    opts = opts.withDefaults()
+    var step int64 = timeStep(opts.Time, DefaultPeriod)
+    var matched int64
+    var err error = opts.Lockout.Guard(opts.User, func() error {
+        var err error
+        matched, err = validateSteam(key, code, step, opts)
+        return err
+    })
+    observeVerify(opts.User, start, err)
+    if (opts.Audit != nil) {
+        var offset int64
+        if (err == nil) {
+            offset = matched - step
+        }
+        audit(opts.Audit, clockNow(opts.Clock), opts.User, TypeTOTP, SHA1, fingerprint(TypeTOTP, SHA1, SteamDigits, DefaultPeriod, time.Time{}, Truncation{}, key), offset, err)
+    }
+    return err
+}
+
+// validateSteam is ValidateSteam without the lockout, observer and audit
+func validateSteam(key []byte, code string, step int64, opts ValidateOpts) (int64, error) {
+    var submitted string = strings.ToUpper(strings.ReplaceAll(code, " ", ""))
+    var matched int64
+    var match bool = false
+    for i := -opts.Skew; i <= opts.Skew; i++ {
+        var expected, err = generateSteam(key, step + int64(i))
+        if (err != nil) {
+            return 0, err
+        }
+        if (subtle.ConstantTimeCompare([]byte(expected), []byte(submitted)) == 1 && !match) {
+            matched, match = step + int64(i), true
+        }
+    }
+    if (!match) {
+        return 0, ErrCodeMismatch
+    }
+    if (opts.Replay != nil) {
+        if err := opts.Replay.MarkUsed(opts.User, matched); err != nil {
+            return 0, err
+        }
+    }
+    return matched, nil
+}
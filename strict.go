@@ -0,0 +1,80 @@
+package otp
+
+import (
+    "crypto/fips140"
+    "fmt"
+    "sync/atomic"
+)
+
+/*
+    Strict mode
+
+    For deployments under FIPS 140 or PCI constraints, SetStrictMode(true) makes the
+    package refuse anything that can't be argued compliant, failing with an error
+    wrapping ErrNotCompliant instead:
+        - SHA-1, MD5 and any algorithm added with RegisterAlgorithm, which the package
+          can't vouch for; only SHA256 and SHA512 are left. That rules out Google
+          Authenticator style keys, Steam, mOTP, S/KEY and the legacy algorithm
+        - secrets shorter than StrictMinSecret
+        - codes shorter than StrictMinDigits
+        - the diagnostic and migration validations that accept a second, non-standard
+          code (ValidateRoundingVariant, ValidateHOTPEncoding, ValidateDuringMigration)
+    NewKey checks the key's settings so a non-compliant key fails when it's made, not
+    at its first login.
+
+    Every verification compares codes in constant time and checks its whole window
+    whether or not strict mode is on. Strict mode is also on whenever Go's own FIPS
+    140-3 mode is (GODEBUG=fips140=on), see crypto/fips140.
+*/
+
+const (
+    StrictMinSecret int = 16    // bytes, RFC 4226's 128 bits, above NIST's 112 bit minimum for HMAC keys
+    StrictMinDigits int = 8     // two over NIST SP 800-63B's minimum of 6, so a guess is a hundred times less likely
+)
+
+var strictMode atomic.Bool
+
+// SetStrictMode turns strict mode on or off for the whole process, see the notes above
+func SetStrictMode(on bool) {
+    strictMode.Store(on)
+}
+
+// StrictMode reports whether strict mode is on, set with SetStrictMode or by Go's FIPS 140-3 mode
+func StrictMode() bool {
+    return strictMode.Load() || fips140.Enabled()
+}
+
+// checkStrict fails in strict mode if algo or a secret of secretLen bytes isn't allowed
+func checkStrict(algo Algorithm, secretLen int) error {
+    if err := checkStrictAlgorithm(algo); err != nil {
+        return err
+    }
+    if (StrictMode() && secretLen < StrictMinSecret) {
+        return fmt.Errorf("%w: a %d byte secret, at least %d are needed", ErrNotCompliant, secretLen, StrictMinSecret)
+    }
+    return nil
+}
+
+// checkStrictAlgorithm fails in strict mode if algo isn't allowed
+func checkStrictAlgorithm(algo Algorithm) error {
+    if (StrictMode() && algo != SHA256 && algo != SHA512) {
+        return fmt.Errorf("%w: %s, only SHA256 and SHA512 are allowed", ErrNotCompliant, algo)
+    }
+    return nil
+}
+
+// checkStrictDigits fails in strict mode for codes shorter than StrictMinDigits
+func checkStrictDigits(digits int) error {
+    if (StrictMode() && digits < StrictMinDigits) {
+        return fmt.Errorf("%w: %d digit codes, at least %d are needed", ErrNotCompliant, digits, StrictMinDigits)
+    }
+    return nil
+}
+
+// refuseStrict fails in strict mode, for what's never allowed in it
+func refuseStrict(what string) error {
+    if (StrictMode()) {
+        return fmt.Errorf("%w: %s", ErrNotCompliant, what)
+    }
+    return nil
+}
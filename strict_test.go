@@ -0,0 +1,44 @@
+package otp
+
+import (
+    "errors"
+    "testing"
+)
+
+// what strict mode refuses is fine outside it
+func TestStrictMode(t *testing.T) {
+    var secret []byte = RFC6238Secrets[SHA256]
+    var tests = []struct {
+        name    string
+        secret  []byte
+        opts    []Option
+        strict  error
+    }{
+        {"sha256, 8 digits", secret, []Option{WithAlgorithm(SHA256), WithDigits(8)}, nil},
+        {"sha512, 10 digits", RFC6238Secrets[SHA512], []Option{WithAlgorithm(SHA512), WithDigits(10)}, nil},
+        {"6 digits", secret, []Option{WithAlgorithm(SHA256)}, ErrNotCompliant},
+        {"7 digits", secret, []Option{WithAlgorithm(SHA256), WithDigits(7)}, ErrNotCompliant},
+        {"sha1", RFC4226Secret, []Option{WithDigits(8)}, ErrNotCompliant},
+        {"15 byte secret", secret[:15], []Option{WithAlgorithm(SHA256), WithDigits(8)}, ErrNotCompliant},
+    }
+    defer SetStrictMode(false)
+    for _, tt := range(tests) {
+        SetStrictMode(false)
+        if _, err := NewKey(tt.secret, tt.opts...); err != nil {
+            t.Errorf("%s outside strict mode: %v", tt.name, err)
+        }
+        SetStrictMode(true)
+        if _, err := NewKey(tt.secret, tt.opts...); !errors.Is(err, tt.strict) {
+            t.Errorf("%s in strict mode: %v, want %v", tt.name, err, tt.strict)
+        }
+    }
+
+    // the validation functions refuse short codes too, not just NewKey
+    SetStrictMode(true)
+    if _, err := GenerateHOTP(secret, 0, 6, SHA256); !errors.Is(err, ErrNotCompliant) {
+        t.Errorf("GenerateHOTP of 6 digits in strict mode: %v, want %v", err, ErrNotCompliant)
+    }
+    if err := ValidateTOTP(secret, "000000", ValidateOpts{Algorithm: SHA256}); !errors.Is(err, ErrNotCompliant) {
+        t.Errorf("ValidateTOTP of 6 digits in strict mode: %v, want %v", err, ErrNotCompliant)
+    }
+}
@@ -0,0 +1,61 @@
+package otp
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestPrecomputeTable(t *testing.T) {
+    var table, err = PrecomputeTable(RFC4226Secret, time.Unix(0, 0), time.Unix(299, 0))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    if (len(table) != 10) {
+        t.Errorf("%d codes for steps 0 to 9, want 10", len(table))
+    }
+
+    var tests = []struct {
+        name    string
+        code    string
+        step    uint64
+        err     error
+    }{
+        {"first step", rfc4226Codes[0], 0, nil},
+        {"last step", rfc4226Codes[9], 9, nil},
+        {"grouped", "359 152", 2, nil},
+        {"not in the table", "000000", 0, ErrCodeMismatch},
+        {"not a code", "abcdef", 0, ErrCodeMismatch},
+        {"empty", "", 0, ErrCodeMismatch},
+    }
+    for _, tt := range(tests) {
+        var step, err = ValidateFromTable(table, tt.code)
+        if (!errors.Is(err, tt.err) || step != tt.step) {
+            t.Errorf("%s: %d, %v, want %d, %v", tt.name, step, err, tt.step, tt.err)
+        }
+    }
+}
+
+func TestPrecomputeTableLimits(t *testing.T) {
+    var start time.Time = time.Unix(0, 0)
+    var tests = []struct {
+        name    string
+        to      time.Time
+        size    int
+        err     error
+    }{
+        {"one step", start, 1, nil},
+        {"to before from", start.Add(-DefaultPeriod), 0, nil},
+        {"too many steps", start.Add(time.Duration(MaxBatchCodes) * DefaultPeriod), 0, ErrBatchTooLarge},
+        {"years", start.Add(100 * 365 * 24 * time.Hour), 0, ErrBatchTooLarge},
+    }
+    for _, tt := range(tests) {
+        var table, err = PrecomputeTable(RFC4226Secret, start, tt.to)
+        if (!errors.Is(err, tt.err) || len(table) != tt.size) {
+            t.Errorf("%s: %d codes, %v, want %d, %v", tt.name, len(table), err, tt.size, tt.err)
+        }
+    }
+    if _, err := PrecomputeTable(nil, start, start); !errors.Is(err, ErrEmptySecret) {
+        t.Errorf("empty secret: %v, want %v", err, ErrEmptySecret)
+    }
+}
@@ -0,0 +1,233 @@
+package otp
+
+import (
+    "fmt"
+    "math"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+)
+
+/*
+    Provisioning URIs
+
+    Authenticator apps (Google Authenticator, Aegis, 1Password, ...) are handed secrets as
+    a Key URI, usually inside a QR code:
+        otpauth://TYPE/LABEL?PARAMETERS
+    where TYPE is totp or hotp, LABEL is "Issuer:account" (or just the account) and the
+    parameters are secret (unpadded Base32), issuer, algorithm, digits and then period
+    for TOTP or counter for HOTP.
+        https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+
+    Settings the format has no parameter for are written as extra ones only when they
+    aren't the default, so the key stores, which keep keys as their URIs, get back
+    the key they were given: t0 (Unix seconds), skew, checksum=true and truncation, the
+    fixed offset of WithTruncationOffset. Apps ignore parameters they
+    don't know, so a URI with them still scans, but codes only match in the app if it
+    happens to use the same settings.
+*/
+
+// uriEscape escapes a label part or parameter value, with spaces as %20 since some apps show a literal +
+func uriEscape(s string) string {
+    return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+/*
+    URI renders the key as an otpauth:// provisioning URI
+
+    It fails with an error wrapping ErrInvalidURI rather than write a URI that breaks
+    the format: one for a key without a secret (also wrapping ErrProvidedKey when the
+    secret is in a KeyProvider), a type other than totp or hotp, a TOTP key with Counter set
+    (counter is only for hotp), a TOTP key whose period is under a second or whose T0
+    isn't a whole second. An HOTP key always has its counter written, counter=0
+    included, since ParseURI requires it.
+*/
+func (k *Key) URI() (string, error) {
+    switch {
+    case (len(k.Secret) == 0 && k.Provider != nil):
+        return "", fmt.Errorf("%w: %w: %q", ErrInvalidURI, ErrProvidedKey, k.SecretName)
+    case (len(k.Secret) == 0):
+        return "", fmt.Errorf("%w: %w", ErrInvalidURI, ErrEmptySecret)
+    case (k.Type != TypeTOTP && k.Type != TypeHOTP):
+        return "", fmt.Errorf("%w: unknown type %d", ErrInvalidURI, int(k.Type))
+    case (k.Type == TypeTOTP && k.Counter != 0):
+        return "", fmt.Errorf("%w: totp key with counter %d", ErrInvalidURI, k.Counter)
+    case (k.Type == TypeTOTP && k.Period < time.Second):
+        return "", fmt.Errorf("%w: totp period %v is under a second", ErrInvalidURI, k.Period)
+    case (k.Type == TypeTOTP && k.T0.Nanosecond() != 0):
+        return "", fmt.Errorf("%w: totp t0 %v isn't a whole second", ErrInvalidURI, k.T0)
+    }
+
+    var label string = uriEscape(k.Account)
+    if (k.Issuer != "") {
+        label = uriEscape(k.Issuer) + ":" + label
+    }
+
+    var params []string = []string{"secret=" + EncodeBase32(k.Secret)}
+    if (k.Issuer != "") {
+        params = append(params, "issuer=" + uriEscape(k.Issuer))
+    }
+    params = append(params, "algorithm=" + uriEscape(k.Algorithm.String()))
+    params = append(params, "digits=" + strconv.Itoa(k.Digits))
+    if (k.Type == TypeHOTP) {
+        params = append(params, "counter=" + strconv.FormatUint(k.Counter, 10))
+    } else {
+        params = append(params, "period=" + strconv.FormatInt(int64(k.Period / time.Second), 10))
+        if (!k.T0.IsZero()) {
+            params = append(params, "t0=" + strconv.FormatInt(k.T0.Unix(), 10))
+        }
+    }
+    if (k.Skew > 0) {
+        params = append(params, "skew=" + strconv.Itoa(k.Skew))
+    }
+    if (k.Checksum) {
+        params = append(params, "checksum=true")
+    }
+    if offset, fixed := k.Truncation.Offset(); fixed {
+        params = append(params, "truncation=" + strconv.Itoa(offset))
+    }
+
+    return "otpauth://" + k.Type.String() + "/" + label + "?" + strings.Join(params, "&"), nil
+}
+
+/*
+    ParseURI reads an otpauth:// provisioning URI back into a Key
+
+    Both totp and hotp URIs are understood. secret is required, and so is counter for
+    hotp; everything else falls back to the defaults. An issuer parameter wins over the
+    issuer prefix of the label. counter, period, skew and truncation must be plain
+    decimal integers, t0 a decimal count of Unix seconds, which may be negative, and
+    checksum true or false. Anything malformed fails with an error wrapping
+    ErrInvalidURI.
+*/
+func ParseURI(uri string) (*Key, error) {
+    var u, err = url.Parse(uri)
+    if (err != nil) {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidURI, err)
+    }
+    if (u.Scheme != "otpauth") {
+        return nil, fmt.Errorf("%w: scheme is %q, not otpauth", ErrInvalidURI, u.Scheme)
+    }
+
+    var opts []Option
+    var q url.Values = u.Query()
+
+    /*
+    *   Type is the host part: otpauth://totp/... or otpauth://hotp/...
+    */
+    var hotp bool
+    switch strings.ToLower(u.Host) {
+    case "totp":
+        hotp = false
+    case "hotp":
+        hotp = true
+    default:
+        return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidURI, u.Host)
+    }
+
+    /*
+    *   Label is "Issuer:account" or just "account"
+    */
+    var label string = strings.TrimPrefix(u.Path, "/")
+    var issuer, account string = "", label
+    if i := strings.Index(label, ":"); i >= 0 {
+        issuer, account = label[:i], strings.TrimLeft(label[i+1:], " ")
+    }
+    if (q.Has("issuer")) {
+        issuer = q.Get("issuer")
+    }
+    opts = append(opts, WithIssuer(issuer), WithAccount(account))
+
+    /*
+    *   Parameters
+    */
+    if (q.Get("secret") == "") {
+        return nil, fmt.Errorf("%w: missing secret", ErrInvalidURI)
+    }
+    var secret []byte
+    if secret, err = DecodeBase32(q.Get("secret")); err != nil {
+        return nil, fmt.Errorf("%w: secret: %v", ErrInvalidURI, err)
+    }
+
+    if (q.Has("algorithm")) {
+        var algo, ok = ParseAlgorithm(q.Get("algorithm"))
+        if (!ok) {
+            return nil, fmt.Errorf("%w: unknown algorithm %q", ErrInvalidURI, q.Get("algorithm"))
+        }
+        opts = append(opts, WithAlgorithm(algo))
+    }
+
+    if (q.Has("digits")) {
+        var digits uint64
+        if digits, err = parseDecimal(q.Get("digits")); err != nil {
+            return nil, fmt.Errorf("%w: digits: %v", ErrInvalidURI, err)
+        }
+        opts = append(opts, WithDigits(int(min(digits, uint64(MaxDigits + 1)))))
+    }
+
+    if (hotp) {
+        if (!q.Has("counter")) {
+            return nil, fmt.Errorf("%w: hotp without a counter", ErrInvalidURI)
+        }
+        var counter uint64
+        if counter, err = parseDecimal(q.Get("counter")); err != nil {
+            return nil, fmt.Errorf("%w: counter: %v", ErrInvalidURI, err)
+        }
+        opts = append(opts, WithHOTP(counter))
+    } else if (q.Has("period")) {
+        var period uint64
+        if period, err = parseDecimal(q.Get("period")); err != nil {
+            return nil, fmt.Errorf("%w: period: %v", ErrInvalidURI, err)
+        }
+        if (period == 0 || period > uint64(math.MaxInt64 / int64(time.Second))) {
+            return nil, fmt.Errorf("%w: period %d out of range", ErrInvalidURI, period)
+        }
+        opts = append(opts, WithPeriod(time.Duration(period) * time.Second))
+    }
+
+    if (!hotp && q.Has("t0")) {
+        var t0 int64
+        if t0, err = strconv.ParseInt(q.Get("t0"), 10, 64); err != nil {
+            return nil, fmt.Errorf("%w: t0: %v", ErrInvalidURI, err)
+        }
+        opts = append(opts, WithT0(time.Unix(t0, 0)))
+    }
+
+    if (q.Has("skew")) {
+        var skew uint64
+        if skew, err = parseDecimal(q.Get("skew")); err != nil || skew > math.MaxInt32 {
+            return nil, fmt.Errorf("%w: skew %q", ErrInvalidURI, q.Get("skew"))
+        }
+        opts = append(opts, WithSkew(int(skew)))
+    }
+
+    if (q.Has("checksum")) {
+        var checksum bool
+        if checksum, err = strconv.ParseBool(q.Get("checksum")); err != nil {
+            return nil, fmt.Errorf("%w: checksum %q", ErrInvalidURI, q.Get("checksum"))
+        }
+        if (checksum) {
+            opts = append(opts, WithChecksum())
+        }
+    }
+
+    if (q.Has("truncation")) {
+        var offset uint64
+        if offset, err = parseDecimal(q.Get("truncation")); err != nil || offset > math.MaxInt32 {
+            return nil, fmt.Errorf("%w: truncation %q", ErrInvalidURI, q.Get("truncation"))
+        }
+        opts = append(opts, WithTruncationOffset(int(offset)))
+    }
+
+    var k *Key
+    if k, err = NewKey(secret, opts...); err != nil {
+        return nil, fmt.Errorf("%w: %w", ErrInvalidURI, err)
+    }
+    return k, nil
+}
+
+// parseDecimal parses a plain unsigned decimal integer, so no sign, hex or other bases
+func parseDecimal(s string) (uint64, error) {
+    return strconv.ParseUint(s, 10, 64)
+}
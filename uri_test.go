@@ -0,0 +1,184 @@
+package otp
+
+import (
+    "bytes"
+    "errors"
+    "math"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestURIChecksType(t *testing.T) {
+    var tests = []struct {
+        name    string
+        key     Key
+        ok      bool
+        want    string
+    }{
+        {"hotp at counter 0", Key{Type: TypeHOTP, Secret: RFC4226Secret, Algorithm: SHA1, Digits: 6}, true, "counter=0"},
+        {"hotp", Key{Type: TypeHOTP, Secret: RFC4226Secret, Algorithm: SHA1, Digits: 6, Counter: 42}, true, "counter=42"},
+        {"totp", Key{Type: TypeTOTP, Secret: RFC4226Secret, Algorithm: SHA1, Digits: 6, Period: DefaultPeriod}, true, "period=30"},
+        {"totp with a counter", Key{Type: TypeTOTP, Secret: RFC4226Secret, Algorithm: SHA1, Digits: 6, Period: DefaultPeriod, Counter: 1}, false, ""},
+        {"totp without a period", Key{Type: TypeTOTP, Secret: RFC4226Secret, Algorithm: SHA1, Digits: 6}, false, ""},
+        {"unknown type", Key{Type: Type(7), Secret: RFC4226Secret, Algorithm: SHA1, Digits: 6}, false, ""},
+    }
+    for _, tt := range(tests) {
+        t.Run(tt.name, func(t *testing.T) {
+            var uri, err = tt.key.URI()
+            if (!tt.ok) {
+                if (!errors.Is(err, ErrInvalidURI) || uri != "") {
+                    t.Fatalf("got %q, %v, want ErrInvalidURI", uri, err)
+                }
+                return
+            }
+            if (err != nil) {
+                t.Fatal(err)
+            }
+            if (!strings.Contains(uri, tt.want)) {
+                t.Errorf("%s doesn't have %s", uri, tt.want)
+            }
+            if _, err = ParseURI(uri); err != nil {
+                t.Errorf("ParseURI(%s): %v", uri, err)
+            }
+        })
+    }
+}
+
+func TestURIRoundTrip(t *testing.T) {
+    var tests = []struct {
+        name    string
+        opts    []Option
+    }{
+        {"defaults", nil},
+        {"issuer and account", []Option{WithIssuer("Example Co"), WithAccount("alice@example.com")}},
+        {"sha256 8 digits 60s", []Option{WithAlgorithm(SHA256), WithDigits(8), WithPeriod(time.Minute)}},
+        {"t0", []Option{WithT0(time.Unix(1_000_000_000, 0))}},
+        {"t0 before the epoch", []Option{WithT0(time.Unix(-3600, 0))}},
+        {"skew", []Option{WithSkew(2)}},
+        {"hotp with a window", []Option{WithHOTP(42), WithSkew(10)}},
+        {"checksum", []Option{WithChecksum()}},
+        {"hotp with a checksum", []Option{WithHOTP(0), WithChecksum()}},
+        {"truncation at 0", []Option{WithTruncationOffset(0)}},
+        {"truncation at the end of a sha512 hmac", []Option{WithAlgorithm(SHA512), WithTruncationOffset(60)}},
+    }
+    for _, tt := range(tests) {
+        t.Run(tt.name, func(t *testing.T) {
+            var k, err = NewKey(RFC4226Secret, tt.opts...)
+            if (err != nil) {
+                t.Fatal(err)
+            }
+            var uri string
+            if uri, err = k.URI(); err != nil {
+                t.Fatal(err)
+            }
+            var got *Key
+            if got, err = ParseURI(uri); err != nil {
+                t.Fatalf("ParseURI(%s): %v", uri, err)
+            }
+            if (!keysEqual(got, k)) {
+                t.Errorf("%s read back as %+v, want %+v", uri, *got, *k)
+            }
+        })
+    }
+}
+
+func TestParseURITruncation(t *testing.T) {
+    var tests = []struct {
+        name    string
+        param   string
+        ok      bool
+    }{
+        {"in the hmac", "truncation=16", true},
+        {"past the end of the hmac", "truncation=17", false},
+        {"negative", "truncation=-1", false},
+        {"not a number", "truncation=dynamic", false},
+    }
+    for _, tt := range(tests) {
+        t.Run(tt.name, func(t *testing.T) {
+            var _, err = ParseURI("otpauth://totp/alice?secret=" + EncodeBase32(RFC4226Secret) + "&" + tt.param)
+            if (tt.ok != (err == nil) || (err != nil && !errors.Is(err, ErrInvalidURI))) {
+                t.Errorf("ParseURI with %s: %v", tt.param, err)
+            }
+        })
+    }
+}
+
+func TestURICounter(t *testing.T) {
+    var tests = []struct {
+        name    string
+        counter string
+        want    uint64
+        ok      bool
+    }{
+        {"zero", "0", 0, true},
+        {"near the top", "18446744073709551614", math.MaxUint64 - 1, true},
+        {"the top", "18446744073709551615", math.MaxUint64, true},
+        {"past the top", "18446744073709551616", 0, false},
+        {"hex", "0x10", 0, false},
+        {"hex without the prefix", "2a", 0, false},
+        {"negative", "-1", 0, false},
+        {"empty", "", 0, false},
+    }
+    for _, tt := range(tests) {
+        t.Run(tt.name, func(t *testing.T) {
+            var k, err = ParseURI("otpauth://hotp/alice?secret=" + EncodeBase32(RFC4226Secret) + "&counter=" + tt.counter)
+            if (!tt.ok) {
+                if (!errors.Is(err, ErrInvalidURI)) {
+                    t.Errorf("counter=%s: %v, want ErrInvalidURI", tt.counter, err)
+                }
+                return
+            }
+            if (err != nil) {
+                t.Fatalf("counter=%s: %v", tt.counter, err)
+            }
+            if (k.Counter != tt.want) {
+                t.Errorf("counter=%s read as %d", tt.counter, k.Counter)
+            }
+            // written back as plain decimal
+            var uri string
+            if uri, err = k.URI(); err != nil {
+                t.Fatal(err)
+            }
+            if (!strings.Contains(uri, "counter=" + strconv.FormatUint(tt.want, 10))) {
+                t.Errorf("%s doesn't have counter=%d", uri, tt.want)
+            }
+        })
+    }
+}
+
+func TestURIRejectsProvidedKey(t *testing.T) {
+    var p KeyProviderFunc = func(name string) ([]byte, error) {
+        return RFC4226Secret, nil
+    }
+    var k, err = NewProvidedKey(p, "alice")
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    var uri string
+    if uri, err = k.URI(); !errors.Is(err, ErrProvidedKey) || !errors.Is(err, ErrInvalidURI) || uri != "" {
+        t.Errorf("URI of a provided key: %q, %v, want ErrProvidedKey", uri, err)
+    }
+    if _, err = (&Key{Type: TypeTOTP, Algorithm: SHA1, Digits: 6, Period: DefaultPeriod}).URI(); !errors.Is(err, ErrEmptySecret) {
+        t.Errorf("URI of a key without a secret: %v, want ErrEmptySecret", err)
+    }
+}
+
+func TestURIRejectsFractionalT0(t *testing.T) {
+    var k, err = NewKey(RFC4226Secret, WithT0(time.Unix(10, 500_000_000)))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    if _, err = k.URI(); !errors.Is(err, ErrInvalidURI) {
+        t.Errorf("t0 with a fraction of a second: %v, want ErrInvalidURI", err)
+    }
+}
+
+// keysEqual compares the parts of two keys a URI keeps
+func keysEqual(a *Key, b *Key) bool {
+    return a.Type == b.Type && bytes.Equal(a.Secret, b.Secret) && a.Issuer == b.Issuer &&
+        a.Account == b.Account && a.Algorithm == b.Algorithm && a.Digits == b.Digits &&
+        a.Period == b.Period && a.T0.Equal(b.T0) && a.Counter == b.Counter && a.Skew == b.Skew &&
+        a.Checksum == b.Checksum && a.Truncation == b.Truncation
+}
@@ -0,0 +1,291 @@
+package otp
+
+import (
+    "errors"
+    "fmt"
+    "time"
+)
+
+/*
+    ValidateOpts describes the codes a validation should accept
+
+    The zero value is the RFC 6238 defaults: SHA1, 6 digits, 30 second period, Unix epoch
+    T0, the current time by the system clock and no skew.
+*/
+type ValidateOpts struct {
+    Digits      int             // code length, 0 means DefaultDigits
+    Algorithm   Algorithm       // hash under the HMAC
+    Period      time.Duration   // TOTP time step, 0 means DefaultPeriod
+    T0          time.Time       // when time steps start counting, zero means the Unix epoch
+    Skew        int             // how many steps either side of the current one are also accepted
+    Time        time.Time       // the time to validate at, zero means Clock's time
+    Clock       Clock           // nil means the system clock
+    Checksum    bool            // codes end in an RFC 4226 checksum digit, see AddChecksum
+    Truncation  Truncation      // the zero value is dynamic truncation, see FixedOffset
+    Encoder     Encoder         // how codes are written, nil means DecimalEncoder
+    Normalization NormalizationPolicy   // how forgiving to be about what was typed, the zero value is Lenient
+
+    // ToleranceTransposition also accepts a code with two neighbouring digits swapped,
+    // "124356" for "123456", for users who find typing codes hard. It's off by default
+    // and weakens every login it's on for: each time step then has up to Digits-1 more
+    // codes that work, so a guess is that many times more likely to get in. Only turn
+    // it on together with a Lockout; strict mode refuses it. An exact match anywhere in
+    // the window is always preferred.
+    ToleranceTransposition bool
+
+    // Replay, if set, makes each code usable once per User, see ReplayStore,
+    // Lockout locks User out after too many wrong codes, Drift follows User's clock
+    // drift, see DriftStore, and Audit is sent an event for every validation
+    Replay      ReplayStore
+    Lockout     *LockoutPolicy
+    Drift       DriftStore
+    Audit       AuditSink
+    User        string
+}
+
+// withDefaults fills in the zero fields of opts
+func (opts ValidateOpts) withDefaults() ValidateOpts {
+    if (opts.Digits == 0) {
+        opts.Digits = DefaultDigits
+    }
+    if (opts.Period == 0) {
+        opts.Period = DefaultPeriod
+    }
+    if (opts.Time.IsZero()) {
+        opts.Time = clockNow(opts.Clock)
+    }
+    if (opts.Skew < 0) {
+        opts.Skew = 0
+    }
+    return opts
+}
+
+/*
+    ValidateTOTP checks a submitted TOTP code
+
+    The code is accepted if it matches the time step for opts.Time or any step up to
+    opts.Skew steps before or after it, which covers clocks that have drifted a little
+    and codes that were typed just as they rolled over. With opts.Drift set the same
+    window around the user's recorded drift is accepted too. It returns nil on a match,
+    ErrCodeMismatch when nothing matched, or the error from generating the codes.
+*/
+func ValidateTOTP(key []byte, code string, opts ValidateOpts) error {
+    var _, err = ValidateTOTPStep(key, code, opts)
+    return err
+}
+
+/*
+    ValidateTOTPStep is ValidateTOTP that also returns the time step the code matched
+
+    RFC 6238 section 5.2 says a code must not be accepted twice. Set opts.Replay to have
+    that enforced, or remember the step of each successful validation yourself and
+    refuse any later code whose step isn't after it. With opts.Lockout set the check
+    runs under LockoutPolicy.Guard.
+*/
+func ValidateTOTPStep(key []byte, code string, opts ValidateOpts) (int64, error) {
+    var result, err = VerifyTOTP(key, code, opts)
+    return result.Step, err
+}
+
+/*
+    Match says how a code matched
+
+    Offset is how many time steps (or HOTP counters) from the expected one the match
+    was: negative for a TOTP code from the past side of the window, positive for one
+    from the future side or an HOTP token that had been pressed ahead. Feed it to
+    drift tracking or an audit log. Step and Expiry are TOTP only, Counter HOTP only.
+*/
+type Match struct {
+    Step    int64       // the time step the code matched
+    Counter uint64      // the counter the code matched
+    Offset  int64
+    Expiry  time.Time   // when the code's time step ended or ends
+}
+
+// Past reports whether the code matched a step before the current one
+func (m Match) Past() bool {
+    return m.Offset < 0
+}
+
+// Future reports whether the code matched a step or counter after the expected one
+func (m Match) Future() bool {
+    return m.Offset > 0
+}
+
+/*
+    VerifyTOTP is ValidateTOTP returning how the code matched, see Match
+
+    On failure the result is the zero Match.
+*/
+func VerifyTOTP(key []byte, code string, opts ValidateOpts) (Match, error) {
+    var start time.Time = time.Now()
+    opts = opts.withDefaults()
+    var matched int64
+    var err error = opts.Lockout.Guard(opts.User, func() error {
+        var err error
+        matched, err = validateTOTPStep(key, code, opts)
+        return err
+    })
+    observeVerify(opts.User, start, err)
+    var result Match
+    if (err == nil) {
+        result = Match{
+            Step:   matched,
+            Offset: matched - timeStepFrom(opts.Time, opts.T0, opts.Period),
+            Expiry: stepStart(matched + 1, opts.T0, opts.Period),
+        }
+    }
+    if (opts.Audit != nil) {
+        var fp string = fingerprint(TypeTOTP, opts.Algorithm, opts.Digits, opts.Period, opts.T0, opts.Truncation, key)
+        audit(opts.Audit, clockNow(opts.Clock), opts.User, TypeTOTP, opts.Algorithm, fp, result.Offset, err)
+    }
+    if (err != nil) {
+        return Match{}, err
+    }
+    return result, nil
+}
+
+// validateTOTPStep is ValidateTOTPStep without the lockout, observer and audit
+func validateTOTPStep(key []byte, code string, opts ValidateOpts) (int64, error) {
+    opts = opts.withDefaults()
+    if (opts.Period < time.Second) {
+        return 0, ErrInvalidPeriod
+    }
+    if (opts.ToleranceTransposition) {
+        if err := refuseStrict("ToleranceTransposition accepts codes that don't match"); err != nil {
+            return 0, err
+        }
+    }
+    if (opts.Checksum) {
+        var ok bool
+        if code, ok = stripChecksum(code, opts.Normalization); !ok {
+            return 0, ErrCodeMismatch
+        }
+    }
+
+    var step int64 = timeStepFrom(opts.Time, opts.T0, opts.Period)
+    var skew int64 = int64(opts.Skew)
+    var drift int64
+    if (opts.Drift != nil) {
+        var err error
+        if drift, err = opts.Drift.Drift(opts.User); err != nil {
+            return 0, err
+        }
+        drift = clampDrift(drift)
+    }
+
+    // the window around now and the one around the user's drift, which may overlap
+    var inWindow = func(offset int64) bool {
+        return (offset >= -skew && offset <= skew) || (offset >= drift - skew && offset <= drift + skew)
+    }
+    var matched, swapped int64
+    var match, swap bool = false, false
+    for offset := min(-skew, drift - skew); offset <= max(skew, drift + skew); offset++ {
+        if (!inWindow(offset)) {
+            continue
+        }
+        var expected, raw, err = generateHOTPRaw(key, uint64(step + offset), opts.Digits, opts.Algorithm, opts.Truncation)
+        if (err != nil) {
+            return 0, err
+        }
+        if (encodedMatches(opts.Encoder, expected, raw, code, opts.Normalization) && !match) {
+            matched, match = step + offset, true
+        }
+        if (opts.ToleranceTransposition && isDecimal(opts.Encoder) && transposedMatches(expected, code, opts.Normalization) && !swap) {
+            swapped, swap = step + offset, true
+        }
+    }
+    if (!match && swap) {
+        matched, match = swapped, true
+    }
+    if (!match) {
+        return 0, ErrCodeMismatch
+    }
+    if (opts.Replay != nil) {
+        if err := opts.Replay.MarkUsed(opts.User, matched); err != nil {
+            return 0, err
+        }
+    }
+    if (opts.Drift != nil && clampDrift(matched - step) != drift) {
+        if err := opts.Drift.SetDrift(opts.User, clampDrift(matched - step)); err != nil {
+            return 0, err
+        }
+    }
+    return matched, nil
+}
+
+/*
+    ValidateHOTP checks a submitted HOTP code with a look-ahead window
+
+    Every press of an HOTP token's button moves its counter on, whether or not the code
+    gets used, so the token is usually ahead of the server. The code is tried against
+    counter through counter+window and the counter it matched is returned; the caller
+    should store matched+1 as the next counter so the same code can't be used again.
+    A code matching nothing in the window fails with ErrCodeMismatch.
+*/
+func ValidateHOTP(key []byte, code string, counter uint64, window int) (uint64, error) {
+    var start time.Time = time.Now()
+    var matched, err = validateHOTP(key, code, counter, window, DefaultDigits, SHA1, Truncation{}, nil, Lenient)
+    observeVerify("", start, err)
+    return matched, err
+}
+
+/*
+    ValidateHOTPOrdered is ValidateHOTP that also recognises codes arriving out of order
+
+    A proxy can reorder requests, so with codes for C+1 and C+2 both in flight the one
+    for C+2 may be accepted first; the counter moves past C+1 and its code no longer
+    matches. ValidateHOTP can only call that a wrong code. This also tries the behind
+    counters before counter and fails with an error matching ErrOutOfOrder when the
+    code is for one of them, so the caller can tell a reordered (or replayed) code
+    from a guess. Only a match at counter or later is ever accepted.
+*/
+func ValidateHOTPOrdered(key []byte, code string, counter uint64, window int, behind int) (uint64, error) {
+    var start time.Time = time.Now()
+    var matched, err = validateHOTPOrdered(key, code, counter, window, behind, DefaultDigits, SHA1, Truncation{}, nil, Lenient)
+    observeVerify("", start, err)
+    return matched, err
+}
+
+// validateHOTPOrdered is ValidateHOTPOrdered for any code length, algorithm, encoding and normalization
+func validateHOTPOrdered(key []byte, code string, counter uint64, window int, behind int, digits int, algo Algorithm, trunc Truncation, enc Encoder, policy NormalizationPolicy) (uint64, error) {
+    var matched, err = validateHOTP(key, code, counter, window, digits, algo, trunc, enc, policy)
+    if (behind <= 0 || (err != nil && !errors.Is(err, ErrCodeMismatch))) {
+        return matched, err
+    }
+    // the counters behind are checked even after a match, as the window is
+    var first uint64 = counter - min(counter, uint64(behind))
+    var late, lateErr = validateHOTP(key, code, first, int(counter - first) - 1, digits, algo, trunc, enc, policy)
+    if (err == nil) {
+        return matched, nil
+    }
+    if (lateErr == nil) {
+        return 0, fmt.Errorf("%w: counter %d is before %d", ErrOutOfOrder, late, counter)
+    }
+    return 0, err
+}
+
+// validateHOTP is ValidateHOTP for any code length, algorithm, encoding and normalization
+func validateHOTP(key []byte, code string, counter uint64, window int, digits int, algo Algorithm, trunc Truncation, enc Encoder, policy NormalizationPolicy) (uint64, error) {
+    var matched uint64
+    var ok bool
+    // the whole window is always checked so the timing doesn't show where the match was
+    for i := 0; i <= window; i++ {
+        var c uint64 = counter + uint64(i)
+        if (c < counter) {
+            // ran past the end of the counter space
+            break
+        }
+        var expected, raw, err = generateHOTPRaw(key, c, digits, algo, trunc)
+        if (err != nil) {
+            return 0, err
+        }
+        if (encodedMatches(enc, expected, raw, code, policy) && !ok) {
+            matched, ok = c, true
+        }
+    }
+    if (!ok) {
+        return 0, ErrCodeMismatch
+    }
+    return matched, nil
+}
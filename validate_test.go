@@ -0,0 +1,125 @@
+package otp
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+// rfc4226Codes are the RFC 4226 appendix D codes for counters 0 to 9
+var rfc4226Codes []string = []string{
+    "755224", "287082", "359152", "969429", "338314",
+    "254676", "287922", "162583", "399871", "520489",
+}
+
+func TestValidateHOTPOrdered(t *testing.T) {
+    var tests = []struct {
+        name    string
+        code    string
+        counter uint64
+        behind  int
+        matched uint64
+        err     error
+    }{
+        {"in the window", rfc4226Codes[5], 3, 2, 5, nil},
+        {"at the counter", rfc4226Codes[3], 3, 2, 3, nil},
+        {"one behind", rfc4226Codes[2], 3, 2, 0, ErrOutOfOrder},
+        {"as far behind as checked", rfc4226Codes[1], 3, 2, 0, ErrOutOfOrder},
+        {"further behind than checked", rfc4226Codes[0], 3, 2, 0, ErrCodeMismatch},
+        {"behind without the check", rfc4226Codes[2], 3, 0, 0, ErrCodeMismatch},
+        {"behind counter 0", rfc4226Codes[9], 0, 5, 0, ErrCodeMismatch},
+        {"wrong code", "000000", 3, 2, 0, ErrCodeMismatch},
+    }
+    for _, tt := range(tests) {
+        t.Run(tt.name, func(t *testing.T) {
+            var matched, err = ValidateHOTPOrdered(RFC4226Secret, tt.code, tt.counter, 3, tt.behind)
+            if (!errors.Is(err, tt.err)) {
+                t.Fatalf("error %v, want %v", err, tt.err)
+            }
+            if (matched != tt.matched) {
+                t.Errorf("matched %d, want %d", matched, tt.matched)
+            }
+            if (tt.err == ErrOutOfOrder && errors.Is(err, ErrCodeMismatch)) {
+                t.Errorf("out of order code also reported as a mismatch: %v", err)
+            }
+        })
+    }
+}
+
+func TestKeyOutOfOrder(t *testing.T) {
+    const c uint64 = 3
+    var k, err = NewKey(RFC4226Secret, WithHOTP(c), WithSkew(5), WithOutOfOrder(5))
+    if (err != nil) {
+        t.Fatal(err)
+    }
+
+    // C+2 arrives first and is accepted, then C+1 turns up late
+    if err = k.Validate(rfc4226Codes[c + 2]); err != nil {
+        t.Fatalf("code for C+2: %v", err)
+    }
+    if (k.Counter != c + 3) {
+        t.Fatalf("counter %d after C+2, want %d", k.Counter, c + 3)
+    }
+    if err = k.Validate(rfc4226Codes[c + 1]); !errors.Is(err, ErrOutOfOrder) {
+        t.Fatalf("code for C+1 after C+2: %v, want ErrOutOfOrder", err)
+    }
+    if (k.Counter != c + 3) {
+        t.Errorf("counter moved to %d on an out of order code", k.Counter)
+    }
+}
+
+func TestTransposedMatches(t *testing.T) {
+    var expected Code = Code{Value: 123456, Digits: 6}
+    var tests = []struct {
+        code    string
+        match   bool
+    }{
+        {"124356", true},
+        {"213456", true},
+        {"123465", true},
+        {"123 465", true},
+        {"123456", false},
+        {"124365", false},
+        {"132546", false},
+        {"143256", false},
+        {"12345", false},
+        {"1234567", false},
+    }
+    for _, tt := range(tests) {
+        if got := transposedMatches(expected, tt.code, Lenient); got != tt.match {
+            t.Errorf("%s for 123456: %v, want %v", tt.code, got, tt.match)
+        }
+    }
+    if (transposedMatches(Code{Value: 112345, Digits: 6}, "112345", Lenient)) {
+        t.Error("swapping two equal digits counted as a transposition")
+    }
+}
+
+func TestValidateTOTPTransposition(t *testing.T) {
+    // "287082" at 59, with the 7 and 0 swapped
+    var opts ValidateOpts = ValidateOpts{Time: time.Unix(59, 0)}
+    if err := ValidateTOTP(RFC4226Secret, "280782", opts); !errors.Is(err, ErrCodeMismatch) {
+        t.Errorf("transposed code without the option: %v, want ErrCodeMismatch", err)
+    }
+    opts.ToleranceTransposition = true
+    if err := ValidateTOTP(RFC4226Secret, "280782", opts); err != nil {
+        t.Errorf("transposed code with the option: %v", err)
+    }
+    if err := ValidateTOTP(RFC4226Secret, "287082", opts); err != nil {
+        t.Errorf("exact code with the option: %v", err)
+    }
+    if err := ValidateTOTP(RFC4226Secret, "827082", opts); err != nil {
+        t.Errorf("first two digits swapped: %v", err)
+    }
+    if err := ValidateTOTP(RFC4226Secret, "782082", opts); !errors.Is(err, ErrCodeMismatch) {
+        t.Errorf("digits that aren't neighbours swapped: %v, want ErrCodeMismatch", err)
+    }
+
+    var k, err = NewKey(RFC4226Secret, WithTranspositionTolerance())
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    if err = k.ValidateAt("280782", time.Unix(59, 0)); err != nil {
+        t.Errorf("transposed code with WithTranspositionTolerance: %v", err)
+    }
+}
@@ -0,0 +1,314 @@
+/*
+    Package vault is an encrypted file of named OTP keys
+
+    A vault is a JSON file:
+
+        {
+            "version": 1,
+            "kdf": {"name": "scrypt", "salt": ..., "n": 32768, "r": 8, "p": 1},
+            "nonce": ...,
+            "data": ...
+        }
+
+    data is a JSON object of name -> otpauth:// URI sealed with AES-256-GCM under a key
+    derived from the passphrase; binary fields are base64. New vaults use scrypt, and
+    "pbkdf2-sha256" (with "iterations" instead of n, r and p) is read as well, which is
+    what the first account stores written by cmd/otp used. Every change is saved with
+    a fresh nonce by writing a new file and renaming it over the old one, so a crash
+    never leaves half a vault.
+
+    A Vault is an otp.KeyStore and is safe for concurrent use within one process.
+*/
+package vault
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/pbkdf2"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "sync"
+
+    otp "github.com/adam-good/OTP"
+    "github.com/adam-good/OTP/internal/scrypt"
+)
+
+// Version is the vault format version this package writes
+const Version int = 1
+
+// KDF names
+const (
+    KDFScrypt       string = "scrypt"
+    KDFPBKDF2SHA256 string = "pbkdf2-sha256"
+)
+
+// scrypt parameters for new vaults, the same as Aegis uses
+const (
+    scryptN int = 1 << 15
+    scryptR int = 8
+    scryptP int = 1
+)
+
+// Errors returned by the package, for use with errors.Is
+var (
+    // the passphrase doesn't open the vault, or the file has been tampered with
+    ErrWrongPassphrase  = errors.New("vault: wrong passphrase or corrupted vault")
+    // the file is a vault of a version or KDF this package doesn't know
+    ErrUnsupported      = errors.New("vault: unsupported vault format")
+    // Create found a file already there
+    ErrExists           = errors.New("vault: already exists")
+)
+
+// KDF describes how the vault key is derived from the passphrase
+type KDF struct {
+    Name        string  `json:"name"`
+    Salt        []byte  `json:"salt"`
+    N           int     `json:"n,omitempty"`
+    R           int     `json:"r,omitempty"`
+    P           int     `json:"p,omitempty"`
+    Iterations  int     `json:"iterations,omitempty"`
+}
+
+// derive returns the 32 byte vault key for passphrase
+func (kdf KDF) derive(passphrase string) ([]byte, error) {
+    switch kdf.Name {
+    case KDFScrypt:
+        return scrypt.Key([]byte(passphrase), kdf.Salt, kdf.N, kdf.R, kdf.P, 32)
+    case KDFPBKDF2SHA256:
+        return pbkdf2.Key(sha256.New, passphrase, kdf.Salt, kdf.Iterations, 32)
+    default:
+        return nil, fmt.Errorf("%w: key derivation %q", ErrUnsupported, kdf.Name)
+    }
+}
+
+// file is the on-disk form
+type file struct {
+    Version int     `json:"version"`
+    KDF     KDF     `json:"kdf"`
+    Nonce   []byte  `json:"nonce"`
+    Data    []byte  `json:"data"`
+}
+
+// Vault is an open vault file
+type Vault struct {
+    mu      sync.Mutex
+    path    string
+    kdf     KDF
+    aead    cipher.AEAD
+    entries map[string]string   // name -> otpauth:// URI
+}
+
+// newAEAD derives the vault key and sets up AES-256-GCM with it
+func newAEAD(kdf KDF, passphrase string) (cipher.AEAD, error) {
+    var key, err = kdf.derive(passphrase)
+    if (err != nil) {
+        return nil, err
+    }
+    var block cipher.Block
+    if block, err = aes.NewCipher(key); err != nil {
+        return nil, err
+    }
+    return cipher.NewGCM(block)
+}
+
+/*
+    Create makes a new, empty vault at path protected by passphrase
+
+    It fails with ErrExists if there's already a file at path.
+*/
+func Create(path string, passphrase string) (*Vault, error) {
+    if _, err := os.Stat(path); err == nil {
+        return nil, fmt.Errorf("%w: %s", ErrExists, path)
+    }
+
+    var kdf KDF = KDF{Name: KDFScrypt, Salt: make([]byte, 16), N: scryptN, R: scryptR, P: scryptP}
+    if _, err := rand.Read(kdf.Salt); err != nil {
+        return nil, err
+    }
+    var aead, err = newAEAD(kdf, passphrase)
+    if (err != nil) {
+        return nil, err
+    }
+
+    var v *Vault = &Vault{path: path, kdf: kdf, aead: aead, entries: map[string]string{}}
+    if err = v.save(); err != nil {
+        return nil, err
+    }
+    return v, nil
+}
+
+// Open reads the vault at path, failing with ErrWrongPassphrase if passphrase doesn't open it
+func Open(path string, passphrase string) (*Vault, error) {
+    var data, err = os.ReadFile(path)
+    if (err != nil) {
+        return nil, err
+    }
+    var f file
+    if err = json.Unmarshal(data, &f); err != nil {
+        return nil, fmt.Errorf("%w: %s: %v", ErrUnsupported, path, err)
+    }
+    if (f.Version != Version) {
+        return nil, fmt.Errorf("%w: version %d", ErrUnsupported, f.Version)
+    }
+
+    var aead cipher.AEAD
+    if aead, err = newAEAD(f.KDF, passphrase); err != nil {
+        return nil, err
+    }
+    var plain []byte
+    if plain, err = aead.Open(nil, f.Nonce, f.Data, nil); err != nil {
+        return nil, ErrWrongPassphrase
+    }
+
+    var v *Vault = &Vault{path: path, kdf: f.KDF, aead: aead, entries: map[string]string{}}
+    if err = json.Unmarshal(plain, &v.entries); err != nil {
+        return nil, fmt.Errorf("%w: %s: %v", ErrUnsupported, path, err)
+    }
+    return v, nil
+}
+
+// OpenOrCreate opens the vault at path, creating it first if there's nothing there
+func OpenOrCreate(path string, passphrase string) (*Vault, error) {
+    var v, err = Open(path, passphrase)
+    if (errors.Is(err, os.ErrNotExist)) {
+        return Create(path, passphrase)
+    }
+    return v, err
+}
+
+// save seals the entries with a fresh nonce and replaces the file; the caller holds mu
+func (v *Vault) save() error {
+    var plain, err = json.Marshal(v.entries)
+    if (err != nil) {
+        return err
+    }
+    var f file = file{Version: Version, KDF: v.kdf, Nonce: make([]byte, v.aead.NonceSize())}
+    if _, err = rand.Read(f.Nonce); err != nil {
+        return err
+    }
+    f.Data = v.aead.Seal(nil, f.Nonce, plain, nil)
+
+    var data []byte
+    if data, err = json.MarshalIndent(f, "", "    "); err != nil {
+        return err
+    }
+    if err = os.MkdirAll(filepath.Dir(v.path), 0700); err != nil {
+        return err
+    }
+    var tmp string = v.path + ".tmp"
+    if err = os.WriteFile(tmp, data, 0600); err != nil {
+        return err
+    }
+    return os.Rename(tmp, v.path)
+}
+
+// Get returns the key stored under name, or otp.ErrKeyNotFound
+func (v *Vault) Get(name string) (*otp.Key, error) {
+    v.mu.Lock()
+    defer v.mu.Unlock()
+
+    var uri, ok = v.entries[name]
+    if (!ok) {
+        return nil, fmt.Errorf("%w: %s", otp.ErrKeyNotFound, name)
+    }
+    return otp.ParseURI(uri)
+}
+
+// Put stores k under name, replacing anything already there, and saves the vault
+func (v *Vault) Put(name string, k *otp.Key) error {
+    v.mu.Lock()
+    defer v.mu.Unlock()
+    return v.put(name, k)
+}
+
+// Add is Put that fails with ErrExists instead of replacing an existing key
+func (v *Vault) Add(name string, k *otp.Key) error {
+    v.mu.Lock()
+    defer v.mu.Unlock()
+
+    if _, exists := v.entries[name]; exists {
+        return fmt.Errorf("%w: %s", ErrExists, name)
+    }
+    return v.put(name, k)
+}
+
+// put is Put with mu held
+func (v *Vault) put(name string, k *otp.Key) error {
+    var uri, err = k.URI()
+    if (err != nil) {
+        return err
+    }
+    var old, had = v.entries[name]
+    v.entries[name] = uri
+    if err := v.save(); err != nil {
+        // keep memory in step with the file
+        if (had) {
+            v.entries[name] = old
+        } else {
+            delete(v.entries, name)
+        }
+        return err
+    }
+    return nil
+}
+
+// Update calls fn on the key stored under name and saves what it leaves, see otp.KeyUpdater
+func (v *Vault) Update(name string, fn func(k *otp.Key) error) error {
+    v.mu.Lock()
+    defer v.mu.Unlock()
+
+    var uri, ok = v.entries[name]
+    if (!ok) {
+        return fmt.Errorf("%w: %s", otp.ErrKeyNotFound, name)
+    }
+    var k, err = otp.ParseURI(uri)
+    if (err != nil) {
+        return err
+    }
+    if err = fn(k); err != nil {
+        return err
+    }
+    return v.put(name, k)
+}
+
+// Remove deletes the key stored under name and saves the vault, or fails with otp.ErrKeyNotFound
+func (v *Vault) Remove(name string) error {
+    v.mu.Lock()
+    defer v.mu.Unlock()
+
+    var old, ok = v.entries[name]
+    if (!ok) {
+        return fmt.Errorf("%w: %s", otp.ErrKeyNotFound, name)
+    }
+    delete(v.entries, name)
+    if err := v.save(); err != nil {
+        v.entries[name] = old
+        return err
+    }
+    return nil
+}
+
+// Names lists the names in the vault in sorted order
+func (v *Vault) Names() ([]string, error) {
+    v.mu.Lock()
+    defer v.mu.Unlock()
+
+    var names []string = make([]string, 0, len(v.entries))
+    for name := range(v.entries) {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names, nil
+}
+
+// the vault is a KeyStore and a KeyUpdater
+var (
+    _ otp.KeyStore      = (*Vault)(nil)
+    _ otp.KeyUpdater    = (*Vault)(nil)
+)
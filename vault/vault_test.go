@@ -0,0 +1,103 @@
+package vault
+
+import (
+    "errors"
+    "path/filepath"
+    "testing"
+    "time"
+
+    otp "github.com/adam-good/OTP"
+)
+
+func TestVaultKeepsKeySettings(t *testing.T) {
+    var path string = filepath.Join(t.TempDir(), "vault.json")
+    var v, err = Create(path, "correct horse")
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    var k *otp.Key
+    if k, err = otp.NewKey(otp.RFC4226Secret, otp.WithT0(time.Unix(1_000_000_000, 0)), otp.WithSkew(2), otp.WithChecksum(), otp.WithTruncationOffset(3)); err != nil {
+        t.Fatal(err)
+    }
+    if err = v.Put("key", k); err != nil {
+        t.Fatal(err)
+    }
+
+    // through a fresh Open, so what's checked is what was written to the file
+    if v, err = Open(path, "correct horse"); err != nil {
+        t.Fatal(err)
+    }
+    var got *otp.Key
+    if got, err = v.Get("key"); err != nil {
+        t.Fatal(err)
+    }
+    if (!got.T0.Equal(k.T0) || got.Skew != k.Skew) {
+        t.Errorf("read back T0 %v skew %d, want %v and %d", got.T0, got.Skew, k.T0, k.Skew)
+    }
+    if (!got.Checksum) {
+        t.Error("checksum digit lost")
+    }
+    if (got.Truncation != k.Truncation) {
+        t.Errorf("read back truncation %+v, want %+v", got.Truncation, k.Truncation)
+    }
+
+    // and the codes still come from the same offset and carry the checksum digit
+    var want, code otp.Code
+    if want, err = k.GenerateAt(time.Unix(1_000_000_059, 0)); err != nil {
+        t.Fatal(err)
+    }
+    if code, err = got.GenerateAt(time.Unix(1_000_000_059, 0)); err != nil {
+        t.Fatal(err)
+    }
+    if (code != want || len(code.String()) != k.Digits + 1) {
+        t.Errorf("read back key generates %s, want %s", code, want)
+    }
+}
+
+func TestVaultRefusesProvidedKey(t *testing.T) {
+    var v, err = Create(filepath.Join(t.TempDir(), "vault.json"), "correct horse")
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    var k *otp.Key
+    if k, err = otp.NewProvidedKey(otp.KeyProviderFunc(func(name string) ([]byte, error) {
+        return otp.RFC4226Secret, nil
+    }), "alice"); err != nil {
+        t.Fatal(err)
+    }
+    if err = v.Put("alice", k); !errors.Is(err, otp.ErrProvidedKey) {
+        t.Fatalf("Put of a provided key: %v, want ErrProvidedKey", err)
+    }
+    if _, err = v.Get("alice"); !errors.Is(err, otp.ErrKeyNotFound) {
+        t.Errorf("Get after a refused Put: %v, want ErrKeyNotFound", err)
+    }
+}
+
+func TestVaultUpdate(t *testing.T) {
+    var v, err = Create(filepath.Join(t.TempDir(), "vault.json"), "correct horse")
+    if (err != nil) {
+        t.Fatal(err)
+    }
+    var k *otp.Key
+    if k, err = otp.NewKey(otp.RFC4226Secret, otp.WithHOTP(0), otp.WithSkew(3)); err != nil {
+        t.Fatal(err)
+    }
+    if err = v.Put("alice", k); err != nil {
+        t.Fatal(err)
+    }
+    if err = v.Update("alice", func(k *otp.Key) error { return k.Validate("359152") }); err != nil {
+        t.Fatal(err)
+    }
+    if k, err = v.Get("alice"); err != nil || k.Counter != 3 {
+        t.Fatalf("counter after Update: %v, %v, want 3", k, err)
+    }
+    if err = v.Update("alice", func(k *otp.Key) error { return k.Validate("359152") }); !errors.Is(err, otp.ErrCodeMismatch) {
+        t.Errorf("Update with a used code: %v, want ErrCodeMismatch", err)
+    }
+    if err = v.Update("bob", func(k *otp.Key) error { return nil }); !errors.Is(err, otp.ErrKeyNotFound) {
+        t.Errorf("Update of a missing key: %v, want ErrKeyNotFound", err)
+    }
+    if _, err = Open(filepath.Join(t.TempDir(), "missing.json"), "correct horse"); err == nil {
+        t.Error("Open of a missing vault succeeded")
+    }
+}
@@ -0,0 +1,247 @@
+package otp
+
+import (
+    "bytes"
+    "encoding/binary"
+    "encoding/hex"
+    "fmt"
+    "time"
+)
+
+/*
+    RFC test vectors
+
+    The published vectors for HOTP (RFC 4226 Appendix D), TOTP (RFC 6238 Appendix B)
+    and OCRA (RFC 6287 Appendix C), for anyone who wants to check this package or
+    their own code against them. SelfTest runs them all.
+*/
+
+// HOTPVector is one row of RFC 4226 Appendix D: the HMAC-SHA1 of the counter, its dynamic truncation and the 6 digit code
+type HOTPVector struct {
+    Counter     uint64
+    HMAC        []byte
+    Truncated   uint32
+    Code        string
+}
+
+// TOTPVector is one row of RFC 6238 Appendix B, 8 digit codes with a 30 second period
+type TOTPVector struct {
+    Time        time.Time
+    Algorithm   Algorithm
+    Code        string
+}
+
+// OCRAVector is one row of RFC 6287 Appendix C, Key being one of RFC6238Secrets
+type OCRAVector struct {
+    Suite       string
+    Key         []byte
+    Input       OCRAInput
+    Code        string
+}
+
+// RFC4226Secret is the secret every HOTPVector uses, the ASCII of "12345678901234567890"
+var RFC4226Secret []byte = []byte("12345678901234567890")
+
+// rfc4226HMAC decodes the hex in the vector tables
+func rfc4226HMAC(s string) []byte {
+    var b, _ = hex.DecodeString(s)
+    return b
+}
+
+// RFC4226Vectors are counters 0 to 9 with RFC4226Secret
+var RFC4226Vectors []HOTPVector = []HOTPVector{
+    {0, rfc4226HMAC("cc93cf18508d94934c64b65d8ba7667fb7cde4b0"), 1284755224, "755224"},
+    {1, rfc4226HMAC("75a48a19d4cbe100644e8ac1397eea747a2d33ab"), 1094287082, "287082"},
+    {2, rfc4226HMAC("0bacb7fa082fef30782211938bc1c5e70416ff44"), 137359152, "359152"},
+    {3, rfc4226HMAC("66c28227d03a2d5529262ff016a1e6ef76557ece"), 1726969429, "969429"},
+    {4, rfc4226HMAC("a904c900a64b35909874b33e61c5938a8e15ed1c"), 1640338314, "338314"},
+    {5, rfc4226HMAC("a37e783d7b7233c083d4f62926c7a25f238d0316"), 868254676, "254676"},
+    {6, rfc4226HMAC("bc9cd28561042c83f219324d3c607256c03272ae"), 1918287922, "287922"},
+    {7, rfc4226HMAC("a4fb960c0bc06e1eabb804e5b397cdc4b45596fa"), 82162583, "162583"},
+    {8, rfc4226HMAC("1b3c89f65e6c9e883012052823443f048b4332db"), 673399871, "399871"},
+    {9, rfc4226HMAC("1637409809a679dc698207310c8c7fc07290d9e5"), 645520489, "520489"},
+}
+
+/*
+    RFC6238Secrets are the secrets the TOTPVectors use, one per algorithm
+
+    The RFC's text says all three are "12345678901234567890", but its reference code
+    and its table use that string repeated to the length of each hash's output, which
+    is what's here.
+*/
+var RFC6238Secrets map[Algorithm][]byte = map[Algorithm][]byte{
+    SHA1:   []byte("12345678901234567890"),
+    SHA256: []byte("12345678901234567890123456789012"),
+    SHA512: []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+}
+
+// RFC6238Vectors are the times in the RFC's table, each with all three algorithms
+var RFC6238Vectors []TOTPVector = []TOTPVector{
+    {time.Unix(59, 0).UTC(), SHA1, "94287082"},
+    {time.Unix(59, 0).UTC(), SHA256, "46119246"},
+    {time.Unix(59, 0).UTC(), SHA512, "90693936"},
+    {time.Unix(1111111109, 0).UTC(), SHA1, "07081804"},
+    {time.Unix(1111111109, 0).UTC(), SHA256, "68084774"},
+    {time.Unix(1111111109, 0).UTC(), SHA512, "25091201"},
+    {time.Unix(1111111111, 0).UTC(), SHA1, "14050471"},
+    {time.Unix(1111111111, 0).UTC(), SHA256, "67062674"},
+    {time.Unix(1111111111, 0).UTC(), SHA512, "99943326"},
+    {time.Unix(1234567890, 0).UTC(), SHA1, "89005924"},
+    {time.Unix(1234567890, 0).UTC(), SHA256, "91819424"},
+    {time.Unix(1234567890, 0).UTC(), SHA512, "93441116"},
+    {time.Unix(2000000000, 0).UTC(), SHA1, "69279037"},
+    {time.Unix(2000000000, 0).UTC(), SHA256, "90698825"},
+    {time.Unix(2000000000, 0).UTC(), SHA512, "38618901"},
+    {time.Unix(20000000000, 0).UTC(), SHA1, "65353130"},
+    {time.Unix(20000000000, 0).UTC(), SHA256, "77737706"},
+    {time.Unix(20000000000, 0).UTC(), SHA512, "47863826"},
+}
+
+// rfc6287Time is the timestamp of RFC 6287's time based vectors, minute 0x132d0b6 since the epoch
+var rfc6287Time time.Time = time.Unix(0x132d0b6 * 60, 0).UTC()
+
+/*
+    RFC6287Vectors are the one-way, mutual and signature challenge-response vectors
+
+    The mutual ones are the server's and then the client's response, each to both
+    challenges run together; every PIN is "1234".
+*/
+var RFC6287Vectors []OCRAVector = []OCRAVector{
+    {"OCRA-1:HOTP-SHA1-6:QN08", RFC6238Secrets[SHA1], OCRAInput{Question: "00000000"}, "237653"},
+    {"OCRA-1:HOTP-SHA1-6:QN08", RFC6238Secrets[SHA1], OCRAInput{Question: "11111111"}, "243178"},
+    {"OCRA-1:HOTP-SHA1-6:QN08", RFC6238Secrets[SHA1], OCRAInput{Question: "22222222"}, "653583"},
+    {"OCRA-1:HOTP-SHA1-6:QN08", RFC6238Secrets[SHA1], OCRAInput{Question: "33333333"}, "740991"},
+    {"OCRA-1:HOTP-SHA1-6:QN08", RFC6238Secrets[SHA1], OCRAInput{Question: "44444444"}, "608993"},
+    {"OCRA-1:HOTP-SHA1-6:QN08", RFC6238Secrets[SHA1], OCRAInput{Question: "55555555"}, "388898"},
+    {"OCRA-1:HOTP-SHA1-6:QN08", RFC6238Secrets[SHA1], OCRAInput{Question: "66666666"}, "816933"},
+    {"OCRA-1:HOTP-SHA1-6:QN08", RFC6238Secrets[SHA1], OCRAInput{Question: "77777777"}, "224598"},
+    {"OCRA-1:HOTP-SHA1-6:QN08", RFC6238Secrets[SHA1], OCRAInput{Question: "88888888"}, "750600"},
+    {"OCRA-1:HOTP-SHA1-6:QN08", RFC6238Secrets[SHA1], OCRAInput{Question: "99999999"}, "294470"},
+    {"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", RFC6238Secrets[SHA256], OCRAInput{Counter: 0, Question: "12345678", PIN: "1234"}, "65347737"},
+    {"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", RFC6238Secrets[SHA256], OCRAInput{Counter: 1, Question: "12345678", PIN: "1234"}, "86775851"},
+    {"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", RFC6238Secrets[SHA256], OCRAInput{Counter: 2, Question: "12345678", PIN: "1234"}, "78192410"},
+    {"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", RFC6238Secrets[SHA256], OCRAInput{Counter: 3, Question: "12345678", PIN: "1234"}, "71565254"},
+    {"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", RFC6238Secrets[SHA256], OCRAInput{Counter: 4, Question: "12345678", PIN: "1234"}, "10104329"},
+    {"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", RFC6238Secrets[SHA256], OCRAInput{Counter: 5, Question: "12345678", PIN: "1234"}, "65983500"},
+    {"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", RFC6238Secrets[SHA256], OCRAInput{Counter: 6, Question: "12345678", PIN: "1234"}, "70069104"},
+    {"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", RFC6238Secrets[SHA256], OCRAInput{Counter: 7, Question: "12345678", PIN: "1234"}, "91771096"},
+    {"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", RFC6238Secrets[SHA256], OCRAInput{Counter: 8, Question: "12345678", PIN: "1234"}, "75011558"},
+    {"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", RFC6238Secrets[SHA256], OCRAInput{Counter: 9, Question: "12345678", PIN: "1234"}, "08522129"},
+    {"OCRA-1:HOTP-SHA256-8:QN08-PSHA1", RFC6238Secrets[SHA256], OCRAInput{Question: "00000000", PIN: "1234"}, "83238735"},
+    {"OCRA-1:HOTP-SHA256-8:QN08-PSHA1", RFC6238Secrets[SHA256], OCRAInput{Question: "11111111", PIN: "1234"}, "01501458"},
+    {"OCRA-1:HOTP-SHA256-8:QN08-PSHA1", RFC6238Secrets[SHA256], OCRAInput{Question: "22222222", PIN: "1234"}, "17957585"},
+    {"OCRA-1:HOTP-SHA256-8:QN08-PSHA1", RFC6238Secrets[SHA256], OCRAInput{Question: "33333333", PIN: "1234"}, "86776967"},
+    {"OCRA-1:HOTP-SHA256-8:QN08-PSHA1", RFC6238Secrets[SHA256], OCRAInput{Question: "44444444", PIN: "1234"}, "86807031"},
+    {"OCRA-1:HOTP-SHA512-8:C-QN08", RFC6238Secrets[SHA512], OCRAInput{Counter: 0, Question: "00000000"}, "07016083"},
+    {"OCRA-1:HOTP-SHA512-8:C-QN08", RFC6238Secrets[SHA512], OCRAInput{Counter: 1, Question: "11111111"}, "63947962"},
+    {"OCRA-1:HOTP-SHA512-8:C-QN08", RFC6238Secrets[SHA512], OCRAInput{Counter: 2, Question: "22222222"}, "70123924"},
+    {"OCRA-1:HOTP-SHA512-8:C-QN08", RFC6238Secrets[SHA512], OCRAInput{Counter: 3, Question: "33333333"}, "25341727"},
+    {"OCRA-1:HOTP-SHA512-8:C-QN08", RFC6238Secrets[SHA512], OCRAInput{Counter: 4, Question: "44444444"}, "33203315"},
+    {"OCRA-1:HOTP-SHA512-8:C-QN08", RFC6238Secrets[SHA512], OCRAInput{Counter: 5, Question: "55555555"}, "34205738"},
+    {"OCRA-1:HOTP-SHA512-8:C-QN08", RFC6238Secrets[SHA512], OCRAInput{Counter: 6, Question: "66666666"}, "44343969"},
+    {"OCRA-1:HOTP-SHA512-8:C-QN08", RFC6238Secrets[SHA512], OCRAInput{Counter: 7, Question: "77777777"}, "51946085"},
+    {"OCRA-1:HOTP-SHA512-8:C-QN08", RFC6238Secrets[SHA512], OCRAInput{Counter: 8, Question: "88888888"}, "20403879"},
+    {"OCRA-1:HOTP-SHA512-8:C-QN08", RFC6238Secrets[SHA512], OCRAInput{Counter: 9, Question: "99999999"}, "31409299"},
+    {"OCRA-1:HOTP-SHA512-8:QN08-T1M", RFC6238Secrets[SHA512], OCRAInput{Question: "00000000", Time: rfc6287Time}, "95209754"},
+    {"OCRA-1:HOTP-SHA512-8:QN08-T1M", RFC6238Secrets[SHA512], OCRAInput{Question: "11111111", Time: rfc6287Time}, "55907591"},
+    {"OCRA-1:HOTP-SHA512-8:QN08-T1M", RFC6238Secrets[SHA512], OCRAInput{Question: "22222222", Time: rfc6287Time}, "22048402"},
+    {"OCRA-1:HOTP-SHA512-8:QN08-T1M", RFC6238Secrets[SHA512], OCRAInput{Question: "33333333", Time: rfc6287Time}, "24218844"},
+    {"OCRA-1:HOTP-SHA512-8:QN08-T1M", RFC6238Secrets[SHA512], OCRAInput{Question: "44444444", Time: rfc6287Time}, "36209546"},
+    {"OCRA-1:HOTP-SHA256-8:QA08", RFC6238Secrets[SHA256], OCRAInput{Question: "CLI22220SRV11110"}, "28247970"},
+    {"OCRA-1:HOTP-SHA256-8:QA08", RFC6238Secrets[SHA256], OCRAInput{Question: "CLI22221SRV11111"}, "01984843"},
+    {"OCRA-1:HOTP-SHA256-8:QA08", RFC6238Secrets[SHA256], OCRAInput{Question: "CLI22222SRV11112"}, "65387857"},
+    {"OCRA-1:HOTP-SHA256-8:QA08", RFC6238Secrets[SHA256], OCRAInput{Question: "CLI22223SRV11113"}, "03351211"},
+    {"OCRA-1:HOTP-SHA256-8:QA08", RFC6238Secrets[SHA256], OCRAInput{Question: "CLI22224SRV11114"}, "83412541"},
+    {"OCRA-1:HOTP-SHA256-8:QA08", RFC6238Secrets[SHA256], OCRAInput{Question: "SRV11110CLI22220"}, "15510767"},
+    {"OCRA-1:HOTP-SHA256-8:QA08", RFC6238Secrets[SHA256], OCRAInput{Question: "SRV11111CLI22221"}, "90175646"},
+    {"OCRA-1:HOTP-SHA256-8:QA08", RFC6238Secrets[SHA256], OCRAInput{Question: "SRV11112CLI22222"}, "33777207"},
+    {"OCRA-1:HOTP-SHA256-8:QA08", RFC6238Secrets[SHA256], OCRAInput{Question: "SRV11113CLI22223"}, "95285278"},
+    {"OCRA-1:HOTP-SHA256-8:QA08", RFC6238Secrets[SHA256], OCRAInput{Question: "SRV11114CLI22224"}, "28934924"},
+    {"OCRA-1:HOTP-SHA512-8:QA08", RFC6238Secrets[SHA512], OCRAInput{Question: "CLI22220SRV11110"}, "79496648"},
+    {"OCRA-1:HOTP-SHA512-8:QA08", RFC6238Secrets[SHA512], OCRAInput{Question: "CLI22221SRV11111"}, "76831980"},
+    {"OCRA-1:HOTP-SHA512-8:QA08", RFC6238Secrets[SHA512], OCRAInput{Question: "CLI22222SRV11112"}, "12250499"},
+    {"OCRA-1:HOTP-SHA512-8:QA08", RFC6238Secrets[SHA512], OCRAInput{Question: "CLI22223SRV11113"}, "90856481"},
+    {"OCRA-1:HOTP-SHA512-8:QA08", RFC6238Secrets[SHA512], OCRAInput{Question: "CLI22224SRV11114"}, "12761449"},
+    {"OCRA-1:HOTP-SHA512-8:QA08-PSHA1", RFC6238Secrets[SHA512], OCRAInput{Question: "SRV11110CLI22220", PIN: "1234"}, "18806276"},
+    {"OCRA-1:HOTP-SHA512-8:QA08-PSHA1", RFC6238Secrets[SHA512], OCRAInput{Question: "SRV11111CLI22221", PIN: "1234"}, "70020315"},
+    {"OCRA-1:HOTP-SHA512-8:QA08-PSHA1", RFC6238Secrets[SHA512], OCRAInput{Question: "SRV11112CLI22222", PIN: "1234"}, "01600026"},
+    {"OCRA-1:HOTP-SHA512-8:QA08-PSHA1", RFC6238Secrets[SHA512], OCRAInput{Question: "SRV11113CLI22223", PIN: "1234"}, "18951020"},
+    {"OCRA-1:HOTP-SHA512-8:QA08-PSHA1", RFC6238Secrets[SHA512], OCRAInput{Question: "SRV11114CLI22224", PIN: "1234"}, "32528969"},
+    {"OCRA-1:HOTP-SHA256-8:QA08", RFC6238Secrets[SHA256], OCRAInput{Question: "SIG10000"}, "53095496"},
+    {"OCRA-1:HOTP-SHA256-8:QA08", RFC6238Secrets[SHA256], OCRAInput{Question: "SIG11000"}, "04110475"},
+    {"OCRA-1:HOTP-SHA256-8:QA08", RFC6238Secrets[SHA256], OCRAInput{Question: "SIG12000"}, "31331128"},
+    {"OCRA-1:HOTP-SHA256-8:QA08", RFC6238Secrets[SHA256], OCRAInput{Question: "SIG13000"}, "76028668"},
+    {"OCRA-1:HOTP-SHA256-8:QA08", RFC6238Secrets[SHA256], OCRAInput{Question: "SIG14000"}, "46554205"},
+    {"OCRA-1:HOTP-SHA512-8:QA10-T1M", RFC6238Secrets[SHA512], OCRAInput{Question: "SIG1000000", Time: rfc6287Time}, "77537423"},
+    {"OCRA-1:HOTP-SHA512-8:QA10-T1M", RFC6238Secrets[SHA512], OCRAInput{Question: "SIG1100000", Time: rfc6287Time}, "31970405"},
+    {"OCRA-1:HOTP-SHA512-8:QA10-T1M", RFC6238Secrets[SHA512], OCRAInput{Question: "SIG1200000", Time: rfc6287Time}, "10235557"},
+    {"OCRA-1:HOTP-SHA512-8:QA10-T1M", RFC6238Secrets[SHA512], OCRAInput{Question: "SIG1300000", Time: rfc6287Time}, "95213541"},
+    {"OCRA-1:HOTP-SHA512-8:QA10-T1M", RFC6238Secrets[SHA512], OCRAInput{Question: "SIG1400000", Time: rfc6287Time}, "65360607"},
+}
+
+/*
+    SelfTest checks this build against every RFC 4226, RFC 6238 and RFC 6287 vector
+
+    It returns nil when all of them come out right, and otherwise an error wrapping
+    ErrSelfTest naming the first that didn't. It takes well under a millisecond, so a
+    server can run it at startup and refuse to start on a broken build or platform:
+
+        if err := otp.SelfTest(); err != nil {
+            log.Fatal(err)
+        }
+
+    The vectors are checked against the HMAC and truncation directly, so it runs in
+    strict mode too, SHA-1 included, and its codes aren't reported to the Observer.
+*/
+func SelfTest() error {
+    for _, v := range(RFC4226Vectors) {
+        var message []byte = make([]byte, 8)
+        binary.BigEndian.PutUint64(message, v.Counter)
+        if mac := HMACHash(SHA1.Hash(), RFC4226Secret, message); !bytes.Equal(mac, v.HMAC) {
+            return fmt.Errorf("%w: RFC 4226 counter %d: HMAC %x, want %x", ErrSelfTest, v.Counter, mac, v.HMAC)
+        }
+        var code, truncated, err = selfTestCode(SHA1, RFC4226Secret, message, 6)
+        if (err != nil || truncated != v.Truncated) {
+            return fmt.Errorf("%w: RFC 4226 counter %d: truncated %d, want %d", ErrSelfTest, v.Counter, truncated, v.Truncated)
+        }
+        if (code.String() != v.Code) {
+            return fmt.Errorf("%w: RFC 4226 counter %d: code %s, want %s", ErrSelfTest, v.Counter, code, v.Code)
+        }
+    }
+    for _, v := range(RFC6238Vectors) {
+        var message []byte = make([]byte, 8)
+        binary.BigEndian.PutUint64(message, uint64(timeStepFrom(v.Time, time.Time{}, DefaultPeriod)))
+        var code, _, err = selfTestCode(v.Algorithm, RFC6238Secrets[v.Algorithm], message, 8)
+        if (err != nil || code.String() != v.Code) {
+            return fmt.Errorf("%w: RFC 6238 %s at %d: code %s, want %s", ErrSelfTest, v.Algorithm, v.Time.Unix(), code, v.Code)
+        }
+    }
+    for _, v := range(RFC6287Vectors) {
+        var suite, err = ParseOCRASuite(v.Suite)
+        var message bytes.Buffer
+        if (err == nil) {
+            err = suite.writeMessage(&message, v.Input)
+        }
+        var code Code
+        if (err == nil) {
+            code, _, err = selfTestCode(suite.Algorithm, v.Key, message.Bytes(), suite.Digits)
+        }
+        if (err != nil || code.String() != v.Code) {
+            return fmt.Errorf("%w: RFC 6287 %s with %q: code %s, want %s (%v)", ErrSelfTest, v.Suite, v.Input.Question, code, v.Code, err)
+        }
+    }
+    return nil
+}
+
+// selfTestCode is hotpMessage without the checks, strict mode's included
+func selfTestCode(algo Algorithm, key []byte, message []byte, digits int) (Code, uint32, error) {
+    var truncated, err = truncateHMAC(HMACHash(algo.Hash(), key, message), Truncation{})
+    if (err != nil) {
+        return Code{}, 0, err
+    }
+    var mod uint64 = 1
+    for i := 0; i < digits; i++ {
+        mod *= 10
+    }
+    return Code{Value: uint32(uint64(truncated) % mod), Digits: digits}, truncated, nil
+}
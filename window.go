@@ -0,0 +1,47 @@
+package otp
+
+import (
+    "time"
+)
+
+/*
+    SkewSample records one successful validation
+
+    Offset is how many time steps away from the server's step the code matched:
+    negative for a client that is behind, positive for one that is ahead.
+*/
+type SkewSample struct {
+    Time    time.Time
+    Offset  int
+}
+
+// the number of extra steps RecommendWindow adds on top of the observed skew
+const windowMargin int = 1
+
+/*
+    RecommendWindow suggests validation windows from a log of observed skew
+
+    past covers the furthest behind any sample was and future the furthest ahead, each
+    with a margin of one extra step so a client drifting a little further still gets in.
+    A side with no skew in that direction gets no margin. With no samples at all both
+    windows are 0.
+*/
+func RecommendWindow(samples []SkewSample) (past, future int) {
+    var maxPast int = 0
+    var maxFuture int = 0
+    for _, s := range(samples) {
+        if (s.Offset < 0 && -s.Offset > maxPast) {
+            maxPast = -s.Offset
+        } else if (s.Offset > maxFuture) {
+            maxFuture = s.Offset
+        }
+    }
+
+    if (maxPast > 0) {
+        past = maxPast + windowMargin
+    }
+    if (maxFuture > 0) {
+        future = maxFuture + windowMargin
+    }
+    return past, future
+}
@@ -0,0 +1,30 @@
+package otp
+
+import (
+    "testing"
+)
+
+func TestRecommendWindow(t *testing.T) {
+    var tests = []struct {
+        name    string
+        offsets []int
+        past    int
+        future  int
+    }{
+        {"no samples", nil, 0, 0},
+        {"all on time", []int{0, 0, 0}, 0, 0},
+        {"behind", []int{-1, 0, -2}, 3, 0},
+        {"ahead", []int{1, 0, 3, 2}, 0, 4},
+        {"both ways", []int{-2, 1, 0, -1, 2}, 3, 3},
+        {"one sample each way", []int{-5, 1}, 6, 2},
+    }
+    for _, tt := range(tests) {
+        var samples []SkewSample
+        for _, offset := range(tt.offsets) {
+            samples = append(samples, SkewSample{Offset: offset})
+        }
+        if past, future := RecommendWindow(samples); past != tt.past || future != tt.future {
+            t.Errorf("%s: %d, %d, want %d, %d", tt.name, past, future, tt.past, tt.future)
+        }
+    }
+}
@@ -0,0 +1,236 @@
+/*
+    Package yubicloud verifies YubiKey OTPs against YubiCloud
+
+    otp.ValidateYubicoOTP checks OTPs locally, but only for keys whose AES key you
+    programmed. Keys straight from Yubico are checked by its validation service
+    instead, with the validation protocol version 2.0: each request carries a nonce and
+    is signed with HMAC-SHA1 under the API key, and each response has to be signed the
+    same way and echo the OTP and nonce back before it's believed. Get a client ID and
+    API key from https://upgrade.yubico.com/getapikey/.
+
+        var c, err = yubicloud.New("12345", "base64 API key")
+        ...
+        if err = c.Verify(ctx, typed); err != nil {
+            // errors.Is(err, otp.ErrCodeReused) for a replay
+        }
+
+    Verify asks every server in Servers at once and takes the first answer that
+    settles it, so one slow or unreachable server doesn't hold up the login.
+*/
+package yubicloud
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/sha1"
+    "crypto/subtle"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "hash"
+    "io"
+    "net/http"
+    "net/url"
+    "sort"
+    "strings"
+    "time"
+
+    otp "github.com/adam-good/OTP"
+)
+
+// DefaultServers is YubiCloud's verify endpoint
+var DefaultServers []string = []string{"https://api.yubico.com/wsapi/2.0/verify"}
+
+// DefaultTimeout is how long Verify waits for the servers
+const DefaultTimeout time.Duration = 10 * time.Second
+
+var (
+    // a response wasn't signed with our API key, or wasn't for our request
+    ErrBadResponse  = errors.New("yubicloud: response doesn't match the request")
+    // the service refused the request, e.g. NO_SUCH_CLIENT or BAD_SIGNATURE
+    ErrRejected     = errors.New("yubicloud: request rejected")
+    // no server gave an answer that settles whether the OTP is good
+    ErrNoAnswer     = errors.New("yubicloud: no server answered")
+)
+
+// Client verifies OTPs for one YubiCloud client ID
+type Client struct {
+    ID          string
+    Key         []byte          // the API key, decoded; nil skips signing, which YubiCloud allows but shouldn't be relied on
+    Servers     []string        // verify URLs, DefaultServers if empty
+    HTTP        *http.Client    // nil means http.DefaultClient
+    Timeout     time.Duration   // 0 means DefaultTimeout
+}
+
+// New returns a Client for id with the base64 API key YubiCloud issued for it
+func New(id string, key string) (*Client, error) {
+    var decoded, err = base64.StdEncoding.DecodeString(key)
+    if (err != nil) {
+        return nil, fmt.Errorf("yubicloud: API key isn't base64: %w", err)
+    }
+    return &Client{ID: id, Key: decoded}, nil
+}
+
+/*
+    PublicID returns the public ID at the front of a YubiKey OTP
+
+    It's what identifies the key, so it's what to store against a user when they
+    register a YubiKey and compare with on every login; Verify only says the OTP is
+    genuine and new, not whose it is.
+*/
+func PublicID(typed string) string {
+    typed = strings.TrimSpace(typed)
+    if (len(typed) < 32) {
+        return ""
+    }
+    return strings.ToLower(typed[:len(typed) - 32])
+}
+
+// sign computes h over params, every pair sorted by key and joined with &
+func (c *Client) sign(params map[string]string) string {
+    var keys []string
+    for k := range(params) {
+        if (k != "h") {
+            keys = append(keys, k)
+        }
+    }
+    sort.Strings(keys)
+    var mac hash.Hash = otp.NewHMAC(sha1.New, c.Key)
+    for i, k := range(keys) {
+        if (i > 0) {
+            io.WriteString(mac, "&")
+        }
+        io.WriteString(mac, k + "=" + params[k])
+    }
+    return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+/*
+    Verify checks typed against the YubiCloud servers
+
+    It returns nil for a valid OTP that hasn't been seen before, an error wrapping
+    otp.ErrInvalidYubicoOTP for one the service says is bad and otp.ErrCodeReused for a
+    replay. ErrRejected means the service wouldn't deal with the request at all,
+    ErrBadResponse that an answer failed its checks and ErrNoAnswer that no server
+    answered in time.
+*/
+func (c *Client) Verify(ctx context.Context, typed string) error {
+    typed = strings.TrimSpace(typed)
+    if (len(typed) < 32 || len(typed) > 48) {
+        return fmt.Errorf("%w: %d characters", otp.ErrInvalidYubicoOTP, len(typed))
+    }
+    var nonce [16]byte
+    if _, err := rand.Read(nonce[:]); err != nil {
+        return err
+    }
+    var params map[string]string = map[string]string{
+        "id":       c.ID,
+        "otp":      typed,
+        "nonce":    hex.EncodeToString(nonce[:]),
+    }
+    var query url.Values = url.Values{}
+    for k, v := range(params) {
+        query.Set(k, v)
+    }
+    if (c.Key != nil) {
+        query.Set("h", c.sign(params))
+    }
+
+    var timeout time.Duration = c.Timeout
+    if (timeout <= 0) {
+        timeout = DefaultTimeout
+    }
+    var cancel context.CancelFunc
+    ctx, cancel = context.WithTimeout(ctx, timeout)
+    defer cancel()
+
+    var servers []string = c.Servers
+    if (len(servers) == 0) {
+        servers = DefaultServers
+    }
+    var answers chan error = make(chan error, len(servers))
+    for _, server := range(servers) {
+        go func() {
+            answers <- c.ask(ctx, server + "?" + query.Encode(), params)
+        }()
+    }
+
+    var last error = ErrNoAnswer
+    for range(servers) {
+        var err error = <-answers
+        switch {
+        case err == nil:
+            return nil
+        case errors.Is(err, errReplayedRequest):
+            // another server has already seen this request, its answer is the one that counts
+        case errors.Is(err, otp.ErrInvalidYubicoOTP), errors.Is(err, otp.ErrCodeReused), errors.Is(err, ErrRejected):
+            return err
+        default:
+            last = err
+        }
+    }
+    return last
+}
+
+// errReplayedRequest is a server saying it has already seen the nonce, from the servers syncing with each other
+var errReplayedRequest error = errors.New("yubicloud: request already seen")
+
+// ask sends the request to one server and checks its answer
+func (c *Client) ask(ctx context.Context, u string, params map[string]string) error {
+    var req, err = http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+    if (err != nil) {
+        return err
+    }
+    var client *http.Client = c.HTTP
+    if (client == nil) {
+        client = http.DefaultClient
+    }
+    var resp *http.Response
+    if resp, err = client.Do(req); err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if (resp.StatusCode != http.StatusOK) {
+        return fmt.Errorf("yubicloud: %s answered %s", req.URL.Host, resp.Status)
+    }
+    var body []byte
+    if body, err = io.ReadAll(io.LimitReader(resp.Body, 4096)); err != nil {
+        return err
+    }
+
+    // key=value lines
+    var answer map[string]string = map[string]string{}
+    for _, line := range(strings.Split(string(body), "\n")) {
+        if k, v, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+            answer[k] = v
+        }
+    }
+    if (c.Key != nil) {
+        var given, derr = base64.StdEncoding.DecodeString(answer["h"])
+        var want, _ = base64.StdEncoding.DecodeString(c.sign(answer))
+        if (derr != nil || subtle.ConstantTimeCompare(given, want) != 1) {
+            return fmt.Errorf("%w: bad signature from %s", ErrBadResponse, req.URL.Host)
+        }
+    }
+
+    var status string = answer["status"]
+    switch status {
+    case "OK":
+    case "BAD_OTP":
+        return fmt.Errorf("%w: %s", otp.ErrInvalidYubicoOTP, status)
+    case "REPLAYED_OTP":
+        return otp.ErrCodeReused
+    case "REPLAYED_REQUEST":
+        return errReplayedRequest
+    case "BACKEND_ERROR", "NOT_ENOUGH_ANSWERS":
+        return fmt.Errorf("yubicloud: %s answered %s", req.URL.Host, status)
+    default:
+        return fmt.Errorf("%w: %s", ErrRejected, status)
+    }
+    // a signed OK for some other OTP or request proves nothing
+    if (answer["otp"] != params["otp"] || answer["nonce"] != params["nonce"]) {
+        return fmt.Errorf("%w: otp or nonce not echoed by %s", ErrBadResponse, req.URL.Host)
+    }
+    return nil
+}
@@ -0,0 +1,169 @@
+package otp
+
+import (
+    "crypto/aes"
+    "crypto/subtle"
+    "encoding/binary"
+    "fmt"
+    "strings"
+)
+
+/*
+    Yubico OTP
+
+    A YubiKey in Yubico OTP mode types ModHex: a public ID that names the key, usually
+    12 characters, followed by 32 characters of one AES-128 block encrypted with a key
+    programmed into it. Inside the block are a private ID, counters that only go up, a
+    timestamp, some randomness and a CRC. ValidateYubicoOTP checks all of that itself,
+    so a service holding the AES keys doesn't need a yubikey-val server.
+*/
+
+// YubicoKey is what's needed to check OTPs from one YubiKey
+type YubicoKey struct {
+    PublicID    string      // ModHex, as the key types it
+    PrivateID   [6]byte
+    AESKey      [16]byte
+}
+
+// YubicoOTP is a decrypted Yubico OTP
+type YubicoOTP struct {
+    PublicID        string
+    PrivateID       [6]byte
+    UseCounter      uint16  // power-ups, or times the session counter wrapped, see Counter
+    Timestamp       uint32  // 24 bits of an 8Hz clock since power-up
+    SessionCounter  uint8   // OTPs since power-up
+    Random          uint16
+}
+
+/*
+    Counter combines the use and session counters into one number that goes up with every OTP
+
+    The top bit of UseCounter isn't part of the count: the key sets it when the OTP was
+    typed with caps lock on, so it's masked off as libyubikey does.
+*/
+func (o YubicoOTP) Counter() uint64 {
+    return uint64(o.UseCounter & 0x7FFF) << 8 | uint64(o.SessionCounter)
+}
+
+// CapsLock reports whether the OTP was typed with caps lock on
+func (o YubicoOTP) CapsLock() bool {
+    return o.UseCounter & 0x8000 != 0
+}
+
+// modhexValues maps ModHex characters back to their values, -1 for anything else
+var modhexValues [256]int8 = func() [256]int8 {
+    var v [256]int8
+    for i := range(v) {
+        v[i] = -1
+    }
+    var upper string = strings.ToUpper(modhexAlphabet)
+    for i := 0; i < len(modhexAlphabet); i++ {
+        v[modhexAlphabet[i]] = int8(i)
+        v[upper[i]] = int8(i)
+    }
+    return v
+}()
+
+// DecodeModHex decodes YubiKey ModHex, the counterpart of ModHexEncoder
+func DecodeModHex(s string) ([]byte, error) {
+    if (len(s) % 2 != 0) {
+        return nil, fmt.Errorf("%w: odd length ModHex", ErrInvalidEncoding)
+    }
+    var out []byte = make([]byte, len(s) / 2)
+    for i := range(out) {
+        var hi, lo int8 = modhexValues[s[2 * i]], modhexValues[s[2 * i + 1]]
+        if (hi < 0 || lo < 0) {
+            return nil, fmt.Errorf("%w: %q isn't ModHex", ErrInvalidEncoding, s[2 * i:2 * i + 2])
+        }
+        out[i] = byte(hi) << 4 | byte(lo)
+    }
+    return out, nil
+}
+
+// yubicoCRC is CRC-16/ISO-13239 as the YubiKey computes it, 0xF0B8 over a token that includes its CRC
+func yubicoCRC(b []byte) uint16 {
+    var crc uint16 = 0xFFFF
+    for _, c := range(b) {
+        crc ^= uint16(c)
+        for i := 0; i < 8; i++ {
+            var carry bool = crc & 1 != 0
+            crc >>= 1
+            if (carry) {
+                crc ^= 0x8408
+            }
+        }
+    }
+    return crc
+}
+
+/*
+    ParseYubicoOTP decodes and decrypts a Yubico OTP with aesKey
+
+    It checks the CRC, which is what tells a wrong key from a right one, but nothing
+    else; see ValidateYubicoOTP. Anything malformed fails with ErrInvalidYubicoOTP.
+*/
+func ParseYubicoOTP(otp string, aesKey []byte) (YubicoOTP, error) {
+    var parsed YubicoOTP
+    otp = strings.TrimSpace(otp)
+    if (len(otp) < 32 || len(otp) > 64) {
+        return parsed, fmt.Errorf("%w: %d characters", ErrInvalidYubicoOTP, len(otp))
+    }
+    parsed.PublicID = strings.ToLower(otp[:len(otp) - 32])
+    if _, err := DecodeModHex(parsed.PublicID); err != nil {
+        return parsed, fmt.Errorf("%w: %w", ErrInvalidYubicoOTP, err)
+    }
+    var token, err = DecodeModHex(otp[len(otp) - 32:])
+    if (err != nil) {
+        return parsed, fmt.Errorf("%w: %w", ErrInvalidYubicoOTP, err)
+    }
+
+    // one block, so ECB is just the block cipher
+    var block, cerr = aes.NewCipher(aesKey)
+    if (cerr != nil) {
+        return parsed, fmt.Errorf("%w: %w", ErrInvalidYubicoOTP, cerr)
+    }
+    var plain [16]byte
+    block.Decrypt(plain[:], token)
+    if (yubicoCRC(plain[:]) != 0xF0B8) {
+        return parsed, fmt.Errorf("%w: CRC mismatch, wrong key?", ErrInvalidYubicoOTP)
+    }
+
+    // uid(6) useCtr(2) tstpl(2) tstph(1) sessionCtr(1) rnd(2) crc(2), little endian
+    copy(parsed.PrivateID[:], plain[0:6])
+    parsed.UseCounter = binary.LittleEndian.Uint16(plain[6:8])
+    parsed.Timestamp = uint32(binary.LittleEndian.Uint16(plain[8:10])) | uint32(plain[10]) << 16
+    parsed.SessionCounter = plain[11]
+    parsed.Random = binary.LittleEndian.Uint16(plain[12:14])
+    return parsed, nil
+}
+
+/*
+    ValidateYubicoOTP checks an OTP typed by key's YubiKey
+
+    The public and private IDs have to be key's, and the counter has to be past the
+    last one store recorded for the public ID, which it then records, so every OTP
+    works once and only in order. An OTP from another key or that won't decrypt fails
+    with ErrInvalidYubicoOTP and one that's been seen, or is older than one that has,
+    with ErrCodeReused.
+*/
+func ValidateYubicoOTP(otp string, key YubicoKey, store CounterStore) (YubicoOTP, error) {
+    var parsed, err = ParseYubicoOTP(otp, key.AESKey[:])
+    if (err != nil) {
+        return YubicoOTP{}, err
+    }
+    if (parsed.PublicID != strings.ToLower(key.PublicID)) {
+        return YubicoOTP{}, fmt.Errorf("%w: public ID %s isn't %s", ErrInvalidYubicoOTP, parsed.PublicID, key.PublicID)
+    }
+    if (subtle.ConstantTimeCompare(parsed.PrivateID[:], key.PrivateID[:]) != 1) {
+        return YubicoOTP{}, fmt.Errorf("%w: wrong private ID", ErrInvalidYubicoOTP)
+    }
+
+    var advanced bool
+    if advanced, err = store.AdvanceCounter("yubico:" + parsed.PublicID, parsed.Counter()); err != nil {
+        return YubicoOTP{}, err
+    }
+    if (!advanced) {
+        return YubicoOTP{}, ErrCodeReused
+    }
+    return parsed, nil
+}
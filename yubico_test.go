@@ -0,0 +1,92 @@
+package otp
+
+import (
+    "crypto/aes"
+    "encoding/binary"
+    "errors"
+    "testing"
+)
+
+// countersSeen is a CounterStore in memory
+type countersSeen map[string]uint64
+
+func (c countersSeen) AdvanceCounter(name string, counter uint64) (bool, error) {
+    if last, ok := c[name]; ok && counter <= last {
+        return false, nil
+    }
+    c[name] = counter
+    return true, nil
+}
+
+// testYubicoKey is the key the OTPs below are made with
+var testYubicoKey YubicoKey = YubicoKey{
+    PublicID:   "cccccccccccb",
+    PrivateID:  [6]byte{0x87, 0x92, 0xeb, 0xfe, 0x26, 0xcc},
+    AESKey:     [16]byte{0xec, 0xde, 0x18, 0xdb, 0xe7, 0x6f, 0xbd, 0x0c, 0x33, 0x33, 0x0f, 0x1c, 0x35, 0x48, 0x71, 0xdb},
+}
+
+// yubicoOTP types an OTP the way key's YubiKey would
+func yubicoOTP(key YubicoKey, useCounter uint16, sessionCounter uint8) string {
+    var plain [16]byte
+    copy(plain[0:6], key.PrivateID[:])
+    binary.LittleEndian.PutUint16(plain[6:8], useCounter)
+    binary.LittleEndian.PutUint16(plain[8:10], 0x1234)
+    plain[10] = 0x56
+    plain[11] = sessionCounter
+    binary.LittleEndian.PutUint16(plain[12:14], 0xbeef)
+    binary.LittleEndian.PutUint16(plain[14:16], ^yubicoCRC(plain[:14]))
+
+    var block, err = aes.NewCipher(key.AESKey[:])
+    if (err != nil) {
+        panic(err)
+    }
+    var token [16]byte
+    block.Encrypt(token[:], plain[:])
+    var otp []byte = []byte(key.PublicID)
+    for _, b := range(token) {
+        otp = append(otp, modhexAlphabet[b >> 4], modhexAlphabet[b & 0xF])
+    }
+    return string(otp)
+}
+
+func TestParseYubicoOTPCapsLock(t *testing.T) {
+    var tests = []struct {
+        name    string
+        use     uint16
+        session uint8
+        counter uint64
+        caps    bool
+    }{
+        {"caps lock off", 0x0013, 0x02, 0x1302, false},
+        {"caps lock on", 0x8013, 0x02, 0x1302, true},
+        {"highest count", 0xFFFF, 0xFF, 0x7FFFFF, true},
+    }
+    for _, tt := range(tests) {
+        t.Run(tt.name, func(t *testing.T) {
+            var parsed, err = ParseYubicoOTP(yubicoOTP(testYubicoKey, tt.use, tt.session), testYubicoKey.AESKey[:])
+            if (err != nil) {
+                t.Fatal(err)
+            }
+            if (parsed.Counter() != tt.counter) {
+                t.Errorf("counter %#x, want %#x", parsed.Counter(), tt.counter)
+            }
+            if (parsed.CapsLock() != tt.caps) {
+                t.Errorf("caps lock %v, want %v", parsed.CapsLock(), tt.caps)
+            }
+        })
+    }
+}
+
+func TestValidateYubicoOTPAfterCapsLock(t *testing.T) {
+    var store countersSeen = countersSeen{}
+    if _, err := ValidateYubicoOTP(yubicoOTP(testYubicoKey, 0x8013, 1), testYubicoKey, store); err != nil {
+        t.Fatalf("OTP typed with caps lock on: %v", err)
+    }
+    // the next OTP without caps lock carries on from it rather than looking far behind
+    if _, err := ValidateYubicoOTP(yubicoOTP(testYubicoKey, 0x0013, 2), testYubicoKey, store); err != nil {
+        t.Fatalf("next OTP with caps lock off: %v", err)
+    }
+    if _, err := ValidateYubicoOTP(yubicoOTP(testYubicoKey, 0x8013, 2), testYubicoKey, store); !errors.Is(err, ErrCodeReused) {
+        t.Errorf("the same count with caps lock on: %v, want ErrCodeReused", err)
+    }
+}